@@ -0,0 +1,141 @@
+// pwd: /app/db/migrate.go
+
+package db
+
+import (
+	"embed"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"api/logger"
+	"api/utils"
+)
+
+// migrationFiles contém os scripts SQL de migração, aplicados em ordem alfabética.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migrate aplica as migrações do banco de dados que ainda não foram executadas.
+//
+// Cada arquivo em db/migrations é aplicado uma única vez, na ordem do nome do arquivo,
+// e registrado na tabela schema_migrations. Os parâmetros {{NOME}} dos scripts são
+// substituídos pelos valores de migrationParams.
+//
+// Retorna:
+//   - error: Erro detalhado em caso de falha ao aplicar alguma migração.
+func Migrate() error {
+	dbConn, err := DbConnection()
+	if err != nil {
+		return err
+	}
+	defer dbConn.Close()
+
+	_, err = dbConn.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version VARCHAR(255) NOT NULL PRIMARY KEY,
+		applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		return fmt.Errorf("falha ao criar tabela de migrações: %w", err)
+	}
+
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return fmt.Errorf("falha ao listar migrações: %w", err)
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		var count int
+		if err := dbConn.QueryRow("SELECT COUNT(*) FROM schema_migrations WHERE version = ?", name).Scan(&count); err != nil {
+			return fmt.Errorf("falha ao verificar migração %s: %w", name, err)
+		}
+		if count > 0 {
+			continue
+		}
+
+		content, err := migrationFiles.ReadFile("migrations/" + name)
+		if err != nil {
+			return fmt.Errorf("falha ao ler migração %s: %w", name, err)
+		}
+
+		logger.Info("Aplicando migração %s", name)
+		for _, statement := range splitStatements(expandParams(string(content))) {
+			if _, err := dbConn.Exec(statement); err != nil {
+				return fmt.Errorf("falha ao aplicar migração %s: %w", name, err)
+			}
+		}
+
+		if _, err := dbConn.Exec("INSERT INTO schema_migrations (version) VALUES (?)", name); err != nil {
+			return fmt.Errorf("falha ao registrar migração %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// migrationParams retorna os parâmetros configuráveis das migrações, lidos das variáveis de ambiente.
+//
+//   - ADMIN_ACCESS_LEVEL: access_level mínimo dos usuários que recebem o papel admin na migração para papéis (padrão: 2).
+func migrationParams() map[string]string {
+	return map[string]string{
+		"ADMIN_ACCESS_LEVEL": strconv.Itoa(utils.GetEnvInt("ADMIN_ACCESS_LEVEL", 2)),
+	}
+}
+
+// expandParams substitui os parâmetros {{NOME}} do script pelos valores de migrationParams.
+//
+// Parâmetros:
+//   - script (string): Conteúdo do arquivo de migração.
+//
+// Retorna:
+//   - string: Script com os parâmetros substituídos.
+func expandParams(script string) string {
+	for name, value := range migrationParams() {
+		script = strings.ReplaceAll(script, "{{"+name+"}}", value)
+	}
+	return script
+}
+
+// splitStatements separa um script SQL em instruções individuais.
+//
+// Uma instrução termina em uma linha finalizada por ";". Linhas de comentário ("--") são ignoradas.
+//
+// Parâmetros:
+//   - script (string): Conteúdo do arquivo de migração.
+//
+// Retorna:
+//   - []string: Instruções SQL, sem o ";" final.
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		current.WriteString(line)
+		current.WriteString("\n")
+
+		if strings.HasSuffix(trimmed, ";") {
+			statement := strings.TrimSuffix(strings.TrimSpace(current.String()), ";")
+			statements = append(statements, statement)
+			current.Reset()
+		}
+	}
+
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+
+	return statements
+}
@@ -0,0 +1,17 @@
+-- Tabelas existentes antes do controle de migrações.
+CREATE TABLE IF NOT EXISTS users (
+    id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    username VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    password VARCHAR(255) NOT NULL,
+    access_level INT NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_users_username (username)
+);
+
+CREATE TABLE IF NOT EXISTS jwt_blacklist (
+    id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    token TEXT NOT NULL,
+    expires_at DATETIME NOT NULL
+);
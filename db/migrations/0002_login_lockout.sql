@@ -0,0 +1,23 @@
+-- Contadores de tentativas de login com falha, por usuário e por IP.
+CREATE TABLE IF NOT EXISTS login_failures (
+    scope VARCHAR(16) NOT NULL,
+    identifier VARCHAR(255) NOT NULL,
+    failures INT NOT NULL DEFAULT 0,
+    last_failure_at DATETIME NOT NULL,
+    locked_until DATETIME NULL,
+    PRIMARY KEY (scope, identifier)
+);
+
+-- Registro de eventos de segurança (bloqueios, desbloqueios, alterações administrativas).
+CREATE TABLE IF NOT EXISTS audit_events (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    event_type VARCHAR(64) NOT NULL,
+    user_id INT NULL,
+    username VARCHAR(255) NOT NULL DEFAULT '',
+    actor_id INT NULL,
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    details TEXT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    KEY idx_audit_events_user (user_id),
+    KEY idx_audit_events_type (event_type)
+);
@@ -0,0 +1,15 @@
+-- Refresh tokens opacos, armazenados apenas como hash (SHA-256).
+-- Tokens rotacionados compartilham o family_id do login que os originou.
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    family_id VARCHAR(64) NOT NULL,
+    token_hash CHAR(64) NOT NULL,
+    expires_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    rotated_at DATETIME NULL,
+    revoked_at DATETIME NULL,
+    UNIQUE KEY uq_refresh_tokens_hash (token_hash),
+    KEY idx_refresh_tokens_family (family_id),
+    KEY idx_refresh_tokens_user (user_id)
+);
@@ -0,0 +1,11 @@
+-- Revogação de tokens pelo identificador único (claim jti), em vez do texto completo do token.
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    jti VARCHAR(64) NOT NULL PRIMARY KEY,
+    user_id INT NULL,
+    expires_at DATETIME NOT NULL,
+    revoked_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    KEY idx_revoked_tokens_expires (expires_at)
+);
+
+-- Tokens antigos não possuem jti e deixam de ser aceitos, portanto a blacklist por texto não é mais necessária.
+DROP TABLE IF EXISTS jwt_blacklist;
@@ -0,0 +1,2 @@
+-- Versão dos tokens do usuário: ao ser incrementada, todos os tokens emitidos anteriormente deixam de valer.
+ALTER TABLE users ADD COLUMN token_version INT NOT NULL DEFAULT 0;
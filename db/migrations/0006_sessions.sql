@@ -0,0 +1,13 @@
+-- Sessões de login (uma por login). O id da sessão é o family_id dos refresh tokens
+-- emitidos para ela e é gravado no claim sid dos tokens de acesso.
+CREATE TABLE IF NOT EXISTS sessions (
+    id VARCHAR(64) NOT NULL PRIMARY KEY,
+    user_id INT NOT NULL,
+    user_agent VARCHAR(512) NOT NULL DEFAULT '',
+    device VARCHAR(128) NOT NULL DEFAULT '',
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_seen_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    revoked_at DATETIME NULL,
+    KEY idx_sessions_user (user_id)
+);
@@ -0,0 +1,51 @@
+-- Controle de acesso por papéis (roles) e permissões, substituindo a coluna users.access_level.
+-- A coluna é mantida: o access_level continua na resposta do login e permite refazer a atribuição dos papéis.
+CREATE TABLE IF NOT EXISTS roles (
+    id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(64) NOT NULL,
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    UNIQUE KEY uq_roles_name (name)
+);
+
+CREATE TABLE IF NOT EXISTS permissions (
+    id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(64) NOT NULL,
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    UNIQUE KEY uq_permissions_name (name)
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role_id INT NOT NULL,
+    permission_id INT NOT NULL,
+    PRIMARY KEY (role_id, permission_id),
+    KEY idx_role_permissions_permission (permission_id)
+);
+
+CREATE TABLE IF NOT EXISTS user_roles (
+    user_id INT NOT NULL,
+    role_id INT NOT NULL,
+    PRIMARY KEY (user_id, role_id),
+    KEY idx_user_roles_role (role_id)
+);
+
+INSERT IGNORE INTO roles (name, description) VALUES
+    ('admin', 'Administração completa de usuários e permissões'),
+    ('user', 'Usuário padrão');
+
+INSERT IGNORE INTO permissions (name, description) VALUES
+    ('users:read', 'Consultar usuários'),
+    ('users:create', 'Cadastrar usuários'),
+    ('users:update', 'Alterar usuários'),
+    ('users:delete', 'Excluir usuários'),
+    ('users:unlock', 'Remover bloqueios de login'),
+    ('users:sessions', 'Consultar e encerrar sessões de outros usuários'),
+    ('roles:read', 'Consultar papéis e permissões'),
+    ('roles:assign', 'Atribuir papéis a usuários');
+
+-- O papel admin recebe todas as permissões
+INSERT IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r CROSS JOIN permissions p WHERE r.name = 'admin';
+
+-- Migração do access_level: a partir de ADMIN_ACCESS_LEVEL (padrão: 2) o usuário vira "admin"; abaixo, "user"
+INSERT IGNORE INTO user_roles (user_id, role_id)
+SELECT u.id, r.id FROM users u JOIN roles r ON r.name = IF(u.access_level >= {{ADMIN_ACCESS_LEVEL}}, 'admin', 'user');
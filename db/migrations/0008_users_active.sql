@@ -0,0 +1,3 @@
+-- Desativação de contas e data da última alteração do cadastro.
+ALTER TABLE users ADD COLUMN active TINYINT(1) NOT NULL DEFAULT 1;
+ALTER TABLE users ADD COLUMN updated_at DATETIME NULL;
@@ -0,0 +1,14 @@
+-- Tokens de uso único enviados por e-mail (troca de e-mail, redefinição de senha, verificação de e-mail).
+-- Apenas o hash (SHA-256) do token é armazenado; data guarda o valor associado (ex: o novo e-mail).
+CREATE TABLE IF NOT EXISTS user_tokens (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    purpose VARCHAR(32) NOT NULL,
+    token_hash CHAR(64) NOT NULL,
+    data VARCHAR(255) NOT NULL DEFAULT '',
+    expires_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    used_at DATETIME NULL,
+    UNIQUE KEY uq_user_tokens_hash (token_hash),
+    KEY idx_user_tokens_user (user_id, purpose)
+);
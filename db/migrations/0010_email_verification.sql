@@ -0,0 +1,4 @@
+-- Verificação de e-mail. Os usuários já cadastrados são considerados verificados.
+ALTER TABLE users ADD COLUMN email_verified_at DATETIME NULL;
+ALTER TABLE users ADD COLUMN email_verification_sent_at DATETIME NULL;
+UPDATE users SET email_verified_at = created_at WHERE email_verified_at IS NULL;
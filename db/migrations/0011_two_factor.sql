@@ -0,0 +1,23 @@
+-- Autenticação em dois fatores (TOTP, RFC 6238) e códigos de recuperação.
+-- totp_secret guarda o segredo criptografado (AES-GCM); com totp_enabled_at nulo, o cadastro ainda não foi confirmado.
+ALTER TABLE users ADD COLUMN totp_secret VARCHAR(255) NULL;
+ALTER TABLE users ADD COLUMN totp_enabled_at DATETIME NULL;
+ALTER TABLE users ADD COLUMN totp_last_step BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    code_hash CHAR(64) NOT NULL,
+    created_at DATETIME NOT NULL,
+    used_at DATETIME NULL,
+    UNIQUE KEY uq_recovery_codes_hash (user_id, code_hash)
+);
+
+-- Papéis cujos usuários são obrigados a usar 2FA
+ALTER TABLE roles ADD COLUMN require_2fa TINYINT(1) NOT NULL DEFAULT 0;
+
+INSERT IGNORE INTO permissions (name, description) VALUES
+    ('roles:update', 'Alterar papéis (ex: exigir 2FA)');
+
+INSERT IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r JOIN permissions p ON p.name = 'roles:update' WHERE r.name = 'admin';
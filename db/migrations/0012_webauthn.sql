@@ -0,0 +1,22 @@
+-- Credenciais WebAuthn (passkeys e chaves de segurança). Um usuário pode ter vários autenticadores.
+-- credential_hash (SHA-256 do ID da credencial) permite a busca e a unicidade sem indexar o ID, que pode ter até 1023 bytes.
+-- flags guarda os bits UP/UV/BE/BS; clone_warning indica um contador de assinaturas que não avançou.
+CREATE TABLE IF NOT EXISTS webauthn_credentials (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    credential_id VARBINARY(1023) NOT NULL,
+    credential_hash CHAR(64) NOT NULL,
+    public_key BLOB NOT NULL,
+    attestation_type VARCHAR(32) NOT NULL DEFAULT '',
+    attestation_format VARCHAR(32) NOT NULL DEFAULT '',
+    transports VARCHAR(255) NOT NULL DEFAULT '',
+    aaguid VARBINARY(16) NULL,
+    flags TINYINT UNSIGNED NOT NULL DEFAULT 0,
+    sign_count INT UNSIGNED NOT NULL DEFAULT 0,
+    clone_warning TINYINT(1) NOT NULL DEFAULT 0,
+    name VARCHAR(100) NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL,
+    last_used_at DATETIME NULL,
+    UNIQUE KEY uq_webauthn_credentials_hash (credential_hash),
+    KEY idx_webauthn_credentials_user (user_id)
+);
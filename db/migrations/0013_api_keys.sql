@@ -0,0 +1,32 @@
+-- Chaves de API, substituindo o token fixo BEARER_PROTECTED_PATHS do módulo finance.
+-- A chave completa é exibida uma única vez; somente o prefixo (visível) e o hash SHA-256 são armazenados.
+-- Chaves pessoais pertencem a um usuário (user_id); chaves de serviço (user_id nulo) são criadas por administradores.
+-- scopes lista, separadas por vírgula, as permissões concedidas à chave.
+CREATE TABLE IF NOT EXISTS api_keys (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NULL,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(16) NOT NULL,
+    secret_hash CHAR(64) NOT NULL,
+    scopes VARCHAR(1000) NOT NULL DEFAULT '',
+    created_by INT NOT NULL,
+    created_at DATETIME NOT NULL,
+    expires_at DATETIME NULL,
+    last_used_at DATETIME NULL,
+    last_used_ip VARCHAR(45) NOT NULL DEFAULT '',
+    revoked_at DATETIME NULL,
+    UNIQUE KEY uq_api_keys_prefix (prefix),
+    KEY idx_api_keys_user (user_id)
+);
+
+INSERT IGNORE INTO permissions (name, description) VALUES
+    ('finance:read', 'Consultar os dados do módulo finance'),
+    ('ppr:calculate', 'Calcular o PPR'),
+    ('api_keys:manage', 'Gerenciar chaves de API de serviço');
+
+INSERT IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r JOIN permissions p ON p.name IN ('finance:read', 'ppr:calculate', 'api_keys:manage') WHERE r.name = 'admin';
+
+-- O /ppr/calculate era público: todo usuário mantém o acesso, e pode emitir chaves pessoais com esse escopo
+INSERT IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r JOIN permissions p ON p.name = 'ppr:calculate' WHERE r.name = 'user';
@@ -0,0 +1,13 @@
+-- Identidades externas (OpenID Connect) vinculadas aos usuários locais.
+-- subject é o claim "sub" do provedor, único por provedor; um usuário tem no máximo uma identidade por provedor.
+CREATE TABLE IF NOT EXISTS user_identities (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    provider VARCHAR(50) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL,
+    last_login_at DATETIME NULL,
+    UNIQUE KEY uq_user_identities_subject (provider, subject),
+    UNIQUE KEY uq_user_identities_user_provider (user_id, provider)
+);
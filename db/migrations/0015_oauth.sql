@@ -0,0 +1,34 @@
+-- Servidor de autorização OAuth2 para as aplicações internas.
+-- secret_hash guarda o SHA-256 do segredo dos clientes confidenciais; clientes públicos (SPA, mobile) não têm segredo.
+-- redirect_uris, scopes e grant_types são listas separadas por espaço. scopes lista as permissões que o cliente pode solicitar.
+-- trusted dispensa a tela de consentimento (aplicações da própria empresa).
+CREATE TABLE IF NOT EXISTS oauth_clients (
+    id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    client_id VARCHAR(64) NOT NULL,
+    secret_hash CHAR(64) NULL,
+    name VARCHAR(100) NOT NULL,
+    redirect_uris TEXT NOT NULL,
+    scopes VARCHAR(1000) NOT NULL DEFAULT '',
+    grant_types VARCHAR(100) NOT NULL,
+    trusted TINYINT(1) NOT NULL DEFAULT 0,
+    created_by INT NOT NULL,
+    created_at DATETIME NOT NULL,
+    revoked_at DATETIME NULL,
+    UNIQUE KEY uq_oauth_clients_client_id (client_id)
+);
+
+-- Consentimentos dados pelos usuários: os escopos já autorizados para cada cliente não são solicitados novamente.
+CREATE TABLE IF NOT EXISTS oauth_consents (
+    user_id INT NOT NULL,
+    client_id VARCHAR(64) NOT NULL,
+    scopes VARCHAR(1000) NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    PRIMARY KEY (user_id, client_id)
+);
+
+INSERT IGNORE INTO permissions (name, description) VALUES
+    ('oauth_clients:manage', 'Gerenciar os clientes OAuth2');
+
+INSERT IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r JOIN permissions p ON p.name = 'oauth_clients:manage' WHERE r.name = 'admin';
@@ -0,0 +1,8 @@
+-- Hashes das senhas anteriores dos usuários, para impedir a reutilização das últimas PASSWORD_HISTORY senhas.
+CREATE TABLE IF NOT EXISTS password_history (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    password_hash VARCHAR(255) NOT NULL,
+    created_at DATETIME NOT NULL,
+    KEY idx_password_history_user (user_id, created_at)
+);
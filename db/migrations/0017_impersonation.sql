@@ -0,0 +1,8 @@
+-- Personificação de usuários pelo suporte (POST /auth/impersonate/:id).
+-- O início da personificação e cada requisição feita com o token são registrados em audit_events
+-- (impersonation_started e impersonated_request), com o usuário em user_id e o administrador em actor_id.
+INSERT IGNORE INTO permissions (name, description) VALUES
+    ('users:impersonate', 'Acessar a API em nome de outro usuário');
+
+INSERT IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r JOIN permissions p ON p.name = 'users:impersonate' WHERE r.name = 'admin';
@@ -0,0 +1,7 @@
+-- Último pedido de redefinição de senha por usuário e por IP, para limitar os envios do link.
+CREATE TABLE IF NOT EXISTS password_reset_requests (
+    scope VARCHAR(16) NOT NULL,
+    identifier VARCHAR(255) NOT NULL,
+    requested_at DATETIME NOT NULL,
+    PRIMARY KEY (scope, identifier)
+);
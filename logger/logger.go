@@ -12,6 +12,8 @@ import (
 	"sync"
 	"time"
 
+	"api/utils/netutil"
+
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 )
@@ -149,7 +151,7 @@ func Panic(message string, args ...interface{}) { GetLogger().logMessage("PANIC"
 
 // LoggerMiddleware cria um middleware para registrar logs de requisições HTTP no Gin.
 //
-// O middleware registra informações como método da requisição, IP do cliente, URI solicitada
+// O middleware registra informações como método da requisição, IP do cliente (resolvido por netutil.ClientIP), URI solicitada
 // e tempo de duração da requisição. Se houver erros no contexto, eles serão registrados.
 //
 // Retorna:
@@ -160,14 +162,8 @@ func LoggerMiddleware() gin.HandlerFunc {
 		c.Next() // Processa a requisição
 		duration := time.Since(start)
 
-		// Obtém o IP do cliente, priorizando cabeçalhos de proxies reversos.
-		clientIP := c.GetHeader("CF-Connecting-IP")
-		if clientIP == "" {
-			clientIP = c.GetHeader("X-Forwarded-For")
-			if clientIP == "" {
-				clientIP = c.ClientIP()
-			}
-		}
+		// Obtém o IP do cliente, considerando cabeçalhos de proxies reversos apenas de proxies confiáveis.
+		clientIP := netutil.ClientIP(c)
 
 		msg := fmt.Sprintf("|%d |%s |%s |%s |%s |", c.Writer.Status(), c.Request.Method, clientIP, c.Request.RequestURI, duration)
 		if len(c.Errors) > 0 {
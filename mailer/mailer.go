@@ -0,0 +1,73 @@
+// pwd: /app/mailer/mailer.go
+
+package mailer
+
+import (
+	"sync"
+
+	"api/logger"
+	"api/utils"
+)
+
+// Message representa um e-mail em texto simples.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender é implementado pelos meios de envio de e-mail (SMTP, log, serviços externos ou dublês em testes).
+type Sender interface {
+	Send(msg Message) error
+}
+
+var (
+	senderMu sync.RWMutex
+	sender   Sender
+)
+
+// SetSender substitui o meio de envio usado por Send. Com nil, volta ao padrão definido pelas variáveis SMTP_*.
+//
+// Parâmetros:
+//   - s (Sender): Meio de envio a ser utilizado.
+func SetSender(s Sender) {
+	senderMu.Lock()
+	defer senderMu.Unlock()
+	sender = s
+}
+
+// Send envia um e-mail pelo meio de envio configurado.
+//
+// Sem um Sender definido por SetSender, usa o SMTPSender configurado pelas variáveis SMTP_*, ou o
+// LogSender quando SMTP_HOST não está definido (ex: em desenvolvimento).
+//
+// Parâmetros:
+//   - msg (Message): Mensagem a enviar.
+//
+// Retorna:
+//   - error: Erro detalhado em caso de falha no envio.
+func Send(msg Message) error {
+	senderMu.RLock()
+	s := sender
+	senderMu.RUnlock()
+
+	if s == nil {
+		if utils.GetEnv("SMTP_HOST") == "" {
+			s = LogSender{}
+		} else {
+			s = NewSMTPSenderFromEnv()
+		}
+	}
+	return s.Send(msg)
+}
+
+// LogSender não envia a mensagem: apenas registra o destinatário e o assunto no log.
+// O corpo só aparece com LOG_LEVEL=DEBUG.
+type LogSender struct{}
+
+// Send registra a mensagem no log.
+func (LogSender) Send(msg Message) error {
+	logger.Warn("E-mail para %s não enviado (sem servidor SMTP configurado): %s", msg.To, msg.Subject)
+	logger.Debug("Conteúdo do e-mail não enviado:\n%s", msg.Body)
+	return nil
+}
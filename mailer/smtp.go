@@ -0,0 +1,136 @@
+// pwd: /app/mailer/smtp.go
+
+package mailer
+
+import (
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"api/logger"
+	"api/utils"
+)
+
+// SMTPSender envia e-mails por um servidor SMTP.
+type SMTPSender struct {
+	Host     string
+	Port     string
+	User     string
+	Password string
+	From     string
+	TLS      bool // TLS implícito (porta 465); sem ele, STARTTLS é negociado pelo net/smtp quando disponível
+}
+
+// NewSMTPSenderFromEnv cria um SMTPSender a partir das variáveis SMTP_HOST, SMTP_PORT, SMTP_USER,
+// SMTP_PASS, SMTP_FROM e SMTP_TLS.
+//
+// Retorna:
+//   - SMTPSender: Meio de envio configurado (porta padrão 587).
+func NewSMTPSenderFromEnv() SMTPSender {
+	port := utils.GetEnv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+	return SMTPSender{
+		Host:     utils.GetEnv("SMTP_HOST"),
+		Port:     port,
+		User:     utils.GetEnv("SMTP_USER"),
+		Password: utils.GetEnv("SMTP_PASS"),
+		From:     utils.GetEnv("SMTP_FROM"),
+		TLS:      utils.GetEnvBool("SMTP_TLS", false),
+	}
+}
+
+// Send envia a mensagem pelo servidor SMTP.
+//
+// Parâmetros:
+//   - msg (Message): Mensagem a enviar.
+//
+// Retorna:
+//   - error: Erro detalhado em caso de falha no envio.
+func (s SMTPSender) Send(msg Message) error {
+	if s.From == "" {
+		return fmt.Errorf("SMTP_FROM é obrigatório para o envio de e-mails")
+	}
+
+	var auth smtp.Auth
+	if s.User != "" {
+		auth = smtp.PlainAuth("", s.User, s.Password, s.Host)
+	}
+
+	content := buildMessage(s.From, msg)
+	address := net.JoinHostPort(s.Host, s.Port)
+
+	var err error
+	if s.TLS {
+		err = sendTLS(address, s.Host, auth, s.From, msg.To, content)
+	} else {
+		err = smtp.SendMail(address, auth, s.From, []string{msg.To}, content)
+	}
+	if err != nil {
+		return fmt.Errorf("falha ao enviar e-mail para %s: %w", msg.To, err)
+	}
+
+	logger.Info("E-mail enviado para %s: %s", msg.To, msg.Subject)
+	return nil
+}
+
+// sendTLS envia a mensagem por uma conexão com TLS implícito.
+func sendTLS(address, host string, auth smtp.Auth, from, to string, content []byte) error {
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 10 * time.Second}, "tcp", address, &tls.Config{ServerName: host})
+	if err != nil {
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if auth != nil {
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(content); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// buildMessage monta os cabeçalhos e o corpo da mensagem (RFC 5322), em UTF-8.
+func buildMessage(from string, msg Message) []byte {
+	// Quebras de linha nos cabeçalhos permitiriam injetar cabeçalhos adicionais
+	header := strings.NewReplacer("\r", "", "\n", "")
+
+	var builder strings.Builder
+	builder.WriteString("From: " + header.Replace(from) + "\r\n")
+	builder.WriteString("To: " + header.Replace(msg.To) + "\r\n")
+	builder.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", msg.Subject) + "\r\n")
+	builder.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	builder.WriteString("MIME-Version: 1.0\r\n")
+	builder.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	builder.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	builder.WriteString("\r\n")
+	builder.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(builder.String())
+}
@@ -0,0 +1,290 @@
+// pwd: /app/server/compression.go
+
+package server
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"api/logger"
+	"api/utils"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+)
+
+// Tipos de conteúdo comprimidos por padrão
+var defaultCompressibleTypes = []string{
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"image/svg+xml",
+	"text/*",
+}
+
+// compressionConfig define quando e como as respostas são comprimidas.
+type compressionConfig struct {
+	minSize   int
+	level     int
+	encodings []string // Codificações habilitadas, em ordem de preferência do servidor
+	types     []string // Tipos MIME comprimíveis (aceita curinga "tipo/*")
+}
+
+// loadCompressionConfig lê a configuração de compressão das variáveis de ambiente.
+func loadCompressionConfig() compressionConfig {
+	config := compressionConfig{
+		minSize:   utils.GetEnvInt("COMPRESSION_MIN_SIZE", 1024),
+		level:     utils.GetEnvInt("COMPRESSION_LEVEL", gzip.DefaultCompression),
+		encodings: []string{"gzip", "deflate"},
+		types:     utils.GetEnvList("COMPRESSION_TYPES"),
+	}
+
+	if utils.GetEnvBool("COMPRESSION_BROTLI", false) {
+		config.encodings = append([]string{"br"}, config.encodings...)
+	}
+	if len(config.types) == 0 {
+		config.types = defaultCompressibleTypes
+	}
+	if config.level < gzip.HuffmanOnly || config.level > gzip.BestCompression {
+		logger.Warn("COMPRESSION_LEVEL inválido: %d. Usando o nível padrão.", config.level)
+		config.level = gzip.DefaultCompression
+	}
+
+	return config
+}
+
+// CompressionMiddleware cria o middleware de compressão de respostas (gzip, deflate e, opcionalmente, brotli).
+//
+// A codificação é negociada pelo cabeçalho Accept-Encoding. Só são comprimidas respostas com tipo de
+// conteúdo listado em COMPRESSION_TYPES e corpo com pelo menos COMPRESSION_MIN_SIZE bytes. Respostas
+// parciais (206), sem corpo (204/304) ou já codificadas são enviadas sem alteração. Um ETag forte
+// é convertido em fraco, pois o corpo transmitido deixa de ser idêntico byte a byte.
+//
+// Retorna:
+//   - gin.HandlerFunc: Middleware de compressão.
+func CompressionMiddleware() gin.HandlerFunc {
+	if !utils.GetEnvBool("COMPRESSION_ENABLED", true) {
+		logger.Info("Compressão de respostas desativada")
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	config := loadCompressionConfig()
+	logger.Debug("Compressão de respostas: codificações=%v tamanho mínimo=%d", config.encodings, config.minSize)
+
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodHead || c.GetHeader("Upgrade") != "" {
+			c.Next()
+			return
+		}
+
+		encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"), config.encodings)
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+		if encoding == "" {
+			c.Next()
+			return
+		}
+
+		writer := &compressWriter{ResponseWriter: c.Writer, config: &config, encoding: encoding}
+		c.Writer = writer
+		defer func() {
+			if err := writer.finish(); err != nil {
+				logger.Error("Erro ao finalizar compressão da resposta: %v", err)
+			}
+			c.Writer = writer.ResponseWriter
+		}()
+
+		c.Next()
+	}
+}
+
+// compressWriter acumula o início do corpo da resposta até ter dados suficientes para decidir
+// se a resposta será comprimida, e então passa a escrever no codificador ou diretamente no cliente.
+type compressWriter struct {
+	gin.ResponseWriter
+	config   *compressionConfig
+	encoding string
+	buffer   []byte
+	decided  bool
+	encoder  io.WriteCloser
+}
+
+// Write acumula ou escreve os dados, conforme a decisão de compressão.
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if !w.decided {
+		w.buffer = append(w.buffer, data...)
+		if len(w.buffer) < w.config.minSize {
+			return len(data), nil
+		}
+		if err := w.decide(); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+
+	if w.encoder != nil {
+		return w.encoder.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// WriteString escreve uma string no corpo da resposta.
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Written informa se algum dado já foi recebido, mesmo que ainda esteja no buffer.
+func (w *compressWriter) Written() bool {
+	return len(w.buffer) > 0 || w.ResponseWriter.Written()
+}
+
+// Flush força a decisão de compressão e envia os dados pendentes ao cliente.
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		_ = w.decide()
+	}
+	if flusher, ok := w.encoder.(interface{ Flush() error }); ok {
+		_ = flusher.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// finish conclui a resposta: decide a compressão de corpos menores que o buffer e fecha o codificador.
+func (w *compressWriter) finish() error {
+	if !w.decided {
+		if len(w.buffer) == 0 {
+			w.decided = true
+			return nil
+		}
+		if err := w.decide(); err != nil {
+			return err
+		}
+	}
+
+	if w.encoder != nil {
+		return w.encoder.Close()
+	}
+	return nil
+}
+
+// decide define se a resposta será comprimida, ajusta os cabeçalhos e escreve o buffer acumulado.
+func (w *compressWriter) decide() error {
+	w.decided = true
+	buffer := w.buffer
+	w.buffer = nil
+
+	if w.shouldCompress(buffer) {
+		header := w.Header()
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		header.Del("Accept-Ranges")
+		if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			header.Set("ETag", "W/"+etag)
+		}
+		w.encoder = newEncoder(w.encoding, w.ResponseWriter, w.config.level)
+		_, err := w.encoder.Write(buffer)
+		return err
+	}
+
+	_, err := w.ResponseWriter.Write(buffer)
+	return err
+}
+
+// shouldCompress aplica as regras de status, cabeçalhos, tamanho e tipo de conteúdo.
+func (w *compressWriter) shouldCompress(body []byte) bool {
+	status := w.Status()
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusPartialContent || status == http.StatusNotModified {
+		return false
+	}
+
+	header := w.Header()
+	if header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" || strings.Contains(header.Get("Cache-Control"), "no-transform") {
+		return false
+	}
+
+	if len(body) < w.config.minSize {
+		return false
+	}
+
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+	return w.config.compressible(contentType)
+}
+
+// compressible verifica se o tipo de conteúdo está na lista de tipos comprimíveis.
+func (c *compressionConfig) compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, allowed := range c.types {
+		if allowed == mediaType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(allowed, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// newEncoder cria o codificador da codificação negociada.
+func newEncoder(encoding string, w io.Writer, level int) io.WriteCloser {
+	switch encoding {
+	case "br":
+		brotliLevel := brotli.DefaultCompression
+		if level >= brotli.BestSpeed && level <= gzip.BestCompression {
+			brotliLevel = level
+		}
+		return brotli.NewWriterLevel(w, brotliLevel)
+	case "deflate":
+		// No HTTP, "deflate" corresponde ao formato zlib (RFC 1950)
+		encoder, _ := zlib.NewWriterLevel(w, level)
+		return encoder
+	default:
+		encoder, _ := gzip.NewWriterLevel(w, level)
+		return encoder
+	}
+}
+
+// negotiateEncoding escolhe a codificação a partir do Accept-Encoding do cliente.
+//
+// Entre as codificações aceitas pelo cliente (q > 0), prevalece a de maior peso; em caso de
+// empate, vale a ordem de preferência do servidor.
+func negotiateEncoding(acceptEncoding string, supported []string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+
+	weights := map[string]float64{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		weight := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(q, 64); err == nil {
+				weight = parsed
+			}
+		}
+		weights[name] = weight
+	}
+
+	best, bestWeight := "", 0.0
+	for _, encoding := range supported {
+		weight, ok := weights[encoding]
+		if !ok {
+			weight, ok = weights["*"]
+		}
+		if ok && weight > bestWeight {
+			best, bestWeight = encoding, weight
+		}
+	}
+	return best
+}
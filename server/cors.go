@@ -0,0 +1,274 @@
+// pwd: /app/server/cors.go
+
+package server
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"api/logger"
+	"api/utils"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// Valores padrão da política de CORS
+var (
+	defaultCORSMethods       = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+	defaultCORSHeaders       = []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With"}
+	defaultCORSExposeHeaders = []string{"Content-Length"}
+)
+
+// CORSPolicy define quais origens podem acessar a API a partir do navegador e com quais métodos e cabeçalhos.
+type CORSPolicy struct {
+	AllowAllOrigins  bool
+	AllowedOrigins   []string // Origens exatas normalizadas (ex: "https://app.exemplo.com")
+	OriginPatterns   []originPattern
+	AllowMethods     []string
+	AllowHeaders     []string
+	ExposeHeaders    []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// originPattern representa uma origem curinga de subdomínio (ex: "https://*.exemplo.com").
+type originPattern struct {
+	scheme string
+	suffix string // Sufixo do host, iniciado por "." (ex: ".exemplo.com")
+	port   string
+}
+
+// loadCORSPolicy carrega uma política de CORS das variáveis de ambiente.
+//
+// Cada variável é lida como <prefix>_<NOME>; quando não definida, o valor da política base é mantido.
+//
+// Parâmetros:
+//   - prefix (string): Prefixo das variáveis (ex: "CORS" ou "CORS_FINANCE").
+//   - base (CORSPolicy): Política usada como valor padrão.
+//
+// Retorna:
+//   - CORSPolicy: Política carregada.
+func loadCORSPolicy(prefix string, base CORSPolicy) CORSPolicy {
+	policy := base
+
+	if origins := utils.GetEnvList(prefix + "_ALLOWED_ORIGINS"); len(origins) > 0 {
+		policy.setOrigins(origins)
+	}
+	if methods := utils.GetEnvList(prefix + "_ALLOWED_METHODS"); len(methods) > 0 {
+		policy.AllowMethods = upperAll(methods)
+	}
+	if headers := utils.GetEnvList(prefix + "_ALLOWED_HEADERS"); len(headers) > 0 {
+		policy.AllowHeaders = headers
+	}
+	if headers := utils.GetEnvList(prefix + "_EXPOSED_HEADERS"); len(headers) > 0 {
+		policy.ExposeHeaders = headers
+	}
+	policy.AllowCredentials = utils.GetEnvBool(prefix+"_ALLOW_CREDENTIALS", policy.AllowCredentials)
+	policy.MaxAge = utils.GetEnvDuration(prefix+"_MAX_AGE", policy.MaxAge)
+
+	// O navegador rejeita credenciais combinadas com "*": nesse caso as credenciais são desativadas
+	if policy.AllowAllOrigins && policy.AllowCredentials {
+		logger.Warn("%s_ALLOWED_ORIGINS=* não pode ser combinado com credenciais. Credenciais desativadas.", prefix)
+		policy.AllowCredentials = false
+	}
+
+	return policy
+}
+
+// defaultCORSPolicy monta a política global a partir das variáveis CORS_* e, na ausência de origens
+// configuradas, do domínio em DOMAIN_NAME (o próprio domínio e seus subdomínios via HTTPS).
+func defaultCORSPolicy() CORSPolicy {
+	base := CORSPolicy{
+		AllowMethods:     defaultCORSMethods,
+		AllowHeaders:     defaultCORSHeaders,
+		ExposeHeaders:    defaultCORSExposeHeaders,
+		AllowCredentials: true,
+		MaxAge:           12 * time.Hour,
+	}
+
+	if domain := utils.GetEnv("DOMAIN_NAME"); domain != "" {
+		scheme := "https"
+		if s, host, ok := strings.Cut(domain, "://"); ok {
+			scheme, domain = s, host
+		}
+		domain = strings.TrimSuffix(domain, "/")
+		base.setOrigins([]string{scheme + "://" + domain, scheme + "://*." + domain})
+	}
+
+	return loadCORSPolicy("CORS", base)
+}
+
+// setOrigins separa as origens configuradas em origens exatas e padrões curinga.
+func (p *CORSPolicy) setOrigins(origins []string) {
+	p.AllowAllOrigins = false
+	p.AllowedOrigins = nil
+	p.OriginPatterns = nil
+
+	for _, origin := range origins {
+		if origin == "*" {
+			p.AllowAllOrigins = true
+			continue
+		}
+
+		if strings.Contains(origin, "*") {
+			pattern, ok := parseOriginPattern(origin)
+			if !ok {
+				logger.Warn("Padrão de origem CORS inválido: %q (formato esperado: https://*.exemplo.com)", origin)
+				continue
+			}
+			p.OriginPatterns = append(p.OriginPatterns, pattern)
+			continue
+		}
+
+		normalized, ok := normalizeOrigin(origin)
+		if !ok {
+			logger.Warn("Origem CORS inválida: %q", origin)
+			continue
+		}
+		p.AllowedOrigins = append(p.AllowedOrigins, normalized)
+	}
+}
+
+// AllowOrigin verifica se a origem informada pelo navegador é permitida pela política.
+//
+// Parâmetros:
+//   - origin (string): Valor do cabeçalho Origin.
+//
+// Retorna:
+//   - bool: true se a origem for permitida.
+func (p CORSPolicy) AllowOrigin(origin string) bool {
+	if p.AllowAllOrigins {
+		return true
+	}
+
+	normalized, ok := normalizeOrigin(origin)
+	if !ok {
+		return false
+	}
+
+	for _, allowed := range p.AllowedOrigins {
+		if normalized == allowed {
+			return true
+		}
+	}
+
+	parsed, _ := url.Parse(normalized)
+	for _, pattern := range p.OriginPatterns {
+		if pattern.matches(parsed) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// handler cria o middleware do gin-contrib/cors para a política.
+func (p CORSPolicy) handler() gin.HandlerFunc {
+	config := cors.Config{
+		AllowMethods:     p.AllowMethods,
+		AllowHeaders:     p.AllowHeaders,
+		ExposeHeaders:    p.ExposeHeaders,
+		AllowCredentials: p.AllowCredentials,
+		MaxAge:           p.MaxAge,
+	}
+
+	if p.AllowAllOrigins {
+		config.AllowAllOrigins = true
+	} else {
+		config.AllowOriginFunc = p.AllowOrigin
+	}
+
+	return cors.New(config)
+}
+
+// CORSMiddleware cria o middleware de CORS da aplicação.
+//
+// A política global é lida das variáveis CORS_*. Grupos de rotas declarados em CORS_GROUPS
+// (ex: "finance=/finance") podem sobrescrever qualquer valor com CORS_<GRUPO>_*,
+// e a política do grupo mais específico é aplicada às requisições (incluindo o preflight OPTIONS).
+//
+// Retorna:
+//   - gin.HandlerFunc: Middleware de CORS.
+func CORSMiddleware() gin.HandlerFunc {
+	global := defaultCORSPolicy()
+	globalHandler := global.handler()
+	logger.Debug("CORS global: origens=%v padrões=%d todas=%t", global.AllowedOrigins, len(global.OriginPatterns), global.AllowAllOrigins)
+
+	groups := loadRouteGroups("CORS_GROUPS")
+	handlers := make([]gin.HandlerFunc, len(groups))
+	for i, group := range groups {
+		policy := loadCORSPolicy(group.envPrefix("CORS"), global)
+		handlers[i] = policy.handler()
+		logger.Debug("CORS do grupo %s (%s): origens=%v", group.name, group.prefix, policy.AllowedOrigins)
+	}
+
+	return func(c *gin.Context) {
+		if i := matchRouteGroup(groups, c.Request.URL.Path); i >= 0 {
+			handlers[i](c)
+			return
+		}
+		globalHandler(c)
+	}
+}
+
+// parseOriginPattern interpreta um padrão de origem com curinga de subdomínio.
+//
+// Apenas a forma "esquema://*.dominio[:porta]" é aceita; o curinga corresponde a um ou mais
+// rótulos de subdomínio e nunca ao domínio base ou a domínios com o mesmo sufixo textual.
+func parseOriginPattern(pattern string) (originPattern, bool) {
+	scheme, rest, ok := strings.Cut(strings.ToLower(strings.TrimSpace(pattern)), "://")
+	if !ok || (scheme != "http" && scheme != "https") || !strings.HasPrefix(rest, "*.") {
+		return originPattern{}, false
+	}
+
+	host, port := rest[1:], ""
+	if h, p, found := strings.Cut(host, ":"); found {
+		if _, err := strconv.Atoi(p); err != nil {
+			return originPattern{}, false
+		}
+		host, port = h, p
+	}
+
+	if len(host) < 2 || strings.ContainsAny(host[1:], "*/") {
+		return originPattern{}, false
+	}
+
+	return originPattern{scheme: scheme, suffix: host, port: port}, true
+}
+
+// matches verifica se a origem (já normalizada) corresponde ao padrão.
+func (p originPattern) matches(origin *url.URL) bool {
+	if origin == nil || origin.Scheme != p.scheme || origin.Port() != p.port {
+		return false
+	}
+
+	host := origin.Hostname()
+	return strings.HasSuffix(host, p.suffix) && len(host) > len(p.suffix)
+}
+
+// normalizeOrigin valida e normaliza um valor de Origin para o formato "esquema://host[:porta]".
+func normalizeOrigin(origin string) (string, bool) {
+	parsed, err := url.Parse(strings.TrimSpace(origin))
+	if err != nil || parsed.User != nil || parsed.RawQuery != "" || parsed.Fragment != "" {
+		return "", false
+	}
+
+	scheme := strings.ToLower(parsed.Scheme)
+	if (scheme != "http" && scheme != "https") || parsed.Host == "" || (parsed.Path != "" && parsed.Path != "/") {
+		return "", false
+	}
+
+	return scheme + "://" + strings.ToLower(parsed.Host), true
+}
+
+// upperAll converte todos os itens para maiúsculas.
+func upperAll(items []string) []string {
+	upper := make([]string, len(items))
+	for i, item := range items {
+		upper[i] = strings.ToUpper(item)
+	}
+	return upper
+}
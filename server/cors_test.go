@@ -0,0 +1,159 @@
+// pwd: /app/server/cors_test.go
+
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCORSPolicyAllowOrigin(t *testing.T) {
+	var policy CORSPolicy
+	policy.setOrigins([]string{
+		"https://app.example.com",
+		"http://localhost:3000",
+		"https://*.example.org",
+		"https://*.example.net:8443",
+	})
+
+	tests := []struct {
+		name   string
+		origin string
+		want   bool
+	}{
+		{"origem exata", "https://app.example.com", true},
+		{"origem exata com maiúsculas", "https://APP.Example.com", true},
+		{"origem exata com barra final", "https://app.example.com/", true},
+		{"origem exata com porta", "http://localhost:3000", true},
+		{"subdomínio não listado", "https://api.example.com", false},
+		{"domínio com o mesmo sufixo textual", "https://evilapp.example.com", false},
+		{"porta diferente", "http://localhost:3001", false},
+		{"porta ausente", "http://localhost", false},
+		{"esquema diferente", "http://app.example.com", false},
+		{"curinga: subdomínio", "https://a.example.org", true},
+		{"curinga: vários níveis", "https://a.b.example.org", true},
+		{"curinga: domínio base", "https://example.org", false},
+		{"curinga: sufixo textual", "https://evilexample.org", false},
+		{"curinga: domínio como prefixo", "https://a.example.org.evil.com", false},
+		{"curinga: esquema diferente", "http://a.example.org", false},
+		{"curinga: porta inesperada", "https://a.example.org:8443", false},
+		{"curinga com porta", "https://a.example.net:8443", true},
+		{"curinga com porta: porta ausente", "https://a.example.net", false},
+		{"curinga com porta: porta diferente", "https://a.example.net:443", false},
+		{"origem null", "null", false},
+		{"origem vazia", "", false},
+		{"origem com usuário", "https://user@app.example.com", false},
+		{"origem com caminho", "https://app.example.com/admin", false},
+		{"esquema não http", "ftp://app.example.com", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.AllowOrigin(tt.origin); got != tt.want {
+				t.Errorf("AllowOrigin(%q) = %t, esperado %t", tt.origin, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCORSPolicyAllowAllOrigins(t *testing.T) {
+	var policy CORSPolicy
+	policy.setOrigins([]string{"*"})
+
+	for _, origin := range []string{"https://qualquer.com", "http://localhost:8080", "null"} {
+		if !policy.AllowOrigin(origin) {
+			t.Errorf("AllowOrigin(%q) = false com CORS_ALLOWED_ORIGINS=*", origin)
+		}
+	}
+}
+
+func TestParseOriginPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    originPattern
+		ok      bool
+	}{
+		{"https://*.example.com", originPattern{scheme: "https", suffix: ".example.com"}, true},
+		{" HTTP://*.Example.com ", originPattern{scheme: "http", suffix: ".example.com"}, true},
+		{"https://*.example.com:8443", originPattern{scheme: "https", suffix: ".example.com", port: "8443"}, true},
+		{"*.example.com", originPattern{}, false},
+		{"ftp://*.example.com", originPattern{}, false},
+		{"https://app.*.example.com", originPattern{}, false},
+		{"https://*example.com", originPattern{}, false},
+		{"https://*.*.example.com", originPattern{}, false},
+		{"https://*.example.com/path", originPattern{}, false},
+		{"https://*.example.com:porta", originPattern{}, false},
+		{"https://*.", originPattern{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			got, ok := parseOriginPattern(tt.pattern)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("parseOriginPattern(%q) = %+v, %t; esperado %+v, %t", tt.pattern, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestCORSMiddlewareGroups(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("DOMAIN_NAME", "")
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://app.example.com")
+	t.Setenv("CORS_GROUPS", "finance=/finance,reports=/finance/reports")
+	t.Setenv("CORS_FINANCE_ALLOWED_ORIGINS", "https://*.partner.com")
+	t.Setenv("CORS_FINANCE_ALLOW_CREDENTIALS", "false")
+	t.Setenv("CORS_REPORTS_ALLOWED_ORIGINS", "*")
+
+	router := gin.New()
+	router.Use(CORSMiddleware())
+	for _, path := range []string{"/auth/ping", "/finance/extract", "/finance/reports/daily", "/financeiro"} {
+		router.GET(path, func(c *gin.Context) { c.Status(http.StatusOK) })
+	}
+
+	tests := []struct {
+		name            string
+		method          string
+		path            string
+		origin          string
+		wantStatus      int
+		wantAllowOrigin string
+		wantCredentials string
+	}{
+		{"global: origem permitida", http.MethodGet, "/auth/ping", "https://app.example.com", http.StatusOK, "https://app.example.com", "true"},
+		{"global: origem do grupo recusada", http.MethodGet, "/auth/ping", "https://a.partner.com", http.StatusForbidden, "", ""},
+		{"grupo: origem do grupo permitida", http.MethodGet, "/finance/extract", "https://a.partner.com", http.StatusOK, "https://a.partner.com", ""},
+		{"grupo: origem global recusada", http.MethodGet, "/finance/extract", "https://app.example.com", http.StatusForbidden, "", ""},
+		{"grupo: preflight", http.MethodOptions, "/finance/extract", "https://a.partner.com", http.StatusNoContent, "https://a.partner.com", ""},
+		{"grupo: preflight recusado", http.MethodOptions, "/finance/extract", "https://evil.com", http.StatusForbidden, "", ""},
+		{"grupo mais específico", http.MethodGet, "/finance/reports/daily", "https://evil.com", http.StatusOK, "*", ""},
+		{"prefixo respeita o segmento", http.MethodGet, "/financeiro", "https://a.partner.com", http.StatusForbidden, "", ""},
+		{"origem null recusada", http.MethodGet, "/finance/extract", "null", http.StatusForbidden, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("Origin", tt.origin)
+			if tt.method == http.MethodOptions {
+				req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, esperado %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantAllowOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, esperado %q", got, tt.wantAllowOrigin)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Credentials"); got != tt.wantCredentials {
+				t.Errorf("Access-Control-Allow-Credentials = %q, esperado %q", got, tt.wantCredentials)
+			}
+		})
+	}
+}
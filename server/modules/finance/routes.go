@@ -3,13 +3,13 @@ package finance
 
 import (
 	"api/logger"
+	"api/server/modules/login/middleware"
 	"api/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
 // Variáveis de ambiente carregadas
-var BearerToken = utils.GetEnv("BEARER_PROTECTED_PATHS")
 var FinancePath = utils.GetEnv("FINANCE_PATH")
 var FinanceCsv = utils.GetEnv("FINANCE_CSV")
 var FinanceCsvDb = utils.GetEnv("FINANCE_CSV_DB")
@@ -17,8 +17,8 @@ var FinanceCsvDb = utils.GetEnv("FINANCE_CSV_DB")
 // RegisterRoutes registra as rotas do módulo Finance.
 //
 // Esta função adiciona as rotas para o módulo de finanças, incluindo rotas protegidas
-// que requerem autenticação via middleware. A rota de /ping serve para verificar se
-// o módulo de finanças está respondendo corretamente. As rotas de upload de CSV e extração de dados
+// que requerem um token JWT ou uma chave de API com a permissão "finance:read". A rota
+// de /ping serve para verificar se o módulo de finanças está respondendo corretamente. As rotas de upload de CSV e extração de dados
 // também são configuradas aqui.
 //
 // Parâmetros:
@@ -27,9 +27,9 @@ func RegisterRoutes(router *gin.Engine) {
 	// Cria um grupo de rotas com o caminho configurado na variável FinancePath
 	group := router.Group(FinancePath)
 
-	// Cria um grupo protegido com middleware de autenticação
+	// Cria um grupo protegido: JWT ou chave de API, com a permissão finance:read
 	protected := group.Group("/")
-	protected.Use(AuthMiddleware()) // Aplica o middleware de autenticação
+	protected.Use(middleware.AuthOrAPIKeyMiddleware(), middleware.RequirePermission("finance:read"))
 
 	// Adiciona as rotas protegidas
 	{
@@ -39,10 +39,13 @@ func RegisterRoutes(router *gin.Engine) {
 		})
 
 		// Rota para upload de arquivos CSV para extração de dados financeiros
+		// (GET permite que o cliente revalide o arquivo com If-None-Match / If-Modified-Since)
 		protected.POST(FinanceCsv, handleExtract)
+		protected.GET(FinanceCsv, handleExtract)
 
 		// Rota para extração de dados financeiros diretamente do banco de dados
 		protected.POST(FinanceCsvDb, handleExtractDB)
+		protected.GET(FinanceCsvDb, handleExtractDB)
 	}
 }
 
@@ -52,4 +55,8 @@ func RegisterRoutes(router *gin.Engine) {
 // indicando que o módulo de finanças foi carregado com sucesso.
 func init() {
 	logger.Debug("Módulo Finance carregado.")
+
+	if utils.GetEnv("BEARER_PROTECTED_PATHS") != "" {
+		logger.Warn("BEARER_PROTECTED_PATHS não é mais usado: o módulo Finance aceita JWT ou chaves de API com o escopo finance:read")
+	}
 }
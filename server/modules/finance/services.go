@@ -3,61 +3,143 @@ package finance
 
 import (
 	"api/logger"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
+
+	"api/utils"
+	"api/utils/cache"
 
 	"github.com/gin-gonic/gin"
 )
 
-// AuthMiddleware é um middleware que verifica se o token Bearer fornecido é válido.
-//
-// Este middleware verifica o cabeçalho "Authorization" da requisição para garantir que
-// o token Bearer seja fornecido e seja válido. Caso o token esteja ausente ou seja inválido,
-// ele retorna um erro com o código de status adequado (401 ou 403) e interrompe a requisição.
-//
-// Retorna:
-//   - gin.HandlerFunc: A função de middleware para ser usada no Gin.
-func AuthMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// Pega o cabeçalho Authorization
-		authHeader := c.GetHeader("Authorization")
-
-		// Verifica se o cabeçalho está presente e se é um Bearer token
-		if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-			c.JSON(401, gin.H{"error": "Autorização necessária"})
-			c.Abort()
-			return
-		}
-
-		// Extrai o token do cabeçalho
-		token := strings.TrimPrefix(authHeader, "Bearer ")
-
-		// Verifica se o token é válido
-		if token != BearerToken {
-			c.JSON(403, gin.H{"error": "Token inválido"})
-			c.Abort()
-			return
-		}
-
-		// Se o token for válido, segue com a requisição
-		c.Next()
-	}
-}
-
 // fileExtract envia o arquivo especificado para o cliente com os headers apropriados.
 //
 // Esta função configura os cabeçalhos de resposta para o arquivo CSV e o envia
 // para o cliente. O arquivo será enviado com o tipo MIME correto (text/csv) e
-// será exibido inline no navegador.
+// será exibido inline no navegador. A resposta inclui ETag e Last-Modified, e
+// clientes que enviarem If-None-Match ou If-Modified-Since de um arquivo inalterado
+// recebem 304 (Not Modified) sem o corpo.
 //
 // Parâmetros:
 //   - c (*gin.Context): O contexto da requisição do Gin.
 //   - filePath (string): O caminho do arquivo a ser enviado.
 func fileExtract(c *gin.Context, filePath string) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		logger.Error("Erro ao abrir o arquivo %s: %v", filePath, err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Arquivo não encontrado"})
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		logger.Error("Erro ao obter informações do arquivo %s: %v", filePath, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao ler o arquivo"})
+		return
+	}
+
+	etag, err := fileETag(file, info)
+	if err != nil {
+		logger.Error("Erro ao calcular o ETag do arquivo %s: %v", filePath, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao ler o arquivo"})
+		return
+	}
+	modTime := info.ModTime().UTC().Truncate(time.Second)
+
 	// Define os cabeçalhos da resposta
 	c.Header("Content-Type", "text/csv; charset=utf-8")
 	c.Header("Content-Disposition", "inline")
+	c.Header("Cache-Control", "private, no-cache")
+	c.Header("ETag", etag)
+	c.Header("Last-Modified", modTime.Format(http.TimeFormat))
+
+	// As rotas de extração também aceitam POST, por isso a validação condicional é feita aqui
+	// (http.ServeContent responderia 412 a um If-None-Match válido em métodos diferentes de GET/HEAD)
+	if notModified(c.Request, etag, modTime) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
 	// Envia o arquivo especificado para o cliente
-	c.File(filePath)
+	http.ServeContent(c.Writer, c.Request, filepath.Base(filePath), modTime, file)
+}
+
+// etagEntry representa o ETag calculado de um arquivo e a versão do arquivo (tamanho e data de modificação) usada no cálculo.
+type etagEntry struct {
+	size    int64
+	modTime time.Time
+	etag    string
+}
+
+// etagCache guarda o ETag de cada arquivo, indexado pelo caminho, por FINANCE_ETAG_CACHE_TTL.
+// Cada arquivo ocupa uma única entrada, substituída quando o arquivo é reescrito.
+var etagCache = cache.New[string, etagEntry](utils.GetEnvDuration("FINANCE_ETAG_CACHE_TTL", 10*time.Minute))
+
+// fileETag calcula o ETag forte do arquivo a partir do SHA-256 do conteúdo.
+//
+// O valor é reaproveitado enquanto o tamanho e a data de modificação do arquivo não mudarem.
+//
+// Parâmetros:
+//   - file (*os.File): O arquivo aberto.
+//   - info (os.FileInfo): As informações do arquivo.
+//
+// Retorna:
+//   - string: O ETag entre aspas.
+//   - error: Erro caso não seja possível ler o arquivo.
+func fileETag(file *os.File, info os.FileInfo) (string, error) {
+	if cached, ok := etagCache.Get(file.Name()); ok && cached.size == info.Size() && cached.modTime.Equal(info.ModTime()) {
+		return cached.etag, nil
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	etag := fmt.Sprintf(`"%x"`, hash.Sum(nil)[:16])
+	etagCache.Set(file.Name(), etagEntry{size: info.Size(), modTime: info.ModTime(), etag: etag})
+	return etag, nil
+}
+
+// notModified verifica os cabeçalhos If-None-Match e If-Modified-Since da requisição.
+//
+// O If-None-Match tem precedência e usa comparação fraca (ignora o prefixo "W/"), de forma que
+// o ETag de uma resposta comprimida continue válido.
+//
+// Parâmetros:
+//   - r (*http.Request): A requisição HTTP.
+//   - etag (string): O ETag atual do recurso.
+//   - modTime (time.Time): A data de modificação atual do recurso.
+//
+// Retorna:
+//   - bool: true se o cliente já possui a versão atual do recurso.
+func notModified(r *http.Request, etag string, modTime time.Time) bool {
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ifModifiedSince := r.Header.Get("If-Modified-Since"); ifModifiedSince != "" {
+		since, err := http.ParseTime(ifModifiedSince)
+		return err == nil && !modTime.After(since)
+	}
+
+	return false
 }
 
 // handleExtract lida com a requisição para servir o arquivo extract.csv.
@@ -0,0 +1,78 @@
+// pwd: /app/server/modules/login/auth_utils/email_token.go
+
+package auth_utils
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"api/logger"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// Os tokens de verificação de e-mail usam uma audiência própria, para que nunca sejam aceitos como tokens de acesso.
+var emailVerificationAudience = tokenAudience + ":email_verification"
+
+// EmailVerificationClaims define os claims do token enviado no link de verificação de e-mail.
+type EmailVerificationClaims struct {
+	Email string `json:"email"`
+	jwt.StandardClaims
+}
+
+// Valid valida os claims padrão do token de verificação de e-mail.
+func (c EmailVerificationClaims) Valid() error {
+	return ValidateStandardClaims(c.StandardClaims, emailVerificationAudience)
+}
+
+// UserID retorna o ID do usuário gravado no claim sub.
+func (c EmailVerificationClaims) UserID() (int, error) {
+	return strconv.Atoi(c.Subject)
+}
+
+// GenerateEmailVerificationToken gera o token assinado que confirma o e-mail de um usuário.
+//
+// O token não é gravado no banco: a assinatura garante a sua origem e o claim "email" o vincula ao
+// endereço verificado, de forma que ele deixa de valer se o e-mail do usuário for alterado.
+//
+// Parâmetros:
+//   - userID (int): ID do usuário.
+//   - email (string): E-mail a verificar.
+//   - ttl (time.Duration): Validade do token.
+//
+// Retorna:
+//   - string: token assinado.
+//   - error: erro em caso de falha na assinatura.
+func GenerateEmailVerificationToken(userID int, email string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := EmailVerificationClaims{
+		Email: email,
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    tokenIssuer,
+			Audience:  emailVerificationAudience,
+			Subject:   strconv.Itoa(userID),
+			IssuedAt:  now.Unix(),
+			NotBefore: now.Unix(),
+			ExpiresAt: now.Add(ttl).Unix(),
+			Id:        NewTokenID(),
+		},
+	}
+	return SignClaims(claims)
+}
+
+// ParseEmailVerificationToken verifica a assinatura e os claims de um token de verificação de e-mail.
+//
+// Retorna:
+//   - *EmailVerificationClaims: claims do token válido.
+//   - error: erro se o token for inválido ou expirado.
+func ParseEmailVerificationToken(tokenString string) (*EmailVerificationClaims, error) {
+	claims := &EmailVerificationClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, keyFunc)
+	if err != nil || !token.Valid {
+		logger.Warn("Token de verificação de e-mail inválido ou expirado: %v", err)
+		return nil, errors.New("token inválido ou expirado")
+	}
+
+	return claims, nil
+}
@@ -5,57 +5,110 @@ package auth_utils
 import (
 	"api/logger"
 	"api/utils"
+	"crypto/rand"
 	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // Claims define a estrutura dos claims do JWT.
+//
+// Além dos dados do usuário, todo token carrega os claims padrão iss, aud, sub, iat, nbf, exp e jti.
 type Claims struct {
-	ID          int    `json:"id"`
-	Username    string `json:"username"`
-	AccessLevel int    `json:"access_level"`
+	ID           int         `json:"id"`
+	Username     string      `json:"username"`
+	Roles        []string    `json:"roles,omitempty"`
+	TokenVersion int         `json:"ver"`
+	SessionID    string      `json:"sid,omitempty"`
+	Actor        *TokenActor `json:"act,omitempty"` // Presente apenas nos tokens de personificação
 	jwt.StandardClaims
 }
 
-// SecretKey contém a chave secreta utilizada para assinar os tokens JWT.
+// TokenActor identifica o administrador que age em nome do usuário do token (claim "act", RFC 8693).
+type TokenActor struct {
+	Subject      string `json:"sub"`
+	ID           int    `json:"id"`
+	Username     string `json:"username"`
+	TokenVersion int    `json:"ver"`           // Versão dos tokens do administrador na emissão
+	SessionID    string `json:"sid,omitempty"` // Sessão do administrador que solicitou a personificação
+}
+
+// Impersonating informa se o token foi emitido para um administrador agindo em nome do usuário.
+func (c Claims) Impersonating() bool {
+	return c.Actor != nil
+}
+
+// TokenSubject reúne os dados do usuário gravados no token de acesso.
+type TokenSubject struct {
+	UserID       int
+	Username     string
+	Roles        []string // Papéis do usuário (informativo; as permissões são consultadas a cada requisição)
+	TokenVersion int      // Versão atual dos tokens do usuário (users.token_version)
+	SessionID    string   // Sessão de login à qual o token pertence (sessions.id)
+
+	Actor     *TokenActor   // Administrador que age em nome do usuário (somente na personificação)
+	ExpiresIn time.Duration // Validade do token (zero: JWT_EXPIRE)
+}
+
+// Configuração da validação dos claims padrão (RFC 7519)
+var (
+	tokenIssuer   = getEnvDefault("JWT_ISSUER", "api_backend")
+	tokenAudience = getEnvDefault("JWT_AUDIENCE", "api_backend")
+	clockSkew     = utils.GetEnvDuration("JWT_CLOCK_SKEW", 30*time.Second)
+)
+
+// SecretKey contém a chave secreta utilizada para assinar os tokens JWT quando JWT_ALGORITHM=HS256.
 var SecretKey = []byte(utils.GetEnv("JWT_SECRET"))
 
-// GenerateJWT gera um token JWT com base no ID do usuário, nome de usuário e nível de acesso.
+// GenerateJWT gera um token JWT com base no ID do usuário, nome de usuário, papéis, versão dos tokens e sessão.
+// Nos tokens de personificação, grava também o administrador (claim act) e usa a validade informada.
+//
+// O token é assinado com a chave ativa da key set (cabeçalho "kid") ou com JWT_SECRET no modo HS256.
 //
 // Retorna:
 //   - string: token JWT assinado.
 //   - error: erro em caso de falha na geração do token.
-func GenerateJWT(userID int, username string, accessLevel int) (string, error) {
+func GenerateJWT(subject TokenSubject) (string, error) {
+	userID := subject.UserID
 	logger.Debug("Gerando um novo token JWT para o usuário ID=%v", userID)
 
-	expirationTime, err := GetExpirationTime()
-	if err != nil {
-		logger.Error("Erro ao obter o tempo de expiração: %v", err)
-		return "", errors.New("erro interno")
+	expirationTime := subject.ExpiresIn
+	if expirationTime <= 0 {
+		var err error
+		if expirationTime, err = GetExpirationTime(); err != nil {
+			logger.Error("Erro ao obter o tempo de expiração: %v", err)
+			return "", errors.New("erro interno")
+		}
 	}
 
 	expirationDate := time.Now().Add(expirationTime)
 	logger.Debug("Data de expiração do token: %v", expirationDate)
 
+	now := time.Now()
 	claims := Claims{
-		ID:          userID,
-		Username:    username,
-		AccessLevel: accessLevel,
+		ID:           userID,
+		Username:     subject.Username,
+		Roles:        subject.Roles,
+		TokenVersion: subject.TokenVersion,
+		SessionID:    subject.SessionID,
+		Actor:        subject.Actor,
 		StandardClaims: jwt.StandardClaims{
+			Issuer:    tokenIssuer,
+			Audience:  tokenAudience,
+			Subject:   strconv.Itoa(userID),
+			IssuedAt:  now.Unix(),
+			NotBefore: now.Unix(),
 			ExpiresAt: expirationDate.Unix(),
+			Id:        NewTokenID(),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	signedToken, err := token.SignedString(SecretKey)
+	signedToken, err := SignClaims(claims)
 	if err != nil {
-		logger.Error("Erro ao assinar o token JWT: %v", err)
-		return "", errors.New("erro ao gerar token JWT")
+		return "", err
 	}
 
 	logger.Debug("Token gerado com sucesso para o usuário ID=%v", userID)
@@ -69,13 +122,12 @@ func GenerateJWT(userID int, username string, accessLevel int) (string, error) {
 //   - bool: verdadeiro se a senha fornecida corresponder ao hash armazenado.
 //   - error: erro em caso de falha na geração ou verificação do hash.
 func HashAndCheckPassword(password, hash string) (string, bool, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hashedPassword, err := HashPassword(password)
 	if err != nil {
 		return "", false, err
 	}
 
-	err = bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return string(hashedPassword), err == nil, nil
+	return hashedPassword, VerifyPassword(password, hash), nil
 }
 
 // GetExpirationTime recupera o tempo de expiração do token JWT da variável de ambiente.
@@ -86,8 +138,8 @@ func HashAndCheckPassword(password, hash string) (string, bool, error) {
 func GetExpirationTime() (time.Duration, error) {
 	expirationStr := utils.GetEnv("JWT_EXPIRE")
 	if expirationStr == "" {
-		logger.Warn("Tempo de expiração não encontrado. Usando o valor padrão de 120 minutos")
-		return 120 * time.Minute, nil
+		logger.Warn("Tempo de expiração não encontrado. Usando o valor padrão de 15 minutos")
+		return 15 * time.Minute, nil
 	}
 
 	expirationTime, err := time.ParseDuration(expirationStr)
@@ -98,71 +150,137 @@ func GetExpirationTime() (time.Duration, error) {
 	return expirationTime, nil
 }
 
-// ValidateJWT verifica se um token JWT é válido.
+// SignClaims assina os claims informados com a chave ativa (cabeçalho "kid") ou com JWT_SECRET no modo HS256.
 //
 // Retorna:
-//   - bool: verdadeiro se o token for válido.
-//   - error: erro se o token for inválido ou expirado.
-func ValidateJWT(tokenString string) (bool, error) {
-	logger.Debug("Validando o token JWT")
+//   - string: token JWT assinado.
+//   - error: erro em caso de falha na assinatura.
+func SignClaims(claims jwt.Claims) (string, error) {
+	method, key, kid, err := signingMethod()
+	if err != nil {
+		logger.Error("Erro ao obter a chave de assinatura: %v", err)
+		return "", errors.New("erro ao gerar token JWT")
+	}
 
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			logger.Warn("Método de assinatura inválido: %v", token.Header["alg"])
-			return nil, errors.New("método de assinatura inválido")
-		}
-		return SecretKey, nil
-	})
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
 
-	if err != nil || !token.Valid {
-		logger.Warn("Token inválido ou expirado: %v", err)
-		return false, errors.New("token inválido")
+	signedToken, err := token.SignedString(key)
+	if err != nil {
+		logger.Error("Erro ao assinar o token JWT: %v", err)
+		return "", errors.New("erro ao gerar token JWT")
 	}
 
-	logger.Debug("Token JWT é válido")
-	return true, nil
+	return signedToken, nil
 }
 
-// CalculateTokenExpirationTime calcula o tempo restante até a expiração de um token JWT.
+// NewTokenID gera um identificador único (UUID v4) para o claim jti.
 //
 // Retorna:
-//   - time.Duration: tempo restante até a expiração do token.
+//   - string: identificador gerado.
+func NewTokenID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// Valid valida os claims padrão do token, com tolerância de JWT_CLOCK_SKEW para diferenças de relógio.
+//
+// Verifica exp, nbf e iat, o emissor (JWT_ISSUER), a audiência (JWT_AUDIENCE) e a presença de sub e jti.
+// É chamado automaticamente pelo jwt-go durante o parse.
+//
+// Retorna:
+//   - error: erro descrevendo o primeiro claim inválido.
+func (c Claims) Valid() error {
+	return ValidateStandardClaims(c.StandardClaims, tokenAudience)
+}
+
+// ValidateStandardClaims valida exp, nbf, iat, iss, aud, sub e jti para a audiência informada.
+//
+// Retorna:
+//   - error: erro descrevendo o primeiro claim inválido.
+func ValidateStandardClaims(c jwt.StandardClaims, audience string) error {
+	now := time.Now()
+
+	if c.ExpiresAt == 0 {
+		return errors.New("token sem data de expiração")
+	}
+	if now.After(time.Unix(c.ExpiresAt, 0).Add(clockSkew)) {
+		return errors.New("token expirado")
+	}
+	if c.NotBefore != 0 && now.Add(clockSkew).Before(time.Unix(c.NotBefore, 0)) {
+		return errors.New("token ainda não é válido")
+	}
+	if c.IssuedAt != 0 && now.Add(clockSkew).Before(time.Unix(c.IssuedAt, 0)) {
+		return errors.New("token emitido no futuro")
+	}
+	if c.Issuer != tokenIssuer {
+		return fmt.Errorf("emissor inválido: %q", c.Issuer)
+	}
+	if c.Audience != audience {
+		return fmt.Errorf("audiência inválida: %q", c.Audience)
+	}
+	if c.Subject == "" || c.Id == "" {
+		return errors.New("token sem sub ou jti")
+	}
+
+	return nil
+}
+
+// ParseToken é o único ponto de validação dos tokens de acesso.
+//
+// Remove o prefixo "Bearer " (se houver), verifica a assinatura com a key set configurada
+// e valida os claims padrão (exp, nbf, iat, iss, aud, sub e jti).
+//
+// Retorna:
+//   - *Claims: claims do token válido.
 //   - error: erro se o token for inválido ou expirado.
-func CalculateTokenExpirationTime(tokenString string) (time.Duration, error) {
+func ParseToken(tokenString string) (*Claims, error) {
 	tokenString = RemoveBearerPrefix(tokenString)
 
-	logger.Debug("Calculando o tempo restante até a expiração do token...")
-
 	claims := &Claims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			logger.Warn("Método de assinatura inválido: %v", token.Header["alg"])
-			return nil, errors.New("método de assinatura inválido")
-		}
-		return SecretKey, nil
-	})
-
+	token, err := jwt.ParseWithClaims(tokenString, claims, keyFunc)
 	if err != nil || !token.Valid {
 		logger.Warn("Token inválido ou expirado: %v", err)
-		return 0, errors.New("token inválido ou expirado")
+		return nil, errors.New("token inválido ou expirado")
 	}
 
-	expirationTime := claims.ExpiresAt
-	if expirationTime == 0 {
-		logger.Warn("Tempo de expiração não encontrado no token")
-		return 0, errors.New("tempo de expiração não encontrado")
-	}
+	return claims, nil
+}
 
-	expirationTimeUnix := time.Unix(expirationTime, 0)
-	timeRemaining := time.Until(expirationTimeUnix)
+// ValidateJWT verifica se um token JWT é válido.
+//
+// Retorna:
+//   - bool: verdadeiro se o token for válido.
+//   - error: erro se o token for inválido ou expirado.
+func ValidateJWT(tokenString string) (bool, error) {
+	if _, err := ParseToken(tokenString); err != nil {
+		return false, errors.New("token inválido")
+	}
+	return true, nil
+}
 
-	logger.Debug("Tempo restante até a expiração do token: %v", timeRemaining)
+// CalculateTokenExpirationTime calcula o tempo restante até a expiração de um token JWT.
+//
+// Retorna:
+//   - time.Duration: tempo restante até a expiração do token.
+//   - error: erro se o token for inválido ou expirado.
+func CalculateTokenExpirationTime(tokenString string) (time.Duration, error) {
+	claims, err := ParseToken(tokenString)
+	if err != nil {
+		return 0, err
+	}
 
+	timeRemaining := time.Until(time.Unix(claims.ExpiresAt, 0))
 	if timeRemaining < 0 {
-		logger.Warn("Token expirado")
-		return 0, errors.New("token expirado")
+		timeRemaining = 0
 	}
 
+	logger.Debug("Tempo restante até a expiração do token: %v", timeRemaining)
 	return timeRemaining, nil
 }
 
@@ -171,7 +289,6 @@ func CalculateTokenExpirationTime(tokenString string) (time.Duration, error) {
 // Retorna:
 //   - string: token JWT sem o prefixo "Bearer ".
 func RemoveBearerPrefix(authHeader string) string {
-	logger.Debug("Conteúdo do cabeçalho de autenticação: %s", authHeader)
 	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
 		return authHeader[7:]
 	}
@@ -184,37 +301,13 @@ func RemoveBearerPrefix(authHeader string) string {
 //   - *Claims: claims extraídos do token JWT.
 //   - error: erro se o token for inválido ou se os claims não puderem ser extraídos.
 func ValidateAndExtractClaims(tokenString string) (*Claims, error) {
-	logger.Debug("Token recebido: %v", tokenString)
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			logger.Warn("Método de assinatura inválido: %v", token.Header["alg"])
-			return nil, errors.New("método de assinatura inválido")
-		}
-		return SecretKey, nil
-	})
-
-	logger.Debug("Validando o token JWT...")
-	if err != nil {
-		logger.Warn("Erro ao validar o token: %v", err)
-		return nil, errors.New("token inválido ou expirado")
-	}
-
-	// Recupera os claims
-	claims, ok := token.Claims.(jwt.MapClaims)
-	logger.Debug("Claims extraídos do token: %v", claims)
-	if !ok {
-		logger.Warn("Falha ao extrair claims do token")
-		return nil, errors.New("erro ao extrair claims do token")
-	}
+	return ParseToken(tokenString)
+}
 
-	// Converte jwt.MapClaims para Claims
-	convertedClaims := &Claims{
-		ID:          int(claims["id"].(float64)),
-		Username:    claims["username"].(string),
-		AccessLevel: int(claims["access_level"].(float64)),
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: int64(claims["exp"].(float64)),
-		},
+// getEnvDefault retorna o valor da variável de ambiente ou o valor padrão, se não estiver definida.
+func getEnvDefault(key, defaultValue string) string {
+	if value := utils.GetEnv(key); value != "" {
+		return value
 	}
-	return convertedClaims, nil
+	return defaultValue
 }
@@ -0,0 +1,328 @@
+// pwd: /app/server/modules/login/auth_utils/keys.go
+
+package auth_utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"api/logger"
+	"api/utils"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// signingKey representa uma chave da key set de assinatura de tokens.
+type signingKey struct {
+	kid     string
+	alg     string
+	private crypto.Signer    // nil para chaves mantidas apenas para verificação
+	public  crypto.PublicKey // chave pública usada na verificação e publicada no JWKS
+}
+
+// keySet contém as chaves carregadas de JWT_KEYS_DIR e a chave ativa de assinatura.
+type keySet struct {
+	algorithm string
+	keys      map[string]*signingKey
+	active    *signingKey
+}
+
+var (
+	keysOnce   sync.Once
+	loadedKeys *keySet
+)
+
+// currentKeySet carrega (uma única vez) a key set configurada nas variáveis de ambiente.
+//
+// Com JWT_ALGORITHM=HS256 (padrão) os tokens continuam assinados com JWT_SECRET.
+// Com RS256, ES256 ou EdDSA, as chaves PEM são lidas de JWT_KEYS_DIR: cada arquivo
+// "<kid>.pem" vira uma chave identificada pelo kid. Chaves privadas podem assinar;
+// arquivos com apenas a chave pública (ex: chaves antigas em rotação) só verificam.
+// A chave que assina é definida por JWT_SIGNING_KEY_ID (padrão: o último kid em ordem alfabética).
+func currentKeySet() *keySet {
+	keysOnce.Do(func() {
+		algorithm := strings.ToUpper(utils.GetEnv("JWT_ALGORITHM"))
+		switch algorithm {
+		case "", "HS256":
+			loadedKeys = &keySet{algorithm: "HS256"}
+			return
+		case "EDDSA":
+			algorithm = "EdDSA"
+		}
+
+		set, err := loadKeySet(algorithm, utils.GetEnv("JWT_KEYS_DIR"), utils.GetEnv("JWT_SIGNING_KEY_ID"))
+		if err != nil {
+			logger.Error("Erro ao carregar as chaves JWT: %v", err)
+			set = &keySet{algorithm: algorithm, keys: map[string]*signingKey{}}
+		}
+		loadedKeys = set
+	})
+	return loadedKeys
+}
+
+// loadKeySet lê as chaves PEM do diretório informado.
+//
+// Retorna:
+//   - *keySet: chaves carregadas, com a chave ativa definida.
+//   - error: erro se o diretório não puder ser lido ou não houver chave de assinatura válida.
+func loadKeySet(algorithm, dir, activeKid string) (*keySet, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("JWT_KEYS_DIR é obrigatório para o algoritmo %s", algorithm)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao listar chaves em %s: %v", dir, err)
+	}
+	sort.Strings(files)
+
+	set := &keySet{algorithm: algorithm, keys: map[string]*signingKey{}}
+	var lastSigner *signingKey
+
+	for _, file := range files {
+		kid := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(file), ".pem"), ".pub")
+		key, err := readPEMKey(file)
+		if err != nil {
+			logger.Warn("Chave JWT ignorada (%s): %v", file, err)
+			continue
+		}
+		key.kid = kid
+
+		if existing, ok := set.keys[kid]; ok && existing.private != nil {
+			continue // a chave privada já contém a pública
+		}
+		set.keys[kid] = key
+
+		if key.private != nil && key.alg == algorithm {
+			lastSigner = key
+		}
+		logger.Debug("Chave JWT carregada: kid=%s alg=%s assinatura=%t", kid, key.alg, key.private != nil)
+	}
+
+	if activeKid != "" {
+		key, ok := set.keys[activeKid]
+		if !ok || key.private == nil {
+			return nil, fmt.Errorf("chave de assinatura %q não encontrada em %s", activeKid, dir)
+		}
+		if key.alg != algorithm {
+			return nil, fmt.Errorf("a chave %q é %s, mas JWT_ALGORITHM é %s", activeKid, key.alg, algorithm)
+		}
+		set.active = key
+	} else {
+		set.active = lastSigner
+	}
+
+	if set.active == nil {
+		return nil, fmt.Errorf("nenhuma chave privada %s encontrada em %s", algorithm, dir)
+	}
+
+	logger.Info("Tokens JWT assinados com %s (kid=%s, %d chave(s) para verificação)", algorithm, set.active.kid, len(set.keys))
+	return set, nil
+}
+
+// readPEMKey interpreta um arquivo PEM com chave privada (PKCS#8, PKCS#1 ou EC) ou pública (PKIX ou PKCS#1).
+func readPEMKey(file string) (*signingKey, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, errors.New("conteúdo PEM inválido")
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("tipo de bloco PEM não suportado: %s", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &signingKey{}
+	if signer, ok := parsed.(crypto.Signer); ok {
+		key.private = signer
+		key.public = signer.Public()
+	} else {
+		key.public = parsed
+	}
+
+	switch public := key.public.(type) {
+	case *rsa.PublicKey:
+		if public.N.BitLen() < 2048 {
+			return nil, errors.New("chaves RSA devem ter pelo menos 2048 bits")
+		}
+		key.alg = "RS256"
+	case *ecdsa.PublicKey:
+		if public.Curve != elliptic.P256() {
+			return nil, errors.New("apenas chaves EC P-256 (ES256) são suportadas")
+		}
+		key.alg = "ES256"
+	case ed25519.PublicKey:
+		key.alg = "EdDSA"
+	default:
+		return nil, fmt.Errorf("tipo de chave não suportado: %T", key.public)
+	}
+
+	return key, nil
+}
+
+// signingMethod retorna o método de assinatura, a chave e o kid usados para emitir novos tokens.
+//
+// Retorna:
+//   - jwt.SigningMethod: método de assinatura.
+//   - interface{}: chave de assinatura.
+//   - string: kid da chave (vazio para HS256).
+//   - error: erro se não houver chave de assinatura disponível.
+func signingMethod() (jwt.SigningMethod, interface{}, string, error) {
+	set := currentKeySet()
+	if set.algorithm == "HS256" {
+		return jwt.SigningMethodHS256, SecretKey, "", nil
+	}
+	if set.active == nil {
+		return nil, nil, "", errors.New("nenhuma chave de assinatura JWT disponível")
+	}
+	return jwt.GetSigningMethod(set.active.alg), set.active.private, set.active.kid, nil
+}
+
+// keyFunc seleciona a chave de verificação de um token recebido.
+//
+// Com HS256, apenas tokens HMAC são aceitos. Com chaves assimétricas, o token deve trazer o
+// cabeçalho "kid" de uma chave conhecida, e o "alg" deve corresponder ao tipo dessa chave
+// (impede a troca de algoritmo, como assinar com HMAC usando a chave pública).
+func keyFunc(token *jwt.Token) (interface{}, error) {
+	set := currentKeySet()
+
+	if set.algorithm == "HS256" {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			logger.Warn("Método de assinatura inválido: %v", token.Header["alg"])
+			return nil, errors.New("método de assinatura inválido")
+		}
+		return SecretKey, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := set.keys[kid]
+	if !ok {
+		logger.Warn("Token assinado com chave desconhecida: kid=%q", kid)
+		return nil, errors.New("chave de assinatura desconhecida")
+	}
+	if token.Method.Alg() != key.alg {
+		logger.Warn("Método de assinatura inválido: %v (esperado %s)", token.Header["alg"], key.alg)
+		return nil, errors.New("método de assinatura inválido")
+	}
+
+	return key.public, nil
+}
+
+// JWK representa uma chave pública no formato JSON Web Key (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// PublicJWKS retorna as chaves públicas de verificação no formato JWKS.
+//
+// Com HS256 a lista é vazia, pois o segredo compartilhado nunca é publicado.
+//
+// Retorna:
+//   - []JWK: chaves públicas ordenadas pelo kid.
+func PublicJWKS() []JWK {
+	set := currentKeySet()
+	jwks := make([]JWK, 0, len(set.keys))
+
+	for _, key := range set.keys {
+		jwk := JWK{Use: "sig", Alg: key.alg, Kid: key.kid}
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case *ecdsa.PublicKey:
+			size := (public.Curve.Params().BitSize + 7) / 8
+			jwk.Kty = "EC"
+			jwk.Crv = public.Curve.Params().Name
+			jwk.X = base64.RawURLEncoding.EncodeToString(public.X.FillBytes(make([]byte, size)))
+			jwk.Y = base64.RawURLEncoding.EncodeToString(public.Y.FillBytes(make([]byte, size)))
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+		jwks = append(jwks, jwk)
+	}
+
+	sort.Slice(jwks, func(i, j int) bool { return jwks[i].Kid < jwks[j].Kid })
+	return jwks
+}
+
+// SigningMethodEdDSA implementa a assinatura Ed25519 ("EdDSA"), ausente no jwt-go v3.
+type SigningMethodEdDSA struct{}
+
+// Alg retorna o nome do algoritmo no cabeçalho do token.
+func (m *SigningMethodEdDSA) Alg() string { return "EdDSA" }
+
+// Verify valida a assinatura Ed25519 de um token.
+func (m *SigningMethodEdDSA) Verify(signingString, signature string, key interface{}) error {
+	public, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return jwt.ErrInvalidKeyType
+	}
+
+	sig, err := jwt.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(public, []byte(signingString), sig) {
+		return jwt.ErrSignatureInvalid
+	}
+	return nil
+}
+
+// Sign assina um token com uma chave privada Ed25519.
+func (m *SigningMethodEdDSA) Sign(signingString string, key interface{}) (string, error) {
+	private, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return "", jwt.ErrInvalidKeyType
+	}
+	return jwt.EncodeSegment(ed25519.Sign(private, []byte(signingString))), nil
+}
+
+func init() {
+	jwt.RegisterSigningMethod("EdDSA", func() jwt.SigningMethod { return &SigningMethodEdDSA{} })
+}
@@ -0,0 +1,106 @@
+// pwd: /app/server/modules/login/auth_utils/oauth_token.go
+
+package auth_utils
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"api/logger"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// OAuthClientSubjectPrefix identifica, no claim sub, os tokens emitidos para o próprio cliente (client_credentials).
+const OAuthClientSubjectPrefix = "client:"
+
+// Os tokens OAuth2 usam uma audiência própria, para que nunca sejam aceitos como tokens de acesso desta API.
+var oauthAudience = tokenAudience + ":oauth"
+
+// OAuthAccessClaims define os claims dos tokens de acesso emitidos às aplicações pelo servidor OAuth2.
+type OAuthAccessClaims struct {
+	ClientID     string `json:"client_id"`
+	Scope        string `json:"scope"`
+	Username     string `json:"username,omitempty"`
+	TokenVersion int    `json:"ver,omitempty"`
+	jwt.StandardClaims
+}
+
+// Valid valida os claims padrão do token OAuth2.
+func (c OAuthAccessClaims) Valid() error {
+	return ValidateStandardClaims(c.StandardClaims, oauthAudience)
+}
+
+// UserID retorna o ID do usuário gravado no claim sub, ou 0 nos tokens do próprio cliente.
+func (c OAuthAccessClaims) UserID() int {
+	if strings.HasPrefix(c.Subject, OAuthClientSubjectPrefix) {
+		return 0
+	}
+	id, _ := strconv.Atoi(c.Subject)
+	return id
+}
+
+// Scopes retorna os escopos do token.
+func (c OAuthAccessClaims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+// GenerateOAuthAccessToken gera um token de acesso OAuth2 assinado.
+//
+// Parâmetros:
+//   - clientID (string): Cliente ao qual o token foi emitido.
+//   - subject (TokenSubject): O usuário que autorizou o acesso; UserID 0 emite o token para o próprio cliente.
+//   - scopes ([]string): Escopos concedidos.
+//   - ttl (time.Duration): Validade do token.
+//
+// Retorna:
+//   - string: token assinado.
+//   - *OAuthAccessClaims: claims gravados no token.
+//   - error: erro em caso de falha na assinatura.
+func GenerateOAuthAccessToken(clientID string, subject TokenSubject, scopes []string, ttl time.Duration) (string, *OAuthAccessClaims, error) {
+	sub := OAuthClientSubjectPrefix + clientID
+	if subject.UserID != 0 {
+		sub = strconv.Itoa(subject.UserID)
+	}
+
+	now := time.Now()
+	claims := &OAuthAccessClaims{
+		ClientID:     clientID,
+		Scope:        strings.Join(scopes, " "),
+		Username:     subject.Username,
+		TokenVersion: subject.TokenVersion,
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    tokenIssuer,
+			Audience:  oauthAudience,
+			Subject:   sub,
+			IssuedAt:  now.Unix(),
+			NotBefore: now.Unix(),
+			ExpiresAt: now.Add(ttl).Unix(),
+			Id:        NewTokenID(),
+		},
+	}
+
+	token, err := SignClaims(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, claims, nil
+}
+
+// ParseOAuthAccessToken verifica a assinatura e os claims de um token de acesso OAuth2.
+//
+// Retorna:
+//   - *OAuthAccessClaims: claims do token válido.
+//   - error: erro se o token for inválido ou expirado.
+func ParseOAuthAccessToken(tokenString string) (*OAuthAccessClaims, error) {
+	claims := &OAuthAccessClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, keyFunc)
+	if err != nil || !token.Valid {
+		logger.Debug("Token OAuth2 inválido ou expirado: %v", err)
+		return nil, errors.New("token inválido ou expirado")
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,186 @@
+// pwd: /app/server/modules/login/auth_utils/password_hash.go
+
+package auth_utils
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"api/logger"
+	"api/utils"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Algoritmos de hash de senha suportados (PASSWORD_HASH_ALGORITHM)
+const (
+	PasswordHashBcrypt   = "bcrypt"
+	PasswordHashArgon2id = "argon2id"
+)
+
+// argon2Params define os parâmetros do argon2id gravados no hash (formato PHC)
+type argon2Params struct {
+	memory      uint32 // KiB
+	iterations  uint32
+	parallelism uint8
+}
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+var (
+	passwordHashAlgorithm = loadPasswordHashAlgorithm()
+	bcryptCost            = loadBcryptCost()
+	argon2Config          = loadArgon2Params()
+
+	// Hash fictício comparado quando o usuário não existe, para que o tempo de resposta não revele a existência da conta
+	dummyPasswordHash, _ = HashPassword("dummy-password")
+)
+
+// loadPasswordHashAlgorithm lê PASSWORD_HASH_ALGORITHM (padrão: bcrypt).
+func loadPasswordHashAlgorithm() string {
+	algorithm := strings.ToLower(utils.GetEnv("PASSWORD_HASH_ALGORITHM"))
+	switch algorithm {
+	case "":
+		return PasswordHashBcrypt
+	case PasswordHashBcrypt, PasswordHashArgon2id:
+		return algorithm
+	}
+	logger.Warn("Valor inválido para PASSWORD_HASH_ALGORITHM: %q. Usando bcrypt", algorithm)
+	return PasswordHashBcrypt
+}
+
+// loadBcryptCost lê BCRYPT_COST (padrão: 10), limitado ao intervalo aceito pelo bcrypt.
+func loadBcryptCost() int {
+	cost := utils.GetEnvInt("BCRYPT_COST", bcrypt.DefaultCost)
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		logger.Warn("BCRYPT_COST fora do intervalo %d-%d: %d. Usando %d", bcrypt.MinCost, bcrypt.MaxCost, cost, bcrypt.DefaultCost)
+		return bcrypt.DefaultCost
+	}
+	return cost
+}
+
+// loadArgon2Params lê ARGON2_MEMORY (KiB, padrão: 65536), ARGON2_ITERATIONS (padrão: 3) e ARGON2_PARALLELISM (padrão: 2).
+func loadArgon2Params() argon2Params {
+	memory := utils.GetEnvInt("ARGON2_MEMORY", 64*1024)
+	iterations := utils.GetEnvInt("ARGON2_ITERATIONS", 3)
+	parallelism := utils.GetEnvInt("ARGON2_PARALLELISM", 2)
+	if iterations < 1 || parallelism < 1 || parallelism > 255 || memory < 8*parallelism {
+		logger.Warn("Parâmetros ARGON2_* inválidos (m=%d, t=%d, p=%d). Usando m=65536, t=3, p=2", memory, iterations, parallelism)
+		return argon2Params{memory: 64 * 1024, iterations: 3, parallelism: 2}
+	}
+	return argon2Params{memory: uint32(memory), iterations: uint32(iterations), parallelism: uint8(parallelism)}
+}
+
+// HashPassword gera o hash da senha com o algoritmo configurado.
+//
+// Parâmetros:
+//   - password (string): A senha em texto puro.
+//
+// Retorna:
+//   - string: hash da senha (bcrypt "$2a$..." ou argon2id no formato PHC "$argon2id$v=19$m=...,t=...,p=...$salt$hash").
+//   - error: erro em caso de falha na geração do hash.
+func HashPassword(password string) (string, error) {
+	if passwordHashAlgorithm == PasswordHashArgon2id {
+		salt := make([]byte, argon2SaltLength)
+		if _, err := rand.Read(salt); err != nil {
+			logger.Error("Erro ao gerar salt da senha: %v", err)
+			return "", errors.New("erro ao processar senha")
+		}
+		p := argon2Config
+		key := argon2.IDKey([]byte(password), salt, p.iterations, p.memory, p.parallelism, argon2KeyLength)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.memory, p.iterations, p.parallelism,
+			base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+	if err != nil {
+		logger.Error("Erro ao gerar hash da senha: %v", err)
+		return "", errors.New("erro ao processar senha")
+	}
+	return string(hash), nil
+}
+
+// VerifyPassword compara a senha com o hash armazenado (bcrypt ou argon2id).
+//
+// Parâmetros:
+//   - password (string): A senha informada.
+//   - hash (string): O hash armazenado.
+//
+// Retorna:
+//   - bool: verdadeiro se a senha corresponder ao hash.
+func VerifyPassword(password, hash string) bool {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		p, salt, key, err := decodeArgon2Hash(hash)
+		if err != nil {
+			logger.Error("Hash argon2id inválido: %v", err)
+			return false
+		}
+		candidate := argon2.IDKey([]byte(password), salt, p.iterations, p.memory, p.parallelism, uint32(len(key)))
+		return subtle.ConstantTimeCompare(candidate, key) == 1
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// CompareDummyPassword gasta o mesmo tempo de uma verificação de senha, para logins de usuários inexistentes.
+func CompareDummyPassword(password string) {
+	_ = VerifyPassword(password, dummyPasswordHash)
+}
+
+// PasswordNeedsRehash informa se o hash foi gerado com outro algoritmo ou com parâmetros diferentes dos configurados
+// (ex: após trocar PASSWORD_HASH_ALGORITHM ou aumentar BCRYPT_COST).
+//
+// Parâmetros:
+//   - hash (string): O hash armazenado.
+//
+// Retorna:
+//   - bool: verdadeiro se a senha deve ser refeita com a configuração atual no próximo login.
+func PasswordNeedsRehash(hash string) bool {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		if passwordHashAlgorithm != PasswordHashArgon2id {
+			return true
+		}
+		p, _, _, err := decodeArgon2Hash(hash)
+		return err != nil || p != argon2Config
+	}
+
+	if passwordHashAlgorithm != PasswordHashBcrypt {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != bcryptCost
+}
+
+// decodeArgon2Hash lê os parâmetros, o salt e a chave de um hash argon2id no formato PHC.
+func decodeArgon2Hash(hash string) (argon2Params, []byte, []byte, error) {
+	var p argon2Params
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return p, nil, nil, errors.New("formato inválido")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, errors.New("versão incompatível")
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.iterations, &p.parallelism); err != nil || p.iterations < 1 || p.parallelism < 1 {
+		return p, nil, nil, errors.New("parâmetros inválidos")
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, errors.New("salt inválido")
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return p, nil, nil, errors.New("hash inválido")
+	}
+	return p, salt, key, nil
+}
@@ -0,0 +1,102 @@
+// pwd: /app/server/modules/login/auth_utils/secret_box.go
+
+package auth_utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"sync"
+
+	"api/logger"
+	"api/utils"
+)
+
+// ErrEncryptionKeyMissing indica que TWO_FACTOR_ENCRYPTION_KEY não está configurada ou é inválida.
+var ErrEncryptionKeyMissing = errors.New("chave de criptografia não configurada")
+
+var (
+	encryptionKeyOnce sync.Once
+	encryptionKey     []byte
+)
+
+// secretKey lê a chave AES-256 de TWO_FACTOR_ENCRYPTION_KEY (32 bytes em base64).
+func secretKey() ([]byte, error) {
+	encryptionKeyOnce.Do(func() {
+		value := utils.GetEnv("TWO_FACTOR_ENCRYPTION_KEY")
+		if value == "" {
+			return
+		}
+		key, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(key) != 32 {
+			logger.Error("TWO_FACTOR_ENCRYPTION_KEY inválida: informe 32 bytes em base64 (ex: openssl rand -base64 32)")
+			return
+		}
+		encryptionKey = key
+	})
+
+	if encryptionKey == nil {
+		return nil, ErrEncryptionKeyMissing
+	}
+	return encryptionKey, nil
+}
+
+// EncryptSecret criptografa um segredo com AES-256-GCM.
+//
+// Retorna:
+//   - string: nonce e texto cifrado, em base64.
+//   - error: ErrEncryptionKeyMissing ou erro de criptografia.
+func EncryptSecret(plaintext string) (string, error) {
+	gcm, err := secretCipher()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptSecret descriptografa um segredo gerado por EncryptSecret.
+//
+// Retorna:
+//   - string: segredo original.
+//   - error: ErrEncryptionKeyMissing ou erro se o valor for inválido ou tiver sido adulterado.
+func DecryptSecret(encoded string) (string, error) {
+	gcm, err := secretCipher()
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < gcm.NonceSize() {
+		return "", errors.New("segredo criptografado inválido")
+	}
+
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", errors.New("segredo criptografado inválido")
+	}
+	return string(plaintext), nil
+}
+
+// secretCipher cria o AES-GCM com a chave configurada.
+func secretCipher() (cipher.AEAD, error) {
+	key, err := secretKey()
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
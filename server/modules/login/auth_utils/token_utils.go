@@ -0,0 +1,46 @@
+// pwd: /app/server/modules/login/auth_utils/token_utils.go
+
+package auth_utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"api/logger"
+	"api/utils"
+)
+
+// GenerateOpaqueToken gera um token aleatório e opaco (256 bits, base64url sem padding).
+//
+// Retorna:
+//   - string: token gerado.
+//   - error: erro se o gerador de números aleatórios falhar.
+func GenerateOpaqueToken() (string, error) {
+	buffer := make([]byte, 32)
+	if _, err := rand.Read(buffer); err != nil {
+		logger.Error("Erro ao gerar token aleatório: %v", err)
+		return "", errors.New("erro ao gerar token")
+	}
+	return base64.RawURLEncoding.EncodeToString(buffer), nil
+}
+
+// HashToken calcula o hash SHA-256 (hexadecimal) de um token opaco, para armazenamento no banco.
+//
+// Retorna:
+//   - string: hash do token.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GetRefreshExpirationTime recupera o tempo de expiração do refresh token da variável JWT_REFRESH_EXPIRE.
+//
+// Retorna:
+//   - time.Duration: duração configurada (padrão: 720 horas).
+func GetRefreshExpirationTime() time.Duration {
+	return utils.GetEnvDuration("JWT_REFRESH_EXPIRE", 720*time.Hour)
+}
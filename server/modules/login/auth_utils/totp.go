@@ -0,0 +1,113 @@
+// pwd: /app/server/modules/login/auth_utils/totp.go
+
+package auth_utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Parâmetros do TOTP (RFC 6238), compatíveis com os aplicativos autenticadores mais comuns.
+const (
+	totpPeriod = 30 // segundos por passo
+	totpDigits = 6
+	totpSkew   = 1 // passos aceitos antes e depois do atual, para tolerar diferenças de relógio
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret gera um segredo TOTP aleatório de 160 bits, codificado em base32 sem padding.
+//
+// Retorna:
+//   - string: segredo gerado.
+//   - error: erro se o gerador de números aleatórios falhar.
+func GenerateTOTPSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(b), nil
+}
+
+// TOTPProvisioningURI monta a URI otpauth:// usada para gerar o QR code lido pelos aplicativos autenticadores.
+//
+// Parâmetros:
+//   - issuer (string): Nome do serviço exibido no aplicativo.
+//   - account (string): Conta do usuário (ex: username ou e-mail).
+//   - secret (string): Segredo TOTP em base32.
+//
+// Retorna:
+//   - string: URI no formato otpauth://totp/<issuer>:<account>?secret=...
+func TOTPProvisioningURI(issuer, account, secret string) string {
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(totpDigits))
+	params.Set("period", fmt.Sprint(totpPeriod))
+
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	// Alguns aplicativos exibem o "+" literalmente, por isso os espaços são codificados como %20
+	return "otpauth://totp/" + label + "?" + strings.ReplaceAll(params.Encode(), "+", "%20")
+}
+
+// ValidateTOTP verifica um código TOTP, aceitando totpSkew passos de diferença.
+//
+// Parâmetros:
+//   - secret (string): Segredo TOTP em base32.
+//   - code (string): Código informado pelo usuário.
+//   - at (time.Time): Momento da verificação.
+//   - lastStep (int64): Último passo já utilizado; códigos de passos iguais ou anteriores são recusados (anti-replay).
+//
+// Retorna:
+//   - int64: passo do código aceito, a ser gravado como novo lastStep.
+//   - bool: true se o código for válido.
+func ValidateTOTP(secret, code string, at time.Time, lastStep int64) (int64, bool) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != totpDigits {
+		return 0, false
+	}
+
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return 0, false
+	}
+
+	current := at.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= lastStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// totpCode calcula o código HOTP (RFC 4226) do passo informado.
+func totpCode(key []byte, step int64) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, value%mod)
+}
@@ -0,0 +1,75 @@
+// pwd: /app/server/modules/login/auth_utils/two_factor_token.go
+
+package auth_utils
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"api/logger"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// Os tokens de desafio do 2FA usam uma audiência própria, para que nunca sejam aceitos como tokens de acesso.
+var twoFactorAudience = tokenAudience + ":2fa_challenge"
+
+// TwoFactorChallengeClaims define os claims do token de desafio devolvido pela primeira etapa do login.
+type TwoFactorChallengeClaims struct {
+	Device string `json:"device,omitempty"`
+	jwt.StandardClaims
+}
+
+// Valid valida os claims padrão do token de desafio.
+func (c TwoFactorChallengeClaims) Valid() error {
+	return ValidateStandardClaims(c.StandardClaims, twoFactorAudience)
+}
+
+// UserID retorna o ID do usuário gravado no claim sub.
+func (c TwoFactorChallengeClaims) UserID() (int, error) {
+	return strconv.Atoi(c.Subject)
+}
+
+// GenerateTwoFactorChallenge gera o token de desafio que comprova a etapa de senha do login.
+//
+// Parâmetros:
+//   - userID (int): ID do usuário que informou a senha correta.
+//   - device (string): Nome do dispositivo informado no login, repassado à sessão criada na segunda etapa.
+//   - ttl (time.Duration): Validade do desafio.
+//
+// Retorna:
+//   - string: token assinado.
+//   - error: erro em caso de falha na assinatura.
+func GenerateTwoFactorChallenge(userID int, device string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := TwoFactorChallengeClaims{
+		Device: device,
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    tokenIssuer,
+			Audience:  twoFactorAudience,
+			Subject:   strconv.Itoa(userID),
+			IssuedAt:  now.Unix(),
+			NotBefore: now.Unix(),
+			ExpiresAt: now.Add(ttl).Unix(),
+			Id:        NewTokenID(),
+		},
+	}
+	return SignClaims(claims)
+}
+
+// ParseTwoFactorChallenge verifica a assinatura e os claims de um token de desafio.
+//
+// Retorna:
+//   - *TwoFactorChallengeClaims: claims do token válido.
+//   - error: erro se o token for inválido ou expirado.
+func ParseTwoFactorChallenge(tokenString string) (*TwoFactorChallengeClaims, error) {
+	claims := &TwoFactorChallengeClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, keyFunc)
+	if err != nil || !token.Valid {
+		logger.Warn("Token de desafio 2FA inválido ou expirado: %v", err)
+		return nil, errors.New("token inválido ou expirado")
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,161 @@
+// pwd: /app/server/modules/login/controllers/api_keys_controller.go
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"api/server/modules/login/services"
+	"api/utils/netutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateAPIKeyRequest representa os dados recebidos para criar uma chave de API
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"` // Opcional (RFC 3339); padrão: API_KEY_DEFAULT_TTL
+}
+
+// ListMyAPIKeys lista as chaves de API pessoais do usuário autenticado.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: `{ "api_keys": [...] }` (sem os segredos).
+// - 500 Internal Server Error: Se ocorrer um erro na consulta.
+func ListMyAPIKeys(c *gin.Context) {
+	keys, err := services.ListUserAPIKeys(c.GetInt("user_id"))
+	if err != nil {
+		respondAPIKeyError(c, err, "Erro ao listar chaves de API")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+// CreateMyAPIKey cria uma chave de API pessoal para o usuário autenticado.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 201 Created: Retorna a chave completa (exibida uma única vez) e os seus dados.
+// - 400 Bad Request: Se o nome, os escopos ou a expiração forem inválidos.
+func CreateMyAPIKey(c *gin.Context) {
+	userID := c.GetInt("user_id")
+	createAPIKey(c, userID, userID)
+}
+
+// RevokeMyAPIKey revoga uma chave de API pessoal do usuário autenticado.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Se a chave foi revogada.
+// - 400 Bad Request: Se o ID for inválido.
+// - 404 Not Found: Se a chave não existir, já estiver revogada ou pertencer a outro usuário.
+func RevokeMyAPIKey(c *gin.Context) {
+	userID := c.GetInt("user_id")
+	revokeAPIKey(c, userID, userID)
+}
+
+// ListServiceAPIKeys lista as chaves de API de serviço.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: `{ "api_keys": [...] }` (sem os segredos).
+// - 500 Internal Server Error: Se ocorrer um erro na consulta.
+func ListServiceAPIKeys(c *gin.Context) {
+	keys, err := services.ListServiceAPIKeys()
+	if err != nil {
+		respondAPIKeyError(c, err, "Erro ao listar chaves de API")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+// CreateServiceAPIKey cria uma chave de API de serviço (sem dono), para integrações entre sistemas.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 201 Created: Retorna a chave completa (exibida uma única vez) e os seus dados.
+// - 400 Bad Request: Se o nome, os escopos ou a expiração forem inválidos.
+func CreateServiceAPIKey(c *gin.Context) {
+	createAPIKey(c, 0, c.GetInt("user_id"))
+}
+
+// RevokeAnyAPIKey revoga qualquer chave de API, pessoal ou de serviço (ex: uma chave vazada).
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Se a chave foi revogada.
+// - 400 Bad Request: Se o ID for inválido.
+// - 404 Not Found: Se a chave não existir ou já estiver revogada.
+func RevokeAnyAPIKey(c *gin.Context) {
+	revokeAPIKey(c, 0, c.GetInt("user_id"))
+}
+
+// createAPIKey cria uma chave pessoal (ownerID) ou de serviço (ownerID 0) e responde com a chave completa.
+func createAPIKey(c *gin.Context, ownerID, actorID int) {
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Requisição inválida"})
+		return
+	}
+
+	rawKey, key, err := services.CreateAPIKey(ownerID, actorID, req.Name, req.Scopes, req.ExpiresAt, netutil.ClientIP(c))
+	if err != nil {
+		respondAPIKeyError(c, err, "Erro ao criar chave de API")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Chave de API criada. Guarde-a em local seguro: ela não será exibida novamente",
+		"key":     rawKey,
+		"api_key": key,
+	})
+}
+
+// revokeAPIKey revoga a chave informada na rota, exigindo que pertença a ownerID (0 para qualquer chave).
+func revokeAPIKey(c *gin.Context, ownerID, actorID int) {
+	keyID, err := strconv.ParseInt(c.Param("key_id"), 10, 64)
+	if err != nil || keyID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de chave de API inválido"})
+		return
+	}
+
+	if err := services.RevokeAPIKey(keyID, ownerID, actorID, netutil.ClientIP(c)); err != nil {
+		respondAPIKeyError(c, err, "Erro ao revogar chave de API")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Chave de API revogada"})
+}
+
+// respondAPIKeyError converte os erros das chaves de API na resposta HTTP correspondente.
+func respondAPIKeyError(c *gin.Context, err error, fallback string) {
+	switch err {
+	case services.ErrAPIKeyNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Chave de API não encontrada"})
+	case services.ErrInvalidAPIKeyName:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Informe um nome de até 100 caracteres"})
+	case services.ErrInvalidScopes:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Escopos inválidos: informe ao menos um e apenas permissões que você possui"})
+	case services.ErrInvalidExpiration:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Data de expiração inválida ou acima do limite permitido"})
+	default:
+		respondUserError(c, err, fallback)
+	}
+}
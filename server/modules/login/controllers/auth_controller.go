@@ -3,25 +3,34 @@
 package controllers
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"api/logger"
 	"api/server/modules/login/auth_utils"
 	"api/server/modules/login/models"
+	"api/server/modules/login/services"
+	"api/utils/netutil"
 
 	"github.com/gin-gonic/gin"
 )
 
-// AuthenticateUser autentica um usuário com base nos dados fornecidos na requisição JSON e retorna um token JWT.
+// AuthenticateUser autentica um usuário pelo username ou e-mail e pela senha, e retorna um token JWT.
+// A senha é validada pelos backends de AUTH_BACKENDS (banco local e/ou LDAP).
 //
 // Parâmetros:
 // - c: *gin.Context - Contexto da requisição.
 //
 // Respostas:
-// - 200 OK: Retorna o token JWT, informações do usuário e tempo restante até a expiração.
+// - 200 OK: Retorna o token JWT, o refresh token, informações do usuário e tempo restante até a expiração.
+// Para contas com 2FA, retorna apenas o token de desafio ("two_factor_required": true), a ser concluído em /auth/login/2fa.
 // - 400 Bad Request: Se os dados da requisição estiverem inválidos.
 // - 401 Unauthorized: Se as credenciais forem inválidas.
+// - 403 Forbidden: Se EMAIL_VERIFICATION_POLICY=block e o e-mail do usuário não estiver verificado.
+// - 429 Too Many Requests: Se o login estiver temporariamente bloqueado por excesso de falhas.
 func AuthenticateUser(c *gin.Context) {
 	var loginData models.LoginRequest
 	if err := c.ShouldBindJSON(&loginData); err != nil {
@@ -29,74 +38,120 @@ func AuthenticateUser(c *gin.Context) {
 		return
 	}
 
-	authResponse, err := models.Authenticate(loginData)
+	result, err := services.Login(loginData, c.Request.UserAgent(), netutil.ClientIP(c))
 	if err != nil {
-		logger.Warn("Tentativa de login falhou para o usuário %s", loginData.Username)
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Credenciais inválidas"})
+		var locked *services.LoginLockedError
+		switch {
+		case errors.As(err, &locked):
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Muitas tentativas de login. Tente novamente mais tarde."})
+		case err == services.ErrMissingCredentials:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Informe o usuário (ou e-mail) e a senha"})
+		case err == services.ErrInvalidCredentials:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Credenciais inválidas"})
+		case err == services.ErrEmailNotVerified:
+			c.JSON(http.StatusForbidden, gin.H{"error": "E-mail não verificado. Confirme o e-mail pelo link enviado ou solicite um novo"})
+		default:
+			logger.Error("Erro ao processar login: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao processar login"})
+		}
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"token": authResponse.Token,
+	respondLogin(c, result)
+}
+
+// respondLogin responde a um login concluído: com o desafio do 2FA ou com os tokens da sessão.
+//
+// É o único ponto que monta a resposta de login, usado também pelo 2FA, pelas passkeys, pelo OIDC e pelo refresh.
+func respondLogin(c *gin.Context, result *services.LoginResult) {
+	if result.ChallengeToken != "" {
+		c.JSON(http.StatusOK, gin.H{"two_factor_required": true, "challenge_token": result.ChallengeToken, "expires_in": int(result.ChallengeTTL.Seconds())})
+		return
+	}
+
+	response := gin.H{
+		"token":         result.Auth.Token,
+		"refresh_token": result.RefreshToken,
 		"user": gin.H{
-			"id":           authResponse.User.ID,
-			"username":     authResponse.User.Username,
-			"email":        authResponse.User.Email,
-			"access_level": authResponse.User.AccessLevel,
+			"id":           result.Auth.User.ID,
+			"username":     result.Auth.User.Username,
+			"email":        result.Auth.User.Email,
+			"roles":        result.Auth.User.Roles,
+			"access_level": result.Auth.User.AccessLevel,
 		},
-		"time_remaining": authResponse.TimeRemaining,
-	})
+		"time_remaining": result.Auth.TimeRemaining,
+	}
+	if result.TwoFactorSetupRequired {
+		response["two_factor_setup_required"] = true
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// beginLogin responde com o desafio do 2FA, para contas com 2FA, ou com os tokens de uma nova sessão.
+func beginLogin(c *gin.Context, user models.User, device, clientIP string) {
+	result, err := services.BeginLogin(user, c.Request.UserAgent(), device, clientIP)
+	if err != nil {
+		logger.Error("Erro ao processar login: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao processar login"})
+		return
+	}
+	respondLogin(c, result)
+}
+
+// completeLogin cria a sessão do usuário autenticado e responde com os tokens.
+func completeLogin(c *gin.Context, user models.User, device, clientIP string) {
+	result, err := services.CompleteLogin(user, c.Request.UserAgent(), device, clientIP)
+	if err != nil {
+		logger.Error("Erro ao iniciar sessão: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao processar login"})
+		return
+	}
+	respondLogin(c, result)
 }
 
 // IsLoggedIn verifica se o usuário está autenticado e retorna o tempo restante de expiração do token.
 //
+// O token (inclusive a revogação) já foi validado pelo AuthMiddleware.
+//
 // Parâmetros:
 // - c: *gin.Context - Contexto da requisição.
 //
 // Respostas:
-// - 200 OK: Retorna se o usuário está logado e o tempo restante do token.
-// - 401 Unauthorized: Se o token não for fornecido, for inválido ou estiver expirado.
+// - 200 OK: Retorna se o usuário está logado e o tempo restante do token (e o administrador, na personificação).
+// - 401 Unauthorized: Se o usuário não estiver autenticado.
 func IsLoggedIn(c *gin.Context) {
-	tokenString := c.GetHeader("Authorization")
-	if tokenString == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token JWT não fornecido"})
-		return
-	}
-
-	userID, exists := c.Get("user_id")
-	if !exists || userID == nil {
+	value, _ := c.Get("claims")
+	claims, ok := value.(*auth_utils.Claims)
+	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
 		return
 	}
 
-	if models.IsTokenBlacklisted(tokenString) {
-		logger.Warn("Tentativa de uso de token inválido")
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token inválido ou expirado"})
-		return
-	}
-
-	timeRemaining, err := auth_utils.CalculateTokenExpirationTime(tokenString)
+	timeRemaining := time.Until(time.Unix(claims.ExpiresAt, 0))
 	logger.Debug("Tempo restante até a expiração do token: %v", timeRemaining)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Erro ao calcular o tempo de expiração do token"})
-		return
-	}
 
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"logged_in":      true,
 		"time_remaining": timeRemaining.String(),
-	})
+	}
+	// Permite ao front-end exibir o aviso de personificação
+	if claims.Impersonating() {
+		response["impersonator"] = gin.H{"id": claims.Actor.ID, "username": claims.Actor.Username}
+	}
+	c.JSON(http.StatusOK, response)
 }
 
-// LogoutUser realiza o logout do usuário, invalidando o token JWT.
+// LogoutUser realiza o logout do usuário, invalidando o token JWT, a sua sessão e os refresh tokens da sessão.
 //
 // Parâmetros:
 // - c: *gin.Context - Contexto da requisição.
 //
 // Respostas:
 // - 200 OK: Se o logout for bem-sucedido.
-// - 400 Bad Request: Se o token não for fornecido ou houver erro ao processar o logout.
-// - 401 Unauthorized: Se o token for inválido ou já estiver na blacklist.
+// - 400 Bad Request: Se o token não for fornecido.
+// - 401 Unauthorized: Se o token for inválido ou já estiver revogado.
+// - 500 Internal Server Error: Se ocorrer um erro ao revogar o token.
 func LogoutUser(c *gin.Context) {
 	tokenString := c.GetHeader("Authorization")
 	if tokenString == "" {
@@ -104,29 +159,86 @@ func LogoutUser(c *gin.Context) {
 		return
 	}
 
-	if models.IsTokenBlacklisted(tokenString) {
+	claims, err := auth_utils.ParseToken(tokenString)
+	if err == nil {
+		err = services.CheckTokenRevocation(claims)
+	}
+	if err != nil {
 		logger.Warn("Tentativa de uso de token inválido")
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token inválido ou expirado"})
 		return
 	}
 
-	expirationDuration, err := auth_utils.CalculateTokenExpirationTime(tokenString)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Erro ao calcular expiração do token"})
+	// O refresh token é opcional no corpo da requisição; a sessão do token é encerrada mesmo sem ele
+	var req RefreshRequest
+	_ = c.ShouldBindJSON(&req)
+
+	if err := services.Logout(claims, req.RefreshToken); err != nil {
+		logger.Error("Erro ao revogar token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao processar logout"})
 		return
 	}
 
-	expirationTime := time.Now().Add(expirationDuration)
+	logger.Info("Usuário deslogado com sucesso")
+	c.JSON(http.StatusOK, gin.H{"message": "Logout realizado. Remova o token do cliente."})
+}
 
-	err = models.AddTokenToBlacklist(tokenString, expirationTime)
-	if err != nil {
-		logger.Error("Erro ao adicionar token à blacklist: %v", err)
+// LogoutAll encerra todas as sessões do usuário autenticado, revogando todos os seus tokens de acesso e refresh tokens.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Se todos os tokens foram revogados.
+// - 401 Unauthorized: Se o usuário não estiver autenticado.
+// - 500 Internal Server Error: Se ocorrer um erro ao revogar os tokens.
+func LogoutAll(c *gin.Context) {
+	userID := c.GetInt("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+		return
+	}
+
+	if err := services.RevokeAllUserTokens(userID, userID, netutil.ClientIP(c), "logout-all"); err != nil {
+		logger.Error("Erro ao revogar os tokens do usuário ID=%d: %v", userID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao processar logout"})
 		return
 	}
 
-	logger.Info("Usuário deslogado com sucesso")
-	c.JSON(http.StatusOK, gin.H{"message": "Logout realizado. Remova o token do cliente."})
+	c.JSON(http.StatusOK, gin.H{"message": "Todas as sessões foram encerradas."})
+}
+
+// RefreshRequest representa os dados recebidos para renovar os tokens
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshToken troca um refresh token válido por um novo access token e um novo refresh token.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Retorna o novo token JWT, o novo refresh token, informações do usuário e tempo restante.
+// - 400 Bad Request: Se o refresh token não for informado.
+// - 401 Unauthorized: Se o refresh token for inválido, expirado, revogado ou reutilizado.
+func RefreshToken(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.RefreshToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Refresh token não fornecido"})
+		return
+	}
+
+	authResponse, refreshToken, err := services.RefreshTokens(req.RefreshToken, netutil.ClientIP(c))
+	if err != nil {
+		if err != services.ErrInvalidRefreshToken && err != services.ErrRefreshTokenReused {
+			logger.Error("Erro ao renovar tokens: %v", err)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token inválido ou expirado"})
+		return
+	}
+
+	respondLogin(c, &services.LoginResult{Auth: authResponse, RefreshToken: refreshToken})
 }
 
 // AddNewUser cria um novo usuário no sistema com base nos dados fornecidos na requisição JSON.
@@ -136,7 +248,8 @@ func LogoutUser(c *gin.Context) {
 //
 // Respostas:
 // - 201 Created: Se o usuário for criado com sucesso.
-// - 400 Bad Request: Se os dados forem inválidos ou o usuário já existir.
+// - 400 Bad Request: Se os dados forem inválidos, a senha não atender à política de senhas ou o usuário já existir.
+// - 403 Forbidden: Se forem informados papéis e o usuário autenticado não puder atribuí-los.
 // - 500 Internal Server Error: Se ocorrer um erro ao criar o usuário.
 func AddNewUser(c *gin.Context) {
 	logger.Debug("Adicionando novo usuário")
@@ -148,27 +261,36 @@ func AddNewUser(c *gin.Context) {
 		return
 	}
 
-	var newUser models.User
-	if err := c.ShouldBindJSON(&newUser); err != nil {
+	// O campo Password de models.User não é lido do JSON, por isso a requisição tem estrutura própria
+	var req services.RegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		logger.Error("Erro ao validar dados do usuário: %v", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos"})
 		return
 	}
 
-	err := models.CheckUserExists(newUser.Username)
-	if err != nil {
-		logger.Warn("Usuário já existe")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Usuário já existe"})
+	// Atribuir papéis diferentes do padrão exige a permissão roles:assign
+	if !onlyDefaultRole(req.Roles) && !services.HasPermission(c.GetInt("user_id"), "roles:assign") {
+		logger.Warn("Tentativa de cadastro com papéis sem a permissão roles:assign")
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permissão insuficiente para atribuir papéis"})
 		return
 	}
 
-	err = models.CreateNewUser(newUser)
-	if err != nil {
-		logger.Error("Erro ao criar usuário: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar usuário"})
+	if _, err := services.Register(req, c.GetInt("user_id"), netutil.ClientIP(c)); err != nil {
+		respondUserError(c, err, "Erro ao criar usuário")
 		return
 	}
 
 	logger.Info("Usuário registrado com sucesso")
 	c.JSON(http.StatusCreated, gin.H{"message": "Usuário registrado com sucesso"})
 }
+
+// onlyDefaultRole informa se a lista de papéis está vazia ou contém apenas o papel padrão.
+func onlyDefaultRole(roles []string) bool {
+	for _, role := range roles {
+		if role != models.DefaultRole {
+			return false
+		}
+	}
+	return true
+}
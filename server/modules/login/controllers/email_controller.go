@@ -0,0 +1,72 @@
+// pwd: /app/server/modules/login/controllers/email_controller.go
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"api/logger"
+	"api/server/modules/login/services"
+	"api/utils/netutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// VerifyEmailRequest representa o token recebido para verificar o e-mail
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+// ResendVerificationRequest representa o e-mail informado para reenviar o link de verificação
+type ResendVerificationRequest struct {
+	Email string `json:"email"`
+}
+
+// VerifyEmail confirma o e-mail do usuário com o token recebido por e-mail.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Se o e-mail foi verificado (ou já estava verificado).
+// - 400 Bad Request: Se o token não for informado, for inválido, estiver expirado ou for de um e-mail que não é mais o atual.
+// - 500 Internal Server Error: Se ocorrer um erro ao verificar o e-mail.
+func VerifyEmail(c *gin.Context) {
+	var req VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Token) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Token não informado"})
+		return
+	}
+
+	if err := services.VerifyEmail(strings.TrimSpace(req.Token), netutil.ClientIP(c)); err != nil {
+		if err == services.ErrInvalidVerificationToken {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Token inválido ou expirado"})
+			return
+		}
+		logger.Error("Erro ao verificar e-mail: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao verificar e-mail"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "E-mail verificado"})
+}
+
+// ResendVerificationEmail reenvia o link de verificação para o e-mail informado, se houver conta não verificada com ele.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Sempre a mesma resposta, exista ou não uma conta com o e-mail informado.
+// - 400 Bad Request: Se o e-mail não for informado.
+func ResendVerificationEmail(c *gin.Context) {
+	var req ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Email) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "E-mail não informado"})
+		return
+	}
+
+	services.ResendVerificationEmail(req.Email)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Se houver uma conta não verificada com este e-mail, você receberá um novo link de verificação"})
+}
@@ -0,0 +1,82 @@
+// pwd: /app/server/modules/login/controllers/impersonation_controller.go
+package controllers
+
+import (
+	"net/http"
+
+	"api/logger"
+	"api/server/modules/login/auth_utils"
+	"api/server/modules/login/models"
+	"api/server/modules/login/services"
+	"api/utils/netutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ImpersonateRequest representa os dados (opcionais) recebidos para iniciar a personificação
+type ImpersonateRequest struct {
+	Reason string `json:"reason"` // Motivo registrado na auditoria (ex: número do chamado)
+}
+
+// ImpersonateUser emite um token de curta duração para o administrador acessar a API em nome de um usuário.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: `{ "token": "Bearer ...", "user": {...}, "impersonator": { "id": 1, "username": "..." }, "time_remaining": "15m0s" }`
+// - 400 Bad Request: Se o ID for inválido ou for o do próprio administrador.
+// - 403 Forbidden: Se o usuário tiver permissões que o administrador não tem, estiver desativado,
+// ou se o token já for de personificação.
+// - 404 Not Found: Se o usuário não existir.
+// - 500 Internal Server Error: Se o token não puder ser gerado.
+func ImpersonateUser(c *gin.Context) {
+	id, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	value, _ := c.Get("claims")
+	claims, ok := value.(*auth_utils.Claims)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+		return
+	}
+
+	// O motivo é opcional; um corpo vazio é aceito
+	var req ImpersonateRequest
+	_ = c.ShouldBindJSON(&req)
+
+	authResponse, err := services.Impersonate(claims, id, req.Reason, netutil.ClientIP(c))
+	if err != nil {
+		switch err {
+		case services.ErrImpersonationNested:
+			c.JSON(http.StatusForbidden, gin.H{"error": "Operação não permitida durante a personificação"})
+		case services.ErrImpersonationInactive:
+			c.JSON(http.StatusForbidden, gin.H{"error": "Não é permitido personificar um usuário desativado"})
+		case services.ErrImpersonationForbidden:
+			c.JSON(http.StatusForbidden, gin.H{"error": "O usuário possui permissões que você não possui"})
+		case services.ErrSelfManagement, models.ErrUserNotFound:
+			respondUserError(c, err, "Erro ao iniciar personificação")
+		default:
+			logger.Error("Erro ao iniciar personificação do usuário ID=%d: %v", id, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao iniciar personificação"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token": authResponse.Token,
+		"user": gin.H{
+			"id":       authResponse.User.ID,
+			"username": authResponse.User.Username,
+			"email":    authResponse.User.Email,
+			"roles":    authResponse.User.Roles,
+		},
+		"impersonator": gin.H{
+			"id":       claims.ID,
+			"username": claims.Username,
+		},
+		"time_remaining": authResponse.TimeRemaining,
+	})
+}
@@ -0,0 +1,27 @@
+// pwd: /app/server/modules/login/controllers/jwks_controller.go
+
+package controllers
+
+import (
+	"net/http"
+
+	"api/server/modules/login/auth_utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetJWKS publica as chaves públicas de verificação dos tokens (JSON Web Key Set).
+//
+// Outros serviços usam este endpoint para validar os tokens emitidos por esta API sem
+// conhecer nenhum segredo. Durante uma rotação, as chaves antigas continuam publicadas
+// até serem removidas de JWT_KEYS_DIR.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Retorna {"keys": [...]} (lista vazia no modo HS256).
+func GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{"keys": auth_utils.PublicJWKS()})
+}
@@ -0,0 +1,280 @@
+// pwd: /app/server/modules/login/controllers/oauth_controller.go
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"api/logger"
+	"api/server/modules/login/services"
+	"api/utils/netutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuthorizeOAuthRequest representa a decisão do usuário na tela de consentimento, com os parâmetros recebidos do cliente
+type AuthorizeOAuthRequest struct {
+	services.AuthorizationRequest
+	Approve bool `json:"approve"`
+}
+
+// GetOAuthAuthorization valida uma requisição de autorização OAuth2 e retorna os dados da tela de consentimento.
+// O front-end recebe os parâmetros do cliente na query e os repassa a este endpoint com a sessão do usuário.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: `{ "client_id": "...", "client_name": "...", "scopes": [...], "consent_required": true }`
+// - 400 Bad Request: Se o client_id ou a redirect_uri forem inválidos (o usuário não deve ser redirecionado).
+// - 400 Bad Request: Demais erros do protocolo, com `redirect_to` para devolver o erro ao cliente.
+func GetOAuthAuthorization(c *gin.Context) {
+	var req services.AuthorizationRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Parâmetros de autorização inválidos"})
+		return
+	}
+
+	info, err := services.AuthorizeInfo(c.GetInt("user_id"), req)
+	if err != nil {
+		respondOAuthError(c, err, "Erro ao processar a autorização")
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
+
+// ApproveOAuthAuthorization registra a decisão do usuário na tela de consentimento.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: `{ "redirect_to": "https://app/callback?code=...&state=..." }` (ou com error=access_denied se recusado).
+// - 400 Bad Request: Mesmos casos de GetOAuthAuthorization.
+func ApproveOAuthAuthorization(c *gin.Context) {
+	var req AuthorizeOAuthRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Parâmetros de autorização inválidos"})
+		return
+	}
+
+	redirectTo, err := services.Authorize(c.GetInt("user_id"), req.AuthorizationRequest, req.Approve, netutil.ClientIP(c))
+	if err != nil {
+		respondOAuthError(c, err, "Erro ao processar a autorização")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"redirect_to": redirectTo})
+}
+
+// IssueOAuthToken implementa o endpoint de token OAuth2 (application/x-www-form-urlencoded).
+// As credenciais do cliente são aceitas no cabeçalho "Authorization: Basic" ou nos campos client_id e client_secret.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: `{ "access_token": "...", "token_type": "Bearer", "expires_in": 900, "scope": "..." }`
+// - 400 Bad Request: `{ "error": "invalid_grant", "error_description": "..." }` (formato da RFC 6749).
+// - 401 Unauthorized: Se as credenciais do cliente forem inválidas (invalid_client).
+func IssueOAuthToken(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	var req services.TokenRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "Requisição inválida"})
+		return
+	}
+	req.ClientID, req.ClientSecret = oauthClientCredentials(c, req.ClientID, req.ClientSecret)
+
+	resp, err := services.ExchangeToken(req, netutil.ClientIP(c))
+	if err != nil {
+		respondOAuthProtocolError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// IntrospectOAuthToken implementa o endpoint de introspecção de tokens (RFC 7662), restrito a clientes confidenciais.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: `{ "active": true, "scope": "...", "client_id": "...", "sub": "...", "exp": ... }` ou `{ "active": false }`.
+// - 400 Bad Request: Se o token não for informado.
+// - 401 Unauthorized: Se as credenciais do cliente forem inválidas (invalid_client).
+func IntrospectOAuthToken(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+
+	token := c.PostForm("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "Informe o token"})
+		return
+	}
+	clientID, clientSecret := oauthClientCredentials(c, c.PostForm("client_id"), c.PostForm("client_secret"))
+
+	resp, err := services.IntrospectToken(clientID, clientSecret, token)
+	if err != nil {
+		respondOAuthProtocolError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// ListOAuthClients lista os clientes OAuth2 ativos.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: `{ "clients": [...] }` (sem os segredos).
+// - 500 Internal Server Error: Se ocorrer um erro na consulta.
+func ListOAuthClients(c *gin.Context) {
+	clients, err := services.ListOAuthClients()
+	if err != nil {
+		respondOAuthError(c, err, "Erro ao listar clientes OAuth2")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"clients": clients})
+}
+
+// CreateOAuthClient registra uma aplicação no servidor OAuth2.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 201 Created: Retorna o cliente e, nos clientes confidenciais, o client_secret (exibido uma única vez).
+// - 400 Bad Request: Se o nome, as redirect_uris, os grant_types ou os escopos forem inválidos.
+func CreateOAuthClient(c *gin.Context) {
+	var req services.OAuthClientInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos"})
+		return
+	}
+
+	secret, client, err := services.CreateOAuthClient(req, c.GetInt("user_id"), netutil.ClientIP(c))
+	if err != nil {
+		respondOAuthError(c, err, "Erro ao criar cliente OAuth2")
+		return
+	}
+
+	resp := gin.H{"message": "Cliente OAuth2 criado com sucesso", "client": client}
+	if secret != "" {
+		resp["client_secret"] = secret
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+// RevokeOAuthClient revoga um cliente OAuth2 e os consentimentos dados a ele.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Se o cliente foi revogado.
+// - 404 Not Found: Se o cliente não existir ou já estiver revogado.
+func RevokeOAuthClient(c *gin.Context) {
+	if err := services.RevokeOAuthClient(c.Param("client_id"), c.GetInt("user_id"), netutil.ClientIP(c)); err != nil {
+		respondOAuthError(c, err, "Erro ao revogar cliente OAuth2")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Cliente OAuth2 revogado"})
+}
+
+// ListMyOAuthConsents lista as aplicações autorizadas pelo usuário autenticado.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: `{ "consents": [...] }`
+// - 500 Internal Server Error: Se ocorrer um erro na consulta.
+func ListMyOAuthConsents(c *gin.Context) {
+	consents, err := services.ListOAuthConsents(c.GetInt("user_id"))
+	if err != nil {
+		respondOAuthError(c, err, "Erro ao listar aplicações autorizadas")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"consents": consents})
+}
+
+// RevokeMyOAuthConsent remove a autorização dada pelo usuário autenticado a uma aplicação.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Se a autorização foi removida.
+// - 404 Not Found: Se o usuário não houver autorizado a aplicação.
+func RevokeMyOAuthConsent(c *gin.Context) {
+	if err := services.RevokeOAuthConsent(c.GetInt("user_id"), c.Param("client_id"), netutil.ClientIP(c)); err != nil {
+		respondOAuthError(c, err, "Erro ao remover a autorização")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Autorização removida"})
+}
+
+// oauthClientCredentials retorna as credenciais do cabeçalho Basic, quando presente, ou as do corpo da requisição.
+func oauthClientCredentials(c *gin.Context, clientID, clientSecret string) (string, string) {
+	if username, password, ok := c.Request.BasicAuth(); ok {
+		return services.OAuthClientCredentials(username, password)
+	}
+	return clientID, clientSecret
+}
+
+// respondOAuthProtocolError responde aos endpoints de token e de introspecção no formato de erro da RFC 6749.
+func respondOAuthProtocolError(c *gin.Context, err error) {
+	var oauthErr *services.OAuthError
+	if !errors.As(err, &oauthErr) {
+		logger.Error("Erro no servidor OAuth2: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error", "error_description": "Erro interno"})
+		return
+	}
+
+	status := http.StatusBadRequest
+	if oauthErr.Code == "invalid_client" {
+		status = http.StatusUnauthorized
+		c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+	}
+	c.JSON(status, gin.H{"error": oauthErr.Code, "error_description": oauthErr.Description})
+}
+
+// respondOAuthError converte os erros da autorização e da administração OAuth2 na resposta HTTP correspondente.
+func respondOAuthError(c *gin.Context, err error, fallback string) {
+	var oauthErr *services.OAuthError
+	if errors.As(err, &oauthErr) {
+		resp := gin.H{"error": oauthErr.Description, "oauth_error": oauthErr.Code}
+		if oauthErr.RedirectTo != "" {
+			resp["redirect_to"] = oauthErr.RedirectTo
+		}
+		c.JSON(http.StatusBadRequest, resp)
+		return
+	}
+
+	switch err {
+	case services.ErrOAuthClientNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cliente OAuth2 não encontrado"})
+	case services.ErrOAuthConsentNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Aplicação não autorizada pelo usuário"})
+	case services.ErrInvalidOAuthClientName:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Informe um nome de até 100 caracteres"})
+	case services.ErrInvalidRedirectURI:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "redirect_uris inválidas: use URLs HTTPS (ou HTTP em localhost) sem fragmento"})
+	case services.ErrInvalidGrantTypes:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "grant_types inválidos: use authorization_code e/ou client_credentials (este apenas em clientes confidenciais)"})
+	case services.ErrInvalidScopes:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Escopos inválidos: informe ao menos um e apenas permissões que você possui"})
+	default:
+		respondUserError(c, err, fallback)
+	}
+}
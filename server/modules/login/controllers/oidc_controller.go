@@ -0,0 +1,149 @@
+// pwd: /app/server/modules/login/controllers/oidc_controller.go
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"api/server/modules/login/services"
+	"api/utils/netutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BeginOIDCLoginRequest representa os dados (opcionais) recebidos para iniciar um login por provedor externo
+type BeginOIDCLoginRequest struct {
+	Device string `json:"device"`
+}
+
+// FinishOIDCLoginRequest representa os parâmetros devolvidos pelo provedor à REDIRECT_URL
+type FinishOIDCLoginRequest struct {
+	State string `json:"state"`
+	Code  string `json:"code"`
+}
+
+// ListOIDCProviders lista os provedores externos de login configurados.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: `{ "providers": [ { "name": "google", "display_name": "Google" } ] }`
+func ListOIDCProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"providers": services.ListOIDCProviders()})
+}
+
+// BeginOIDCLogin inicia um login por provedor externo (OpenID Connect com PKCE).
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Retorna a URL de autorização do provedor, para onde o navegador deve ser redirecionado, e o state.
+// - 404 Not Found: Se o provedor não estiver configurado.
+// - 503 Service Unavailable: Se o provedor não responder à descoberta.
+func BeginOIDCLogin(c *gin.Context) {
+	var req BeginOIDCLoginRequest
+	_ = c.ShouldBindJSON(&req) // O corpo é opcional
+
+	authURL, state, err := services.BeginOIDCLogin(c.Param("provider"), req.Device)
+	if err != nil {
+		respondOIDCError(c, err, "Erro ao iniciar login externo")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"authorization_url": authURL, "state": state})
+}
+
+// FinishOIDCLogin conclui um login por provedor externo com o code e o state devolvidos à REDIRECT_URL.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Mesmo formato do login. Para contas com 2FA, retorna o token de desafio, a ser concluído em /auth/login/2fa.
+// - 400 Bad Request: Se a requisição for inválida.
+// - 401 Unauthorized: Se o state for inválido ou expirado, ou se o provedor recusar o code ou o ID token.
+// - 403 Forbidden: Se não houver conta local vinculada à identidade, ou se o e-mail não estiver verificado
+// (EMAIL_VERIFICATION_POLICY=block).
+func FinishOIDCLogin(c *gin.Context) {
+	var req FinishOIDCLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.State == "" || req.Code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Informe o state e o code devolvidos pelo provedor"})
+		return
+	}
+
+	clientIP := netutil.ClientIP(c)
+	user, device, err := services.FinishOIDCLogin(c.Param("provider"), req.State, req.Code, clientIP)
+	if err != nil {
+		respondOIDCError(c, err, "Erro ao processar login")
+		return
+	}
+
+	// O provedor substitui a senha, não o 2FA local
+	beginLogin(c, *user, device, clientIP)
+}
+
+// ListMyIdentities lista as identidades externas vinculadas ao usuário autenticado.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: `{ "identities": [...] }`
+// - 500 Internal Server Error: Se ocorrer um erro na consulta.
+func ListMyIdentities(c *gin.Context) {
+	identities, err := services.ListIdentities(c.GetInt("user_id"))
+	if err != nil {
+		respondOIDCError(c, err, "Erro ao listar identidades externas")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"identities": identities})
+}
+
+// DeleteMyIdentity desvincula uma identidade externa do usuário autenticado.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Se a identidade foi desvinculada.
+// - 400 Bad Request: Se o ID for inválido.
+// - 404 Not Found: Se a identidade não existir ou pertencer a outro usuário.
+func DeleteMyIdentity(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("identity_id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de identidade inválido"})
+		return
+	}
+
+	if err := services.UnlinkIdentity(c.GetInt("user_id"), id, netutil.ClientIP(c)); err != nil {
+		respondOIDCError(c, err, "Erro ao desvincular a identidade externa")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Identidade externa desvinculada"})
+}
+
+// respondOIDCError converte os erros do login externo na resposta HTTP correspondente.
+func respondOIDCError(c *gin.Context, err error, fallback string) {
+	switch err {
+	case services.ErrOIDCProviderNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Provedor de login não encontrado"})
+	case services.ErrOIDCUnavailable:
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Provedor de login indisponível. Tente novamente mais tarde"})
+	case services.ErrInvalidOIDCState:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login externo inválido ou expirado. Tente novamente"})
+	case services.ErrOIDCRejected:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login externo recusado"})
+	case services.ErrOIDCNoAccount:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Nenhuma conta vinculada a esta identidade. Procure o administrador"})
+	case services.ErrIdentityNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Identidade externa não encontrada"})
+	case services.ErrEmailNotVerified:
+		c.JSON(http.StatusForbidden, gin.H{"error": "E-mail não verificado. Confirme o e-mail pelo link enviado ou solicite um novo"})
+	default:
+		respondTwoFactorError(c, err, fallback)
+	}
+}
@@ -0,0 +1,78 @@
+// pwd: /app/server/modules/login/controllers/password_controller.go
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"api/logger"
+	"api/server/modules/login/services"
+	"api/utils/netutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ForgotPasswordRequest representa o e-mail informado para recuperar a senha
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+// ResetPasswordRequest representa os dados recebidos para redefinir a senha
+type ResetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+// ForgotPassword envia um link de redefinição de senha para o e-mail informado, se houver conta com ele.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Sempre a mesma resposta, exista ou não uma conta com o e-mail informado.
+// - 400 Bad Request: Se o e-mail não for informado.
+func ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Email) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "E-mail não informado"})
+		return
+	}
+
+	services.RequestPasswordReset(req.Email, netutil.ClientIP(c))
+
+	c.JSON(http.StatusOK, gin.H{"message": "Se houver uma conta com este e-mail, você receberá um link para redefinir a senha"})
+}
+
+// ResetPassword define uma nova senha com o token recebido por e-mail e encerra todas as sessões do usuário.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Se a senha foi redefinida.
+// - 400 Bad Request: Se o token for inválido, já tiver sido utilizado ou estiver expirado, ou se a nova senha não atender à política.
+// - 500 Internal Server Error: Se ocorrer um erro ao redefinir a senha.
+func ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Token) == "" || req.NewPassword == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Informe o token e a nova senha"})
+		return
+	}
+
+	if err := services.ResetPassword(strings.TrimSpace(req.Token), req.NewPassword, netutil.ClientIP(c)); err != nil {
+		var policyErr *services.PasswordPolicyError
+		switch {
+		case errors.As(err, &policyErr):
+			c.JSON(http.StatusBadRequest, gin.H{"error": policyErr.Reason})
+		case err == services.ErrInvalidResetToken:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Token inválido ou expirado"})
+		default:
+			logger.Error("Erro ao redefinir senha: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao redefinir senha"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Senha redefinida. Faça login com a nova senha"})
+}
@@ -0,0 +1,156 @@
+// pwd: /app/server/modules/login/controllers/profile_controller.go
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"api/server/modules/login/models"
+	"api/server/modules/login/services"
+	"api/utils/netutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UpdateProfileRequest representa as alterações no perfil do usuário autenticado (campos omitidos não são alterados)
+type UpdateProfileRequest struct {
+	Name  *string `json:"name"`
+	Email *string `json:"email"`
+}
+
+// ChangePasswordRequest representa os dados recebidos para trocar a senha
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// ConfirmEmailChangeRequest representa o token recebido para confirmar a troca de e-mail
+type ConfirmEmailChangeRequest struct {
+	Token string `json:"token"`
+}
+
+// GetMyProfile retorna o perfil do usuário autenticado.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Retorna o usuário (sem o hash da senha).
+// - 404 Not Found: Se o usuário não existir mais.
+// - 500 Internal Server Error: Se ocorrer um erro ao buscar o usuário.
+func GetMyProfile(c *gin.Context) {
+	user, err := models.GetUserByID(c.GetInt("user_id"))
+	if err != nil {
+		respondUserError(c, err, "Erro ao buscar perfil")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+// UpdateMyProfile altera o nome e/ou o e-mail do usuário autenticado.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Retorna o perfil atualizado. Se o e-mail foi alterado, "email_verification_pending" é true
+// e o novo endereço só passa a valer após a confirmação do link enviado a ele.
+// - 400 Bad Request: Se os dados forem inválidos ou o e-mail já estiver em uso.
+// - 500 Internal Server Error: Se ocorrer um erro ao atualizar o perfil.
+func UpdateMyProfile(c *gin.Context) {
+	var req UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Requisição inválida"})
+		return
+	}
+
+	user, pending, err := services.UpdateProfile(c.GetInt("user_id"), services.ProfileChanges{Name: req.Name, Email: req.Email}, netutil.ClientIP(c))
+	if err != nil {
+		respondUserError(c, err, "Erro ao atualizar perfil")
+		return
+	}
+
+	message := "Perfil atualizado"
+	if pending {
+		message = "Perfil atualizado. Confirme o novo e-mail pelo link enviado a ele"
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message, "user": user, "email_verification_pending": pending})
+}
+
+// ChangeMyPassword troca a senha do usuário autenticado, invalida os seus tokens e encerra as suas outras sessões.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Se a senha foi alterada. Retorna o novo access token da sessão atual (o token usado na requisição
+// deixa de valer) e a quantidade de outras sessões encerradas.
+// - 400 Bad Request: Se a requisição for inválida ou a nova senha não atender à política de senhas.
+// - 401 Unauthorized: Se a senha atual estiver incorreta.
+// - 409 Conflict: Se a senha for gerenciada pelo diretório LDAP.
+// - 429 Too Many Requests: Se houver tentativas demais com senha incorreta.
+// - 500 Internal Server Error: Se ocorrer um erro ao alterar a senha.
+func ChangeMyPassword(c *gin.Context) {
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.CurrentPassword == "" || req.NewPassword == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Informe a senha atual e a nova senha"})
+		return
+	}
+
+	authResponse, revoked, err := services.ChangePassword(c.GetInt("user_id"), currentSessionID(c), req.CurrentPassword, req.NewPassword, netutil.ClientIP(c))
+	if err != nil {
+		var policyErr *services.PasswordPolicyError
+		switch {
+		case errors.As(err, &policyErr):
+			c.JSON(http.StatusBadRequest, gin.H{"error": policyErr.Reason})
+		case err == services.ErrWrongPassword:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Senha atual incorreta"})
+		case err == services.ErrTooManyAttempts:
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Muitas tentativas. Tente novamente mais tarde"})
+		case err == services.ErrPasswordUnchanged:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A nova senha deve ser diferente da atual"})
+		case err == services.ErrPasswordManagedByDirectory:
+			c.JSON(http.StatusConflict, gin.H{"error": "A senha desta conta é gerenciada pelo diretório corporativo (LDAP / Active Directory)"})
+		default:
+			respondUserError(c, err, "Erro ao alterar senha")
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "Senha alterada",
+		"token":            authResponse.Token,
+		"time_remaining":   authResponse.TimeRemaining,
+		"revoked_sessions": revoked,
+	})
+}
+
+// ConfirmEmailChange conclui a troca de e-mail com o token enviado ao novo endereço.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Se o e-mail foi alterado.
+// - 400 Bad Request: Se o token for inválido, já tiver sido utilizado ou estiver expirado, ou se o e-mail já estiver em uso.
+// - 500 Internal Server Error: Se ocorrer um erro ao alterar o e-mail.
+func ConfirmEmailChange(c *gin.Context) {
+	var req ConfirmEmailChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Token) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Token não informado"})
+		return
+	}
+
+	if err := services.ConfirmEmailChange(strings.TrimSpace(req.Token), netutil.ClientIP(c)); err != nil {
+		if err == services.ErrInvalidEmailToken {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Token inválido ou expirado"})
+			return
+		}
+		respondUserError(c, err, "Erro ao confirmar a troca de e-mail")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "E-mail alterado"})
+}
@@ -0,0 +1,120 @@
+// pwd: /app/server/modules/login/controllers/roles_controller.go
+package controllers
+
+import (
+	"net/http"
+
+	"api/logger"
+	"api/server/modules/login/models"
+	"api/server/modules/login/services"
+	"api/utils/netutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RolesRequest representa os papéis atribuídos a um usuário
+type RolesRequest struct {
+	Roles []string `json:"roles" binding:"required"`
+}
+
+// ListRoles lista os papéis cadastrados e as permissões de cada um.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Retorna os papéis e as suas permissões.
+// - 500 Internal Server Error: Se ocorrer um erro ao listar os papéis.
+func ListRoles(c *gin.Context) {
+	roles, err := services.ListRoles()
+	if err != nil {
+		logger.Error("Erro ao listar papéis: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao listar papéis"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"roles": roles})
+}
+
+// SetUserRoles substitui os papéis de um usuário.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Se os papéis foram atualizados.
+// - 400 Bad Request: Se o ID do usuário ou os papéis forem inválidos.
+// - 403 Forbidden: Se os papéis concederem permissões que o administrador não possui.
+// - 404 Not Found: Se o usuário não existir.
+// - 500 Internal Server Error: Se ocorrer um erro ao atualizar os papéis.
+func SetUserRoles(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req RolesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Requisição inválida"})
+		return
+	}
+
+	if _, err := models.GetUserByID(userID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Usuário não encontrado"})
+		return
+	}
+
+	err := services.AssignRoles(userID, req.Roles, c.GetInt("user_id"), netutil.ClientIP(c))
+	if err == models.ErrUnknownRole {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Papel inexistente"})
+		return
+	}
+	if err == services.ErrRoleGrantForbidden {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permissão insuficiente para atribuir os papéis"})
+		return
+	}
+	if err != nil {
+		logger.Error("Erro ao atualizar os papéis do usuário ID=%d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar papéis"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Papéis atualizados", "roles": req.Roles})
+}
+
+// UpdateRoleRequest representa as alterações em um papel
+type UpdateRoleRequest struct {
+	Require2FA *bool `json:"require_2fa"`
+}
+
+// UpdateRole altera as configurações de um papel (atualmente, a exigência de 2FA).
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Se o papel foi atualizado.
+// - 400 Bad Request: Se a requisição for inválida.
+// - 404 Not Found: Se o papel não existir.
+// - 500 Internal Server Error: Se ocorrer um erro ao atualizar o papel.
+func UpdateRole(c *gin.Context) {
+	var req UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Require2FA == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Informe require_2fa"})
+		return
+	}
+
+	name := c.Param("name")
+	err := services.SetRoleRequire2FA(name, *req.Require2FA, c.GetInt("user_id"), netutil.ClientIP(c))
+	if err == models.ErrUnknownRole {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Papel inexistente"})
+		return
+	}
+	if err != nil {
+		logger.Error("Erro ao atualizar o papel %s: %v", name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar papel"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Papel atualizado", "role": name, "require_2fa": *req.Require2FA})
+}
@@ -0,0 +1,188 @@
+// pwd: /app/server/modules/login/controllers/sessions_controller.go
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"api/logger"
+	"api/server/modules/login/auth_utils"
+	"api/server/modules/login/models"
+	"api/server/modules/login/services"
+	"api/utils/netutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListMySessions lista as sessões ativas do usuário autenticado.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Retorna as sessões ativas, indicando a sessão atual com "current": true.
+// - 500 Internal Server Error: Se ocorrer um erro ao listar as sessões.
+func ListMySessions(c *gin.Context) {
+	listSessions(c, c.GetInt("user_id"), currentSessionID(c))
+}
+
+// RevokeMySession encerra uma sessão do usuário autenticado.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Se a sessão foi encerrada.
+// - 404 Not Found: Se a sessão não existir, já estiver encerrada ou pertencer a outro usuário.
+// - 500 Internal Server Error: Se ocorrer um erro ao encerrar a sessão.
+func RevokeMySession(c *gin.Context) {
+	userID := c.GetInt("user_id")
+	revokeSession(c, userID, userID)
+}
+
+// RevokeMyOtherSessions encerra todas as sessões do usuário autenticado, exceto a sessão atual.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Retorna a quantidade de sessões encerradas.
+// - 500 Internal Server Error: Se ocorrer um erro ao encerrar as sessões.
+func RevokeMyOtherSessions(c *gin.Context) {
+	userID := c.GetInt("user_id")
+
+	revoked, err := services.RevokeOtherSessions(userID, currentSessionID(c), userID, netutil.ClientIP(c))
+	if err != nil {
+		logger.Error("Erro ao encerrar as sessões do usuário ID=%d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao encerrar sessões"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Sessões encerradas", "revoked": revoked})
+}
+
+// ListUserSessions lista as sessões ativas de qualquer usuário (administração).
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Retorna as sessões ativas do usuário.
+// - 400 Bad Request: Se o ID do usuário for inválido.
+// - 500 Internal Server Error: Se ocorrer um erro ao listar as sessões.
+func ListUserSessions(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+	listSessions(c, userID, currentSessionID(c))
+}
+
+// RevokeUserSession encerra uma sessão de qualquer usuário (administração).
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Se a sessão foi encerrada.
+// - 400 Bad Request: Se o ID do usuário for inválido.
+// - 404 Not Found: Se a sessão não existir, já estiver encerrada ou pertencer a outro usuário.
+// - 500 Internal Server Error: Se ocorrer um erro ao encerrar a sessão.
+func RevokeUserSession(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+	revokeSession(c, userID, c.GetInt("user_id"))
+}
+
+// RevokeAllUserSessions encerra todas as sessões de qualquer usuário (administração).
+//
+// Todos os tokens de acesso e refresh tokens já emitidos para o usuário deixam de valer.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Se as sessões foram encerradas.
+// - 400 Bad Request: Se o ID do usuário for inválido.
+// - 500 Internal Server Error: Se ocorrer um erro ao encerrar as sessões.
+func RevokeAllUserSessions(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := services.RevokeAllUserTokens(userID, c.GetInt("user_id"), netutil.ClientIP(c), "admin_revoke_sessions"); err != nil {
+		logger.Error("Erro ao encerrar as sessões do usuário ID=%d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao encerrar sessões"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Todas as sessões do usuário foram encerradas."})
+}
+
+// listSessions responde com as sessões ativas do usuário, marcando a sessão atual.
+func listSessions(c *gin.Context, userID int, currentID string) {
+	sessions, err := services.ListSessions(userID)
+	if err != nil {
+		logger.Error("Erro ao listar as sessões do usuário ID=%d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao listar sessões"})
+		return
+	}
+
+	response := make([]gin.H, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, sessionResponse(session, session.ID == currentID))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": response})
+}
+
+// revokeSession encerra a sessão informada na rota (":session_id") e responde ao cliente.
+func revokeSession(c *gin.Context, userID, actorID int) {
+	err := services.RevokeSession(userID, c.Param("session_id"), actorID, netutil.ClientIP(c))
+	if err == services.ErrSessionNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sessão não encontrada"})
+		return
+	}
+	if err != nil {
+		logger.Error("Erro ao encerrar a sessão do usuário ID=%d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao encerrar sessão"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Sessão encerrada"})
+}
+
+// sessionResponse monta a representação de uma sessão na resposta.
+func sessionResponse(session models.Session, current bool) gin.H {
+	return gin.H{
+		"id":           session.ID,
+		"device":       session.Device,
+		"user_agent":   session.UserAgent,
+		"ip":           session.IP,
+		"created_at":   session.CreatedAt,
+		"last_seen_at": session.LastSeenAt,
+		"current":      current,
+	}
+}
+
+// currentSessionID retorna a sessão do token da requisição (claim sid), definida pelo AuthMiddleware.
+func currentSessionID(c *gin.Context) string {
+	value, _ := c.Get("claims")
+	if claims, ok := value.(*auth_utils.Claims); ok {
+		return claims.SessionID
+	}
+	return ""
+}
+
+// userIDParam lê o parâmetro ":id" da rota. Em caso de valor inválido, responde 400 e retorna false.
+func userIDParam(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de usuário inválido"})
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,229 @@
+// pwd: /app/server/modules/login/controllers/two_factor_controller.go
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"api/logger"
+	"api/server/modules/login/auth_utils"
+	"api/server/modules/login/models"
+	"api/server/modules/login/services"
+	"api/utils/netutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TwoFactorLoginRequest representa a segunda etapa do login
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code"` // Código TOTP ou de recuperação
+}
+
+// TwoFactorCodeRequest representa um código TOTP (ou de recuperação, quando aceito)
+type TwoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+// DisableTwoFactorRequest representa os dados recebidos para desativar o 2FA
+type DisableTwoFactorRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+// CompleteTwoFactorLogin conclui o login de uma conta com 2FA.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Mesmo formato do login.
+// - 400 Bad Request: Se a requisição for inválida.
+// - 401 Unauthorized: Se o desafio for inválido ou expirado, ou se o código estiver incorreto.
+// - 429 Too Many Requests: Se o login estiver temporariamente bloqueado por excesso de falhas.
+func CompleteTwoFactorLogin(c *gin.Context) {
+	var req TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.ChallengeToken == "" || strings.TrimSpace(req.Code) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Informe o token de desafio e o código"})
+		return
+	}
+
+	clientIP := netutil.ClientIP(c)
+	user, device, err := services.CompleteTwoFactorLogin(req.ChallengeToken, req.Code, clientIP)
+	if err != nil {
+		respondTwoFactorError(c, err, "Erro ao processar login")
+		return
+	}
+
+	completeLogin(c, *user, device, clientIP)
+}
+
+// GetTwoFactorStatus retorna a configuração de 2FA do usuário autenticado.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: `{ "enabled": true, "pending": false, "required": false, "recovery_codes_remaining": 10 }`
+// - 500 Internal Server Error: Se ocorrer um erro na consulta.
+func GetTwoFactorStatus(c *gin.Context) {
+	status, err := services.GetTwoFactorStatus(c.GetInt("user_id"))
+	if err != nil {
+		respondTwoFactorError(c, err, "Erro ao consultar 2FA")
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// SetupTwoFactor inicia o cadastro do TOTP do usuário autenticado.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Retorna o segredo e a URI otpauth:// (para o QR code). O 2FA só é ativado após a confirmação.
+// - 409 Conflict: Se o 2FA já estiver ativado.
+// - 500 Internal Server Error: Se ocorrer um erro ou a chave de criptografia não estiver configurada.
+func SetupTwoFactor(c *gin.Context) {
+	user, err := models.GetUserByID(c.GetInt("user_id"))
+	if err != nil {
+		respondUserError(c, err, "Erro ao iniciar o cadastro do 2FA")
+		return
+	}
+
+	secret, uri, err := services.BeginTOTPEnrollment(*user)
+	if err != nil {
+		respondTwoFactorError(c, err, "Erro ao iniciar o cadastro do 2FA")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"secret": secret, "otpauth_uri": uri})
+}
+
+// ConfirmTwoFactor ativa o 2FA com um código gerado pelo aplicativo autenticador.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Retorna os códigos de recuperação, exibidos uma única vez.
+// - 400 Bad Request: Se o código for inválido ou não houver cadastro pendente.
+// - 409 Conflict: Se o 2FA já estiver ativado.
+func ConfirmTwoFactor(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Code) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Código não informado"})
+		return
+	}
+
+	codes, err := services.ConfirmTOTPEnrollment(c.GetInt("user_id"), req.Code, netutil.ClientIP(c))
+	if err != nil {
+		respondTwoFactorError(c, err, "Erro ao confirmar o 2FA")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "2FA ativado. Guarde os códigos de recuperação em local seguro", "recovery_codes": codes})
+}
+
+// DisableTwoFactor desativa o 2FA do usuário autenticado.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Se o 2FA foi desativado.
+// - 400 Bad Request: Se a requisição for inválida ou o 2FA não estiver ativado.
+// - 401 Unauthorized: Se a senha ou o código estiverem incorretos.
+// - 403 Forbidden: Se algum papel do usuário exigir 2FA.
+// - 429 Too Many Requests: Se houver tentativas demais com senha ou código incorretos.
+func DisableTwoFactor(c *gin.Context) {
+	var req DisableTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Password == "" || strings.TrimSpace(req.Code) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Informe a senha e o código"})
+		return
+	}
+
+	if err := services.DisableTwoFactor(c.GetInt("user_id"), req.Password, req.Code, netutil.ClientIP(c)); err != nil {
+		respondTwoFactorError(c, err, "Erro ao desativar o 2FA")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "2FA desativado"})
+}
+
+// RegenerateRecoveryCodes gera novos códigos de recuperação para o usuário autenticado.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Retorna os novos códigos; os anteriores deixam de valer.
+// - 400 Bad Request: Se a requisição for inválida ou o 2FA não estiver ativado.
+// - 401 Unauthorized: Se o código TOTP estiver incorreto.
+// - 429 Too Many Requests: Se houver tentativas demais com código incorreto.
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Code) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Código não informado"})
+		return
+	}
+
+	codes, err := services.RegenerateRecoveryCodes(c.GetInt("user_id"), req.Code, netutil.ClientIP(c))
+	if err != nil {
+		respondTwoFactorError(c, err, "Erro ao gerar códigos de recuperação")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// ResetUserTwoFactor remove o 2FA de um usuário (ex: perda do aplicativo e dos códigos de recuperação).
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Se o 2FA foi removido.
+// - 400 Bad Request: Se o ID for inválido ou o usuário não tiver 2FA.
+// - 404 Not Found: Se o usuário não existir.
+func ResetUserTwoFactor(c *gin.Context) {
+	id, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := services.ResetTwoFactor(id, c.GetInt("user_id"), netutil.ClientIP(c)); err != nil {
+		respondTwoFactorError(c, err, "Erro ao remover o 2FA")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "2FA removido"})
+}
+
+// respondTwoFactorError converte os erros do 2FA na resposta HTTP correspondente.
+func respondTwoFactorError(c *gin.Context, err error, fallback string) {
+	switch err {
+	case services.ErrInvalidChallenge:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Desafio de login inválido ou expirado. Faça login novamente"})
+	case services.ErrInvalidTwoFactorCode:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Código de verificação inválido"})
+	case services.ErrWrongPassword:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Senha incorreta"})
+	case services.ErrTooManyAttempts:
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Muitas tentativas. Tente novamente mais tarde"})
+	case services.ErrTwoFactorAlreadyEnabled:
+		c.JSON(http.StatusConflict, gin.H{"error": "2FA já está ativado"})
+	case services.ErrTwoFactorNotPending:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nenhum cadastro de 2FA pendente. Inicie o cadastro novamente"})
+	case services.ErrTwoFactorNotEnabled:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "2FA não está ativado"})
+	case services.ErrTwoFactorRequired:
+		c.JSON(http.StatusForbidden, gin.H{"error": "2FA é obrigatório para um dos seus papéis"})
+	case auth_utils.ErrEncryptionKeyMissing:
+		logger.Error("%s: TWO_FACTOR_ENCRYPTION_KEY não configurada", fallback)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "2FA não está disponível neste servidor"})
+	default:
+		respondUserError(c, err, fallback)
+	}
+}
@@ -1,2 +1,271 @@
 // pwd: /app/server/modules/login/controllers/users_controller.go
 package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"api/logger"
+	"api/server/modules/login/models"
+	"api/server/modules/login/services"
+	"api/utils/netutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UnlockRequest representa os dados recebidos para desbloquear o login
+type UnlockRequest struct {
+	Username string `json:"username"`
+	IP       string `json:"ip"`
+}
+
+// UnlockUser remove o bloqueio de login de um usuário e/ou de um IP.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Se o bloqueio foi removido (ou não havia bloqueio).
+// - 400 Bad Request: Se nem o usuário nem o IP forem informados.
+// - 500 Internal Server Error: Se ocorrer um erro ao remover o bloqueio.
+func UnlockUser(c *gin.Context) {
+	var req UnlockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Requisição inválida"})
+		return
+	}
+
+	req.Username = strings.TrimSpace(req.Username)
+	req.IP = strings.TrimSpace(req.IP)
+	if req.Username == "" && req.IP == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Informe o usuário ou o IP a desbloquear"})
+		return
+	}
+
+	actorID, _ := c.Get("user_id")
+	actor, _ := actorID.(int)
+
+	removed, err := services.UnlockLogin(req.Username, req.IP, actor, netutil.ClientIP(c))
+	if err != nil {
+		logger.Error("Erro ao desbloquear login: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao desbloquear login"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Desbloqueio processado", "unlocked": removed})
+}
+
+// UpdateUserRequest representa as alterações no cadastro de um usuário (campos omitidos não são alterados)
+type UpdateUserRequest struct {
+	Name  *string   `json:"name"`
+	Email *string   `json:"email"`
+	Roles *[]string `json:"roles"`
+}
+
+// ListUsers lista os usuários com paginação e busca.
+//
+// Parâmetros de consulta:
+// - page: Página (padrão 1).
+// - per_page: Usuários por página (padrão 20, máximo 100).
+// - search: Busca parcial por nome, username ou e-mail.
+// - active: "true" ou "false" para filtrar por contas ativas ou desativadas.
+//
+// Respostas:
+// - 200 OK: Retorna os usuários da página e o total de registros.
+// - 400 Bad Request: Se algum parâmetro for inválido.
+// - 500 Internal Server Error: Se ocorrer um erro ao listar os usuários.
+func ListUsers(c *gin.Context) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Página inválida"})
+		return
+	}
+	perPage, err := strconv.Atoi(c.DefaultQuery("per_page", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Quantidade por página inválida"})
+		return
+	}
+
+	var active *bool
+	if value := c.Query("active"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Filtro active inválido"})
+			return
+		}
+		active = &parsed
+	}
+
+	result, err := services.ListUsers(c.Query("search"), active, page, perPage)
+	if err != nil {
+		logger.Error("Erro ao listar usuários: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao listar usuários"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetUser retorna os dados de um usuário.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Retorna o usuário (sem o hash da senha).
+// - 400 Bad Request: Se o ID for inválido.
+// - 404 Not Found: Se o usuário não existir.
+func GetUser(c *gin.Context) {
+	id, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	user, err := models.GetUserByID(id)
+	if err != nil {
+		respondUserError(c, err, "Erro ao buscar usuário")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+// UpdateUser altera o nome, o e-mail e/ou os papéis de um usuário.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Retorna o usuário atualizado.
+// - 400 Bad Request: Se os dados forem inválidos, o e-mail já estiver em uso ou algum papel não existir.
+// - 403 Forbidden: Se forem informados papéis e o usuário autenticado não puder atribuí-los.
+// - 404 Not Found: Se o usuário não existir.
+// - 500 Internal Server Error: Se ocorrer um erro ao atualizar o usuário.
+func UpdateUser(c *gin.Context) {
+	id, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Requisição inválida"})
+		return
+	}
+
+	actorID := c.GetInt("user_id")
+	if req.Roles != nil && !services.HasPermission(actorID, "roles:assign") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permissão insuficiente para atribuir papéis"})
+		return
+	}
+
+	user, err := services.UpdateUser(id, services.UserChanges{Name: req.Name, Email: req.Email, Roles: req.Roles}, actorID, netutil.ClientIP(c))
+	if err != nil {
+		respondUserError(c, err, "Erro ao atualizar usuário")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Usuário atualizado", "user": user})
+}
+
+// DeactivateUser desativa a conta de um usuário, encerrando todas as suas sessões.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Se a conta foi desativada (ou já estava desativada).
+// - 400 Bad Request: Se o ID for inválido ou for o do próprio usuário autenticado.
+// - 404 Not Found: Se o usuário não existir.
+// - 500 Internal Server Error: Se ocorrer um erro ao desativar a conta.
+func DeactivateUser(c *gin.Context) {
+	setUserActive(c, false, "Usuário desativado")
+}
+
+// ReactivateUser reativa a conta de um usuário.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Se a conta foi reativada (ou já estava ativa).
+// - 400 Bad Request: Se o ID for inválido.
+// - 404 Not Found: Se o usuário não existir.
+// - 500 Internal Server Error: Se ocorrer um erro ao reativar a conta.
+func ReactivateUser(c *gin.Context) {
+	setUserActive(c, true, "Usuário reativado")
+}
+
+// DeleteUser exclui um usuário.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Se o usuário foi excluído.
+// - 400 Bad Request: Se o ID for inválido ou for o do próprio usuário autenticado.
+// - 404 Not Found: Se o usuário não existir.
+// - 500 Internal Server Error: Se ocorrer um erro ao excluir o usuário.
+func DeleteUser(c *gin.Context) {
+	id, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := services.DeleteUser(id, c.GetInt("user_id"), netutil.ClientIP(c)); err != nil {
+		respondUserError(c, err, "Erro ao excluir usuário")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Usuário excluído"})
+}
+
+// setUserActive altera o estado da conta do usuário informado na rota e responde ao cliente.
+func setUserActive(c *gin.Context, active bool, message string) {
+	id, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := services.SetUserActive(id, active, c.GetInt("user_id"), netutil.ClientIP(c)); err != nil {
+		respondUserError(c, err, "Erro ao alterar o estado do usuário")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
+
+// respondUserError converte os erros da administração de usuários na resposta HTTP correspondente.
+func respondUserError(c *gin.Context, err error, fallback string) {
+	var policyErr *services.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": policyErr.Reason})
+		return
+	}
+
+	switch err {
+	case models.ErrUserNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Usuário não encontrado"})
+	case models.ErrUnknownRole:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Papel inexistente"})
+	case services.ErrInvalidUser:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Username e senha são obrigatórios"})
+	case services.ErrInvalidName:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nome inválido"})
+	case services.ErrInvalidEmail:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "E-mail inválido"})
+	case services.ErrUsernameInUse:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Usuário já existe"})
+	case services.ErrEmailInUse:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "E-mail já está em uso"})
+	case services.ErrSelfManagement:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Operação não permitida sobre o próprio usuário"})
+	case services.ErrRoleGrantForbidden:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permissão insuficiente para atribuir os papéis"})
+	default:
+		logger.Error("%s: %v", fallback, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
@@ -0,0 +1,204 @@
+// pwd: /app/server/modules/login/controllers/webauthn_controller.go
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"api/server/modules/login/services"
+	"api/utils/netutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BeginPasskeyRegistrationRequest representa os dados recebidos para iniciar o registro de uma passkey
+type BeginPasskeyRegistrationRequest struct {
+	Password string `json:"password"`
+}
+
+// FinishPasskeyRegistrationRequest representa a resposta do autenticador ao registro de uma passkey
+type FinishPasskeyRegistrationRequest struct {
+	CeremonyID string          `json:"ceremony_id"`
+	Name       string          `json:"name"`
+	Credential json.RawMessage `json:"credential"` // Resultado de navigator.credentials.create()
+}
+
+// BeginPasskeyLoginRequest representa os dados (opcionais) recebidos para iniciar um login por passkey
+type BeginPasskeyLoginRequest struct {
+	Device string `json:"device"`
+}
+
+// FinishPasskeyLoginRequest representa a resposta do autenticador ao login por passkey
+type FinishPasskeyLoginRequest struct {
+	CeremonyID string          `json:"ceremony_id"`
+	Credential json.RawMessage `json:"credential"` // Resultado de navigator.credentials.get()
+}
+
+// BeginPasskeyLogin inicia um login por passkey.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Retorna o ID da cerimônia e as opções para navigator.credentials.get().
+// - 503 Service Unavailable: Se o WebAuthn não estiver configurado.
+func BeginPasskeyLogin(c *gin.Context) {
+	var req BeginPasskeyLoginRequest
+	_ = c.ShouldBindJSON(&req) // O corpo é opcional
+
+	ceremonyID, options, err := services.BeginPasskeyLogin(req.Device)
+	if err != nil {
+		respondPasskeyError(c, err, "Erro ao iniciar login por passkey")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ceremony_id": ceremonyID, "options": options})
+}
+
+// FinishPasskeyLogin conclui um login por passkey.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Mesmo formato do login.
+// - 400 Bad Request: Se a requisição for inválida.
+// - 401 Unauthorized: Se a cerimônia for inválida ou expirada, ou se a passkey for recusada.
+// - 403 Forbidden: Se EMAIL_VERIFICATION_POLICY=block e o e-mail do usuário não estiver verificado.
+func FinishPasskeyLogin(c *gin.Context) {
+	var req FinishPasskeyLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.CeremonyID == "" || len(req.Credential) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Informe o ID da cerimônia e a credencial"})
+		return
+	}
+
+	clientIP := netutil.ClientIP(c)
+	user, device, err := services.FinishPasskeyLogin(req.CeremonyID, req.Credential, clientIP)
+	if err != nil {
+		respondPasskeyError(c, err, "Erro ao processar login")
+		return
+	}
+
+	completeLogin(c, *user, device, clientIP)
+}
+
+// ListMyPasskeys lista as passkeys do usuário autenticado.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: `{ "passkeys": [...] }`
+// - 500 Internal Server Error: Se ocorrer um erro na consulta.
+func ListMyPasskeys(c *gin.Context) {
+	passkeys, err := services.ListPasskeys(c.GetInt("user_id"))
+	if err != nil {
+		respondPasskeyError(c, err, "Erro ao listar passkeys")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"passkeys": passkeys})
+}
+
+// BeginPasskeyRegistration inicia o registro de uma passkey para o usuário autenticado.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Retorna o ID da cerimônia e as opções para navigator.credentials.create().
+// - 400 Bad Request: Se a senha não for informada.
+// - 401 Unauthorized: Se a senha estiver incorreta.
+// - 429 Too Many Requests: Se houver tentativas demais com senha incorreta.
+// - 503 Service Unavailable: Se o WebAuthn não estiver configurado.
+func BeginPasskeyRegistration(c *gin.Context) {
+	var req BeginPasskeyRegistrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Password == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Senha não informada"})
+		return
+	}
+
+	ceremonyID, options, err := services.BeginPasskeyRegistration(c.GetInt("user_id"), req.Password, netutil.ClientIP(c))
+	if err != nil {
+		respondPasskeyError(c, err, "Erro ao iniciar o registro da passkey")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ceremony_id": ceremonyID, "options": options})
+}
+
+// FinishPasskeyRegistration conclui o registro de uma passkey para o usuário autenticado.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 201 Created: Retorna a passkey registrada.
+// - 400 Bad Request: Se a requisição, a cerimônia ou a resposta do autenticador forem inválidas.
+func FinishPasskeyRegistration(c *gin.Context) {
+	var req FinishPasskeyRegistrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.CeremonyID == "" || len(req.Credential) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Informe o ID da cerimônia e a credencial"})
+		return
+	}
+
+	passkey, err := services.FinishPasskeyRegistration(c.GetInt("user_id"), req.CeremonyID, req.Name, req.Credential, netutil.ClientIP(c))
+	if err != nil {
+		switch err {
+		case services.ErrInvalidCeremony:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Registro de passkey inválido ou expirado. Inicie o registro novamente"})
+		case services.ErrPasskeyRejected:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Resposta do autenticador inválida"})
+		default:
+			respondPasskeyError(c, err, "Erro ao registrar a passkey")
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Passkey registrada", "passkey": passkey})
+}
+
+// DeleteMyPasskey remove uma passkey do usuário autenticado.
+//
+// Parâmetros:
+// - c: *gin.Context - Contexto da requisição.
+//
+// Respostas:
+// - 200 OK: Se a passkey foi removida.
+// - 400 Bad Request: Se o ID for inválido.
+// - 404 Not Found: Se a passkey não existir ou pertencer a outro usuário.
+func DeleteMyPasskey(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("passkey_id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de passkey inválido"})
+		return
+	}
+
+	if err := services.DeletePasskey(c.GetInt("user_id"), id, netutil.ClientIP(c)); err != nil {
+		respondPasskeyError(c, err, "Erro ao remover a passkey")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Passkey removida"})
+}
+
+// respondPasskeyError converte os erros das passkeys na resposta HTTP correspondente.
+func respondPasskeyError(c *gin.Context, err error, fallback string) {
+	switch err {
+	case services.ErrWebAuthnUnavailable:
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Passkeys não estão disponíveis neste servidor"})
+	case services.ErrInvalidCeremony:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login por passkey inválido ou expirado. Tente novamente"})
+	case services.ErrPasskeyRejected:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Passkey inválida"})
+	case services.ErrPasskeyNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Passkey não encontrada"})
+	case services.ErrInvalidPasskeyName:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nome da passkey muito longo"})
+	case services.ErrEmailNotVerified:
+		c.JSON(http.StatusForbidden, gin.H{"error": "E-mail não verificado. Confirme o e-mail pelo link enviado ou solicite um novo"})
+	default:
+		respondTwoFactorError(c, err, fallback)
+	}
+}
@@ -0,0 +1,79 @@
+// pwd: /app/server/modules/login/middleware/api_key_middleware.go
+
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"api/logger"
+	"api/server/modules/login/services"
+	"api/utils/netutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuthOrAPIKeyMiddleware é um middleware de autenticação que aceita um token JWT ou uma chave de API.
+//
+// Funcionamento:
+// - A credencial é lida do cabeçalho "Authorization: Bearer <credencial>" ou, para chaves de API, também de "X-API-Key".
+// - Credenciais com o prefixo "ak_" são validadas como chaves de API; as demais, como tokens JWT (igual ao AuthMiddleware).
+// - Com uma chave de API, as permissões efetivas (os escopos da chave) são guardadas em "permissions" no contexto,
+// para que RequirePermission as use; "api_key_id" identifica a chave e "user_id" o dono (0 nas chaves de serviço).
+// - Deve ser combinado com RequirePermission, pois uma chave de API não dá acesso às rotas sem permissão exigida.
+//
+// Uso:
+// group.Use(middleware.AuthOrAPIKeyMiddleware(), middleware.RequirePermission("finance:read"))
+//
+// Respostas:
+// - 401 Unauthorized: Se nenhuma credencial for fornecida ou se ela for inválida, expirada ou revogada.
+// - 500 Internal Server Error: Se a chave de API não puder ser validada.
+func AuthOrAPIKeyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		credential := strings.TrimSpace(c.GetHeader("X-API-Key"))
+		if credential == "" {
+			authHeader := c.GetHeader("Authorization")
+			if authHeader == "" {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Token ou chave de API não fornecido"})
+				c.Abort()
+				return
+			}
+
+			tokenParts := strings.Split(authHeader, " ")
+			if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Formato do token inválido"})
+				c.Abort()
+				return
+			}
+			credential = tokenParts[1]
+
+			if !services.IsAPIKey(credential) {
+				if authenticateJWT(c, credential) {
+					c.Next()
+					recordImpersonatedRequest(c)
+				}
+				return
+			}
+		}
+
+		clientIP := netutil.ClientIP(c)
+		identity, err := services.AuthenticateAPIKey(credential, clientIP)
+		if err != nil {
+			if err == services.ErrInvalidAPIKey {
+				logger.Warn("Chave de API inválida utilizada (IP %s)", clientIP)
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Chave de API inválida"})
+			} else {
+				logger.Error("Erro ao validar chave de API: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao validar chave de API"})
+			}
+			c.Abort()
+			return
+		}
+
+		c.Set("api_key_id", identity.KeyID)
+		c.Set("user_id", identity.UserID)
+		c.Set("permissions", identity.Permissions)
+
+		c.Next()
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 	"api/logger"
 	"api/server/modules/login/auth_utils"
+	"api/server/modules/login/services"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,13 +18,15 @@ import (
 // Funcionamento:
 // - O token deve ser fornecido no cabeçalho "Authorization" no formato: "Bearer <token>".
 // - Caso o token não seja fornecido ou esteja em um formato inválido, a requisição é abortada com status 401 (Unauthorized).
+// - Tokens revogados (logout, logout-all, troca de senha ou conta desativada) também são recusados.
 // - Se o token for válido, os dados do usuário são extraídos e armazenados no contexto da requisição.
+// - Com um token de personificação, o administrador fica em "impersonator_id" e a requisição é registrada na auditoria.
 //
 // Uso:
 // router.Use(AuthMiddleware())
 //
 // Respostas:
-// - 401 Unauthorized: Se o token não for fornecido, estiver inválido ou expirado.
+// - 401 Unauthorized: Se o token não for fornecido, estiver inválido, expirado ou revogado.
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
@@ -41,21 +44,53 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Valida o token e extrai os claims (dados do usuário)
-		claims, err := auth_utils.ValidateAndExtractClaims(tokenParts[1])
-		if err != nil {
-			logger.Warn("Falha ao validar token: %v", err)
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token inválido"})
-			c.Abort()
+		if !authenticateJWT(c, tokenParts[1]) {
 			return
 		}
 
-		// Armazena os dados do usuário extraídos do token no contexto da requisição
-		c.Set("user_id", claims.ID)
-		// c.Set("username", claims.Username) // Descomentar se necessário
-		c.Set("access_level", claims.AccessLevel)
-
 		// Prossegue para a próxima etapa da requisição
 		c.Next()
+		recordImpersonatedRequest(c)
+	}
+}
+
+// authenticateJWT valida um token de acesso e armazena os dados do usuário no contexto da requisição.
+// Se o token for inválido ou revogado, responde 401, aborta a requisição e retorna false.
+func authenticateJWT(c *gin.Context, token string) bool {
+	claims, ok := parseAccessToken(token)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token inválido"})
+		c.Abort()
+		return false
+	}
+
+	// Armazena os dados do usuário extraídos do token no contexto da requisição
+	c.Set("claims", claims)
+	c.Set("user_id", claims.ID)
+	// c.Set("username", claims.Username) // Descomentar se necessário
+	c.Set("roles", claims.Roles)
+	if claims.Impersonating() {
+		c.Set("impersonator_id", claims.Actor.ID)
 	}
+
+	return true
+}
+
+// parseAccessToken valida um token de acesso (assinatura, expiração e revogação) e retorna os seus claims.
+// Retorna false se o token for inválido ou revogado.
+func parseAccessToken(token string) (*auth_utils.Claims, bool) {
+	// Valida o token e extrai os claims (dados do usuário)
+	claims, err := auth_utils.ParseToken(token)
+	if err != nil {
+		logger.Warn("Falha ao validar token: %v", err)
+		return nil, false
+	}
+
+	// Recusa tokens revogados individualmente (jti) ou por versão (logout-all)
+	if err := services.CheckTokenRevocation(claims); err != nil {
+		logger.Warn("Token revogado utilizado pelo usuário ID=%d", claims.ID)
+		return nil, false
+	}
+
+	return claims, true
 }
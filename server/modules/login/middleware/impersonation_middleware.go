@@ -0,0 +1,50 @@
+// pwd: /app/server/modules/login/middleware/impersonation_middleware.go
+
+package middleware
+
+import (
+	"net/http"
+
+	"api/logger"
+	"api/server/modules/login/auth_utils"
+	"api/server/modules/login/services"
+	"api/utils/netutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DenyImpersonation é um middleware que recusa a operação quando o token é de personificação.
+//
+// Funcionamento:
+// - Deve ser usado depois do AuthMiddleware, que guarda "impersonator_id" no contexto para os tokens de personificação.
+// - Protege as operações sensíveis (senha, 2FA, passkeys, chaves de API, sessões e administração), que o suporte
+// não pode executar em nome do usuário.
+//
+// Uso:
+// userGroup.POST("/password", DenyImpersonation(), controllers.ChangeMyPassword)
+//
+// Respostas:
+// - 403 Forbidden: Se a requisição usar um token de personificação.
+func DenyImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if actorID, ok := c.Get("impersonator_id"); ok {
+			logger.Warn("Operação recusada durante a personificação: administrador ID=%v como usuário ID=%d (%s %s)",
+				actorID, c.GetInt("user_id"), c.Request.Method, c.FullPath())
+			c.JSON(http.StatusForbidden, gin.H{"error": "Operação não permitida durante a personificação"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// recordImpersonatedRequest registra, depois de respondida, a requisição feita com um token de personificação.
+func recordImpersonatedRequest(c *gin.Context) {
+	value, _ := c.Get("claims")
+	claims, ok := value.(*auth_utils.Claims)
+	if !ok || !claims.Impersonating() {
+		return
+	}
+	services.RecordImpersonatedRequest(claims, c.Request.Method, c.Request.URL.Path, c.Writer.Status(), netutil.ClientIP(c))
+}
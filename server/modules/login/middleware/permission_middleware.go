@@ -0,0 +1,64 @@
+// pwd: /app/server/modules/login/middleware/permission_middleware.go
+
+package middleware
+
+import (
+	"net/http"
+
+	"api/logger"
+	"api/server/modules/login/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequirePermission é um middleware de autorização que exige uma permissão do usuário autenticado.
+//
+// Funcionamento:
+// - Deve ser usado depois do AuthMiddleware (ou do AuthOrAPIKeyMiddleware), que identifica o usuário ("user_id" no contexto).
+// - As permissões do usuário são obtidas dos seus papéis (com cache) uma única vez por requisição e guardadas em "permissions" no contexto.
+// - Com uma chave de API, valem as permissões já guardadas pelo AuthOrAPIKeyMiddleware (os escopos da chave).
+// - Se o usuário não possuir a permissão, a requisição é abortada com status 403 (Forbidden).
+//
+// Uso:
+// usersGroup.POST("/register", RequirePermission("users:create"), controllers.AddNewUser)
+//
+// Respostas:
+// - 401 Unauthorized: Se a requisição não estiver autenticada.
+// - 403 Forbidden: Se o usuário não possuir a permissão.
+// - 500 Internal Server Error: Se as permissões não puderem ser consultadas.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("user_id")
+		permissions, ok := c.Get("permissions")
+		if !ok {
+			if userID == 0 {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+				c.Abort()
+				return
+			}
+
+			loaded, err := services.GetUserPermissions(userID)
+			if err != nil {
+				logger.Error("Erro ao consultar as permissões do usuário ID=%d: %v", userID, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao verificar permissões"})
+				c.Abort()
+				return
+			}
+			c.Set("permissions", loaded)
+			permissions = loaded
+		}
+
+		if granted, _ := permissions.(map[string]bool); !granted[permission] {
+			if keyID, isKey := c.Get("api_key_id"); isKey {
+				logger.Warn("Acesso negado à chave de API ID=%v: escopo %q ausente (%s %s)", keyID, permission, c.Request.Method, c.FullPath())
+			} else {
+				logger.Warn("Acesso negado ao usuário ID=%d: permissão %q ausente (%s %s)", userID, permission, c.Request.Method, c.FullPath())
+			}
+			c.JSON(http.StatusForbidden, gin.H{"error": "Permissão insuficiente"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
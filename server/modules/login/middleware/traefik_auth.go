@@ -1,50 +1,263 @@
-// // pwd: /app/server/modules/login/middleware/traefik_auth.go
+// pwd: /app/server/modules/login/middleware/traefik_auth.go
+
 package middleware
 
-// import (
-// 	// "errors"
-// 	"net/http"
-// 	"strings"
-
-// 	"api/logger"
-// 	"api/server/modules/login/services"
-// 	"github.com/gin-gonic/gin"
-// )
-
-// // TraefikAuthMiddleware verifica se a requisição contém um token JWT válido antes de liberar o acesso
-// func TraefikAuthMiddleware() gin.HandlerFunc {
-// 	return func(c *gin.Context) {
-// 		// Obtém o token JWT do cabeçalho Authorization
-// 		authHeader := c.GetHeader("Authorization")
-// 		if authHeader == "" {
-// 			logger.Warn("Tentativa de acesso sem token JWT")
-// 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token JWT ausente"})
-// 			c.Abort()
-// 			return
-// 		}
-
-// 		// Extrai o token removendo "Bearer "
-// 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-// 		if tokenString == "" {
-// 			logger.Warn("Token JWT inválido no cabeçalho Authorization")
-// 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token JWT inválido"})
-// 			c.Abort()
-// 			return
-// 		}
-
-// 		// Valida o token JWT
-// 		userID, err := services.ValidateJWT(tokenString)
-// 		if err != nil {
-// 			logger.Warn("Falha na autenticação JWT: %s", err.Error())
-// 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token inválido ou expirado"})
-// 			c.Abort()
-// 			return
-// 		}
-
-// 		// Define o usuário autenticado no contexto
-// 		c.Set("user_id", userID)
-
-// 		// Continua para o serviço protegido
-// 		c.Next()
-// 	}
-// }
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"api/logger"
+	"api/server/modules/login/auth_utils"
+	"api/server/modules/login/services"
+	"api/utils"
+	"api/utils/netutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Valores especiais de permissão nas regras do forwardAuth
+const (
+	traefikAnyUser = "*"    // Qualquer usuário autenticado
+	traefikDeny    = "deny" // Acesso negado a todos
+)
+
+// traefikRule associa um host (e, opcionalmente, um prefixo de caminho) a uma permissão exigida.
+type traefikRule struct {
+	host       string // Host exato, "*.dominio" (subdomínios) ou "*" (qualquer host)
+	path       string // Prefixo do caminho em minúsculas, sem a barra final ("" = qualquer caminho)
+	permission string
+}
+
+// TraefikForwardAuth é o endpoint de autenticação do middleware forwardAuth do Traefik, que protege outras
+// aplicações do domínio com os logins desta API.
+//
+// Funcionamento:
+//   - O Traefik repassa cada requisição das aplicações protegidas, com o destino original nos cabeçalhos
+//     X-Forwarded-Method, X-Forwarded-Proto, X-Forwarded-Host e X-Forwarded-Uri.
+//   - O token JWT é lido do cabeçalho "Authorization: Bearer <token>" ou, se ausente, do cookie TRAEFIK_AUTH_COOKIE.
+//   - A permissão exigida vem da regra mais específica de TRAEFIK_AUTH_RULES para o host e o caminho originais
+//     (ex: "grafana.exemplo.com=monitoring:read,exemplo.com/admin=users:read,*.exemplo.com=*").
+//     Hosts e caminhos são comparados sem diferenciar maiúsculas de minúsculas: "/ADMIN" atende à regra "/admin",
+//     para que a regra não seja contornada em aplicações que não diferenciam o caso.
+//     Sem regra, vale TRAEFIK_AUTH_DEFAULT_PERMISSION ("*" = qualquer usuário autenticado; "deny" = acesso negado).
+//   - Se o acesso for liberado, responde 200 com os cabeçalhos X-Auth-User, X-Auth-User-Id e X-Auth-Roles, que o
+//     Traefik repassa à aplicação quando listados em authResponseHeaders. Com um token de personificação, o
+//     administrador é informado em X-Auth-Impersonator e X-Auth-Impersonator-Id, e o acesso é registrado na auditoria.
+//
+// Uso:
+// router.GET("/auth/traefik", middleware.TraefikForwardAuth())
+//
+// Respostas:
+// - 200 OK: Se o token for válido e o usuário possuir a permissão exigida.
+// - 302 Found: Se não houver token válido, a requisição original vier de um navegador e TRAEFIK_AUTH_LOGIN_URL estiver definido.
+// - 401 Unauthorized: Se o token não for fornecido, estiver inválido, expirado ou revogado.
+// - 403 Forbidden: Se o usuário não possuir a permissão exigida.
+// - 500 Internal Server Error: Se as permissões não puderem ser consultadas.
+func TraefikForwardAuth() gin.HandlerFunc {
+	rules := loadTraefikRules("TRAEFIK_AUTH_RULES")
+	defaultPermission := utils.GetEnv("TRAEFIK_AUTH_DEFAULT_PERMISSION")
+	if defaultPermission == "" {
+		defaultPermission = traefikAnyUser
+	}
+	cookieName := utils.GetEnv("TRAEFIK_AUTH_COOKIE")
+	if cookieName == "" {
+		cookieName = "access_token"
+	}
+	loginURL := utils.GetEnv("TRAEFIK_AUTH_LOGIN_URL")
+
+	return func(c *gin.Context) {
+		host := forwardedHost(c)
+		reqPath := forwardedPath(c)
+
+		permission := defaultPermission
+		if rule := matchTraefikRule(rules, host, reqPath); rule != nil {
+			permission = rule.permission
+		}
+		if permission == traefikDeny {
+			logger.Warn("Acesso negado pelo forwardAuth: nenhuma regra libera %s%s", host, reqPath)
+			c.JSON(http.StatusForbidden, gin.H{"error": "Acesso negado"})
+			c.Abort()
+			return
+		}
+
+		token, hasBearer := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !hasBearer {
+			token, _ = c.Cookie(cookieName)
+		}
+		var claims *auth_utils.Claims
+		ok := false
+		if token != "" {
+			claims, ok = parseAccessToken(token)
+		}
+		if !ok {
+			if loginURL != "" && isBrowserRequest(c) {
+				c.Redirect(http.StatusFound, traefikLoginRedirect(loginURL, c))
+				c.Abort()
+				return
+			}
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token inválido ou não fornecido"})
+			c.Abort()
+			return
+		}
+
+		if permission != traefikAnyUser {
+			granted, err := services.GetUserPermissions(claims.ID)
+			if err != nil {
+				logger.Error("Erro ao consultar as permissões do usuário ID=%d: %v", claims.ID, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao verificar permissões"})
+				c.Abort()
+				return
+			}
+			if !granted[permission] {
+				logger.Warn("Acesso negado pelo forwardAuth ao usuário ID=%d: permissão %q ausente (%s%s)", claims.ID, permission, host, reqPath)
+				c.JSON(http.StatusForbidden, gin.H{"error": "Permissão insuficiente"})
+				c.Abort()
+				return
+			}
+		}
+
+		c.Header("X-Auth-User", claims.Username)
+		c.Header("X-Auth-User-Id", strconv.Itoa(claims.ID))
+		c.Header("X-Auth-Roles", strings.Join(claims.Roles, ","))
+		if claims.Impersonating() {
+			c.Header("X-Auth-Impersonator", claims.Actor.Username)
+			c.Header("X-Auth-Impersonator-Id", strconv.Itoa(claims.Actor.ID))
+			services.RecordImpersonatedRequest(claims, c.GetHeader("X-Forwarded-Method"), host+reqPath, http.StatusOK, netutil.ClientIP(c))
+		}
+		c.Status(http.StatusOK)
+	}
+}
+
+// loadTraefikRules lê as regras no formato "host[/caminho]=permissão", ordenadas da mais específica para a menos.
+//
+// O host e o caminho são gravados em minúsculas, como são comparados em matches.
+func loadTraefikRules(key string) []traefikRule {
+	var rules []traefikRule
+
+	for _, item := range utils.GetEnvList(key) {
+		target, permission, ok := strings.Cut(item, "=")
+		target = strings.TrimSpace(target)
+		permission = strings.TrimSpace(permission)
+		if !ok || target == "" || permission == "" {
+			logger.Warn("Regra inválida em %s: %q (formato esperado: host[/caminho]=permissão)", key, item)
+			continue
+		}
+
+		host, prefix := target, ""
+		if i := strings.Index(target, "/"); i >= 0 {
+			host, prefix = target[:i], strings.TrimSuffix(target[i:], "/")
+		}
+		host = strings.ToLower(host)
+		if host == "" {
+			host = "*"
+		}
+		rules = append(rules, traefikRule{host: host, path: strings.ToLower(prefix), permission: permission})
+	}
+
+	sort.SliceStable(rules, func(i, j int) bool {
+		if ri, rj := rules[i].hostRank(), rules[j].hostRank(); ri != rj {
+			return ri > rj
+		}
+		return len(rules[i].path) > len(rules[j].path)
+	})
+	return rules
+}
+
+// hostRank indica a especificidade do host da regra: exato (2), subdomínios (1) ou qualquer host (0).
+func (r traefikRule) hostRank() int {
+	switch {
+	case r.host == "*":
+		return 0
+	case strings.HasPrefix(r.host, "*."):
+		return 1
+	default:
+		return 2
+	}
+}
+
+// matches verifica se a regra atende ao host e ao caminho, respeitando os limites de segmento do caminho.
+//
+// O host já chega em minúsculas (forwardedHost); o caminho é comparado sem diferenciar maiúsculas de minúsculas.
+func (r traefikRule) matches(host, path string) bool {
+	switch r.hostRank() {
+	case 1:
+		if !strings.HasSuffix(host, r.host[1:]) {
+			return false
+		}
+	case 2:
+		if host != r.host {
+			return false
+		}
+	}
+	if r.path == "" {
+		return true
+	}
+	path = strings.ToLower(path)
+	return path == r.path || strings.HasPrefix(path, r.path+"/")
+}
+
+// matchTraefikRule retorna a regra mais específica que atende ao host e ao caminho, ou nil.
+func matchTraefikRule(rules []traefikRule, host, path string) *traefikRule {
+	for i := range rules {
+		if rules[i].matches(host, path) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+// forwardedHost retorna o host da requisição original, sem a porta e em minúsculas.
+func forwardedHost(c *gin.Context) string {
+	host := c.GetHeader("X-Forwarded-Host")
+	if host == "" {
+		host = c.Request.Host
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(host)
+}
+
+// forwardedPath retorna o caminho da requisição original, sem a query string e normalizado
+// (ex: "/public/../admin" → "/admin"), para que as regras não sejam contornadas.
+func forwardedPath(c *gin.Context) string {
+	uri := c.GetHeader("X-Forwarded-Uri")
+	p, _, _ := strings.Cut(uri, "?")
+	if u, err := url.ParseRequestURI(uri); err == nil {
+		p = u.Path
+	}
+	return path.Clean("/" + p)
+}
+
+// isBrowserRequest verifica se a requisição original é uma navegação de página (GET que aceita HTML).
+func isBrowserRequest(c *gin.Context) bool {
+	method := c.GetHeader("X-Forwarded-Method")
+	if method != "" && method != http.MethodGet {
+		return false
+	}
+	return strings.Contains(c.GetHeader("Accept"), "text/html")
+}
+
+// traefikLoginRedirect monta a URL da página de login com o endereço original no parâmetro "redirect".
+func traefikLoginRedirect(loginURL string, c *gin.Context) string {
+	proto := c.GetHeader("X-Forwarded-Proto")
+	if proto == "" {
+		proto = "https"
+	}
+	host := c.GetHeader("X-Forwarded-Host")
+	if host == "" {
+		return loginURL
+	}
+	original := proto + "://" + host + c.GetHeader("X-Forwarded-Uri")
+
+	separator := "?"
+	if strings.Contains(loginURL, "?") {
+		separator = "&"
+	}
+	return loginURL + separator + "redirect=" + url.QueryEscape(original)
+}
@@ -0,0 +1,40 @@
+// pwd: /app/server/modules/login/middleware/traefik_auth_test.go
+
+package middleware
+
+import "testing"
+
+func TestMatchTraefikRule(t *testing.T) {
+	t.Setenv("TRAEFIK_AUTH_RULES", "App.Example.com/Admin=users:read,app.example.com=*,*.example.com/Reports/=reports:read,/metrics=deny")
+	rules := loadTraefikRules("TRAEFIK_AUTH_RULES")
+
+	tests := []struct {
+		name string
+		host string
+		path string
+		want string // Permissão da regra ("" = nenhuma regra)
+	}{
+		{"prefixo com maiúsculas na regra", "app.example.com", "/Admin", "users:read"},
+		{"caminho em minúsculas", "app.example.com", "/admin/users", "users:read"},
+		{"caminho em maiúsculas", "app.example.com", "/ADMIN", "users:read"},
+		{"caminho misto", "app.example.com", "/aDmIn/x", "users:read"},
+		{"limite de segmento", "app.example.com", "/administrator", "*"},
+		{"host sem caminho", "app.example.com", "/", "*"},
+		{"subdomínio com barra final na regra", "bi.example.com", "/REPORTS/daily", "reports:read"},
+		{"subdomínio fora do prefixo", "bi.example.com", "/", ""},
+		{"qualquer host", "outro.com", "/Metrics", "deny"},
+		{"sem regra", "outro.com", "/", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			if rule := matchTraefikRule(rules, tt.host, tt.path); rule != nil {
+				got = rule.permission
+			}
+			if got != tt.want {
+				t.Errorf("matchTraefikRule(%q, %q) = %q, esperado %q", tt.host, tt.path, got, tt.want)
+			}
+		})
+	}
+}
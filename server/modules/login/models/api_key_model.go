@@ -0,0 +1,244 @@
+// pwd: /app/server/modules/login/models/api_key_model.go
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"api/db"
+	"api/logger"
+)
+
+// APIKey representa uma chave de API (somente o prefixo e o hash do segredo são persistidos)
+type APIKey struct {
+	ID          int64      `json:"id"`
+	UserID      *int       `json:"user_id,omitempty"` // Dono da chave pessoal; nulo nas chaves de serviço
+	Name        string     `json:"name"`
+	Prefix      string     `json:"prefix"`
+	SecretHash  string     `json:"-"`
+	Scopes      []string   `json:"scopes"`
+	CreatedBy   int        `json:"created_by"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP  string     `json:"last_used_ip,omitempty"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	OwnerActive bool       `json:"-"` // Se o dono da chave pessoal está ativo
+}
+
+// apiKeyColumns lista as colunas lidas por scanAPIKey, na mesma ordem.
+const apiKeyColumns = `k.id, k.user_id, k.name, k.prefix, k.secret_hash, k.scopes, k.created_by, k.created_at, k.expires_at,
+	k.last_used_at, k.last_used_ip, k.revoked_at, COALESCE(u.active, 0)`
+
+// apiKeyFrom é a origem das consultas de chaves, com o dono para verificar se ele está ativo.
+const apiKeyFrom = " FROM api_keys k LEFT JOIN users u ON u.id = k.user_id"
+
+// CreateAPIKey grava uma nova chave de API.
+//
+// Parâmetros:
+// - key: APIKey - A chave a gravar (UserID, Name, Prefix, SecretHash, Scopes, CreatedBy e ExpiresAt).
+//
+// Respostas:
+// - int64: O ID da chave gravada.
+// - error: Se ocorrer um erro durante a gravação.
+func CreateAPIKey(key APIKey) (int64, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return 0, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	query := "INSERT INTO api_keys (user_id, name, prefix, secret_hash, scopes, created_by, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+	result, err := dbConn.Exec(query, key.UserID, key.Name, key.Prefix, key.SecretHash, strings.Join(key.Scopes, ","),
+		key.CreatedBy, key.CreatedAt, key.ExpiresAt)
+	if err != nil {
+		logger.Error("Erro ao gravar chave de API: %v", err)
+		return 0, errors.New("erro interno ao gravar chave de API")
+	}
+
+	return result.LastInsertId()
+}
+
+// GetAPIKeyByPrefix busca uma chave de API pelo prefixo.
+//
+// Parâmetros:
+// - prefix: string - O prefixo visível da chave.
+//
+// Respostas:
+// - *APIKey: A chave encontrada (inclusive revogada ou expirada) ou nil se não existir.
+// - error: Se ocorrer um erro durante a consulta.
+func GetAPIKeyByPrefix(prefix string) (*APIKey, error) {
+	return getAPIKey("k.prefix = ?", prefix)
+}
+
+// GetAPIKeyByID busca uma chave de API pelo ID.
+//
+// Parâmetros:
+// - id: int64 - O ID da chave.
+//
+// Respostas:
+// - *APIKey: A chave encontrada (inclusive revogada ou expirada) ou nil se não existir.
+// - error: Se ocorrer um erro durante a consulta.
+func GetAPIKeyByID(id int64) (*APIKey, error) {
+	return getAPIKey("k.id = ?", id)
+}
+
+// ListUserAPIKeys lista as chaves pessoais não revogadas de um usuário.
+//
+// Parâmetros:
+// - userID: int - O ID do usuário.
+//
+// Respostas:
+// - []APIKey: As chaves do usuário, da mais recente para a mais antiga.
+// - error: Se ocorrer um erro durante a consulta.
+func ListUserAPIKeys(userID int) ([]APIKey, error) {
+	return listAPIKeys("k.user_id = ? AND k.revoked_at IS NULL", userID)
+}
+
+// ListServiceAPIKeys lista as chaves de serviço não revogadas.
+//
+// Respostas:
+// - []APIKey: As chaves de serviço, da mais recente para a mais antiga.
+// - error: Se ocorrer um erro durante a consulta.
+func ListServiceAPIKeys() ([]APIKey, error) {
+	return listAPIKeys("k.user_id IS NULL AND k.revoked_at IS NULL")
+}
+
+// RevokeAPIKey revoga uma chave de API.
+//
+// Parâmetros:
+// - id: int64 - O ID da chave.
+//
+// Respostas:
+// - bool: false se a chave não existir ou já estiver revogada.
+// - error: Se ocorrer um erro durante a atualização.
+func RevokeAPIKey(id int64) (bool, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return false, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	result, err := dbConn.Exec("UPDATE api_keys SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL", time.Now(), id)
+	if err != nil {
+		logger.Error("Erro ao revogar chave de API: %v", err)
+		return false, errors.New("erro interno ao revogar chave de API")
+	}
+
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
+
+// TouchAPIKey registra o último uso de uma chave de API.
+//
+// Parâmetros:
+// - id: int64 - O ID da chave.
+// - ip: string - O IP de origem da requisição.
+//
+// Respostas:
+// - nil: Se o uso foi registrado.
+// - error: Se ocorrer um erro durante a atualização.
+func TouchAPIKey(id int64, ip string) error {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	if _, err := dbConn.Exec("UPDATE api_keys SET last_used_at = ?, last_used_ip = ? WHERE id = ?", time.Now(), ip, id); err != nil {
+		logger.Error("Erro ao registrar uso da chave de API: %v", err)
+		return errors.New("erro interno")
+	}
+	return nil
+}
+
+// getAPIKey busca uma única chave de API pela condição informada.
+func getAPIKey(where string, args ...any) (*APIKey, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return nil, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	key, err := scanAPIKey(dbConn.QueryRow("SELECT "+apiKeyColumns+apiKeyFrom+" WHERE "+where+" LIMIT 1", args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		logger.Error("Erro ao buscar chave de API: %v", err)
+		return nil, errors.New("erro interno")
+	}
+
+	return key, nil
+}
+
+// listAPIKeys lista as chaves de API que atendem à condição informada.
+func listAPIKeys(where string, args ...any) ([]APIKey, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return nil, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	rows, err := dbConn.Query("SELECT "+apiKeyColumns+apiKeyFrom+" WHERE "+where+" ORDER BY k.id DESC", args...)
+	if err != nil {
+		logger.Error("Erro ao listar chaves de API: %v", err)
+		return nil, errors.New("erro interno")
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			logger.Error("Erro ao ler chave de API: %v", err)
+			return nil, errors.New("erro interno")
+		}
+		keys = append(keys, *key)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("Erro ao listar chaves de API: %v", err)
+		return nil, errors.New("erro interno")
+	}
+
+	return keys, nil
+}
+
+// scanAPIKey lê uma chave de API a partir das colunas de apiKeyColumns.
+func scanAPIKey(row interface{ Scan(...any) error }) (*APIKey, error) {
+	var key APIKey
+	var userID sql.NullInt64
+	var scopes string
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+	err := row.Scan(&key.ID, &userID, &key.Name, &key.Prefix, &key.SecretHash, &scopes, &key.CreatedBy, &key.CreatedAt,
+		&expiresAt, &lastUsedAt, &key.LastUsedIP, &revokedAt, &key.OwnerActive)
+	if err != nil {
+		return nil, err
+	}
+
+	if userID.Valid {
+		id := int(userID.Int64)
+		key.UserID = &id
+	}
+	key.Scopes = []string{}
+	if scopes != "" {
+		key.Scopes = strings.Split(scopes, ",")
+	}
+	if expiresAt.Valid {
+		key.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	return &key, nil
+}
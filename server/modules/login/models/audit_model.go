@@ -0,0 +1,91 @@
+// pwd: /app/server/modules/login/models/audit_model.go
+package models
+
+import (
+	"database/sql"
+	"errors"
+
+	"api/db"
+	"api/logger"
+)
+
+// Tipos de eventos de auditoria registrados pelo módulo de login.
+const (
+	AuditAccountLocked            = "account_locked"
+	AuditAccountUnlocked          = "account_unlocked"
+	AuditRefreshReuse             = "refresh_token_reuse"
+	AuditTokensRevoked            = "tokens_revoked"
+	AuditSessionRevoked           = "session_revoked"
+	AuditRolesChanged             = "roles_changed"
+	AuditUserCreated              = "user_created"
+	AuditUserUpdated              = "user_updated"
+	AuditUserDeactivated          = "user_deactivated"
+	AuditUserReactivated          = "user_reactivated"
+	AuditUserDeleted              = "user_deleted"
+	AuditEmailChanged             = "email_changed"
+	AuditPasswordChanged          = "password_changed"
+	AuditPasswordReset            = "password_reset"
+	AuditEmailVerified            = "email_verified"
+	AuditTwoFactorEnabled         = "two_factor_enabled"
+	AuditTwoFactorDisabled        = "two_factor_disabled"
+	AuditRecoveryCodeUsed         = "recovery_code_used"
+	AuditRecoveryCodesRegenerated = "recovery_codes_regenerated"
+	AuditRoleUpdated              = "role_updated"
+	AuditPasskeyAdded             = "passkey_added"
+	AuditPasskeyRemoved           = "passkey_removed"
+	AuditPasskeyCloneWarning      = "passkey_clone_warning"
+	AuditAPIKeyCreated            = "api_key_created"
+	AuditAPIKeyRevoked            = "api_key_revoked"
+	AuditIdentityLinked           = "identity_linked"
+	AuditIdentityUnlinked         = "identity_unlinked"
+	AuditOAuthClientCreated       = "oauth_client_created"
+	AuditOAuthClientRevoked       = "oauth_client_revoked"
+	AuditOAuthConsentGranted      = "oauth_consent_granted"
+	AuditOAuthConsentRevoked      = "oauth_consent_revoked"
+	AuditImpersonationStarted     = "impersonation_started"
+	AuditImpersonatedRequest      = "impersonated_request"
+)
+
+// AuditEvent representa um evento de segurança registrado na tabela audit_events
+type AuditEvent struct {
+	EventType string
+	UserID    int    // 0 quando o evento não está associado a um usuário existente
+	Username  string // Identificador utilizado na operação
+	ActorID   int    // 0 quando a ação foi executada pelo próprio sistema
+	IP        string
+	Details   string
+}
+
+// RecordAuditEvent grava um evento de auditoria no banco de dados.
+//
+// Parâmetros:
+// - event: AuditEvent - Evento a ser registrado.
+//
+// Respostas:
+// - nil: Se o evento foi registrado com sucesso.
+// - error: Se ocorrer um erro durante a gravação.
+func RecordAuditEvent(event AuditEvent) error {
+	logger.Info("Auditoria: %s | usuário=%q id=%d | ator=%d | ip=%s | %s",
+		event.EventType, event.Username, event.UserID, event.ActorID, event.IP, event.Details)
+
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	query := "INSERT INTO audit_events (event_type, user_id, username, actor_id, ip, details) VALUES (?, ?, ?, ?, ?, ?)"
+	_, err = dbConn.Exec(query, event.EventType, nullableID(event.UserID), event.Username, nullableID(event.ActorID), event.IP, event.Details)
+	if err != nil {
+		logger.Error("Erro ao registrar evento de auditoria: %v", err)
+		return errors.New("erro interno ao registrar auditoria")
+	}
+
+	return nil
+}
+
+// nullableID converte um ID zerado em NULL para colunas opcionais.
+func nullableID(id int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(id), Valid: id > 0}
+}
@@ -0,0 +1,195 @@
+// pwd: /app/server/modules/login/models/identity_model.go
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"api/db"
+	"api/logger"
+)
+
+// UserIdentity representa uma identidade externa (OpenID Connect) vinculada a um usuário local
+type UserIdentity struct {
+	ID          int64      `json:"id"`
+	UserID      int        `json:"user_id"`
+	Provider    string     `json:"provider"`
+	Subject     string     `json:"subject"`
+	Email       string     `json:"email,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+}
+
+// userIdentityColumns lista as colunas lidas por scanUserIdentity, na mesma ordem.
+const userIdentityColumns = "id, user_id, provider, subject, email, created_at, last_login_at"
+
+// GetUserIdentity busca a identidade externa de um provedor pelo subject.
+//
+// Parâmetros:
+// - provider: string - O nome do provedor.
+// - subject: string - O claim "sub" emitido pelo provedor.
+//
+// Respostas:
+// - *UserIdentity: A identidade encontrada ou nil se não existir.
+// - error: Se ocorrer um erro durante a consulta.
+func GetUserIdentity(provider, subject string) (*UserIdentity, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return nil, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	query := "SELECT " + userIdentityColumns + " FROM user_identities WHERE provider = ? AND subject = ? LIMIT 1"
+	identity, err := scanUserIdentity(dbConn.QueryRow(query, provider, subject))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		logger.Error("Erro ao buscar identidade externa: %v", err)
+		return nil, errors.New("erro interno")
+	}
+
+	return identity, nil
+}
+
+// CreateUserIdentity vincula uma identidade externa a um usuário.
+//
+// Parâmetros:
+// - identity: UserIdentity - A identidade a gravar (UserID, Provider, Subject e Email).
+//
+// Respostas:
+// - int64: O ID da identidade gravada.
+// - error: Se ocorrer um erro durante a gravação (inclusive se o usuário já tiver uma identidade do provedor).
+func CreateUserIdentity(identity UserIdentity) (int64, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return 0, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	query := "INSERT INTO user_identities (user_id, provider, subject, email, created_at) VALUES (?, ?, ?, ?, ?)"
+	result, err := dbConn.Exec(query, identity.UserID, identity.Provider, identity.Subject, identity.Email, time.Now())
+	if err != nil {
+		logger.Error("Erro ao vincular identidade externa: %v", err)
+		return 0, errors.New("erro interno ao vincular identidade")
+	}
+
+	return result.LastInsertId()
+}
+
+// ListUserIdentities lista as identidades externas vinculadas a um usuário.
+//
+// Parâmetros:
+// - userID: int - O ID do usuário.
+//
+// Respostas:
+// - []UserIdentity: As identidades do usuário, da mais antiga para a mais recente.
+// - error: Se ocorrer um erro durante a consulta.
+func ListUserIdentities(userID int) ([]UserIdentity, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return nil, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	rows, err := dbConn.Query("SELECT "+userIdentityColumns+" FROM user_identities WHERE user_id = ? ORDER BY id", userID)
+	if err != nil {
+		logger.Error("Erro ao listar identidades externas: %v", err)
+		return nil, errors.New("erro interno")
+	}
+	defer rows.Close()
+
+	identities := []UserIdentity{}
+	for rows.Next() {
+		identity, err := scanUserIdentity(rows)
+		if err != nil {
+			logger.Error("Erro ao ler identidade externa: %v", err)
+			return nil, errors.New("erro interno")
+		}
+		identities = append(identities, *identity)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("Erro ao listar identidades externas: %v", err)
+		return nil, errors.New("erro interno")
+	}
+
+	return identities, nil
+}
+
+// TouchUserIdentity registra um login pela identidade externa e atualiza o e-mail informado pelo provedor.
+//
+// Parâmetros:
+// - id: int64 - O ID da identidade.
+// - email: string - O e-mail atual no provedor.
+//
+// Respostas:
+// - nil: Se a identidade foi atualizada.
+// - error: Se ocorrer um erro durante a atualização.
+func TouchUserIdentity(id int64, email string) error {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	if _, err := dbConn.Exec("UPDATE user_identities SET email = ?, last_login_at = ? WHERE id = ?", email, time.Now(), id); err != nil {
+		logger.Error("Erro ao atualizar identidade externa: %v", err)
+		return errors.New("erro interno")
+	}
+	return nil
+}
+
+// DeleteUserIdentity desvincula uma identidade externa do usuário.
+//
+// Parâmetros:
+// - userID: int - O ID do usuário dono da identidade.
+// - id: int64 - O ID da identidade.
+//
+// Respostas:
+// - *UserIdentity: A identidade removida, ou nil se ela não existir ou pertencer a outro usuário.
+// - error: Se ocorrer um erro durante a remoção.
+func DeleteUserIdentity(userID int, id int64) (*UserIdentity, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return nil, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	query := "SELECT " + userIdentityColumns + " FROM user_identities WHERE id = ? AND user_id = ?"
+	identity, err := scanUserIdentity(dbConn.QueryRow(query, id, userID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		logger.Error("Erro ao buscar identidade externa: %v", err)
+		return nil, errors.New("erro interno")
+	}
+
+	if _, err := dbConn.Exec("DELETE FROM user_identities WHERE id = ? AND user_id = ?", id, userID); err != nil {
+		logger.Error("Erro ao remover identidade externa: %v", err)
+		return nil, errors.New("erro interno ao remover identidade")
+	}
+	return identity, nil
+}
+
+// scanUserIdentity lê uma identidade a partir das colunas de userIdentityColumns.
+func scanUserIdentity(row interface{ Scan(...any) error }) (*UserIdentity, error) {
+	var identity UserIdentity
+	var lastLoginAt sql.NullTime
+	err := row.Scan(&identity.ID, &identity.UserID, &identity.Provider, &identity.Subject, &identity.Email,
+		&identity.CreatedAt, &lastLoginAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if lastLoginAt.Valid {
+		identity.LastLoginAt = &lastLoginAt.Time
+	}
+	return &identity, nil
+}
@@ -0,0 +1,156 @@
+// pwd: /app/server/modules/login/models/login_attempt_model.go
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"api/db"
+	"api/logger"
+)
+
+// Escopos de contagem de falhas de login.
+const (
+	FailureScopeUsername = "username"
+	FailureScopeIP       = "ip"
+)
+
+// LoginFailure representa o contador de falhas de login de um usuário ou IP
+type LoginFailure struct {
+	Scope         string
+	Identifier    string
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   *time.Time
+}
+
+// GetLoginFailure busca o contador de falhas de login para o escopo e identificador informados.
+//
+// Parâmetros:
+// - scope: string - Escopo do contador (FailureScopeUsername ou FailureScopeIP).
+// - identifier: string - Nome de usuário ou IP.
+//
+// Respostas:
+// - *LoginFailure: O contador encontrado ou nil se não houver falhas registradas.
+// - error: Se ocorrer um erro durante a consulta.
+func GetLoginFailure(scope, identifier string) (*LoginFailure, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return nil, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	failure := LoginFailure{Scope: scope, Identifier: identifier}
+	var lockedUntil sql.NullTime
+	query := "SELECT failures, last_failure_at, locked_until FROM login_failures WHERE scope = ? AND identifier = ? LIMIT 1"
+	err = dbConn.QueryRow(query, scope, identifier).Scan(&failure.Failures, &failure.LastFailureAt, &lockedUntil)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		logger.Error("Erro ao buscar falhas de login: %v", err)
+		return nil, errors.New("erro interno")
+	}
+
+	if lockedUntil.Valid {
+		failure.LockedUntil = &lockedUntil.Time
+	}
+
+	return &failure, nil
+}
+
+// IncrementLoginFailure registra uma nova falha de login e retorna o total acumulado.
+//
+// Falhas mais antigas que a janela informada são descartadas antes da contagem.
+//
+// Parâmetros:
+// - scope: string - Escopo do contador (FailureScopeUsername ou FailureScopeIP).
+// - identifier: string - Nome de usuário ou IP.
+// - window: time.Duration - Janela de tempo em que as falhas são acumuladas.
+//
+// Respostas:
+// - int: Total de falhas dentro da janela.
+// - error: Se ocorrer um erro durante a gravação.
+func IncrementLoginFailure(scope, identifier string, window time.Duration) (int, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return 0, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	now := time.Now()
+	query := `INSERT INTO login_failures (scope, identifier, failures, last_failure_at) VALUES (?, ?, 1, ?)
+		ON DUPLICATE KEY UPDATE failures = IF(last_failure_at < ?, 1, failures + 1), last_failure_at = VALUES(last_failure_at)`
+	if _, err = dbConn.Exec(query, scope, identifier, now, now.Add(-window)); err != nil {
+		logger.Error("Erro ao registrar falha de login: %v", err)
+		return 0, errors.New("erro interno ao registrar falha de login")
+	}
+
+	var failures int
+	err = dbConn.QueryRow("SELECT failures FROM login_failures WHERE scope = ? AND identifier = ?", scope, identifier).Scan(&failures)
+	if err != nil {
+		logger.Error("Erro ao consultar falhas de login: %v", err)
+		return 0, errors.New("erro interno")
+	}
+
+	return failures, nil
+}
+
+// LockLogin bloqueia o login para o escopo e identificador até o horário informado.
+//
+// O contador de falhas é zerado para que a contagem recomece após o término do bloqueio.
+//
+// Parâmetros:
+// - scope: string - Escopo do bloqueio (FailureScopeUsername ou FailureScopeIP).
+// - identifier: string - Nome de usuário ou IP.
+// - until: time.Time - Horário de término do bloqueio.
+//
+// Respostas:
+// - nil: Se o bloqueio foi registrado.
+// - error: Se ocorrer um erro durante a gravação.
+func LockLogin(scope, identifier string, until time.Time) error {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	query := "UPDATE login_failures SET failures = 0, locked_until = ? WHERE scope = ? AND identifier = ?"
+	if _, err = dbConn.Exec(query, until, scope, identifier); err != nil {
+		logger.Error("Erro ao bloquear login: %v", err)
+		return errors.New("erro interno ao bloquear login")
+	}
+
+	return nil
+}
+
+// ClearLoginFailures remove o contador de falhas e qualquer bloqueio do escopo e identificador.
+//
+// Parâmetros:
+// - scope: string - Escopo do contador (FailureScopeUsername ou FailureScopeIP).
+// - identifier: string - Nome de usuário ou IP.
+//
+// Respostas:
+// - bool: true se havia um contador ou bloqueio registrado.
+// - error: Se ocorrer um erro durante a remoção.
+func ClearLoginFailures(scope, identifier string) (bool, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return false, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	result, err := dbConn.Exec("DELETE FROM login_failures WHERE scope = ? AND identifier = ?", scope, identifier)
+	if err != nil {
+		logger.Error("Erro ao remover falhas de login: %v", err)
+		return false, errors.New("erro interno ao remover falhas de login")
+	}
+
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
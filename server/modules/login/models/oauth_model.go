@@ -0,0 +1,326 @@
+// pwd: /app/server/modules/login/models/oauth_model.go
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"api/db"
+	"api/logger"
+)
+
+// OAuthClient representa uma aplicação registrada no servidor OAuth2 (somente o hash do segredo é persistido)
+type OAuthClient struct {
+	ID           int        `json:"id"`
+	ClientID     string     `json:"client_id"`
+	SecretHash   string     `json:"-"` // Vazio nos clientes públicos
+	Name         string     `json:"name"`
+	RedirectURIs []string   `json:"redirect_uris"`
+	Scopes       []string   `json:"scopes"`
+	GrantTypes   []string   `json:"grant_types"`
+	Trusted      bool       `json:"trusted"`
+	CreatedBy    int        `json:"created_by"`
+	CreatedAt    time.Time  `json:"created_at"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Confidential informa se o cliente possui segredo (aplicações com back-end).
+func (c OAuthClient) Confidential() bool {
+	return c.SecretHash != ""
+}
+
+// OAuthConsent representa os escopos autorizados por um usuário para um cliente
+type OAuthConsent struct {
+	ClientID   string    `json:"client_id"`
+	ClientName string    `json:"client_name"`
+	Scopes     []string  `json:"scopes"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// oauthClientColumns lista as colunas lidas por scanOAuthClient, na mesma ordem.
+const oauthClientColumns = "id, client_id, secret_hash, name, redirect_uris, scopes, grant_types, trusted, created_by, created_at, revoked_at"
+
+// CreateOAuthClient grava um novo cliente OAuth2.
+//
+// Parâmetros:
+// - client: OAuthClient - O cliente a gravar.
+//
+// Respostas:
+// - int: O ID do cliente gravado.
+// - error: Se ocorrer um erro durante a gravação.
+func CreateOAuthClient(client OAuthClient) (int, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return 0, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	var secretHash sql.NullString
+	if client.SecretHash != "" {
+		secretHash = sql.NullString{String: client.SecretHash, Valid: true}
+	}
+
+	query := `INSERT INTO oauth_clients (client_id, secret_hash, name, redirect_uris, scopes, grant_types, trusted, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := dbConn.Exec(query, client.ClientID, secretHash, client.Name, strings.Join(client.RedirectURIs, " "),
+		strings.Join(client.Scopes, " "), strings.Join(client.GrantTypes, " "), client.Trusted, client.CreatedBy, client.CreatedAt)
+	if err != nil {
+		logger.Error("Erro ao gravar cliente OAuth2: %v", err)
+		return 0, errors.New("erro interno ao gravar cliente")
+	}
+
+	id, _ := result.LastInsertId()
+	return int(id), nil
+}
+
+// GetOAuthClient busca um cliente OAuth2 pelo client_id.
+//
+// Parâmetros:
+// - clientID: string - O client_id.
+//
+// Respostas:
+// - *OAuthClient: O cliente encontrado (inclusive revogado) ou nil se não existir.
+// - error: Se ocorrer um erro durante a consulta.
+func GetOAuthClient(clientID string) (*OAuthClient, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return nil, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	client, err := scanOAuthClient(dbConn.QueryRow("SELECT "+oauthClientColumns+" FROM oauth_clients WHERE client_id = ? LIMIT 1", clientID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		logger.Error("Erro ao buscar cliente OAuth2: %v", err)
+		return nil, errors.New("erro interno")
+	}
+
+	return client, nil
+}
+
+// ListOAuthClients lista os clientes OAuth2 não revogados.
+//
+// Respostas:
+// - []OAuthClient: Os clientes, em ordem alfabética.
+// - error: Se ocorrer um erro durante a consulta.
+func ListOAuthClients() ([]OAuthClient, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return nil, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	rows, err := dbConn.Query("SELECT " + oauthClientColumns + " FROM oauth_clients WHERE revoked_at IS NULL ORDER BY name")
+	if err != nil {
+		logger.Error("Erro ao listar clientes OAuth2: %v", err)
+		return nil, errors.New("erro interno")
+	}
+	defer rows.Close()
+
+	clients := []OAuthClient{}
+	for rows.Next() {
+		client, err := scanOAuthClient(rows)
+		if err != nil {
+			logger.Error("Erro ao ler cliente OAuth2: %v", err)
+			return nil, errors.New("erro interno")
+		}
+		clients = append(clients, *client)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("Erro ao listar clientes OAuth2: %v", err)
+		return nil, errors.New("erro interno")
+	}
+
+	return clients, nil
+}
+
+// RevokeOAuthClient revoga um cliente OAuth2 e remove os consentimentos dados a ele.
+//
+// Parâmetros:
+// - clientID: string - O client_id.
+//
+// Respostas:
+// - bool: false se o cliente não existir ou já estiver revogado.
+// - error: Se ocorrer um erro durante a atualização.
+func RevokeOAuthClient(clientID string) (bool, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return false, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	result, err := dbConn.Exec("UPDATE oauth_clients SET revoked_at = ? WHERE client_id = ? AND revoked_at IS NULL", time.Now(), clientID)
+	if err != nil {
+		logger.Error("Erro ao revogar cliente OAuth2: %v", err)
+		return false, errors.New("erro interno ao revogar cliente")
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return false, nil
+	}
+
+	if _, err := dbConn.Exec("DELETE FROM oauth_consents WHERE client_id = ?", clientID); err != nil {
+		logger.Error("Erro ao remover consentimentos do cliente OAuth2: %v", err)
+	}
+	return true, nil
+}
+
+// GetOAuthConsent busca os escopos já autorizados por um usuário para um cliente.
+//
+// Parâmetros:
+// - userID: int - O ID do usuário.
+// - clientID: string - O client_id.
+//
+// Respostas:
+// - []string: Os escopos autorizados (vazio se não houver consentimento).
+// - error: Se ocorrer um erro durante a consulta.
+func GetOAuthConsent(userID int, clientID string) ([]string, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return nil, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	var scopes string
+	err = dbConn.QueryRow("SELECT scopes FROM oauth_consents WHERE user_id = ? AND client_id = ?", userID, clientID).Scan(&scopes)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return []string{}, nil
+		}
+		logger.Error("Erro ao buscar consentimento OAuth2: %v", err)
+		return nil, errors.New("erro interno")
+	}
+
+	return strings.Fields(scopes), nil
+}
+
+// SaveOAuthConsent grava (ou substitui) os escopos autorizados por um usuário para um cliente.
+//
+// Parâmetros:
+// - userID: int - O ID do usuário.
+// - clientID: string - O client_id.
+// - scopes: []string - Todos os escopos autorizados.
+//
+// Respostas:
+// - nil: Se o consentimento foi gravado.
+// - error: Se ocorrer um erro durante a gravação.
+func SaveOAuthConsent(userID int, clientID string, scopes []string) error {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	now := time.Now()
+	query := `INSERT INTO oauth_consents (user_id, client_id, scopes, created_at, updated_at) VALUES (?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE scopes = VALUES(scopes), updated_at = VALUES(updated_at)`
+	if _, err := dbConn.Exec(query, userID, clientID, strings.Join(scopes, " "), now, now); err != nil {
+		logger.Error("Erro ao gravar consentimento OAuth2: %v", err)
+		return errors.New("erro interno ao gravar consentimento")
+	}
+	return nil
+}
+
+// ListOAuthConsents lista os consentimentos dados por um usuário a clientes não revogados.
+//
+// Parâmetros:
+// - userID: int - O ID do usuário.
+//
+// Respostas:
+// - []OAuthConsent: Os consentimentos do usuário.
+// - error: Se ocorrer um erro durante a consulta.
+func ListOAuthConsents(userID int) ([]OAuthConsent, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return nil, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	query := `SELECT c.client_id, k.name, c.scopes, c.created_at, c.updated_at FROM oauth_consents c
+		JOIN oauth_clients k ON k.client_id = c.client_id AND k.revoked_at IS NULL
+		WHERE c.user_id = ? ORDER BY k.name`
+	rows, err := dbConn.Query(query, userID)
+	if err != nil {
+		logger.Error("Erro ao listar consentimentos OAuth2: %v", err)
+		return nil, errors.New("erro interno")
+	}
+	defer rows.Close()
+
+	consents := []OAuthConsent{}
+	for rows.Next() {
+		var consent OAuthConsent
+		var scopes string
+		if err := rows.Scan(&consent.ClientID, &consent.ClientName, &scopes, &consent.CreatedAt, &consent.UpdatedAt); err != nil {
+			logger.Error("Erro ao ler consentimento OAuth2: %v", err)
+			return nil, errors.New("erro interno")
+		}
+		consent.Scopes = strings.Fields(scopes)
+		consents = append(consents, consent)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("Erro ao listar consentimentos OAuth2: %v", err)
+		return nil, errors.New("erro interno")
+	}
+
+	return consents, nil
+}
+
+// DeleteOAuthConsent remove o consentimento dado por um usuário a um cliente.
+//
+// Parâmetros:
+// - userID: int - O ID do usuário.
+// - clientID: string - O client_id.
+//
+// Respostas:
+// - bool: false se não houver consentimento.
+// - error: Se ocorrer um erro durante a remoção.
+func DeleteOAuthConsent(userID int, clientID string) (bool, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return false, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	result, err := dbConn.Exec("DELETE FROM oauth_consents WHERE user_id = ? AND client_id = ?", userID, clientID)
+	if err != nil {
+		logger.Error("Erro ao remover consentimento OAuth2: %v", err)
+		return false, errors.New("erro interno ao remover consentimento")
+	}
+
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
+
+// scanOAuthClient lê um cliente a partir das colunas de oauthClientColumns.
+func scanOAuthClient(row interface{ Scan(...any) error }) (*OAuthClient, error) {
+	var client OAuthClient
+	var secretHash sql.NullString
+	var redirectURIs, scopes, grantTypes string
+	var revokedAt sql.NullTime
+	err := row.Scan(&client.ID, &client.ClientID, &secretHash, &client.Name, &redirectURIs, &scopes, &grantTypes,
+		&client.Trusted, &client.CreatedBy, &client.CreatedAt, &revokedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	client.SecretHash = secretHash.String
+	client.RedirectURIs = strings.Fields(redirectURIs)
+	client.Scopes = strings.Fields(scopes)
+	client.GrantTypes = strings.Fields(grantTypes)
+	if revokedAt.Valid {
+		client.RevokedAt = &revokedAt.Time
+	}
+	return &client, nil
+}
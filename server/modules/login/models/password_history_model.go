@@ -0,0 +1,93 @@
+// pwd: /app/server/modules/login/models/password_history_model.go
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"api/db"
+	"api/logger"
+)
+
+// GetPasswordHistory retorna os hashes das senhas anteriores do usuário, da mais recente para a mais antiga.
+//
+// Parâmetros:
+// - userID: int - O ID do usuário.
+// - limit: int - A quantidade máxima de senhas retornadas.
+//
+// Respostas:
+// - []string: Os hashes das senhas anteriores.
+// - error: Se ocorrer um erro durante a consulta.
+func GetPasswordHistory(userID, limit int) ([]string, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return nil, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	rows, err := dbConn.Query("SELECT password_hash FROM password_history WHERE user_id = ? ORDER BY id DESC LIMIT ?", userID, limit)
+	if err != nil {
+		logger.Error("Erro ao consultar o histórico de senhas: %v", err)
+		return nil, errors.New("erro interno")
+	}
+	defer rows.Close()
+
+	hashes := []string{}
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			logger.Error("Erro ao ler o histórico de senhas: %v", err)
+			return nil, errors.New("erro interno")
+		}
+		hashes = append(hashes, hash)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("Erro ao consultar o histórico de senhas: %v", err)
+		return nil, errors.New("erro interno")
+	}
+
+	return hashes, nil
+}
+
+// AddPasswordHistory grava o hash de uma senha substituída e remove as entradas além das keep mais recentes.
+//
+// Parâmetros:
+// - userID: int - O ID do usuário.
+// - passwordHash: string - O hash da senha substituída.
+// - keep: int - A quantidade de senhas mantidas no histórico.
+//
+// Respostas:
+// - nil: Se o histórico foi atualizado.
+// - error: Se ocorrer um erro durante a gravação.
+func AddPasswordHistory(userID int, passwordHash string, keep int) error {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	if _, err := dbConn.Exec("INSERT INTO password_history (user_id, password_hash, created_at) VALUES (?, ?, ?)",
+		userID, passwordHash, time.Now()); err != nil {
+		logger.Error("Erro ao gravar o histórico de senhas: %v", err)
+		return errors.New("erro interno")
+	}
+
+	// Remove as entradas mais antigas que a keep-ésima mais recente
+	var cutoff int64
+	err = dbConn.QueryRow("SELECT id FROM password_history WHERE user_id = ? ORDER BY id DESC LIMIT 1 OFFSET ?", userID, keep-1).Scan(&cutoff)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		logger.Error("Erro ao consultar o histórico de senhas: %v", err)
+		return errors.New("erro interno")
+	}
+	if _, err := dbConn.Exec("DELETE FROM password_history WHERE user_id = ? AND id < ?", userID, cutoff); err != nil {
+		logger.Error("Erro ao limpar o histórico de senhas: %v", err)
+		return errors.New("erro interno")
+	}
+	return nil
+}
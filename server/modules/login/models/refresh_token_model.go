@@ -0,0 +1,169 @@
+// pwd: /app/server/modules/login/models/refresh_token_model.go
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"api/db"
+	"api/logger"
+)
+
+// RefreshToken representa um refresh token armazenado (somente o hash do valor é persistido)
+type RefreshToken struct {
+	ID        int64
+	UserID    int
+	FamilyID  string
+	TokenHash string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	RotatedAt *time.Time
+	RevokedAt *time.Time
+}
+
+// CreateRefreshToken grava um novo refresh token no banco de dados.
+//
+// Parâmetros:
+// - userID: int - ID do usuário dono do token.
+// - familyID: string - Identificador da família de tokens (um por login).
+// - tokenHash: string - Hash SHA-256 do token.
+// - expiresAt: time.Time - Data e hora de expiração.
+//
+// Respostas:
+// - nil: Se o token foi gravado com sucesso.
+// - error: Se ocorrer um erro durante a gravação.
+func CreateRefreshToken(userID int, familyID, tokenHash string, expiresAt time.Time) error {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	query := "INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at, created_at) VALUES (?, ?, ?, ?, ?)"
+	if _, err = dbConn.Exec(query, userID, familyID, tokenHash, expiresAt, time.Now()); err != nil {
+		logger.Error("Erro ao gravar refresh token: %v", err)
+		return errors.New("erro interno ao gravar refresh token")
+	}
+
+	return nil
+}
+
+// GetRefreshTokenByHash busca um refresh token pelo hash.
+//
+// Parâmetros:
+// - tokenHash: string - Hash SHA-256 do token.
+//
+// Respostas:
+// - *RefreshToken: O token encontrado ou nil se não existir.
+// - error: Se ocorrer um erro durante a consulta.
+func GetRefreshTokenByHash(tokenHash string) (*RefreshToken, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return nil, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	var token RefreshToken
+	var rotatedAt, revokedAt sql.NullTime
+	query := "SELECT id, user_id, family_id, token_hash, expires_at, created_at, rotated_at, revoked_at FROM refresh_tokens WHERE token_hash = ? LIMIT 1"
+	err = dbConn.QueryRow(query, tokenHash).Scan(&token.ID, &token.UserID, &token.FamilyID, &token.TokenHash, &token.ExpiresAt, &token.CreatedAt, &rotatedAt, &revokedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		logger.Error("Erro ao buscar refresh token: %v", err)
+		return nil, errors.New("erro interno")
+	}
+
+	if rotatedAt.Valid {
+		token.RotatedAt = &rotatedAt.Time
+	}
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+
+	return &token, nil
+}
+
+// MarkRefreshTokenRotated marca um refresh token como utilizado (rotacionado).
+//
+// A atualização só ocorre se o token ainda estiver ativo, garantindo que duas requisições
+// simultâneas com o mesmo token não consigam rotacioná-lo duas vezes.
+//
+// Parâmetros:
+// - id: int64 - ID do refresh token.
+//
+// Respostas:
+// - bool: true se o token foi rotacionado por esta chamada.
+// - error: Se ocorrer um erro durante a atualização.
+func MarkRefreshTokenRotated(id int64) (bool, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return false, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	query := "UPDATE refresh_tokens SET rotated_at = ? WHERE id = ? AND rotated_at IS NULL AND revoked_at IS NULL"
+	result, err := dbConn.Exec(query, time.Now(), id)
+	if err != nil {
+		logger.Error("Erro ao rotacionar refresh token: %v", err)
+		return false, errors.New("erro interno ao rotacionar refresh token")
+	}
+
+	affected, _ := result.RowsAffected()
+	return affected == 1, nil
+}
+
+// RevokeRefreshTokenFamily revoga todos os refresh tokens de uma família.
+//
+// Parâmetros:
+// - familyID: string - Identificador da família de tokens.
+//
+// Respostas:
+// - nil: Se a família foi revogada.
+// - error: Se ocorrer um erro durante a atualização.
+func RevokeRefreshTokenFamily(familyID string) error {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	query := "UPDATE refresh_tokens SET revoked_at = ? WHERE family_id = ? AND revoked_at IS NULL"
+	if _, err = dbConn.Exec(query, time.Now(), familyID); err != nil {
+		logger.Error("Erro ao revogar família de refresh tokens: %v", err)
+		return errors.New("erro interno ao revogar refresh tokens")
+	}
+
+	return nil
+}
+
+// RevokeUserRefreshTokens revoga todos os refresh tokens ativos de um usuário.
+//
+// Parâmetros:
+// - userID: int - O ID do usuário.
+//
+// Respostas:
+// - nil: Se os tokens foram revogados.
+// - error: Se ocorrer um erro durante a atualização.
+func RevokeUserRefreshTokens(userID int) error {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	query := "UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL"
+	if _, err = dbConn.Exec(query, time.Now(), userID); err != nil {
+		logger.Error("Erro ao revogar refresh tokens do usuário: %v", err)
+		return errors.New("erro interno ao revogar refresh tokens")
+	}
+
+	return nil
+}
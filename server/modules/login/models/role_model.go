@@ -0,0 +1,276 @@
+// pwd: /app/server/modules/login/models/role_model.go
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"api/db"
+	"api/logger"
+	"api/utils"
+)
+
+// DefaultRole é o papel atribuído aos usuários cadastrados sem papéis explícitos.
+const DefaultRole = "user"
+
+// AdminRole é o papel de administrador, que corresponde ao access_level legado ADMIN_ACCESS_LEVEL.
+const AdminRole = "admin"
+
+// access_level mínimo de um administrador (ADMIN_ACCESS_LEVEL), o mesmo usado pela migração para papéis
+var adminAccessLevel = utils.GetEnvInt("ADMIN_ACCESS_LEVEL", 2)
+
+// Role representa um papel e as permissões concedidas por ele
+type Role struct {
+	ID          int      `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Require2FA  bool     `json:"require_2fa"`
+	Permissions []string `json:"permissions"`
+}
+
+// ErrUnknownRole indica que algum dos papéis informados não existe.
+var ErrUnknownRole = errors.New("papel inexistente")
+
+// GetUserRoles retorna os nomes dos papéis de um usuário.
+//
+// Parâmetros:
+// - userID: int - O ID do usuário.
+//
+// Respostas:
+// - []string: Os papéis do usuário, em ordem alfabética.
+// - error: Se ocorrer um erro durante a consulta.
+func GetUserRoles(userID int) ([]string, error) {
+	query := "SELECT r.name FROM user_roles ur JOIN roles r ON r.id = ur.role_id WHERE ur.user_id = ? ORDER BY r.name"
+	return queryNames(query, userID)
+}
+
+// GetUserPermissions retorna as permissões concedidas a um usuário por todos os seus papéis.
+//
+// Parâmetros:
+// - userID: int - O ID do usuário.
+//
+// Respostas:
+// - []string: As permissões do usuário, sem repetição.
+// - error: Se ocorrer um erro durante a consulta.
+func GetUserPermissions(userID int) ([]string, error) {
+	query := `SELECT DISTINCT p.name FROM user_roles ur
+		JOIN role_permissions rp ON rp.role_id = ur.role_id
+		JOIN permissions p ON p.id = rp.permission_id
+		WHERE ur.user_id = ? ORDER BY p.name`
+	return queryNames(query, userID)
+}
+
+// GetRolesPermissions retorna as permissões concedidas por um conjunto de papéis.
+//
+// Parâmetros:
+// - roles: []string - Os nomes dos papéis. Papéis inexistentes não concedem permissões.
+//
+// Respostas:
+// - []string: As permissões dos papéis, sem repetição.
+// - error: Se ocorrer um erro durante a consulta.
+func GetRolesPermissions(roles []string) ([]string, error) {
+	if len(roles) == 0 {
+		return []string{}, nil
+	}
+
+	args := make([]interface{}, len(roles))
+	for i, role := range roles {
+		args[i] = strings.TrimSpace(role)
+	}
+	query := `SELECT DISTINCT p.name FROM roles r
+		JOIN role_permissions rp ON rp.role_id = r.id
+		JOIN permissions p ON p.id = rp.permission_id
+		WHERE r.name IN (?` + strings.Repeat(", ?", len(roles)-1) + `) ORDER BY p.name`
+	return queryNames(query, args...)
+}
+
+// SetUserRoles substitui os papéis de um usuário.
+//
+// Parâmetros:
+// - userID: int - O ID do usuário.
+// - roles: []string - Os nomes dos novos papéis.
+//
+// Respostas:
+// - nil: Se os papéis foram atualizados.
+// - ErrUnknownRole: Se algum papel não existir (nenhuma alteração é feita).
+// - error: Se ocorrer um erro durante a atualização.
+func SetUserRoles(userID int, roles []string) error {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	tx, err := dbConn.Begin()
+	if err != nil {
+		logger.Error("Erro ao iniciar transação: %v", err)
+		return errors.New("erro interno")
+	}
+	defer tx.Rollback()
+
+	if err := setUserRoles(tx, userID, roles); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("Erro ao confirmar transação: %v", err)
+		return errors.New("erro interno ao atualizar papéis")
+	}
+	return nil
+}
+
+// setUserRoles substitui os papéis de um usuário dentro da transação informada.
+// Retorna ErrUnknownRole, antes de qualquer alteração, se algum papel não existir.
+func setUserRoles(tx *sql.Tx, userID int, roles []string) error {
+	roleIDs := make([]int, 0, len(roles))
+	for _, name := range roles {
+		var id int
+		if err := tx.QueryRow("SELECT id FROM roles WHERE name = ?", strings.TrimSpace(name)).Scan(&id); err != nil {
+			logger.Warn("Papel inexistente: %q", name)
+			return ErrUnknownRole
+		}
+		roleIDs = append(roleIDs, id)
+	}
+
+	if _, err := tx.Exec("DELETE FROM user_roles WHERE user_id = ?", userID); err != nil {
+		logger.Error("Erro ao remover papéis do usuário: %v", err)
+		return errors.New("erro interno ao atualizar papéis")
+	}
+	for _, roleID := range roleIDs {
+		if _, err := tx.Exec("INSERT IGNORE INTO user_roles (user_id, role_id) VALUES (?, ?)", userID, roleID); err != nil {
+			logger.Error("Erro ao atribuir papel ao usuário: %v", err)
+			return errors.New("erro interno ao atualizar papéis")
+		}
+	}
+
+	// Mantém o access_level legado coerente com o papel admin
+	query := "UPDATE users SET access_level = GREATEST(LEAST(access_level, ?), 0) WHERE id = ?"
+	args := []interface{}{adminAccessLevel - 1, userID}
+	if hasRole(roles, AdminRole) {
+		query = "UPDATE users SET access_level = GREATEST(access_level, ?) WHERE id = ?"
+		args[0] = adminAccessLevel
+	}
+	if _, err := tx.Exec(query, args...); err != nil {
+		logger.Error("Erro ao atualizar o access_level do usuário: %v", err)
+		return errors.New("erro interno ao atualizar papéis")
+	}
+	return nil
+}
+
+// ListRoles lista os papéis cadastrados e as suas permissões.
+//
+// Respostas:
+// - []Role: Os papéis, em ordem alfabética.
+// - error: Se ocorrer um erro durante a consulta.
+func ListRoles() ([]Role, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return nil, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	query := `SELECT r.id, r.name, r.description, r.require_2fa, p.name FROM roles r
+		LEFT JOIN role_permissions rp ON rp.role_id = r.id
+		LEFT JOIN permissions p ON p.id = rp.permission_id
+		ORDER BY r.name, p.name`
+	rows, err := dbConn.Query(query)
+	if err != nil {
+		logger.Error("Erro ao listar papéis: %v", err)
+		return nil, errors.New("erro interno")
+	}
+	defer rows.Close()
+
+	roles := []Role{}
+	for rows.Next() {
+		var role Role
+		var permission *string
+		if err := rows.Scan(&role.ID, &role.Name, &role.Description, &role.Require2FA, &permission); err != nil {
+			logger.Error("Erro ao ler papel: %v", err)
+			return nil, errors.New("erro interno")
+		}
+
+		if len(roles) == 0 || roles[len(roles)-1].ID != role.ID {
+			role.Permissions = []string{}
+			roles = append(roles, role)
+		}
+		if permission != nil {
+			last := &roles[len(roles)-1]
+			last.Permissions = append(last.Permissions, *permission)
+		}
+	}
+
+	return roles, rows.Err()
+}
+
+// SetRoleRequire2FA define se os usuários de um papel são obrigados a usar 2FA.
+//
+// Parâmetros:
+// - name: string - O nome do papel.
+// - require: bool - true para exigir 2FA.
+//
+// Respostas:
+// - nil: Se o papel foi atualizado.
+// - ErrUnknownRole: Se o papel não existir.
+// - error: Se ocorrer um erro durante a atualização.
+func SetRoleRequire2FA(name string, require bool) error {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	var id int
+	if err := dbConn.QueryRow("SELECT id FROM roles WHERE name = ?", strings.TrimSpace(name)).Scan(&id); err != nil {
+		logger.Warn("Papel inexistente: %q", name)
+		return ErrUnknownRole
+	}
+
+	if _, err := dbConn.Exec("UPDATE roles SET require_2fa = ? WHERE id = ?", require, id); err != nil {
+		logger.Error("Erro ao atualizar papel: %v", err)
+		return errors.New("erro interno ao atualizar papel")
+	}
+	return nil
+}
+
+// queryNames executa uma consulta que retorna uma única coluna de texto por linha.
+func queryNames(query string, args ...interface{}) ([]string, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return nil, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	rows, err := dbConn.Query(query, args...)
+	if err != nil {
+		logger.Error("Erro ao consultar papéis/permissões: %v", err)
+		return nil, errors.New("erro interno")
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			logger.Error("Erro ao ler papéis/permissões: %v", err)
+			return nil, errors.New("erro interno")
+		}
+		names = append(names, name)
+	}
+
+	return names, rows.Err()
+}
+
+// hasRole informa se a lista de papéis contém o papel informado.
+func hasRole(roles []string, role string) bool {
+	for _, name := range roles {
+		if strings.TrimSpace(name) == role {
+			return true
+		}
+	}
+	return false
+}
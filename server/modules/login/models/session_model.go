@@ -0,0 +1,213 @@
+// pwd: /app/server/modules/login/models/session_model.go
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"api/db"
+	"api/logger"
+)
+
+// Session representa uma sessão de login (um dispositivo/navegador em que o usuário está logado)
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     int        `json:"user_id"`
+	UserAgent  string     `json:"user_agent"`
+	Device     string     `json:"device"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// sessionColumns lista as colunas lidas por scanSession, na mesma ordem.
+const sessionColumns = "id, user_id, user_agent, device, ip, created_at, last_seen_at, revoked_at"
+
+// CreateSession grava uma nova sessão de login no banco de dados.
+//
+// Parâmetros:
+// - session: Session - Sessão a ser gravada (ID, UserID, UserAgent, Device e IP).
+//
+// Respostas:
+// - nil: Se a sessão foi gravada com sucesso.
+// - error: Se ocorrer um erro durante a gravação.
+func CreateSession(session Session) error {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	now := time.Now()
+	query := "INSERT INTO sessions (id, user_id, user_agent, device, ip, created_at, last_seen_at) VALUES (?, ?, ?, ?, ?, ?, ?)"
+	if _, err = dbConn.Exec(query, session.ID, session.UserID, session.UserAgent, session.Device, session.IP, now, now); err != nil {
+		logger.Error("Erro ao gravar sessão: %v", err)
+		return errors.New("erro interno ao gravar sessão")
+	}
+
+	return nil
+}
+
+// GetSession busca uma sessão pelo ID.
+//
+// Parâmetros:
+// - id: string - ID da sessão.
+//
+// Respostas:
+// - *Session: A sessão encontrada ou nil se não existir.
+// - error: Se ocorrer um erro durante a consulta.
+func GetSession(id string) (*Session, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return nil, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	session, err := scanSession(dbConn.QueryRow("SELECT "+sessionColumns+" FROM sessions WHERE id = ? LIMIT 1", id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		logger.Error("Erro ao buscar sessão: %v", err)
+		return nil, errors.New("erro interno")
+	}
+
+	return session, nil
+}
+
+// ListActiveSessions lista as sessões ativas de um usuário, da mais recente para a mais antiga.
+//
+// Uma sessão está ativa se não foi revogada e se a sua família ainda tem um refresh token válido (não rotacionado,
+// não revogado e não expirado). As sessões cujo refresh token expirou (JWT_REFRESH_EXPIRE) ficam de fora.
+//
+// Parâmetros:
+// - userID: int - O ID do usuário.
+//
+// Respostas:
+// - []Session: As sessões ativas.
+// - error: Se ocorrer um erro durante a consulta.
+func ListActiveSessions(userID int) ([]Session, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return nil, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	query := "SELECT " + sessionColumns + " FROM sessions WHERE user_id = ? AND revoked_at IS NULL AND EXISTS (" +
+		"SELECT 1 FROM refresh_tokens r WHERE r.family_id = sessions.id AND r.rotated_at IS NULL AND r.revoked_at IS NULL AND r.expires_at > ?" +
+		") ORDER BY last_seen_at DESC"
+	rows, err := dbConn.Query(query, userID, time.Now())
+	if err != nil {
+		logger.Error("Erro ao listar sessões: %v", err)
+		return nil, errors.New("erro interno")
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			logger.Error("Erro ao ler sessão: %v", err)
+			return nil, errors.New("erro interno")
+		}
+		sessions = append(sessions, *session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// TouchSession atualiza o horário e o IP do último uso de uma sessão.
+//
+// Parâmetros:
+// - id: string - ID da sessão.
+// - ip: string - IP de origem da requisição (ignorado se vazio).
+//
+// Respostas:
+// - nil: Se a sessão foi atualizada.
+// - error: Se ocorrer um erro durante a atualização.
+func TouchSession(id, ip string) error {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	query := "UPDATE sessions SET last_seen_at = ?, ip = IF(? = '', ip, ?) WHERE id = ? AND revoked_at IS NULL"
+	if _, err = dbConn.Exec(query, time.Now(), ip, ip, id); err != nil {
+		logger.Error("Erro ao atualizar sessão: %v", err)
+		return errors.New("erro interno ao atualizar sessão")
+	}
+
+	return nil
+}
+
+// RevokeSessionByID marca uma sessão como revogada.
+//
+// Parâmetros:
+// - id: string - ID da sessão.
+//
+// Respostas:
+// - bool: true se a sessão estava ativa e foi revogada por esta chamada.
+// - error: Se ocorrer um erro durante a atualização.
+func RevokeSessionByID(id string) (bool, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return false, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	result, err := dbConn.Exec("UPDATE sessions SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL", time.Now(), id)
+	if err != nil {
+		logger.Error("Erro ao revogar sessão: %v", err)
+		return false, errors.New("erro interno ao revogar sessão")
+	}
+
+	affected, _ := result.RowsAffected()
+	return affected == 1, nil
+}
+
+// RevokeUserSessions marca como revogadas todas as sessões ativas de um usuário.
+//
+// Parâmetros:
+// - userID: int - O ID do usuário.
+//
+// Respostas:
+// - nil: Se as sessões foram revogadas.
+// - error: Se ocorrer um erro durante a atualização.
+func RevokeUserSessions(userID int) error {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	if _, err = dbConn.Exec("UPDATE sessions SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL", time.Now(), userID); err != nil {
+		logger.Error("Erro ao revogar sessões do usuário: %v", err)
+		return errors.New("erro interno ao revogar sessões")
+	}
+
+	return nil
+}
+
+// scanSession lê uma sessão de uma linha de resultado (colunas em sessionColumns).
+func scanSession(row interface{ Scan(...any) error }) (*Session, error) {
+	var session Session
+	var revokedAt sql.NullTime
+	err := row.Scan(&session.ID, &session.UserID, &session.UserAgent, &session.Device, &session.IP,
+		&session.CreatedAt, &session.LastSeenAt, &revokedAt)
+	if err != nil {
+		return nil, err
+	}
+	if revokedAt.Valid {
+		session.RevokedAt = &revokedAt.Time
+	}
+	return &session, nil
+}
@@ -0,0 +1,258 @@
+// pwd: /app/server/modules/login/models/two_factor_model.go
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"api/db"
+	"api/logger"
+)
+
+// TwoFactorState representa a configuração de TOTP de um usuário
+type TwoFactorState struct {
+	Secret   string // Segredo criptografado (vazio se o usuário nunca iniciou o cadastro)
+	Enabled  bool   // true após a confirmação do cadastro
+	LastStep int64  // Último passo TOTP utilizado (anti-replay)
+}
+
+// GetTwoFactorState retorna a configuração de TOTP de um usuário.
+//
+// Parâmetros:
+// - userID: int - O ID do usuário.
+//
+// Respostas:
+// - *TwoFactorState: A configuração de TOTP.
+// - error: ErrUserNotFound se o usuário não existir, ou erro interno.
+func GetTwoFactorState(userID int) (*TwoFactorState, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return nil, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	var state TwoFactorState
+	var secret sql.NullString
+	query := "SELECT totp_secret, totp_enabled_at IS NOT NULL, totp_last_step FROM users WHERE id = ? LIMIT 1"
+	if err := dbConn.QueryRow(query, userID).Scan(&secret, &state.Enabled, &state.LastStep); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		logger.Error("Erro ao buscar configuração de 2FA: %v", err)
+		return nil, errors.New("erro interno")
+	}
+	state.Secret = secret.String
+
+	return &state, nil
+}
+
+// SetPendingTOTPSecret grava o segredo de um cadastro de TOTP ainda não confirmado.
+//
+// Parâmetros:
+// - userID: int - O ID do usuário.
+// - secret: string - O segredo criptografado.
+//
+// Respostas:
+// - bool: false se o usuário já tiver o 2FA ativado (nada é alterado).
+// - error: Se ocorrer um erro durante a atualização.
+func SetPendingTOTPSecret(userID int, secret string) (bool, error) {
+	return execTwoFactorUpdate("UPDATE users SET totp_secret = ?, totp_last_step = 0 WHERE id = ? AND totp_enabled_at IS NULL", secret, userID)
+}
+
+// EnableTOTP confirma o cadastro de TOTP pendente de um usuário.
+//
+// Parâmetros:
+// - userID: int - O ID do usuário.
+// - step: int64 - Passo do código usado na confirmação (não poderá ser reutilizado).
+//
+// Respostas:
+// - bool: false se não houver cadastro pendente.
+// - error: Se ocorrer um erro durante a atualização.
+func EnableTOTP(userID int, step int64) (bool, error) {
+	query := "UPDATE users SET totp_enabled_at = ?, totp_last_step = ? WHERE id = ? AND totp_secret IS NOT NULL AND totp_enabled_at IS NULL"
+	return execTwoFactorUpdate(query, time.Now(), step, userID)
+}
+
+// UseTOTPStep registra o passo TOTP utilizado, recusando passos iguais ou anteriores ao último.
+//
+// A verificação e a gravação ocorrem na mesma instrução, para que o mesmo código não seja aceito
+// duas vezes em requisições simultâneas.
+//
+// Parâmetros:
+// - userID: int - O ID do usuário.
+// - step: int64 - Passo do código aceito.
+//
+// Respostas:
+// - bool: false se o passo já tiver sido utilizado.
+// - error: Se ocorrer um erro durante a atualização.
+func UseTOTPStep(userID int, step int64) (bool, error) {
+	return execTwoFactorUpdate("UPDATE users SET totp_last_step = ? WHERE id = ? AND totp_last_step < ?", step, userID, step)
+}
+
+// DisableTOTP remove o TOTP e os códigos de recuperação de um usuário.
+//
+// Parâmetros:
+// - userID: int - O ID do usuário.
+//
+// Respostas:
+// - bool: true se o usuário tinha 2FA ativado ou cadastro pendente.
+// - error: Se ocorrer um erro durante a remoção.
+func DisableTOTP(userID int) (bool, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return false, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	tx, err := dbConn.Begin()
+	if err != nil {
+		logger.Error("Erro ao iniciar transação: %v", err)
+		return false, errors.New("erro interno")
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = 0 WHERE id = ? AND totp_secret IS NOT NULL", userID)
+	if err != nil {
+		logger.Error("Erro ao desativar 2FA: %v", err)
+		return false, errors.New("erro interno ao desativar 2FA")
+	}
+	if _, err := tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", userID); err != nil {
+		logger.Error("Erro ao remover códigos de recuperação: %v", err)
+		return false, errors.New("erro interno ao desativar 2FA")
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("Erro ao confirmar transação: %v", err)
+		return false, errors.New("erro interno ao desativar 2FA")
+	}
+
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
+
+// ReplaceRecoveryCodes substitui os códigos de recuperação de um usuário.
+//
+// Parâmetros:
+// - userID: int - O ID do usuário.
+// - codeHashes: []string - Hashes SHA-256 dos novos códigos.
+//
+// Respostas:
+// - nil: Se os códigos foram substituídos.
+// - error: Se ocorrer um erro durante a gravação.
+func ReplaceRecoveryCodes(userID int, codeHashes []string) error {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	tx, err := dbConn.Begin()
+	if err != nil {
+		logger.Error("Erro ao iniciar transação: %v", err)
+		return errors.New("erro interno")
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", userID); err != nil {
+		logger.Error("Erro ao remover códigos de recuperação: %v", err)
+		return errors.New("erro interno ao gravar códigos de recuperação")
+	}
+	now := time.Now()
+	for _, hash := range codeHashes {
+		if _, err := tx.Exec("INSERT INTO recovery_codes (user_id, code_hash, created_at) VALUES (?, ?, ?)", userID, hash, now); err != nil {
+			logger.Error("Erro ao gravar código de recuperação: %v", err)
+			return errors.New("erro interno ao gravar códigos de recuperação")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("Erro ao confirmar transação: %v", err)
+		return errors.New("erro interno ao gravar códigos de recuperação")
+	}
+	return nil
+}
+
+// UseRecoveryCode marca como utilizado um código de recuperação do usuário.
+//
+// Parâmetros:
+// - userID: int - O ID do usuário.
+// - codeHash: string - Hash SHA-256 do código informado.
+//
+// Respostas:
+// - bool: true se o código existia e ainda não tinha sido utilizado.
+// - error: Se ocorrer um erro durante a atualização.
+func UseRecoveryCode(userID int, codeHash string) (bool, error) {
+	return execTwoFactorUpdate("UPDATE recovery_codes SET used_at = ? WHERE user_id = ? AND code_hash = ? AND used_at IS NULL", time.Now(), userID, codeHash)
+}
+
+// CountRecoveryCodes retorna a quantidade de códigos de recuperação ainda não utilizados.
+//
+// Parâmetros:
+// - userID: int - O ID do usuário.
+//
+// Respostas:
+// - int: A quantidade de códigos disponíveis.
+// - error: Se ocorrer um erro durante a consulta.
+func CountRecoveryCodes(userID int) (int, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return 0, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	var count int
+	if err := dbConn.QueryRow("SELECT COUNT(*) FROM recovery_codes WHERE user_id = ? AND used_at IS NULL", userID).Scan(&count); err != nil {
+		logger.Error("Erro ao contar códigos de recuperação: %v", err)
+		return 0, errors.New("erro interno")
+	}
+	return count, nil
+}
+
+// UserRequiresTwoFactor informa se algum papel do usuário exige 2FA.
+//
+// Parâmetros:
+// - userID: int - O ID do usuário.
+//
+// Respostas:
+// - bool: true se o usuário for obrigado a usar 2FA.
+// - error: Se ocorrer um erro durante a consulta.
+func UserRequiresTwoFactor(userID int) (bool, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return false, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	var count int
+	query := "SELECT COUNT(*) FROM user_roles ur JOIN roles r ON r.id = ur.role_id WHERE ur.user_id = ? AND r.require_2fa = 1"
+	if err := dbConn.QueryRow(query, userID).Scan(&count); err != nil {
+		logger.Error("Erro ao verificar a exigência de 2FA: %v", err)
+		return false, errors.New("erro interno")
+	}
+	return count > 0, nil
+}
+
+// execTwoFactorUpdate executa uma atualização da configuração de 2FA e informa se alguma linha foi alterada.
+func execTwoFactorUpdate(query string, args ...interface{}) (bool, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return false, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	result, err := dbConn.Exec(query, args...)
+	if err != nil {
+		logger.Error("Erro ao atualizar 2FA: %v", err)
+		return false, errors.New("erro interno")
+	}
+
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
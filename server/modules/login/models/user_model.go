@@ -5,32 +5,67 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"api/db"
 	"api/logger"
 	"api/server/modules/login/auth_utils"
-
-	"golang.org/x/crypto/bcrypt"
 )
 
+// ErrUserNotFound indica que o usuário não existe.
+var ErrUserNotFound = errors.New("usuário não encontrado")
+
 // User representa a estrutura do usuário no banco de dados
 type User struct {
-	ID          int       `json:"id"`
-	Name        string    `json:"name"`
-	Username    string    `json:"username"`
-	Email       string    `json:"email"`
-	Password    string    `json:"-"`
-	AccessLevel int       `json:"access_level"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID              int        `json:"id"`
+	Name            string     `json:"name"`
+	Username        string     `json:"username"`
+	Email           string     `json:"email"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	Password        string     `json:"-"`
+	Roles           []string   `json:"roles"`
+	AccessLevel     int        `json:"access_level"` // Legado: mantido em sincronia com o papel admin (SetUserRoles)
+	Active          bool       `json:"active"`
+	TwoFactor       bool       `json:"two_factor_enabled"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       *time.Time `json:"updated_at,omitempty"`
+
+	TokenVersion int `json:"-"`
+}
+
+// TokenSubject retorna os dados do usuário que são gravados no token de acesso.
+func (u User) TokenSubject() auth_utils.TokenSubject {
+	return auth_utils.TokenSubject{
+		UserID:       u.ID,
+		Username:     u.Username,
+		Roles:        u.Roles,
+		TokenVersion: u.TokenVersion,
+	}
+}
+
+// EmailVerified informa se o e-mail atual do usuário foi confirmado.
+func (u User) EmailVerified() bool {
+	return u.EmailVerifiedAt != nil
 }
 
 // LoginRequest representa os dados recebidos para login
 type LoginRequest struct {
-	Name     string `json:"name"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Identifier string `json:"identifier"` // Username ou e-mail
+	Username   string `json:"username"`   // Alternativa a identifier
+	Email      string `json:"email"`      // Alternativa a identifier
+	Password   string `json:"password"`
+	Device     string `json:"device"` // Nome do dispositivo exibido na lista de sessões (opcional)
+}
+
+// LoginIdentifier retorna o identificador informado no login: identifier, username ou email, nessa ordem.
+func (r LoginRequest) LoginIdentifier() string {
+	for _, value := range []string{r.Identifier, r.Username, r.Email} {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+	return ""
 }
 
 // AuthResponse estrutura para a resposta de autenticação
@@ -40,16 +75,19 @@ type AuthResponse struct {
 	TimeRemaining string `json:"time_remaining"`
 }
 
-// Authenticate verifica as credenciais do usuário e retorna um token JWT com as informações do usuário
+// Authenticate verifica a senha local do usuário e retorna o usuário autenticado
+//
+// A busca do usuário pelo e-mail e a emissão dos tokens ficam a cargo de services.Login.
 //
 // Parâmetros:
-// - loginData: LoginRequest - Dados para autenticação (nome, username, email e senha).
+// - username: string - O username do usuário.
+// - password: string - A senha informada.
 //
 // Respostas:
-// - 200 OK: Retorna o token JWT, informações do usuário e tempo restante até a expiração do token.
-// - 400 Bad Request: Se as credenciais estiverem incorretas ou faltando.
-// - 500 Internal Server Error: Se ocorrer um erro durante o processo de autenticação.
-func Authenticate(loginData LoginRequest) (*AuthResponse, error) {
+// - *User: O usuário autenticado.
+// - error: ErrUserNotFound se o username não existir, ou erro se a senha estiver incorreta, se a conta estiver
+// desativada ou se ocorrer um erro durante a autenticação.
+func Authenticate(username, password string) (*User, error) {
 	dbConn, err := db.DbConnection()
 	if err != nil {
 		logger.Error("Erro ao conectar ao banco de dados: %v", err)
@@ -58,59 +96,42 @@ func Authenticate(loginData LoginRequest) (*AuthResponse, error) {
 	defer dbConn.Close()
 
 	var user User
-	var query string
-	var args []interface{}
-
-	// Verifica se foi passado o Name, Username ou o Email
-	if loginData.Username != "" {
-		// Se passar o username, usar o username na consulta
-		query = "SELECT id, username, email, password, access_level FROM users WHERE username = ? LIMIT 1"
-		args = append(args, loginData.Username)
-	} else {
-		return nil, errors.New("username é necessário para o login")
-	}
+	var verifiedAt sql.NullTime
+	query := "SELECT id, username, email, email_verified_at, password, active, totp_enabled_at IS NOT NULL, token_version, access_level FROM users WHERE username = ? LIMIT 1"
 
 	// Executa a consulta
-	err = dbConn.QueryRow(query, args...).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.AccessLevel)
+	err = dbConn.QueryRow(query, username).Scan(&user.ID, &user.Username, &user.Email, &verifiedAt, &user.Password, &user.Active, &user.TwoFactor, &user.TokenVersion, &user.AccessLevel)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			logger.Warn("Usuário não encontrado: %v", loginData.Name)
-			return nil, errors.New("usuário ou senha inválidos")
+			logger.Warn("Usuário não encontrado: %v", username)
+			// Compara com um hash fictício para que o tempo de resposta não revele se o usuário existe
+			auth_utils.CompareDummyPassword(password)
+			return nil, ErrUserNotFound
 		}
 		logger.Error("Erro ao buscar usuário: %v", err)
 		return nil, errors.New("erro interno")
 	}
 
 	// Verifica a senha
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(loginData.Password))
-	if err != nil {
-		logger.Warn("Tentativa de login com senha incorreta para usuário %v", loginData.Username)
+	if !auth_utils.VerifyPassword(password, user.Password) {
+		logger.Warn("Tentativa de login com senha incorreta para usuário %v", username)
 		return nil, errors.New("usuário ou senha inválidos")
 	}
 
-	// Gera o token JWT
-	token, err := auth_utils.GenerateJWT(user.ID, user.Username, user.AccessLevel)
-	if err != nil {
-		logger.Error("Erro ao gerar token JWT: %v", err)
-		return nil, errors.New("erro interno ao gerar token")
+	// Contas desativadas não podem fazer login
+	if !user.Active {
+		logger.Warn("Tentativa de login em conta desativada: %v", username)
+		return nil, errors.New("usuário ou senha inválidos")
 	}
-
-	// Calcular o tempo restante até o vencimento do token (exemplo: 3600 segundos)
-	token = "Bearer " + token
-	timeRemaining, err := auth_utils.CalculateTokenExpirationTime(token) // Exemplo de tempo restante em segundos (1 hora)
-	if err != nil {
-		logger.Error("Erro ao calcular o tempo de expiração do token: %v", err)
-		return nil, errors.New("erro interno ao calcular o tempo de expiração do token")
+	if verifiedAt.Valid {
+		user.EmailVerifiedAt = &verifiedAt.Time
 	}
 
-	// Retorna o token e o usuário com o tempo restante
-	authResponse := &AuthResponse{
-		Token:         token,
-		User:          user,
-		TimeRemaining: timeRemaining.String(),
+	if user.Roles, err = GetUserRoles(user.ID); err != nil {
+		return nil, err
 	}
 
-	return authResponse, nil
+	return &user, nil
 }
 
 // GetUserByEmail busca um usuário pelo e-mail no banco de dados
@@ -132,56 +153,119 @@ func GetUserByEmail(email string) (*User, error) {
 	defer dbConn.Close()
 
 	var user User
-	query := "SELECT id, name, email, password FROM users WHERE email = ? LIMIT 1"
-	err = dbConn.QueryRow(query, email).Scan(&user.ID, &user.Name, &user.Email, &user.Password)
+	var verifiedAt sql.NullTime
+	query := "SELECT id, name, username, email, email_verified_at, password, active, totp_enabled_at IS NOT NULL, token_version, access_level FROM users WHERE email = ? LIMIT 1"
+	err = dbConn.QueryRow(query, email).Scan(&user.ID, &user.Name, &user.Username, &user.Email, &verifiedAt, &user.Password, &user.Active, &user.TwoFactor, &user.TokenVersion, &user.AccessLevel)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			logger.Warn("Usuário não encontrado: %v", email)
-			return nil, errors.New("usuário não encontrado")
+			return nil, ErrUserNotFound
 		}
 		logger.Error("Erro ao buscar usuário por email: %v", err)
 		return nil, errors.New("erro interno")
 	}
+	if verifiedAt.Valid {
+		user.EmailVerifiedAt = &verifiedAt.Time
+	}
+
+	if user.Roles, err = GetUserRoles(user.ID); err != nil {
+		return nil, err
+	}
 
 	return &user, nil
 }
 
+// GetUserByUsername busca um usuário pelo username no banco de dados
+//
+// Parâmetros:
+// - username: string - O username do usuário.
+//
+// Respostas:
+// - *User: O usuário encontrado (sem o hash da senha).
+// - error: ErrUserNotFound se o usuário não existir, ou erro na consulta.
+func GetUserByUsername(username string) (*User, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return nil, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	var id int
+	err = dbConn.QueryRow("SELECT id FROM users WHERE username = ? LIMIT 1", username).Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			logger.Debug("Usuário não encontrado: %v", username)
+			return nil, ErrUserNotFound
+		}
+		logger.Error("Erro ao buscar usuário por username: %v", err)
+		return nil, errors.New("erro interno")
+	}
+
+	return GetUserByID(id)
+}
+
 // CreateNewUser insere um novo usuário no banco de dados
 //
 // Parâmetros:
 // - user: User - O usuário que será criado no sistema.
 //
 // Respostas:
-// - 201 Created: Se o usuário for criado com sucesso.
-// - 400 Bad Request: Se os dados do usuário forem inválidos.
-// - 500 Internal Server Error: Se ocorrer um erro durante o processo de criação do usuário.
-func CreateNewUser(user User) error {
+// - int: O ID do usuário criado.
+// - error: ErrUnknownRole se algum papel não existir, ou erro interno durante a criação do usuário.
+//
+// Detalhes:
+// - O usuário e seus papéis são gravados na mesma transação: se a atribuição falhar, nenhum registro é criado.
+func CreateNewUser(user User) (int, error) {
 	logger.Debug("Criando novo usuário: %v", user.Username)
 	dbConn, err := db.DbConnection()
 	if err != nil {
 		logger.Error("Erro ao conectar ao banco de dados: %v", err)
-		return errors.New("erro interno de conexão")
+		return 0, errors.New("erro interno de conexão")
 	}
 	defer dbConn.Close()
 
 	// Hash da senha antes de armazenar
 	logger.Debug("Gerando hash da senha para o usuário %v", user.Username)
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
-	logger.Debug("Hash gerado: %v | Base: %v", string(hashedPassword), user.Password)
+	hashedPassword, err := auth_utils.HashPassword(user.Password)
+	if err != nil {
+		return 0, err
+	}
 
+	tx, err := dbConn.Begin()
 	if err != nil {
-		logger.Error("Erro ao gerar hash da senha: %v", err)
-		return errors.New("erro ao processar senha")
+		logger.Error("Erro ao iniciar transação: %v", err)
+		return 0, errors.New("erro interno")
 	}
+	defer tx.Rollback()
 
-	query := "INSERT INTO users (name, username, email, password, access_level) VALUES (?, ?, ?, ?, ?)"
-	_, err = dbConn.Exec(query, user.Name, user.Username, user.Email, string(hashedPassword), user.AccessLevel) // Converte o hash para string
+	query := "INSERT INTO users (name, username, email, password) VALUES (?, ?, ?, ?)"
+	result, err := tx.Exec(query, user.Name, user.Username, user.Email, hashedPassword)
 	if err != nil {
 		logger.Error("Erro ao criar usuário: %v", err)
-		return errors.New("erro ao registrar usuário")
+		return 0, errors.New("erro ao registrar usuário")
 	}
 
-	return nil
+	// Usuários sem papéis explícitos recebem o papel padrão
+	roles := user.Roles
+	if len(roles) == 0 {
+		roles = []string{DefaultRole}
+	}
+
+	id, _ := result.LastInsertId()
+	if err := setUserRoles(tx, int(id), roles); err != nil {
+		if err == ErrUnknownRole {
+			return 0, err
+		}
+		logger.Error("Erro ao atribuir papéis ao usuário %v: %v", user.Username, err)
+		return 0, errors.New("erro ao registrar usuário")
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("Erro ao confirmar transação: %v", err)
+		return 0, errors.New("erro ao registrar usuário")
+	}
+	return int(id), nil
 }
 
 // CheckUserExists verifica se o nome de usuário já existe no banco
@@ -217,16 +301,19 @@ func CheckUserExists(username string) error {
 	return fmt.Errorf("usuário já existe")
 }
 
-// AddTokenToBlacklist adiciona um token à blacklist de tokens JWT no banco de dados.
+// AddTokenToBlacklist revoga um token JWT pelo seu identificador único (claim jti).
+//
+// Registros de tokens já expirados são removidos na mesma operação, pois não precisam mais ser bloqueados.
 //
 // Parâmetros:
-// - tokenString: string - Token JWT a ser invalidado.
+// - jti: string - Identificador único do token.
+// - userID: int - ID do usuário dono do token.
 // - expiresAt: time.Time - Data e hora de expiração do token.
 //
 // Respostas:
 // - 200 OK: Se o token foi adicionado com sucesso à blacklist.
 // - 500 Internal Server Error: Se ocorrer um erro durante o processo de inserção.
-func AddTokenToBlacklist(tokenString string, expiresAt time.Time) error {
+func AddTokenToBlacklist(jti string, userID int, expiresAt time.Time) error {
 	// Estabelece conexão com o banco de dados
 	dbConn, err := db.DbConnection()
 	if err != nil {
@@ -235,21 +322,25 @@ func AddTokenToBlacklist(tokenString string, expiresAt time.Time) error {
 	}
 	defer dbConn.Close()
 
-	query := "INSERT INTO jwt_blacklist (token, expires_at) VALUES (?, ?)"
-	_, err = dbConn.Exec(query, tokenString, expiresAt)
+	query := "INSERT IGNORE INTO revoked_tokens (jti, user_id, expires_at, revoked_at) VALUES (?, ?, ?, ?)"
+	_, err = dbConn.Exec(query, jti, nullableID(userID), expiresAt, time.Now())
 	if err != nil {
 		logger.Error("Erro ao adicionar token à blacklist: %v", err)
 		return errors.New("erro interno ao adicionar token à blacklist")
 	}
 
+	if _, err = dbConn.Exec("DELETE FROM revoked_tokens WHERE expires_at < ?", time.Now()); err != nil {
+		logger.Warn("Erro ao limpar tokens expirados da blacklist: %v", err)
+	}
+
 	return nil
 }
 
-// IsTokenBlacklisted verifica se um token está na blacklist.
+// IsTokenBlacklisted verifica se um token (identificado pelo jti) está na blacklist.
 //
 // Retorna true se o token estiver na blacklist, false caso contrário.
 // Em caso de erro, assume-se que o token é válido (retorna false).
-func IsTokenBlacklisted(tokenString string) bool {
+func IsTokenBlacklisted(jti string) bool {
 	dbConn, err := db.DbConnection()
 	if err != nil {
 		logger.Error("Erro ao conectar ao banco de dados: %v", err)
@@ -258,7 +349,7 @@ func IsTokenBlacklisted(tokenString string) bool {
 	defer dbConn.Close()
 
 	var count int
-	err = dbConn.QueryRow("SELECT COUNT(*) FROM jwt_blacklist WHERE token = ?", tokenString).Scan(&count)
+	err = dbConn.QueryRow("SELECT COUNT(*) FROM revoked_tokens WHERE jti = ?", jti).Scan(&count)
 	if err != nil {
 		logger.Error("Erro ao verificar blacklist: %v", err)
 		return false // Assume token válido em caso de erro
@@ -266,3 +357,470 @@ func IsTokenBlacklisted(tokenString string) bool {
 
 	return count > 0
 }
+
+// GetUserByID busca um usuário pelo ID no banco de dados
+//
+// Parâmetros:
+// - id: int - O ID do usuário.
+//
+// Respostas:
+// - *User: O usuário encontrado.
+// - error: Se o usuário não for encontrado ou ocorrer um erro na consulta.
+func GetUserByID(id int) (*User, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return nil, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	var user User
+	var verifiedAt, updatedAt sql.NullTime
+	query := "SELECT id, name, username, email, email_verified_at, active, totp_enabled_at IS NOT NULL, created_at, updated_at, token_version, access_level FROM users WHERE id = ? LIMIT 1"
+	err = dbConn.QueryRow(query, id).Scan(&user.ID, &user.Name, &user.Username, &user.Email, &verifiedAt, &user.Active, &user.TwoFactor, &user.CreatedAt, &updatedAt, &user.TokenVersion, &user.AccessLevel)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			logger.Warn("Usuário não encontrado: ID=%v", id)
+			return nil, ErrUserNotFound
+		}
+		logger.Error("Erro ao buscar usuário por ID: %v", err)
+		return nil, errors.New("erro interno")
+	}
+	if verifiedAt.Valid {
+		user.EmailVerifiedAt = &verifiedAt.Time
+	}
+	if updatedAt.Valid {
+		user.UpdatedAt = &updatedAt.Time
+	}
+
+	if user.Roles, err = GetUserRoles(user.ID); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// GetUserTokenVersion retorna a versão atual dos tokens do usuário.
+//
+// Parâmetros:
+// - userID: int - O ID do usuário.
+//
+// Respostas:
+// - int: A versão atual dos tokens.
+// - error: Se o usuário não for encontrado ou ocorrer um erro na consulta.
+func GetUserTokenVersion(userID int) (int, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return 0, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	var version int
+	err = dbConn.QueryRow("SELECT token_version FROM users WHERE id = ? LIMIT 1", userID).Scan(&version)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrUserNotFound
+		}
+		logger.Error("Erro ao buscar versão dos tokens: %v", err)
+		return 0, errors.New("erro interno")
+	}
+
+	return version, nil
+}
+
+// IncrementTokenVersion incrementa a versão dos tokens do usuário, invalidando todos os tokens já emitidos.
+//
+// Parâmetros:
+// - userID: int - O ID do usuário.
+//
+// Respostas:
+// - int: A nova versão dos tokens.
+// - error: Se ocorrer um erro durante a atualização.
+func IncrementTokenVersion(userID int) (int, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return 0, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	if _, err = dbConn.Exec("UPDATE users SET token_version = token_version + 1 WHERE id = ?", userID); err != nil {
+		logger.Error("Erro ao incrementar versão dos tokens: %v", err)
+		return 0, errors.New("erro interno ao revogar tokens")
+	}
+
+	var version int
+	if err = dbConn.QueryRow("SELECT token_version FROM users WHERE id = ?", userID).Scan(&version); err != nil {
+		logger.Error("Erro ao buscar versão dos tokens: %v", err)
+		return 0, errors.New("erro interno")
+	}
+
+	return version, nil
+}
+
+// UserFilter define os filtros e a paginação da listagem de usuários
+type UserFilter struct {
+	Search string // Busca parcial por nome, username ou e-mail
+	Active *bool  // nil lista usuários ativos e desativados
+	Limit  int
+	Offset int
+}
+
+// UserUpdate contém os campos alteráveis do cadastro (campos nil não são alterados)
+type UserUpdate struct {
+	Name  *string
+	Email *string
+	Roles *[]string // Novos papéis (nil mantém os atuais)
+}
+
+// ListUsers lista os usuários que atendem ao filtro, ordenados pelo ID.
+//
+// Parâmetros:
+// - filter: UserFilter - Filtros de busca e paginação.
+//
+// Respostas:
+// - []User: Os usuários da página solicitada (sem o hash da senha).
+// - int: O total de usuários que atendem ao filtro.
+// - error: Se ocorrer um erro durante a consulta.
+func ListUsers(filter UserFilter) ([]User, int, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return nil, 0, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	where := " WHERE 1 = 1"
+	var args []interface{}
+	if filter.Search != "" {
+		like := "%" + escapeLike(filter.Search) + "%"
+		where += " AND (name LIKE ? OR username LIKE ? OR email LIKE ?)"
+		args = append(args, like, like, like)
+	}
+	if filter.Active != nil {
+		where += " AND active = ?"
+		args = append(args, *filter.Active)
+	}
+
+	var total int
+	if err := dbConn.QueryRow("SELECT COUNT(*) FROM users"+where, args...).Scan(&total); err != nil {
+		logger.Error("Erro ao contar usuários: %v", err)
+		return nil, 0, errors.New("erro interno")
+	}
+
+	query := "SELECT id, name, username, email, email_verified_at, active, totp_enabled_at IS NOT NULL, created_at, updated_at, access_level FROM users" + where + " ORDER BY id LIMIT ? OFFSET ?"
+	rows, err := dbConn.Query(query, append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		logger.Error("Erro ao listar usuários: %v", err)
+		return nil, 0, errors.New("erro interno")
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		var user User
+		var verifiedAt, updatedAt sql.NullTime
+		if err := rows.Scan(&user.ID, &user.Name, &user.Username, &user.Email, &verifiedAt, &user.Active, &user.TwoFactor, &user.CreatedAt, &updatedAt, &user.AccessLevel); err != nil {
+			logger.Error("Erro ao ler usuário: %v", err)
+			return nil, 0, errors.New("erro interno")
+		}
+		if verifiedAt.Valid {
+			user.EmailVerifiedAt = &verifiedAt.Time
+		}
+		if updatedAt.Valid {
+			user.UpdatedAt = &updatedAt.Time
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("Erro ao listar usuários: %v", err)
+		return nil, 0, errors.New("erro interno")
+	}
+
+	for i := range users {
+		if users[i].Roles, err = GetUserRoles(users[i].ID); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return users, total, nil
+}
+
+// IsEmailInUse verifica se o e-mail já pertence a outro usuário.
+//
+// Parâmetros:
+// - email: string - O e-mail a verificar.
+// - exceptID: int - ID do usuário ignorado na verificação (0 para verificar todos).
+//
+// Respostas:
+// - bool: true se o e-mail já estiver em uso.
+// - error: Se ocorrer um erro durante a consulta.
+func IsEmailInUse(email string, exceptID int) (bool, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return false, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	var count int
+	if err := dbConn.QueryRow("SELECT COUNT(*) FROM users WHERE email = ? AND id <> ?", email, exceptID).Scan(&count); err != nil {
+		logger.Error("Erro ao verificar e-mail: %v", err)
+		return false, errors.New("erro interno")
+	}
+
+	return count > 0, nil
+}
+
+// UpdateUser altera os dados cadastrais e/ou os papéis de um usuário em uma única transação.
+//
+// Parâmetros:
+// - id: int - O ID do usuário.
+// - update: UserUpdate - Os campos a alterar.
+//
+// Respostas:
+// - nil: Se o usuário foi atualizado (ou não havia campos a alterar).
+// - ErrUnknownRole: Se algum papel não existir (nenhuma alteração é feita).
+// - error: Se ocorrer um erro durante a atualização (nenhuma alteração é feita).
+func UpdateUser(id int, update UserUpdate) error {
+	var sets []string
+	var args []interface{}
+	if update.Name != nil {
+		sets = append(sets, "name = ?")
+		args = append(args, *update.Name)
+	}
+	if update.Email != nil {
+		// Um novo e-mail precisa ser verificado novamente
+		sets = append(sets, "email = ?", "email_verified_at = NULL")
+		args = append(args, *update.Email)
+	}
+	if len(sets) == 0 && update.Roles == nil {
+		return nil
+	}
+
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	tx, err := dbConn.Begin()
+	if err != nil {
+		logger.Error("Erro ao iniciar transação: %v", err)
+		return errors.New("erro interno")
+	}
+	defer tx.Rollback()
+
+	if len(sets) > 0 {
+		query := "UPDATE users SET " + strings.Join(sets, ", ") + ", updated_at = ? WHERE id = ?"
+		if _, err := tx.Exec(query, append(args, time.Now(), id)...); err != nil {
+			logger.Error("Erro ao atualizar usuário: %v", err)
+			return errors.New("erro interno ao atualizar usuário")
+		}
+	}
+	if update.Roles != nil {
+		if err := setUserRoles(tx, id, *update.Roles); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("Erro ao confirmar transação: %v", err)
+		return errors.New("erro interno ao atualizar usuário")
+	}
+	return nil
+}
+
+// SetUserActive ativa ou desativa a conta de um usuário.
+//
+// Parâmetros:
+// - id: int - O ID do usuário.
+// - active: bool - O novo estado da conta.
+//
+// Respostas:
+// - bool: true se o estado da conta foi alterado por esta chamada.
+// - error: Se ocorrer um erro durante a atualização.
+func SetUserActive(id int, active bool) (bool, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return false, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	result, err := dbConn.Exec("UPDATE users SET active = ?, updated_at = ? WHERE id = ? AND active <> ?", active, time.Now(), id, active)
+	if err != nil {
+		logger.Error("Erro ao alterar o estado do usuário: %v", err)
+		return false, errors.New("erro interno ao atualizar usuário")
+	}
+
+	affected, _ := result.RowsAffected()
+	return affected == 1, nil
+}
+
+// DeleteUser exclui um usuário e os seus dados de autenticação (papéis, sessões, refresh tokens, 2FA, passkeys, chaves de API, identidades externas, consentimentos OAuth2 e histórico de senhas).
+//
+// Os eventos de auditoria do usuário são mantidos.
+//
+// Parâmetros:
+// - id: int - O ID do usuário.
+//
+// Respostas:
+// - bool: true se o usuário existia e foi excluído.
+// - error: Se ocorrer um erro durante a exclusão.
+func DeleteUser(id int) (bool, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return false, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	tx, err := dbConn.Begin()
+	if err != nil {
+		logger.Error("Erro ao iniciar transação: %v", err)
+		return false, errors.New("erro interno")
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"user_roles", "sessions", "refresh_tokens", "revoked_tokens", "user_tokens", "recovery_codes", "webauthn_credentials", "api_keys", "user_identities", "oauth_consents", "password_history"} {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE user_id = ?", id); err != nil {
+			logger.Error("Erro ao excluir dados do usuário em %s: %v", table, err)
+			return false, errors.New("erro interno ao excluir usuário")
+		}
+	}
+
+	result, err := tx.Exec("DELETE FROM users WHERE id = ?", id)
+	if err != nil {
+		logger.Error("Erro ao excluir usuário: %v", err)
+		return false, errors.New("erro interno ao excluir usuário")
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("Erro ao confirmar transação: %v", err)
+		return false, errors.New("erro interno ao excluir usuário")
+	}
+
+	affected, _ := result.RowsAffected()
+	return affected == 1, nil
+}
+
+// escapeLike escapa os curingas do LIKE (%, _ e \) em um termo de busca.
+func escapeLike(term string) string {
+	return strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(term)
+}
+
+// GetPasswordHash retorna o hash da senha de um usuário.
+//
+// Parâmetros:
+// - userID: int - O ID do usuário.
+//
+// Respostas:
+// - string: O hash da senha.
+// - error: ErrUserNotFound se o usuário não existir, ou erro interno.
+func GetPasswordHash(userID int) (string, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return "", errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	var hash string
+	if err := dbConn.QueryRow("SELECT password FROM users WHERE id = ? LIMIT 1", userID).Scan(&hash); err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrUserNotFound
+		}
+		logger.Error("Erro ao buscar senha do usuário: %v", err)
+		return "", errors.New("erro interno")
+	}
+
+	return hash, nil
+}
+
+// UpdatePassword grava o novo hash da senha de um usuário.
+//
+// Parâmetros:
+// - userID: int - O ID do usuário.
+// - passwordHash: string - O hash da nova senha.
+//
+// Respostas:
+// - nil: Se a senha foi atualizada.
+// - error: Se ocorrer um erro durante a atualização.
+func UpdatePassword(userID int, passwordHash string) error {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	if _, err := dbConn.Exec("UPDATE users SET password = ?, updated_at = ? WHERE id = ?", passwordHash, time.Now(), userID); err != nil {
+		logger.Error("Erro ao atualizar senha: %v", err)
+		return errors.New("erro interno ao atualizar senha")
+	}
+
+	return nil
+}
+
+// MarkEmailVerified registra a verificação do e-mail de um usuário.
+//
+// A verificação só é gravada se o e-mail informado ainda for o e-mail atual do usuário.
+//
+// Parâmetros:
+// - userID: int - O ID do usuário.
+// - email: string - O e-mail verificado.
+//
+// Respostas:
+// - bool: true se a verificação foi gravada; false se o e-mail mudou ou já estava verificado.
+// - error: Se ocorrer um erro durante a atualização.
+func MarkEmailVerified(userID int, email string) (bool, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return false, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	result, err := dbConn.Exec("UPDATE users SET email_verified_at = ? WHERE id = ? AND email = ? AND email_verified_at IS NULL", time.Now(), userID, email)
+	if err != nil {
+		logger.Error("Erro ao registrar a verificação do e-mail: %v", err)
+		return false, errors.New("erro interno ao verificar e-mail")
+	}
+
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
+
+// ReserveVerificationEmail registra o envio de um e-mail de verificação, respeitando o intervalo mínimo entre envios.
+//
+// A reserva é feita em uma única instrução, para que requisições simultâneas não enviem e-mails repetidos.
+//
+// Parâmetros:
+// - userID: int - O ID do usuário.
+// - interval: time.Duration - Intervalo mínimo entre dois envios.
+//
+// Respostas:
+// - bool: true se o envio foi liberado; false se o último envio foi há menos de interval.
+// - error: Se ocorrer um erro durante a atualização.
+func ReserveVerificationEmail(userID int, interval time.Duration) (bool, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return false, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	now := time.Now()
+	query := "UPDATE users SET email_verification_sent_at = ? WHERE id = ? AND (email_verification_sent_at IS NULL OR email_verification_sent_at <= ?)"
+	result, err := dbConn.Exec(query, now, userID, now.Add(-interval))
+	if err != nil {
+		logger.Error("Erro ao registrar o envio do e-mail de verificação: %v", err)
+		return false, errors.New("erro interno")
+	}
+
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
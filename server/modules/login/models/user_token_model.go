@@ -0,0 +1,185 @@
+// pwd: /app/server/modules/login/models/user_token_model.go
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"api/db"
+	"api/logger"
+)
+
+// Finalidades dos tokens de uso único enviados por e-mail.
+const (
+	TokenPurposeEmailChange   = "email_change"
+	TokenPurposePasswordReset = "password_reset"
+)
+
+// Escopos do limite de pedidos de redefinição de senha.
+const (
+	ResetScopeUser = "user"
+	ResetScopeIP   = "ip"
+)
+
+// UserToken representa um token de uso único (somente o hash do valor é persistido)
+type UserToken struct {
+	ID        int64
+	UserID    int
+	Purpose   string
+	Data      string // Valor associado ao token (ex: o novo e-mail)
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+// CreateUserToken grava um novo token de uso único, invalidando os tokens anteriores do usuário com a mesma finalidade.
+//
+// Parâmetros:
+// - userID: int - ID do usuário dono do token.
+// - purpose: string - Finalidade do token (TokenPurpose*).
+// - tokenHash: string - Hash SHA-256 do token.
+// - data: string - Valor associado ao token.
+// - expiresAt: time.Time - Data e hora de expiração.
+//
+// Respostas:
+// - nil: Se o token foi gravado com sucesso.
+// - error: Se ocorrer um erro durante a gravação.
+func CreateUserToken(userID int, purpose, tokenHash, data string, expiresAt time.Time) error {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	now := time.Now()
+	if _, err = dbConn.Exec("UPDATE user_tokens SET used_at = ? WHERE user_id = ? AND purpose = ? AND used_at IS NULL", now, userID, purpose); err != nil {
+		logger.Error("Erro ao invalidar tokens anteriores: %v", err)
+		return errors.New("erro interno ao gravar token")
+	}
+
+	query := "INSERT INTO user_tokens (user_id, purpose, token_hash, data, expires_at, created_at) VALUES (?, ?, ?, ?, ?, ?)"
+	if _, err = dbConn.Exec(query, userID, purpose, tokenHash, data, expiresAt, now); err != nil {
+		logger.Error("Erro ao gravar token: %v", err)
+		return errors.New("erro interno ao gravar token")
+	}
+
+	return nil
+}
+
+// GetUserToken busca um token de uso único válido sem consumi-lo.
+//
+// Permite validar os dados que acompanham o token antes de utilizá-lo com ConsumeUserToken.
+//
+// Parâmetros:
+// - purpose: string - Finalidade esperada do token.
+// - tokenHash: string - Hash SHA-256 do token.
+//
+// Respostas:
+// - *UserToken: O token, ou nil se não existir, já tiver sido utilizado, estiver expirado ou tiver outra finalidade.
+// - error: Se ocorrer um erro durante a consulta.
+func GetUserToken(purpose, tokenHash string) (*UserToken, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return nil, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	var token UserToken
+	query := "SELECT id, user_id, purpose, data, expires_at, created_at FROM user_tokens WHERE token_hash = ? AND purpose = ? AND used_at IS NULL LIMIT 1"
+	err = dbConn.QueryRow(query, tokenHash, purpose).Scan(&token.ID, &token.UserID, &token.Purpose, &token.Data, &token.ExpiresAt, &token.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		logger.Error("Erro ao buscar token: %v", err)
+		return nil, errors.New("erro interno")
+	}
+
+	if time.Now().After(token.ExpiresAt) {
+		return nil, nil
+	}
+	return &token, nil
+}
+
+// ConsumeUserToken valida e marca como utilizado um token de uso único.
+//
+// A marcação só ocorre se o token ainda não tiver sido utilizado, garantindo que duas
+// requisições simultâneas com o mesmo token não o utilizem duas vezes.
+//
+// Parâmetros:
+// - purpose: string - Finalidade esperada do token.
+// - tokenHash: string - Hash SHA-256 do token.
+//
+// Respostas:
+// - *UserToken: O token consumido, ou nil se não existir, já tiver sido utilizado, estiver expirado ou tiver outra finalidade.
+// - error: Se ocorrer um erro durante a consulta.
+func ConsumeUserToken(purpose, tokenHash string) (*UserToken, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return nil, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	var token UserToken
+	query := "SELECT id, user_id, purpose, data, expires_at, created_at FROM user_tokens WHERE token_hash = ? AND purpose = ? AND used_at IS NULL LIMIT 1"
+	err = dbConn.QueryRow(query, tokenHash, purpose).Scan(&token.ID, &token.UserID, &token.Purpose, &token.Data, &token.ExpiresAt, &token.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		logger.Error("Erro ao buscar token: %v", err)
+		return nil, errors.New("erro interno")
+	}
+
+	result, err := dbConn.Exec("UPDATE user_tokens SET used_at = ? WHERE id = ? AND used_at IS NULL", time.Now(), token.ID)
+	if err != nil {
+		logger.Error("Erro ao marcar token como utilizado: %v", err)
+		return nil, errors.New("erro interno")
+	}
+	if affected, _ := result.RowsAffected(); affected != 1 {
+		return nil, nil
+	}
+
+	if time.Now().After(token.ExpiresAt) {
+		return nil, nil
+	}
+
+	return &token, nil
+}
+
+// ReservePasswordResetRequest registra um pedido de redefinição de senha, respeitando o intervalo mínimo entre pedidos.
+//
+// A reserva é feita em uma única instrução, para que requisições simultâneas não enviem e-mails repetidos.
+//
+// Parâmetros:
+// - scope: string - Escopo do limite (ResetScopeUser ou ResetScopeIP).
+// - identifier: string - ID do usuário ou IP.
+// - interval: time.Duration - Intervalo mínimo entre dois pedidos.
+//
+// Respostas:
+// - bool: true se o pedido foi liberado; false se o último pedido foi há menos de interval.
+// - error: Se ocorrer um erro durante a gravação.
+func ReservePasswordResetRequest(scope, identifier string, interval time.Duration) (bool, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return false, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	// Linhas afetadas: 1 na inserção, 2 na atualização e 0 se o último pedido ainda estiver no intervalo
+	now := time.Now()
+	query := `INSERT INTO password_reset_requests (scope, identifier, requested_at) VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE requested_at = IF(requested_at <= ?, VALUES(requested_at), requested_at)`
+	result, err := dbConn.Exec(query, scope, identifier, now, now.Add(-interval))
+	if err != nil {
+		logger.Error("Erro ao registrar o pedido de redefinição de senha: %v", err)
+		return false, errors.New("erro interno")
+	}
+
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
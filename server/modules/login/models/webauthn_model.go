@@ -0,0 +1,179 @@
+// pwd: /app/server/modules/login/models/webauthn_model.go
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"api/db"
+	"api/logger"
+)
+
+// WebAuthnCredential representa uma credencial WebAuthn (passkey ou chave de segurança) de um usuário
+type WebAuthnCredential struct {
+	ID                int64      `json:"id"`
+	UserID            int        `json:"user_id"`
+	CredentialID      []byte     `json:"-"`
+	PublicKey         []byte     `json:"-"`
+	AttestationType   string     `json:"-"`
+	AttestationFormat string     `json:"-"`
+	Transports        []string   `json:"transports"`
+	AAGUID            []byte     `json:"-"`
+	Flags             uint8      `json:"-"` // Bits UP/UV/BE/BS dos dados do autenticador
+	SignCount         uint32     `json:"sign_count"`
+	CloneWarning      bool       `json:"clone_warning"`
+	Name              string     `json:"name"`
+	CreatedAt         time.Time  `json:"created_at"`
+	LastUsedAt        *time.Time `json:"last_used_at,omitempty"`
+}
+
+// webAuthnCredentialColumns lista as colunas lidas por scanWebAuthnCredential, na mesma ordem.
+const webAuthnCredentialColumns = "id, user_id, credential_id, public_key, attestation_type, attestation_format, transports, aaguid, flags, sign_count, clone_warning, name, created_at, last_used_at"
+
+// CreateWebAuthnCredential grava uma nova credencial WebAuthn.
+//
+// Parâmetros:
+// - credential: WebAuthnCredential - A credencial validada na cerimônia de registro.
+// - credentialHash: string - Hash SHA-256 do ID da credencial.
+//
+// Respostas:
+// - int64: O ID da credencial gravada.
+// - error: Se ocorrer um erro durante a gravação.
+func CreateWebAuthnCredential(credential WebAuthnCredential, credentialHash string) (int64, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return 0, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	query := `INSERT INTO webauthn_credentials (user_id, credential_id, credential_hash, public_key, attestation_type,
+		attestation_format, transports, aaguid, flags, sign_count, name, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := dbConn.Exec(query, credential.UserID, credential.CredentialID, credentialHash, credential.PublicKey,
+		credential.AttestationType, credential.AttestationFormat, strings.Join(credential.Transports, ","), credential.AAGUID,
+		credential.Flags, credential.SignCount, credential.Name, time.Now())
+	if err != nil {
+		logger.Error("Erro ao gravar credencial WebAuthn: %v", err)
+		return 0, errors.New("erro interno ao gravar credencial")
+	}
+
+	return result.LastInsertId()
+}
+
+// ListWebAuthnCredentials lista as credenciais WebAuthn de um usuário, da mais antiga para a mais recente.
+//
+// Parâmetros:
+// - userID: int - O ID do usuário.
+//
+// Respostas:
+// - []WebAuthnCredential: As credenciais do usuário.
+// - error: Se ocorrer um erro durante a consulta.
+func ListWebAuthnCredentials(userID int) ([]WebAuthnCredential, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return nil, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	rows, err := dbConn.Query("SELECT "+webAuthnCredentialColumns+" FROM webauthn_credentials WHERE user_id = ? ORDER BY id", userID)
+	if err != nil {
+		logger.Error("Erro ao listar credenciais WebAuthn: %v", err)
+		return nil, errors.New("erro interno")
+	}
+	defer rows.Close()
+
+	credentials := []WebAuthnCredential{}
+	for rows.Next() {
+		credential, err := scanWebAuthnCredential(rows)
+		if err != nil {
+			logger.Error("Erro ao ler credencial WebAuthn: %v", err)
+			return nil, errors.New("erro interno")
+		}
+		credentials = append(credentials, *credential)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("Erro ao listar credenciais WebAuthn: %v", err)
+		return nil, errors.New("erro interno")
+	}
+
+	return credentials, nil
+}
+
+// UpdateWebAuthnCredentialUsage grava o estado da credencial após um login.
+//
+// Parâmetros:
+// - credentialHash: string - Hash SHA-256 do ID da credencial.
+// - signCount: uint32 - Contador de assinaturas informado pelo autenticador.
+// - flags: uint8 - Bits UP/UV/BE/BS atualizados.
+// - cloneWarning: bool - true se o contador de assinaturas não avançou (possível clonagem).
+//
+// Respostas:
+// - nil: Se a credencial foi atualizada.
+// - error: Se ocorrer um erro durante a atualização.
+func UpdateWebAuthnCredentialUsage(credentialHash string, signCount uint32, flags uint8, cloneWarning bool) error {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	query := "UPDATE webauthn_credentials SET sign_count = ?, flags = ?, clone_warning = ?, last_used_at = ? WHERE credential_hash = ?"
+	if _, err := dbConn.Exec(query, signCount, flags, cloneWarning, time.Now(), credentialHash); err != nil {
+		logger.Error("Erro ao atualizar credencial WebAuthn: %v", err)
+		return errors.New("erro interno ao atualizar credencial")
+	}
+	return nil
+}
+
+// DeleteWebAuthnCredential remove uma credencial WebAuthn do usuário.
+//
+// Parâmetros:
+// - userID: int - O ID do usuário dono da credencial.
+// - id: int64 - O ID da credencial.
+//
+// Respostas:
+// - bool: false se a credencial não existir ou pertencer a outro usuário.
+// - error: Se ocorrer um erro durante a remoção.
+func DeleteWebAuthnCredential(userID int, id int64) (bool, error) {
+	dbConn, err := db.DbConnection()
+	if err != nil {
+		logger.Error("Erro ao conectar ao banco de dados: %v", err)
+		return false, errors.New("erro interno de conexão")
+	}
+	defer dbConn.Close()
+
+	result, err := dbConn.Exec("DELETE FROM webauthn_credentials WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		logger.Error("Erro ao remover credencial WebAuthn: %v", err)
+		return false, errors.New("erro interno ao remover credencial")
+	}
+
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
+
+// scanWebAuthnCredential lê uma credencial a partir das colunas de webAuthnCredentialColumns.
+func scanWebAuthnCredential(row interface{ Scan(...any) error }) (*WebAuthnCredential, error) {
+	var credential WebAuthnCredential
+	var transports string
+	var lastUsedAt sql.NullTime
+	err := row.Scan(&credential.ID, &credential.UserID, &credential.CredentialID, &credential.PublicKey, &credential.AttestationType,
+		&credential.AttestationFormat, &transports, &credential.AAGUID, &credential.Flags, &credential.SignCount,
+		&credential.CloneWarning, &credential.Name, &credential.CreatedAt, &lastUsedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	credential.Transports = []string{}
+	if transports != "" {
+		credential.Transports = strings.Split(transports, ",")
+	}
+	if lastUsedAt.Valid {
+		credential.LastUsedAt = &lastUsedAt.Time
+	}
+	return &credential, nil
+}
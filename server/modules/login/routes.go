@@ -15,6 +15,9 @@ import (
 // Parâmetros:
 //   - router: ponteiro para a instância do Gin Engine onde as rotas serão registradas.
 func RegisterRoutes(router *gin.Engine) {
+	// Chaves públicas para verificação dos tokens por outros serviços
+	router.GET("/.well-known/jwks.json", controllers.GetJWKS)
+
 	// Grupo de rotas de autenticação
 	authGroup := router.Group("/auth")
 	{
@@ -22,28 +25,101 @@ func RegisterRoutes(router *gin.Engine) {
 			c.JSON(200, gin.H{"message": "pong - Auth"})
 		})
 		authGroup.POST("/login", controllers.AuthenticateUser)
+		authGroup.POST("/login/2fa", controllers.CompleteTwoFactorLogin)
+		authGroup.POST("/login/passkey/begin", controllers.BeginPasskeyLogin)
+		authGroup.POST("/login/passkey/finish", controllers.FinishPasskeyLogin)
+		authGroup.GET("/oidc/providers", controllers.ListOIDCProviders)
+		authGroup.POST("/oidc/:provider/begin", controllers.BeginOIDCLogin)
+		authGroup.POST("/oidc/:provider/callback", controllers.FinishOIDCLogin)
+		authGroup.POST("/refresh", controllers.RefreshToken)
 		authGroup.POST("/logout", controllers.LogoutUser)
+		authGroup.POST("/logout-all", middleware.AuthMiddleware(), middleware.DenyImpersonation(), controllers.LogoutAll)
 		authGroup.GET("/is_logged", middleware.AuthMiddleware(), controllers.IsLoggedIn)
+		authGroup.GET("/traefik", middleware.TraefikForwardAuth())
+		authGroup.GET("/oauth/authorize", middleware.AuthMiddleware(), middleware.DenyImpersonation(), controllers.GetOAuthAuthorization)
+		authGroup.POST("/oauth/authorize", middleware.AuthMiddleware(), middleware.DenyImpersonation(), controllers.ApproveOAuthAuthorization)
+		authGroup.POST("/oauth/token", controllers.IssueOAuthToken)
+		authGroup.POST("/oauth/introspect", controllers.IntrospectOAuthToken)
+		authGroup.POST("/email/verify", controllers.VerifyEmail)
+		authGroup.POST("/email/resend", controllers.ResendVerificationEmail)
+		authGroup.POST("/email/confirm-change", controllers.ConfirmEmailChange)
+		authGroup.POST("/password/forgot", controllers.ForgotPassword)
+		authGroup.POST("/password/reset", controllers.ResetPassword)
+		authGroup.POST("/impersonate/:id", middleware.AuthMiddleware(), middleware.DenyImpersonation(), middleware.RequirePermission("users:impersonate"), controllers.ImpersonateUser)
 	}
 
-	// Grupo de rotas de administração de usuários (Nível Admin)
-	usersGroup := authGroup.Group("/users").Use(middleware.AuthMiddleware())
+	// Grupo de rotas de administração de usuários (cada rota exige uma permissão; nenhuma é liberada na personificação)
+	usersGroup := authGroup.Group("/users").Use(middleware.AuthMiddleware(), middleware.DenyImpersonation())
 	{
 		usersGroup.GET("/ping", func(c *gin.Context) {
 			c.JSON(200, gin.H{"message": "pong - Users"})
 		})
-		usersGroup.POST("/register", controllers.AddNewUser)
-		// usersGroup.GET("/", controllers.ListUsers)
-		// usersGroup.PUT("/change_password", controllers.ChangePassword)
+		usersGroup.POST("/register", middleware.RequirePermission("users:create"), controllers.AddNewUser)
+		usersGroup.GET("", middleware.RequirePermission("users:read"), controllers.ListUsers)
+		usersGroup.GET("/:id", middleware.RequirePermission("users:read"), controllers.GetUser)
+		usersGroup.PATCH("/:id", middleware.RequirePermission("users:update"), controllers.UpdateUser)
+		usersGroup.POST("/:id/deactivate", middleware.RequirePermission("users:update"), controllers.DeactivateUser)
+		usersGroup.POST("/:id/reactivate", middleware.RequirePermission("users:update"), controllers.ReactivateUser)
+		usersGroup.DELETE("/:id", middleware.RequirePermission("users:delete"), controllers.DeleteUser)
+		usersGroup.POST("/unlock", middleware.RequirePermission("users:unlock"), controllers.UnlockUser)
+		usersGroup.PUT("/:id/roles", middleware.RequirePermission("roles:assign"), controllers.SetUserRoles)
+		usersGroup.GET("/:id/sessions", middleware.RequirePermission("users:sessions"), controllers.ListUserSessions)
+		usersGroup.DELETE("/:id/sessions", middleware.RequirePermission("users:sessions"), controllers.RevokeAllUserSessions)
+		usersGroup.DELETE("/:id/sessions/:session_id", middleware.RequirePermission("users:sessions"), controllers.RevokeUserSession)
+		usersGroup.DELETE("/:id/2fa", middleware.RequirePermission("users:update"), controllers.ResetUserTwoFactor)
+	}
+
+	// Grupo de rotas de papéis e permissões
+	rolesGroup := authGroup.Group("/roles").Use(middleware.AuthMiddleware(), middleware.DenyImpersonation())
+	{
+		rolesGroup.GET("", middleware.RequirePermission("roles:read"), controllers.ListRoles)
+		rolesGroup.PATCH("/:name", middleware.RequirePermission("roles:update"), controllers.UpdateRole)
+	}
+
+	// Grupo de rotas de chaves de API de serviço
+	apiKeysGroup := authGroup.Group("/api-keys").Use(middleware.AuthMiddleware(), middleware.DenyImpersonation(), middleware.RequirePermission("api_keys:manage"))
+	{
+		apiKeysGroup.GET("", controllers.ListServiceAPIKeys)
+		apiKeysGroup.POST("", controllers.CreateServiceAPIKey)
+		apiKeysGroup.DELETE("/:key_id", controllers.RevokeAnyAPIKey)
+	}
+
+	// Grupo de rotas de clientes do servidor OAuth2
+	oauthClientsGroup := authGroup.Group("/oauth/clients").Use(middleware.AuthMiddleware(), middleware.DenyImpersonation(), middleware.RequirePermission("oauth_clients:manage"))
+	{
+		oauthClientsGroup.GET("", controllers.ListOAuthClients)
+		oauthClientsGroup.POST("", controllers.CreateOAuthClient)
+		oauthClientsGroup.DELETE("/:client_id", controllers.RevokeOAuthClient)
 	}
 
-	// Grupo de rotas para o usuário autenticado
+	// Grupo de rotas para o usuário autenticado (as alterações de conta e credenciais são recusadas na personificação)
 	userGroup := authGroup.Group("/user").Use(middleware.AuthMiddleware())
 	{
 		userGroup.GET("/ping", func(c *gin.Context) {
 			c.JSON(200, gin.H{"message": "pong - User"})
 		})
-		// userGroup.GET("/me", controllers.GetUserDetails)
+		userGroup.GET("/sessions", controllers.ListMySessions)
+		userGroup.DELETE("/sessions", middleware.DenyImpersonation(), controllers.RevokeMyOtherSessions)
+		userGroup.DELETE("/sessions/:session_id", middleware.DenyImpersonation(), controllers.RevokeMySession)
+		userGroup.GET("/me", controllers.GetMyProfile)
+		userGroup.PATCH("/me", middleware.DenyImpersonation(), controllers.UpdateMyProfile)
+		userGroup.POST("/password", middleware.DenyImpersonation(), controllers.ChangeMyPassword)
+		userGroup.GET("/2fa", controllers.GetTwoFactorStatus)
+		userGroup.POST("/2fa/setup", middleware.DenyImpersonation(), controllers.SetupTwoFactor)
+		userGroup.POST("/2fa/confirm", middleware.DenyImpersonation(), controllers.ConfirmTwoFactor)
+		userGroup.POST("/2fa/disable", middleware.DenyImpersonation(), controllers.DisableTwoFactor)
+		userGroup.POST("/2fa/recovery-codes", middleware.DenyImpersonation(), controllers.RegenerateRecoveryCodes)
+		userGroup.GET("/passkeys", controllers.ListMyPasskeys)
+		userGroup.POST("/passkeys/register/begin", middleware.DenyImpersonation(), controllers.BeginPasskeyRegistration)
+		userGroup.POST("/passkeys/register/finish", middleware.DenyImpersonation(), controllers.FinishPasskeyRegistration)
+		userGroup.DELETE("/passkeys/:passkey_id", middleware.DenyImpersonation(), controllers.DeleteMyPasskey)
+		userGroup.GET("/api-keys", controllers.ListMyAPIKeys)
+		userGroup.POST("/api-keys", middleware.DenyImpersonation(), controllers.CreateMyAPIKey)
+		userGroup.DELETE("/api-keys/:key_id", middleware.DenyImpersonation(), controllers.RevokeMyAPIKey)
+		userGroup.GET("/identities", controllers.ListMyIdentities)
+		userGroup.DELETE("/identities/:identity_id", middleware.DenyImpersonation(), controllers.DeleteMyIdentity)
+		userGroup.GET("/oauth/consents", controllers.ListMyOAuthConsents)
+		userGroup.DELETE("/oauth/consents/:client_id", middleware.DenyImpersonation(), controllers.RevokeMyOAuthConsent)
 		// userGroup.GET("/", controllers.ListUsers)
 		// userGroup.GET("/:id", controllers.GetUserByID)
 		// userGroup.PUT("/:id", controllers.UpdateUser)
@@ -72,7 +148,7 @@ func init() {
 // 		Username:    "userdefault",
 // 		Email:       "userdefault@example.com",
 // 		Password:    "senha123",
-// 		AccessLevel: 1,
+// 		Roles:       []string{"user"},
 // 	})
 
 // 	if err != nil {
@@ -0,0 +1,328 @@
+// pwd: /app/server/modules/login/services/api_key_service.go
+package services
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"api/logger"
+	"api/server/modules/login/auth_utils"
+	"api/server/modules/login/models"
+	"api/utils"
+	"api/utils/cache"
+)
+
+// Erros das chaves de API
+var (
+	ErrInvalidAPIKey     = errors.New("chave de API inválida, revogada ou expirada")
+	ErrAPIKeyNotFound    = errors.New("chave de API não encontrada")
+	ErrInvalidAPIKeyName = errors.New("nome de chave de API inválido")
+	ErrInvalidScopes     = errors.New("escopos inválidos")
+	ErrInvalidExpiration = errors.New("data de expiração inválida")
+)
+
+// APIKeyPrefix identifica as chaves de API no cabeçalho Authorization (ex: "ak_3f9a1b2c4d5e_<segredo>")
+const APIKeyPrefix = "ak_"
+
+// Tamanhos (em bytes aleatórios) do identificador visível e do segredo das chaves
+const (
+	apiKeyIDBytes     = 6
+	apiKeySecretBytes = 32
+	maxAPIKeyName     = 100
+)
+
+var (
+	apiKeyDefaultTTL = utils.GetEnvDuration("API_KEY_DEFAULT_TTL", 90*24*time.Hour)
+	apiKeyMaxTTL     = utils.GetEnvDuration("API_KEY_MAX_TTL", 365*24*time.Hour)
+
+	// Chaves consultadas recentemente, indexadas pelo prefixo. A revogação vale imediatamente nesta instância
+	// e, nas demais, em até REVOCATION_CACHE_TTL.
+	apiKeyCache = cache.New[string, models.APIKey](revocationCacheTTL)
+
+	// Chaves cujo último uso já foi gravado há menos de um minuto, para não gravar a cada requisição.
+	apiKeyUsageCache = cache.New[int64, bool](time.Minute)
+)
+
+// APIKeyIdentity representa o chamador autenticado por uma chave de API
+type APIKeyIdentity struct {
+	KeyID       int64
+	UserID      int // Dono da chave pessoal; 0 nas chaves de serviço
+	Permissions map[string]bool
+}
+
+// CreateAPIKey cria uma chave de API.
+//
+// Parâmetros:
+// - ownerID: O dono da chave pessoal, ou 0 para uma chave de serviço.
+// - actorID: O ID do usuário que está criando a chave (o próprio dono, ou o administrador nas chaves de serviço).
+// - name: Nome da chave, para identificá-la na listagem.
+// - scopes: As permissões concedidas à chave (ex: "finance:read").
+// - expiresAt: Data de expiração (opcional; padrão: API_KEY_DEFAULT_TTL).
+// - ip: O IP de origem da requisição.
+//
+// Retorno:
+// - string: A chave completa, exibida uma única vez (somente o hash é gravado).
+// - *models.APIKey: Os dados da chave criada.
+// - error: ErrInvalidAPIKeyName, ErrInvalidScopes, ErrInvalidExpiration ou erro interno.
+//
+// Detalhes:
+// - Só é possível conceder permissões que o próprio criador possui.
+// - A expiração não pode passar de API_KEY_MAX_TTL (0 = sem limite).
+func CreateAPIKey(ownerID, actorID int, name string, scopes []string, expiresAt *time.Time, ip string) (string, *models.APIKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxAPIKeyName {
+		return "", nil, ErrInvalidAPIKeyName
+	}
+
+	scopes, err := validateScopes(actorID, scopes)
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	expiresAt, err = apiKeyExpiration(now, expiresAt)
+	if err != nil {
+		return "", nil, err
+	}
+
+	id, err := randomHex(apiKeyIDBytes)
+	if err != nil {
+		return "", nil, err
+	}
+	secret, err := randomHex(apiKeySecretBytes)
+	if err != nil {
+		return "", nil, err
+	}
+	rawKey := APIKeyPrefix + id + "_" + secret
+
+	key := models.APIKey{
+		Name:       name,
+		Prefix:     APIKeyPrefix + id,
+		SecretHash: auth_utils.HashToken(rawKey),
+		Scopes:     scopes,
+		CreatedBy:  actorID,
+		CreatedAt:  now,
+		ExpiresAt:  expiresAt,
+	}
+	if ownerID != 0 {
+		key.UserID = &ownerID
+	}
+	if key.ID, err = models.CreateAPIKey(key); err != nil {
+		return "", nil, err
+	}
+
+	recordAPIKeyEvent(models.AuditAPIKeyCreated, key, actorID, ip)
+	return rawKey, &key, nil
+}
+
+// ListUserAPIKeys lista as chaves pessoais ativas do usuário.
+//
+// Parâmetros:
+// - userID: O ID do usuário.
+//
+// Retorno:
+// - []models.APIKey: As chaves do usuário (sem o segredo).
+// - error: Retorna erro se a consulta falhar.
+func ListUserAPIKeys(userID int) ([]models.APIKey, error) {
+	return models.ListUserAPIKeys(userID)
+}
+
+// ListServiceAPIKeys lista as chaves de serviço ativas.
+//
+// Retorno:
+// - []models.APIKey: As chaves de serviço (sem o segredo).
+// - error: Retorna erro se a consulta falhar.
+func ListServiceAPIKeys() ([]models.APIKey, error) {
+	return models.ListServiceAPIKeys()
+}
+
+// RevokeAPIKey revoga uma chave de API.
+//
+// Parâmetros:
+// - keyID: O ID da chave.
+// - ownerID: O dono exigido, ou 0 para revogar qualquer chave (administração).
+// - actorID: O ID do usuário que executou a ação.
+// - ip: O IP de origem da requisição.
+//
+// Retorno:
+// - error: ErrAPIKeyNotFound se a chave não existir, já estiver revogada ou pertencer a outro usuário, ou erro interno.
+func RevokeAPIKey(keyID int64, ownerID, actorID int, ip string) error {
+	key, err := models.GetAPIKeyByID(keyID)
+	if err != nil {
+		return err
+	}
+	if key == nil || key.RevokedAt != nil || (ownerID != 0 && (key.UserID == nil || *key.UserID != ownerID)) {
+		return ErrAPIKeyNotFound
+	}
+
+	revoked, err := models.RevokeAPIKey(keyID)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrAPIKeyNotFound
+	}
+	apiKeyCache.Delete(key.Prefix)
+
+	recordAPIKeyEvent(models.AuditAPIKeyRevoked, *key, actorID, ip)
+	return nil
+}
+
+// IsAPIKey informa se a credencial enviada no cabeçalho Authorization é uma chave de API (e não um JWT).
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, APIKeyPrefix)
+}
+
+// AuthenticateAPIKey valida uma chave de API e retorna as permissões efetivas do chamador.
+//
+// Parâmetros:
+// - rawKey: A chave completa enviada pelo cliente.
+// - ip: O IP de origem da requisição (gravado como último uso).
+//
+// Retorno:
+// - *APIKeyIdentity: A chave, o dono (nas chaves pessoais) e as permissões efetivas.
+// - error: ErrInvalidAPIKey se a chave não existir, estiver revogada ou expirada, ou se o dono estiver desativado.
+//
+// Detalhes:
+// - As permissões de uma chave pessoal são os seus escopos que o dono ainda possui: se ele perder uma permissão,
+// a chave também a perde.
+// - As permissões de uma chave de serviço são os seus escopos.
+func AuthenticateAPIKey(rawKey, ip string) (*APIKeyIdentity, error) {
+	id, _, ok := strings.Cut(strings.TrimPrefix(rawKey, APIKeyPrefix), "_")
+	if !IsAPIKey(rawKey) || !ok || len(id) != apiKeyIDBytes*2 {
+		return nil, ErrInvalidAPIKey
+	}
+	prefix := APIKeyPrefix + id
+
+	key, ok := apiKeyCache.Get(prefix)
+	if !ok {
+		loaded, err := models.GetAPIKeyByPrefix(prefix)
+		if err != nil {
+			return nil, err
+		}
+		if loaded == nil {
+			return nil, ErrInvalidAPIKey
+		}
+		key = *loaded
+		apiKeyCache.Set(prefix, key)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(auth_utils.HashToken(rawKey)), []byte(key.SecretHash)) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt)) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	identity := &APIKeyIdentity{KeyID: key.ID, Permissions: make(map[string]bool, len(key.Scopes))}
+	if key.UserID != nil {
+		if !key.OwnerActive {
+			return nil, ErrInvalidAPIKey
+		}
+		identity.UserID = *key.UserID
+		granted, err := GetUserPermissions(identity.UserID)
+		if err != nil {
+			return nil, err
+		}
+		for _, scope := range key.Scopes {
+			if granted[scope] {
+				identity.Permissions[scope] = true
+			}
+		}
+	} else {
+		for _, scope := range key.Scopes {
+			identity.Permissions[scope] = true
+		}
+	}
+
+	if _, recent := apiKeyUsageCache.Get(key.ID); !recent {
+		apiKeyUsageCache.Set(key.ID, true)
+		if err := models.TouchAPIKey(key.ID, ip); err != nil {
+			logger.Error("Erro ao registrar o uso da chave de API %s: %v", key.Prefix, err)
+		}
+	}
+	return identity, nil
+}
+
+// validateScopes normaliza os escopos e verifica se o criador da chave possui cada um deles.
+func validateScopes(actorID int, scopes []string) ([]string, error) {
+	granted, err := GetUserPermissions(actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	unique := map[string]bool{}
+	for _, scope := range scopes {
+		scope = strings.TrimSpace(scope)
+		if scope == "" || !granted[scope] {
+			return nil, ErrInvalidScopes
+		}
+		unique[scope] = true
+	}
+	if len(unique) == 0 {
+		return nil, ErrInvalidScopes
+	}
+
+	normalized := make([]string, 0, len(unique))
+	for scope := range unique {
+		normalized = append(normalized, scope)
+	}
+	sort.Strings(normalized)
+	return normalized, nil
+}
+
+// apiKeyExpiration aplica API_KEY_DEFAULT_TTL e API_KEY_MAX_TTL à expiração solicitada.
+func apiKeyExpiration(now time.Time, requested *time.Time) (*time.Time, error) {
+	var maxExpiration *time.Time
+	if apiKeyMaxTTL > 0 {
+		limit := now.Add(apiKeyMaxTTL)
+		maxExpiration = &limit
+	}
+
+	if requested == nil {
+		if apiKeyDefaultTTL <= 0 {
+			return maxExpiration, nil
+		}
+		expiresAt := now.Add(apiKeyDefaultTTL)
+		if maxExpiration != nil && expiresAt.After(*maxExpiration) {
+			return maxExpiration, nil
+		}
+		return &expiresAt, nil
+	}
+
+	if !requested.After(now) || (maxExpiration != nil && requested.After(*maxExpiration)) {
+		return nil, ErrInvalidExpiration
+	}
+	return requested, nil
+}
+
+// randomHex gera n bytes aleatórios em hexadecimal.
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		logger.Error("Erro ao gerar chave de API: %v", err)
+		return "", errors.New("erro ao gerar chave de API")
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// recordAPIKeyEvent registra a criação ou a revogação de uma chave de API na auditoria.
+func recordAPIKeyEvent(eventType string, key models.APIKey, actorID int, ip string) {
+	event := models.AuditEvent{
+		EventType: eventType,
+		ActorID:   actorID,
+		IP:        ip,
+		Details:   fmt.Sprintf("chave=%s; nome=%s; escopos=%s", key.Prefix, key.Name, strings.Join(key.Scopes, ",")),
+	}
+	if key.UserID != nil {
+		event.UserID = *key.UserID
+	}
+	_ = models.RecordAuditEvent(event)
+}
@@ -3,93 +3,241 @@ package services
 
 import (
 	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"api/logger"
-	"api/server/modules/login/auth_utils"
 	"api/server/modules/login/models"
+)
 
-	"golang.org/x/crypto/bcrypt"
+// Erros do login e do cadastro
+var (
+	ErrMissingCredentials = errors.New("usuário (ou e-mail) e senha são obrigatórios")
+	ErrUsernameInUse      = errors.New("usuário já existe")
 )
 
-// LoginRequest representa a estrutura da requisição de login
-type LoginRequest struct {
-	Email    string `json:"email" binding:"required,email"` // O email é obrigatório e deve ter formato válido
-	Password string `json:"password" binding:"required"`    // A senha é obrigatória
+// LoginLockedError indica que o login está temporariamente bloqueado por excesso de falhas.
+type LoginLockedError struct {
+	RetryAfter time.Duration
+}
+
+// Error descreve o bloqueio.
+func (e *LoginLockedError) Error() string {
+	return fmt.Sprintf("login bloqueado por %s", e.RetryAfter.Round(time.Second))
 }
 
-// RegisterRequest representa a estrutura da requisição de registro
+// LoginResult representa o resultado de um login: os tokens da nova sessão ou, para contas com 2FA, o desafio
+// da segunda etapa (/auth/login/2fa).
+type LoginResult struct {
+	Auth                   *models.AuthResponse
+	RefreshToken           string
+	TwoFactorSetupRequired bool
+
+	ChallengeToken string
+	ChallengeTTL   time.Duration
+}
+
+// RegisterRequest representa os dados de cadastro de um usuário
 type RegisterRequest struct {
-	Email    string `json:"email" binding:"required,email"`    // O email é obrigatório e deve ter formato válido
-	Password string `json:"password" binding:"required,min=6"` // A senha é obrigatória e deve ter no mínimo 6 caracteres
-	Name     string `json:"name" binding:"required"`           // O nome do usuário é obrigatório
+	Name     string   `json:"name"`
+	Username string   `json:"username"`
+	Email    string   `json:"email"`
+	Password string   `json:"password"` // Validada pela política de senhas (ValidatePassword)
+	Roles    []string `json:"roles"`
 }
 
-// Login autentica o usuário e gera um token JWT
+// Login autentica o usuário com o identificador (username ou e-mail) e a senha.
 //
 // Parâmetros:
-// - req: A requisição de login contendo email e senha do usuário.
+// - req: Os dados do login (identificador, senha e nome do dispositivo).
+// - userAgent: O cabeçalho User-Agent da requisição.
+// - ip: O IP de origem da requisição.
 //
 // Retorno:
-// - string: O token JWT gerado para o usuário.
-// - error: Retorna erro caso as credenciais estejam incorretas ou se houver falha na geração do token.
+// - *LoginResult: Os tokens da sessão ou o desafio do 2FA.
+// - error: ErrMissingCredentials, *LoginLockedError, ErrInvalidCredentials, ErrEmailNotVerified ou erro interno.
 //
 // Detalhes:
-// - Verifica se o usuário existe e se a senha fornecida corresponde ao hash armazenado.
-// - Se as credenciais forem válidas, um token JWT é gerado e retornado.
-func Login(req LoginRequest) (string, error) {
-	// Recupera o usuário pelo email
-	user, err := models.GetUserByEmail(req.Email)
+//   - A senha é validada pelos backends de AUTH_BACKENDS (banco local e/ou LDAP).
+//   - Um identificador com @ que corresponda ao e-mail de um usuário é convertido no seu username, para que o
+//     bloqueio por excesso de falhas conte as tentativas por username e por e-mail juntas.
+//   - As falhas aplicam o atraso progressivo antes de retornar. Com 2FA, as falhas continuam contando até a
+//     segunda etapa.
+func Login(req models.LoginRequest, userAgent, ip string) (*LoginResult, error) {
+	identifier := req.LoginIdentifier()
+	if identifier == "" || req.Password == "" {
+		return nil, ErrMissingCredentials
+	}
+	username := resolveLoginName(identifier)
+
+	// Bloqueio temporário por excesso de falhas (por usuário ou por IP)
+	if remaining := CheckLoginLockout(username, ip); remaining > 0 {
+		logger.Warn("Tentativa de login bloqueada para o usuário %s (IP %s)", username, ip)
+		return nil, &LoginLockedError{RetryAfter: remaining}
+	}
+
+	user, err := AuthenticatePassword(username, req.Password, ip)
 	if err != nil {
-		logger.Warn("Usuário não encontrado: %v", req.Email)
-		return "", errors.New("credenciais inválidas") // Retorna erro se o usuário não for encontrado
+		if !errors.Is(err, ErrInvalidCredentials) {
+			logger.Error("Erro ao autenticar o usuário %s: %v", username, err)
+		}
+		logger.Warn("Tentativa de login falhou para o usuário %s", username)
+		if delay := RegisterLoginFailure(username, ip); delay > 0 {
+			time.Sleep(delay)
+		}
+		return nil, ErrInvalidCredentials
 	}
 
-	// Verifica se a senha fornecida corresponde ao hash armazenado
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		logger.Warn("Senha incorreta para: %v", req.Email)
-		return "", errors.New("credenciais inválidas") // Retorna erro se a senha for inválida
+	if err := CheckEmailVerifiedForLogin(*user); err != nil {
+		RegisterLoginSuccess(user.Username)
+		logger.Warn("Login recusado para o usuário %s: e-mail não verificado", user.Username)
+		return nil, err
 	}
 
-	// Gera um token JWT para o usuário
-	token, err := auth_utils.GenerateJWT(user.ID, user.Username, user.AccessLevel)
+	if !user.TwoFactor {
+		RegisterLoginSuccess(user.Username)
+	}
+	return BeginLogin(*user, userAgent, req.Device, ip)
+}
+
+// BeginLogin conclui o primeiro fator do login: retorna o desafio do 2FA para contas com 2FA, ou cria a sessão.
+//
+// Usado pelo login com senha e pelo login com provedor externo, que substituem a senha, mas não o 2FA local.
+//
+// Parâmetros:
+// - user: O usuário autenticado pelo primeiro fator.
+// - userAgent: O cabeçalho User-Agent da requisição.
+// - device: O nome do dispositivo informado pelo cliente (opcional).
+// - ip: O IP de origem da requisição.
+//
+// Retorno:
+// - *LoginResult: Os tokens da sessão ou o desafio do 2FA.
+// - error: Retorna erro se o desafio ou a sessão não puderem ser gerados.
+func BeginLogin(user models.User, userAgent, device, ip string) (*LoginResult, error) {
+	if !user.TwoFactor {
+		return CompleteLogin(user, userAgent, device, ip)
+	}
+
+	challenge, ttl, err := NewTwoFactorChallenge(user, device)
 	if err != nil {
-		logger.Error("Erro ao gerar token JWT: %v", err)
-		return "", errors.New("erro ao gerar token") // Retorna erro se a geração do token falhar
+		logger.Error("Erro ao gerar desafio 2FA do usuário ID=%d: %v", user.ID, err)
+		return nil, errors.New("erro ao gerar desafio 2FA")
 	}
+	return &LoginResult{ChallengeToken: challenge, ChallengeTTL: ttl}, nil
+}
 
-	return token, nil // Retorna o token gerado
+// CompleteLogin cria a sessão de um usuário já autenticado (inclusive pelo 2FA ou por passkey) e emite os tokens.
+//
+// Parâmetros:
+// - user: O usuário autenticado.
+// - userAgent: O cabeçalho User-Agent da requisição.
+// - device: O nome do dispositivo informado pelo cliente (opcional).
+// - ip: O IP de origem da requisição.
+//
+// Retorno:
+// - *LoginResult: O access token, o refresh token e se o usuário precisa cadastrar o 2FA exigido pelos seus papéis.
+// - error: Retorna erro se a sessão ou os tokens não puderem ser gerados.
+func CompleteLogin(user models.User, userAgent, device, ip string) (*LoginResult, error) {
+	authResponse, refreshToken, err := StartSession(user, userAgent, device, ip)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoginResult{
+		Auth:                   authResponse,
+		RefreshToken:           refreshToken,
+		TwoFactorSetupRequired: TwoFactorSetupRequired(user),
+	}, nil
+}
+
+// resolveLoginName converte um identificador com @ no username do usuário com esse e-mail.
+// Sem usuário com o e-mail, o identificador é mantido (ex: username com @ ou usuário apenas no LDAP).
+func resolveLoginName(identifier string) string {
+	if !strings.Contains(identifier, "@") {
+		return identifier
+	}
+
+	user, err := models.GetUserByEmail(identifier)
+	if err != nil {
+		if !errors.Is(err, models.ErrUserNotFound) {
+			logger.Error("Erro ao buscar o usuário do e-mail %s no login: %v", identifier, err)
+		}
+		return identifier
+	}
+	return user.Username
 }
 
-// Register cria um novo usuário no banco de dados
+// Register cadastra um novo usuário e registra a criação na auditoria.
 //
 // Parâmetros:
-// - req: A requisição de registro contendo email, senha e nome do usuário.
+// - req: Os dados do novo usuário (a senha é armazenada como hash por models.CreateNewUser).
+// - actorID: O ID do administrador que executou a ação.
+// - ip: O IP de origem da requisição.
 //
 // Retorno:
-// - error: Retorna erro caso haja falha na criptografia da senha ou no registro do usuário.
+// - int: O ID do usuário criado.
+// - error: ErrInvalidUser, ErrInvalidEmail, *PasswordPolicyError, ErrRoleGrantForbidden, ErrUsernameInUse,
+// ErrEmailInUse, models.ErrUnknownRole ou erro interno.
 //
 // Detalhes:
-// - A senha fornecida é criptografada e o usuário é registrado no banco de dados.
-func Register(req RegisterRequest) error {
-	// Criptografa a senha antes de armazená-la
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+// - O administrador só pode conceder papéis cujas permissões ele mesmo possui (CheckRoleGrant).
+// - O novo usuário recebe o link de verificação do e-mail.
+func Register(req RegisterRequest, actorID int, ip string) (int, error) {
+	user := models.User{
+		Name:     strings.TrimSpace(req.Name),
+		Username: strings.TrimSpace(req.Username),
+		Password: req.Password,
+		Roles:    req.Roles,
+	}
+	if user.Username == "" || user.Password == "" {
+		return 0, ErrInvalidUser
+	}
+
+	email, err := normalizeEmail(req.Email)
 	if err != nil {
-		logger.Error("Erro ao criptografar senha: %v", err)
-		return errors.New("erro ao registrar usuário") // Retorna erro se a criptografia falhar
+		return 0, err
 	}
+	user.Email = email
 
-	// Cria o objeto de usuário com os dados fornecidos
-	user := models.User{
-		Email:    req.Email,
-		Password: string(hashedPassword),
-		Name:     req.Name,
+	if err := ValidatePassword(user.Password, user); err != nil {
+		return 0, err
+	}
+	if err := CheckRoleGrant(actorID, user.Roles); err != nil {
+		return 0, err
+	}
+
+	if _, err := models.GetUserByUsername(user.Username); err == nil {
+		return 0, ErrUsernameInUse
+	} else if !errors.Is(err, models.ErrUserNotFound) {
+		return 0, err
+	}
+
+	inUse, err := models.IsEmailInUse(email, 0)
+	if err != nil {
+		return 0, err
+	}
+	if inUse {
+		return 0, ErrEmailInUse
+	}
+
+	id, err := models.CreateNewUser(user)
+	if err != nil {
+		return 0, err
 	}
 
-	// Registra o novo usuário no banco de dados
-	if err := models.CreateNewUser(user); err != nil {
-		logger.Error("Erro ao salvar usuário no banco: %v", err)
-		return errors.New("erro ao registrar usuário") // Retorna erro caso falhe ao registrar o usuário
+	user.ID = id
+	if _, err := SendVerificationEmail(user); err != nil {
+		logger.Error("Erro ao enviar e-mail de verificação ao usuário ID=%d: %v", id, err)
 	}
 
-	return nil // Retorna nil se o registro for bem-sucedido
+	_ = models.RecordAuditEvent(models.AuditEvent{
+		EventType: models.AuditUserCreated,
+		UserID:    id,
+		Username:  user.Username,
+		ActorID:   actorID,
+		IP:        ip,
+		Details:   fmt.Sprintf("papeis=%s", strings.Join(user.Roles, ",")),
+	})
+	return id, nil
 }
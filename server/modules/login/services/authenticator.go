@@ -0,0 +1,155 @@
+// pwd: /app/server/modules/login/services/authenticator.go
+package services
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	"api/logger"
+	"api/server/modules/login/models"
+	"api/utils"
+)
+
+// Erros dos autenticadores de senha
+var (
+	// ErrInvalidCredentials encerra o login: o autenticador conhece o usuário e recusou a senha (ou a conta).
+	ErrInvalidCredentials = errors.New("usuário ou senha inválidos")
+	// ErrUnknownUser indica que o autenticador não conhece o usuário; o próximo autenticador é consultado.
+	ErrUnknownUser = errors.New("usuário desconhecido pelo autenticador")
+	// ErrAuthenticatorUnavailable indica falha de comunicação com o autenticador; o próximo autenticador é consultado.
+	ErrAuthenticatorUnavailable = errors.New("autenticador indisponível")
+)
+
+// Authenticator valida o username e a senha de um usuário em um backend (banco local, LDAP...).
+type Authenticator interface {
+	// Name identifica o autenticador nos logs e em AUTH_BACKENDS.
+	Name() string
+	// Authenticate retorna o usuário local autenticado, ou ErrInvalidCredentials, ErrUnknownUser ou ErrAuthenticatorUnavailable.
+	Authenticate(username, password, ip string) (*models.User, error)
+}
+
+var (
+	authenticatorsMu sync.Mutex
+	authenticators   []Authenticator // nil até a primeira chamada a getAuthenticators ou SetAuthenticators
+)
+
+// SetAuthenticators substitui os autenticadores lidos de AUTH_BACKENDS.
+//
+// Permite usar outros backends (ex: um servidor LDAP local em testes, com SetLDAPConfig).
+//
+// Parâmetros:
+// - list: Os autenticadores, na ordem em que são consultados.
+func SetAuthenticators(list []Authenticator) {
+	authenticatorsMu.Lock()
+	authenticators = list
+	authenticatorsMu.Unlock()
+}
+
+// getAuthenticators retorna os autenticadores configurados, lidos do ambiente na primeira chamada.
+//
+// Variáveis:
+// - AUTH_BACKENDS: Backends consultados, em ordem, separados por vírgula ("ldap", "local").
+// Padrão: "ldap,local" se LDAP_URL estiver configurada, senão "local".
+func getAuthenticators() []Authenticator {
+	authenticatorsMu.Lock()
+	defer authenticatorsMu.Unlock()
+
+	if authenticators != nil {
+		return authenticators
+	}
+
+	names := utils.GetEnvList("AUTH_BACKENDS")
+	if len(names) == 0 {
+		names = []string{"local"}
+		if getLDAPConfig() != nil {
+			names = []string{"ldap", "local"}
+		}
+	}
+
+	authenticators = []Authenticator{}
+	for _, name := range names {
+		switch strings.ToLower(name) {
+		case "local":
+			authenticators = append(authenticators, LocalAuthenticator{})
+		case "ldap":
+			if getLDAPConfig() == nil {
+				logger.Error("Backend ldap ignorado em AUTH_BACKENDS: configure LDAP_URL e LDAP_BASE_DN")
+				continue
+			}
+			authenticators = append(authenticators, LDAPAuthenticator{})
+		default:
+			logger.Error("Backend de autenticação desconhecido em AUTH_BACKENDS: %q", name)
+		}
+	}
+	if len(authenticators) == 0 {
+		logger.Error("Nenhum backend de autenticação válido em AUTH_BACKENDS; usando o banco local")
+		authenticators = append(authenticators, LocalAuthenticator{})
+	}
+	return authenticators
+}
+
+// AuthenticatePassword valida o username e a senha nos autenticadores configurados.
+//
+// Parâmetros:
+// - username: O username informado no login.
+// - password: A senha informada no login.
+// - ip: O IP de origem da requisição.
+//
+// Retorno:
+// - *models.User: O usuário local autenticado.
+// - error: ErrInvalidCredentials ou erro interno.
+//
+// Detalhes:
+//   - Os autenticadores são consultados em ordem. Um autenticador que não conhece o usuário (ou está fora do ar)
+//     passa a vez ao próximo; uma senha recusada encerra o login.
+//   - Com "ldap,local", as contas de serviço que só existem no banco continuam entrando com a senha local.
+func AuthenticatePassword(username, password, ip string) (*models.User, error) {
+	for _, authenticator := range getAuthenticators() {
+		user, err := authenticator.Authenticate(username, password, ip)
+		switch {
+		case err == nil:
+			return user, nil
+		case errors.Is(err, ErrUnknownUser):
+			continue
+		case errors.Is(err, ErrAuthenticatorUnavailable):
+			logger.Error("Autenticador %s indisponível no login do usuário %s: %v", authenticator.Name(), username, err)
+			continue
+		default:
+			return nil, err
+		}
+	}
+	return nil, ErrInvalidCredentials
+}
+
+// LocalAuthenticator valida a senha no banco de dados local (bcrypt ou argon2id).
+type LocalAuthenticator struct{}
+
+// Name identifica o autenticador.
+func (LocalAuthenticator) Name() string {
+	return "local"
+}
+
+// Authenticate valida a senha local do usuário e, se preciso, refaz o hash com o algoritmo e os parâmetros atuais.
+// Usuários vinculados ao diretório LDAP não entram com a senha local, mesmo com o LDAP fora do ar.
+func (LocalAuthenticator) Authenticate(username, password, ip string) (*models.User, error) {
+	user, err := models.Authenticate(username, password)
+	if err != nil {
+		if errors.Is(err, models.ErrUserNotFound) {
+			return nil, ErrUnknownUser
+		}
+		return nil, ErrInvalidCredentials
+	}
+
+	subject, err := ldapSubject(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if subject != "" {
+		logger.Warn("Senha local recusada para o usuário %s, vinculado ao diretório LDAP (IP %s)", username, ip)
+		return nil, ErrUnknownUser
+	}
+
+	rehashPassword(*user, password)
+	return user, nil
+}
@@ -0,0 +1,196 @@
+// pwd: /app/server/modules/login/services/authorization_service.go
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"api/logger"
+	"api/server/modules/login/models"
+	"api/utils"
+	"api/utils/cache"
+)
+
+// Tempo que as permissões de um usuário ficam em cache antes de serem consultadas novamente.
+var permissionCacheTTL = utils.GetEnvDuration("PERMISSION_CACHE_TTL", 30*time.Second)
+
+var userPermissionsCache = cache.New[int, map[string]bool](permissionCacheTTL)
+
+// ErrRoleGrantForbidden indica que os papéis concedem permissões que quem os atribui não possui.
+var ErrRoleGrantForbidden = errors.New("os papéis concedem permissões que o usuário não possui")
+
+// GetUserPermissions retorna o conjunto de permissões concedidas ao usuário pelos seus papéis.
+//
+// Parâmetros:
+// - userID: O ID do usuário.
+//
+// Retorno:
+// - map[string]bool: As permissões do usuário.
+// - error: Retorna erro se a consulta falhar.
+//
+// Detalhes:
+// - O resultado é mantido em cache por PERMISSION_CACHE_TTL.
+// - Não têm nenhuma permissão os usuários com e-mail não verificado (com EMAIL_VERIFICATION_POLICY=restrict)
+// e os que ainda não cadastraram o 2FA exigido por algum dos seus papéis.
+func GetUserPermissions(userID int) (map[string]bool, error) {
+	if permissions, ok := userPermissionsCache.Get(userID); ok {
+		return permissions, nil
+	}
+
+	restricted, err := isRestricted(userID)
+	if err != nil {
+		return nil, err
+	}
+	if restricted {
+		permissions := map[string]bool{}
+		userPermissionsCache.Set(userID, permissions)
+		return permissions, nil
+	}
+
+	names, err := models.GetUserPermissions(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions := make(map[string]bool, len(names))
+	for _, name := range names {
+		permissions[name] = true
+	}
+	userPermissionsCache.Set(userID, permissions)
+	return permissions, nil
+}
+
+// HasPermission verifica se o usuário possui a permissão informada.
+//
+// Parâmetros:
+// - userID: O ID do usuário.
+// - permission: A permissão exigida (ex: "users:create").
+//
+// Retorno:
+// - bool: true se o usuário possuir a permissão. Em caso de erro na consulta, retorna false.
+func HasPermission(userID int, permission string) bool {
+	permissions, err := GetUserPermissions(userID)
+	if err != nil {
+		logger.Error("Erro ao consultar as permissões do usuário ID=%d: %v", userID, err)
+		return false
+	}
+	return permissions[permission]
+}
+
+// ListRoles lista os papéis cadastrados e as suas permissões.
+//
+// Retorno:
+// - []models.Role: Os papéis cadastrados.
+// - error: Retorna erro se a consulta falhar.
+func ListRoles() ([]models.Role, error) {
+	return models.ListRoles()
+}
+
+// AssignRoles substitui os papéis de um usuário e registra a alteração na auditoria.
+//
+// Parâmetros:
+// - userID: O ID do usuário.
+// - roles: Os nomes dos novos papéis.
+// - actorID: O ID do administrador que executou a ação (zero para o provisionamento automático).
+// - ip: O IP de origem da requisição.
+//
+// Retorno:
+// - error: ErrRoleGrantForbidden, models.ErrUnknownRole se algum papel não existir, ou erro interno.
+//
+// Detalhes:
+//   - As novas permissões valem imediatamente; os papéis gravados nos tokens já emitidos são atualizados na renovação.
+//   - O administrador só pode conceder papéis cujas permissões ele mesmo possui (CheckRoleGrant).
+func AssignRoles(userID int, roles []string, actorID int, ip string) error {
+	current, err := models.GetUserRoles(userID)
+	if err != nil {
+		return err
+	}
+	if err := CheckRoleGrant(actorID, newRoles(roles, current)); err != nil {
+		return err
+	}
+
+	if err := models.SetUserRoles(userID, roles); err != nil {
+		return err
+	}
+	rolesChanged(userID, roles, actorID, ip)
+	return nil
+}
+
+// rolesChanged descarta as permissões em cache do usuário e registra a troca de papéis na auditoria.
+func rolesChanged(userID int, roles []string, actorID int, ip string) {
+	userPermissionsCache.Delete(userID)
+
+	_ = models.RecordAuditEvent(models.AuditEvent{
+		EventType: models.AuditRolesChanged,
+		UserID:    userID,
+		ActorID:   actorID,
+		IP:        ip,
+		Details:   fmt.Sprintf("papeis=%s", strings.Join(roles, ",")),
+	})
+}
+
+// CheckRoleGrant verifica se quem atribui os papéis possui todas as permissões concedidas por eles.
+//
+// Parâmetros:
+// - actorID: O ID de quem atribui os papéis. Zero corresponde ao provisionamento automático (OIDC / LDAP), que não é verificado.
+// - roles: Os papéis concedidos. O papel padrão (models.DefaultRole) é sempre permitido.
+//
+// Retorno:
+// - error: ErrRoleGrantForbidden se algum papel conceder uma permissão que o ator não possui, ou erro interno.
+func CheckRoleGrant(actorID int, roles []string) error {
+	if actorID == 0 {
+		return nil
+	}
+
+	granted, err := models.GetRolesPermissions(newRoles(roles, []string{models.DefaultRole}))
+	if err != nil {
+		return err
+	}
+	actorPermissions, err := GetUserPermissions(actorID)
+	if err != nil {
+		return err
+	}
+	for _, permission := range granted {
+		if !actorPermissions[permission] {
+			logger.Warn("Atribuição dos papéis %v recusada ao usuário ID=%d: permissão %q ausente", roles, actorID, permission)
+			return ErrRoleGrantForbidden
+		}
+	}
+	return nil
+}
+
+// newRoles retorna os papéis de roles que não estão em existing.
+func newRoles(roles, existing []string) []string {
+	var added []string
+	for _, role := range roles {
+		role = strings.TrimSpace(role)
+		found := false
+		for _, name := range existing {
+			if name == role {
+				found = true
+				break
+			}
+		}
+		if !found {
+			added = append(added, role)
+		}
+	}
+	return added
+}
+
+// isRestricted informa se o usuário deve ficar sem permissões até verificar o e-mail ou cadastrar o 2FA.
+func isRestricted(userID int) (bool, error) {
+	user, err := models.GetUserByID(userID)
+	if err != nil {
+		return false, err
+	}
+	if emailVerificationPolicy == EmailPolicyRestrict && !user.EmailVerified() {
+		return true, nil
+	}
+	if user.TwoFactor {
+		return false, nil
+	}
+	return models.UserRequiresTwoFactor(userID)
+}
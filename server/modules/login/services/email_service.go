@@ -0,0 +1,43 @@
+// pwd: /app/server/modules/login/services/email_service.go
+package services
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"api/logger"
+	"api/mailer"
+	"api/server/modules/login/auth_utils"
+	"api/server/modules/login/models"
+	"api/utils"
+)
+
+// issueUserToken gera um token de uso único, grava o seu hash e retorna o valor em texto claro.
+func issueUserToken(userID int, purpose, data string, ttl time.Duration) (string, error) {
+	token, err := auth_utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	if err := models.CreateUserToken(userID, purpose, auth_utils.HashToken(token), data, time.Now().Add(ttl)); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// appLink monta o link da aplicação (APP_BASE_URL, padrão DOMAIN_NAME) com o token como parâmetro.
+func appLink(path, token string) string {
+	base := utils.GetEnv("APP_BASE_URL")
+	if base == "" {
+		base = utils.GetEnv("DOMAIN_NAME")
+	}
+	return strings.TrimSuffix(base, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+// sendEmail envia um e-mail e registra a falha no log, sem interromper o fluxo que o originou.
+func sendEmail(to, subject, body string) {
+	if err := mailer.Send(mailer.Message{To: to, Subject: subject, Body: body}); err != nil {
+		logger.Error("Erro ao enviar e-mail (%s): %v", subject, err)
+	}
+}
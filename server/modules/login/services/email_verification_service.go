@@ -0,0 +1,177 @@
+// pwd: /app/server/modules/login/services/email_verification_service.go
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"api/logger"
+	"api/server/modules/login/auth_utils"
+	"api/server/modules/login/models"
+	"api/utils"
+)
+
+// Políticas aplicadas às contas com e-mail ainda não verificado (EMAIL_VERIFICATION_POLICY)
+const (
+	EmailPolicyOff      = "off"      // Nenhuma restrição
+	EmailPolicyRestrict = "restrict" // Login permitido, mas sem nenhuma permissão (RequirePermission recusa)
+	EmailPolicyBlock    = "block"    // Login e renovação de tokens recusados
+)
+
+// Erros da verificação de e-mail
+var (
+	ErrEmailNotVerified         = errors.New("e-mail não verificado")
+	ErrInvalidVerificationToken = errors.New("token de verificação inválido ou expirado")
+)
+
+var (
+	emailVerificationPolicy         = loadEmailVerificationPolicy()
+	emailVerificationTokenTTL       = utils.GetEnvDuration("EMAIL_VERIFICATION_TOKEN_TTL", 48*time.Hour)
+	emailVerificationResendInterval = utils.GetEnvDuration("EMAIL_VERIFICATION_RESEND_INTERVAL", 5*time.Minute)
+	emailVerificationPath           = "/verify-email"
+)
+
+// loadEmailVerificationPolicy lê EMAIL_VERIFICATION_POLICY. Valores desconhecidos equivalem a "off".
+func loadEmailVerificationPolicy() string {
+	policy := strings.ToLower(strings.TrimSpace(utils.GetEnv("EMAIL_VERIFICATION_POLICY")))
+	switch policy {
+	case "", EmailPolicyOff:
+		return EmailPolicyOff
+	case EmailPolicyRestrict, EmailPolicyBlock:
+		return policy
+	default:
+		logger.Warn("EMAIL_VERIFICATION_POLICY inválida (%q). Usando %q", policy, EmailPolicyOff)
+		return EmailPolicyOff
+	}
+}
+
+// CheckEmailVerifiedForLogin aplica a política "block" no login e na renovação de tokens.
+//
+// Parâmetros:
+// - user: O usuário autenticado.
+//
+// Retorno:
+// - error: ErrEmailNotVerified se a política for "block" e o e-mail não estiver verificado.
+func CheckEmailVerifiedForLogin(user models.User) error {
+	if emailVerificationPolicy == EmailPolicyBlock && !user.EmailVerified() {
+		return ErrEmailNotVerified
+	}
+	return nil
+}
+
+// SendVerificationEmail envia o link de verificação para o e-mail atual do usuário.
+//
+// Parâmetros:
+// - user: O usuário a verificar.
+//
+// Retorno:
+// - bool: true se o e-mail foi enviado; false se o último envio foi há menos de EMAIL_VERIFICATION_RESEND_INTERVAL.
+// - error: Retorna erro se a reserva do envio ou a geração do token falharem.
+func SendVerificationEmail(user models.User) (bool, error) {
+	reserved, err := models.ReserveVerificationEmail(user.ID, emailVerificationResendInterval)
+	if err != nil || !reserved {
+		return false, err
+	}
+
+	token, err := auth_utils.GenerateEmailVerificationToken(user.ID, user.Email, emailVerificationTokenTTL)
+	if err != nil {
+		return false, err
+	}
+
+	sendEmail(user.Email, "Confirme o seu e-mail", fmt.Sprintf(
+		"Olá, %s.\n\nPara confirmar o e-mail da sua conta, acesse o link abaixo (válido por %s):\n\n%s\n\nSe você não reconhece este cadastro, ignore esta mensagem.",
+		user.Name, emailVerificationTokenTTL, appLink(emailVerificationPath, token)))
+
+	logger.Info("E-mail de verificação enviado ao usuário ID=%d", user.ID)
+	return true, nil
+}
+
+// ResendVerificationEmail reenvia o link de verificação para a conta com o e-mail informado.
+//
+// Parâmetros:
+// - email: O e-mail informado pelo usuário.
+//
+// Detalhes:
+// - Assim como RequestPasswordReset, o processamento ocorre em segundo plano e nada é retornado,
+// para não revelar se a conta existe.
+// - Contas inexistentes, desativadas ou já verificadas não recebem e-mail, e os reenvios respeitam
+// EMAIL_VERIFICATION_RESEND_INTERVAL.
+func ResendVerificationEmail(email string) {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return
+	}
+
+	go func() {
+		user, err := models.GetUserByEmail(email)
+		if err != nil {
+			if err != models.ErrUserNotFound {
+				logger.Error("Erro ao buscar usuário para reenvio da verificação: %v", err)
+			}
+			return
+		}
+		if !user.Active || user.EmailVerified() {
+			return
+		}
+
+		if sent, err := SendVerificationEmail(*user); err != nil {
+			logger.Error("Erro ao reenviar e-mail de verificação: %v", err)
+		} else if !sent {
+			logger.Warn("Reenvio da verificação de e-mail ignorado para o usuário ID=%d: intervalo mínimo não atingido", user.ID)
+		}
+	}()
+}
+
+// VerifyEmail confirma o e-mail do usuário a partir do token enviado por e-mail.
+//
+// Parâmetros:
+// - token: O token recebido por e-mail.
+// - ip: O IP de origem da requisição.
+//
+// Retorno:
+// - error: ErrInvalidVerificationToken se o token for inválido, expirado ou de um e-mail que não é mais o atual.
+//
+// Detalhes:
+// - Verificar novamente um e-mail já verificado não é um erro.
+func VerifyEmail(token, ip string) error {
+	claims, err := auth_utils.ParseEmailVerificationToken(token)
+	if err != nil {
+		return ErrInvalidVerificationToken
+	}
+	userID, err := claims.UserID()
+	if err != nil {
+		return ErrInvalidVerificationToken
+	}
+
+	user, err := models.GetUserByID(userID)
+	if err != nil {
+		if err == models.ErrUserNotFound {
+			return ErrInvalidVerificationToken
+		}
+		return err
+	}
+	if !strings.EqualFold(user.Email, claims.Email) {
+		return ErrInvalidVerificationToken
+	}
+	if user.EmailVerified() {
+		return nil
+	}
+
+	verified, err := models.MarkEmailVerified(userID, user.Email)
+	if err != nil || !verified {
+		return err
+	}
+	userPermissionsCache.Delete(userID)
+
+	_ = models.RecordAuditEvent(models.AuditEvent{
+		EventType: models.AuditEmailVerified,
+		UserID:    userID,
+		Username:  user.Username,
+		ActorID:   userID,
+		IP:        ip,
+		Details:   "email=" + user.Email,
+	})
+	return nil
+}
@@ -0,0 +1,139 @@
+// pwd: /app/server/modules/login/services/impersonation_service.go
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"api/logger"
+	"api/server/modules/login/auth_utils"
+	"api/server/modules/login/models"
+	"api/utils"
+)
+
+// Validade dos tokens de personificação (IMPERSONATION_TOKEN_TTL)
+var impersonationTokenTTL = utils.GetEnvDuration("IMPERSONATION_TOKEN_TTL", 15*time.Minute)
+
+// Tamanho máximo do motivo e do caminho registrados na auditoria
+const maxImpersonationDetailLength = 255
+
+// Erros da personificação de usuários
+var (
+	ErrImpersonationNested    = errors.New("não é permitido personificar outro usuário durante uma personificação")
+	ErrImpersonationInactive  = errors.New("não é permitido personificar um usuário desativado")
+	ErrImpersonationForbidden = errors.New("o usuário possui permissões que o administrador não possui")
+)
+
+// Impersonate emite um token de acesso de curta duração para um administrador agir em nome de outro usuário.
+//
+// Parâmetros:
+// - actor: Os claims do token do administrador.
+// - targetID: O ID do usuário personificado.
+// - reason: O motivo informado pelo administrador (opcional), registrado na auditoria.
+// - ip: O IP de origem da requisição.
+//
+// Retorno:
+// - *models.AuthResponse: O token (com prefixo "Bearer "), o usuário personificado e o tempo restante.
+// - error: ErrImpersonationNested, ErrSelfManagement, models.ErrUserNotFound, ErrImpersonationInactive,
+// ErrImpersonationForbidden ou erro interno.
+//
+// Detalhes:
+//   - O token tem o usuário como sujeito e o administrador no claim act, vale por IMPERSONATION_TOKEN_TTL e não tem
+//     refresh token nem sessão própria. O logout com o token encerra a personificação.
+//   - O usuário não pode ter permissões que o administrador não tem, para que a personificação não amplie o acesso.
+//   - Com o token, as operações sensíveis (senha, 2FA, passkeys, chaves de API, administração) são recusadas e
+//     todas as requisições são registradas na auditoria com as duas identidades (RecordImpersonatedRequest).
+func Impersonate(actor *auth_utils.Claims, targetID int, reason, ip string) (*models.AuthResponse, error) {
+	if actor.Impersonating() {
+		return nil, ErrImpersonationNested
+	}
+	if targetID == actor.ID {
+		return nil, ErrSelfManagement
+	}
+
+	target, err := models.GetUserByID(targetID)
+	if err != nil {
+		return nil, err
+	}
+	if !target.Active {
+		return nil, ErrImpersonationInactive
+	}
+
+	actorPermissions, err := GetUserPermissions(actor.ID)
+	if err != nil {
+		return nil, err
+	}
+	targetPermissions, err := GetUserPermissions(target.ID)
+	if err != nil {
+		return nil, err
+	}
+	for permission, granted := range targetPermissions {
+		if granted && !actorPermissions[permission] {
+			logger.Warn("Personificação do usuário ID=%d recusada ao administrador ID=%d: permissão %q ausente", target.ID, actor.ID, permission)
+			return nil, ErrImpersonationForbidden
+		}
+	}
+
+	subject := target.TokenSubject()
+	subject.ExpiresIn = impersonationTokenTTL
+	subject.Actor = &auth_utils.TokenActor{
+		Subject:      strconv.Itoa(actor.ID),
+		ID:           actor.ID,
+		Username:     actor.Username,
+		TokenVersion: actor.TokenVersion,
+		SessionID:    actor.SessionID,
+	}
+
+	token, err := auth_utils.GenerateJWT(subject)
+	if err != nil {
+		logger.Error("Erro ao gerar token de personificação: %v", err)
+		return nil, errors.New("erro interno ao gerar token")
+	}
+
+	reason = truncate(strings.TrimSpace(reason), maxImpersonationDetailLength)
+	logger.Info("Administrador ID=%d (%s) iniciou a personificação do usuário ID=%d (%s) por %s (IP %s)",
+		actor.ID, actor.Username, target.ID, target.Username, impersonationTokenTTL, ip)
+	_ = models.RecordAuditEvent(models.AuditEvent{
+		EventType: models.AuditImpersonationStarted,
+		UserID:    target.ID,
+		Username:  target.Username,
+		ActorID:   actor.ID,
+		IP:        ip,
+		Details:   fmt.Sprintf("validade=%s; motivo=%s", impersonationTokenTTL, reason),
+	})
+
+	return &models.AuthResponse{
+		Token:         "Bearer " + token,
+		User:          *target,
+		TimeRemaining: impersonationTokenTTL.String(),
+	}, nil
+}
+
+// RecordImpersonatedRequest registra no log e na auditoria uma requisição feita com um token de personificação.
+//
+// Parâmetros:
+// - claims: Os claims do token de personificação.
+// - method: O método HTTP da requisição.
+// - path: O caminho da requisição (ou o host e o caminho, no forwardAuth do Traefik).
+// - status: O status HTTP da resposta.
+// - ip: O IP de origem da requisição.
+func RecordImpersonatedRequest(claims *auth_utils.Claims, method, path string, status int, ip string) {
+	actor := claims.Actor
+	if actor == nil {
+		return
+	}
+
+	logger.Info("Personificação: administrador ID=%d (%s) como usuário ID=%d (%s): %s %s -> %d (IP %s)",
+		actor.ID, actor.Username, claims.ID, claims.Username, method, path, status, ip)
+	_ = models.RecordAuditEvent(models.AuditEvent{
+		EventType: models.AuditImpersonatedRequest,
+		UserID:    claims.ID,
+		Username:  claims.Username,
+		ActorID:   actor.ID,
+		IP:        ip,
+		Details:   fmt.Sprintf("%s %s; status=%d; jti=%s", method, truncate(path, maxImpersonationDetailLength), status, claims.Id),
+	})
+}
@@ -0,0 +1,489 @@
+// pwd: /app/server/modules/login/services/ldap_service.go
+package services
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"api/logger"
+	"api/server/modules/login/models"
+	"api/utils"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// LDAPProvider é o provedor gravado em user_identities para os usuários vinculados ao diretório LDAP
+const LDAPProvider = "ldap"
+
+// ErrPasswordManagedByDirectory indica que a senha do usuário é gerenciada pelo diretório LDAP
+var ErrPasswordManagedByDirectory = errors.New("senha gerenciada pelo diretório ldap")
+
+// LDAPGroupRole atribui um papel local aos membros de um grupo do diretório
+type LDAPGroupRole struct {
+	Group string // DN completo do grupo ou apenas o seu CN (ex: "CN=Financeiro,OU=Grupos,DC=empresa,DC=com" ou "Financeiro")
+	Role  string
+}
+
+// LDAPConfig representa a configuração do backend LDAP / Active Directory
+type LDAPConfig struct {
+	URL               string      // ldap://host:389 ou ldaps://host:636
+	StartTLS          bool        // Usa STARTTLS em conexões ldap://
+	TLSConfig         *tls.Config // Configuração TLS (padrão: CAs do sistema e, se informado, LDAP_CA_FILE)
+	BindDN            string      // Conta de serviço usada na busca do usuário (vazio = busca anônima)
+	BindPassword      string
+	BaseDN            string
+	UserFilter        string // Filtro de busca; {username} é substituído pelo username escapado
+	UsernameAttribute string
+	EmailAttribute    string
+	NameAttribute     string
+	GroupAttribute    string
+	GroupRoles        []LDAPGroupRole
+	AutoCreate        bool // Cria o usuário local no primeiro login
+	LinkByUsername    bool // Vincula ao usuário local com o mesmo username
+	LinkPrivileged    bool // Permite o vínculo pelo username a usuários com papéis além do padrão (ex: admin)
+	SyncRoles         bool // Substitui os papéis do usuário a cada login pelos papéis dos grupos
+	Timeout           time.Duration
+}
+
+var (
+	ldapMu     sync.Mutex
+	ldapConfig *LDAPConfig
+	ldapLoaded bool
+)
+
+// ldapEntry reúne os atributos do usuário lidos do diretório
+type ldapEntry struct {
+	DN       string
+	Username string
+	Email    string
+	Name     string
+	Groups   []string
+}
+
+// SetLDAPConfig substitui a configuração lida do ambiente.
+//
+// Permite apontar o backend para outro diretório (ex: um servidor LDAP local em testes).
+//
+// Parâmetros:
+// - config: A configuração do diretório; nil desabilita o backend LDAP.
+func SetLDAPConfig(config *LDAPConfig) {
+	if config != nil {
+		applyLDAPDefaults(config)
+	}
+
+	ldapMu.Lock()
+	ldapConfig = config
+	ldapLoaded = true
+	ldapMu.Unlock()
+}
+
+// getLDAPConfig retorna a configuração do diretório, lida do ambiente na primeira chamada, ou nil se não configurado.
+//
+// Variáveis:
+// - LDAP_URL e LDAP_BASE_DN: Obrigatórias para habilitar o backend.
+// - LDAP_START_TLS, LDAP_CA_FILE, LDAP_BIND_DN, LDAP_BIND_PASSWORD, LDAP_USER_FILTER, LDAP_USERNAME_ATTRIBUTE,
+// LDAP_EMAIL_ATTRIBUTE, LDAP_NAME_ATTRIBUTE, LDAP_GROUP_ATTRIBUTE, LDAP_GROUP_ROLES, LDAP_AUTO_CREATE,
+// LDAP_LINK_BY_USERNAME, LDAP_LINK_PRIVILEGED, LDAP_SYNC_ROLES e LDAP_TIMEOUT: Opcionais.
+func getLDAPConfig() *LDAPConfig {
+	ldapMu.Lock()
+	defer ldapMu.Unlock()
+
+	if ldapLoaded {
+		return ldapConfig
+	}
+	ldapLoaded = true
+
+	config := &LDAPConfig{
+		URL:               utils.GetEnv("LDAP_URL"),
+		StartTLS:          utils.GetEnvBool("LDAP_START_TLS", false),
+		BindDN:            utils.GetEnv("LDAP_BIND_DN"),
+		BindPassword:      utils.GetEnv("LDAP_BIND_PASSWORD"),
+		BaseDN:            utils.GetEnv("LDAP_BASE_DN"),
+		UserFilter:        utils.GetEnv("LDAP_USER_FILTER"),
+		UsernameAttribute: utils.GetEnv("LDAP_USERNAME_ATTRIBUTE"),
+		EmailAttribute:    utils.GetEnv("LDAP_EMAIL_ATTRIBUTE"),
+		NameAttribute:     utils.GetEnv("LDAP_NAME_ATTRIBUTE"),
+		GroupAttribute:    utils.GetEnv("LDAP_GROUP_ATTRIBUTE"),
+		AutoCreate:        utils.GetEnvBool("LDAP_AUTO_CREATE", false),
+		LinkByUsername:    utils.GetEnvBool("LDAP_LINK_BY_USERNAME", false),
+		LinkPrivileged:    utils.GetEnvBool("LDAP_LINK_PRIVILEGED", false),
+		SyncRoles:         utils.GetEnvBool("LDAP_SYNC_ROLES", true),
+		Timeout:           utils.GetEnvDuration("LDAP_TIMEOUT", 0),
+	}
+	if config.URL == "" {
+		return nil
+	}
+	if config.BaseDN == "" {
+		logger.Error("Backend LDAP desabilitado: configure LDAP_BASE_DN")
+		return nil
+	}
+
+	if caFile := utils.GetEnv("LDAP_CA_FILE"); caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			logger.Error("Erro ao ler LDAP_CA_FILE %s: %v", caFile, err)
+			return nil
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			logger.Error("LDAP_CA_FILE %s não contém certificados PEM válidos", caFile)
+			return nil
+		}
+		config.TLSConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+
+	// Os DNs contêm vírgulas, por isso as regras são separadas por ";"
+	for _, item := range strings.Split(utils.GetEnv("LDAP_GROUP_ROLES"), ";") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		i := strings.LastIndex(item, ":")
+		if i <= 0 || strings.TrimSpace(item[i+1:]) == "" {
+			logger.Warn("Regra inválida em LDAP_GROUP_ROLES: %q (formato esperado: grupo:papel)", item)
+			continue
+		}
+		config.GroupRoles = append(config.GroupRoles, LDAPGroupRole{
+			Group: strings.TrimSpace(item[:i]),
+			Role:  strings.TrimSpace(item[i+1:]),
+		})
+	}
+
+	applyLDAPDefaults(config)
+	ldapConfig = config
+	return ldapConfig
+}
+
+// applyLDAPDefaults preenche os atributos e o filtro padrão (OpenLDAP), o timeout e a configuração TLS.
+func applyLDAPDefaults(config *LDAPConfig) {
+	if config.UsernameAttribute == "" {
+		config.UsernameAttribute = "uid"
+	}
+	if config.UserFilter == "" {
+		config.UserFilter = "(&(objectClass=person)(" + config.UsernameAttribute + "={username}))"
+	}
+	if config.EmailAttribute == "" {
+		config.EmailAttribute = "mail"
+	}
+	if config.NameAttribute == "" {
+		config.NameAttribute = "cn"
+	}
+	if config.GroupAttribute == "" {
+		config.GroupAttribute = "memberOf"
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+	if config.TLSConfig == nil {
+		config.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+}
+
+// LDAPAuthenticator valida a senha no diretório LDAP / Active Directory (busca do usuário + bind com a sua senha).
+type LDAPAuthenticator struct{}
+
+// Name identifica o autenticador.
+func (LDAPAuthenticator) Name() string {
+	return LDAPProvider
+}
+
+// Authenticate valida a senha no diretório e retorna o usuário local vinculado.
+//
+// Detalhes:
+//   - O usuário é localizado pela conta de serviço (LDAP_BIND_DN) com LDAP_USER_FILTER e a senha é validada
+//     com um bind no DN encontrado. Usuários que não estão no diretório passam ao próximo autenticador.
+//   - A identidade é gravada em user_identities (provedor "ldap"). Sem vínculo, ela é vinculada ao usuário local
+//     com o mesmo username (LDAP_LINK_BY_USERNAME, e LDAP_LINK_PRIVILEGED se ele tiver papéis além do padrão)
+//     ou, com LDAP_AUTO_CREATE, a um novo usuário.
+//   - Os papéis de LDAP_GROUP_ROLES são atribuídos ao novo usuário e, com LDAP_SYNC_ROLES, a cada login.
+func (LDAPAuthenticator) Authenticate(username, password, ip string) (*models.User, error) {
+	config := getLDAPConfig()
+	if config == nil {
+		return nil, ErrUnknownUser
+	}
+	// Um bind com senha vazia é aceito como anônimo por muitos servidores
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	entry, err := ldapVerifyPassword(config, username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	user, identity, err := resolveLDAPUser(config, entry, ip)
+	if err != nil {
+		return nil, err
+	}
+	if !user.Active {
+		logger.Warn("Login LDAP recusado para o usuário desativado %s", user.Username)
+		return nil, ErrInvalidCredentials
+	}
+
+	if config.SyncRoles && len(config.GroupRoles) > 0 {
+		roles := mapLDAPRoles(config.GroupRoles, entry.Groups)
+		if len(roles) == 0 {
+			roles = []string{models.DefaultRole}
+		}
+		if !sameRoles(roles, user.Roles) {
+			if err := AssignRoles(user.ID, roles, 0, ip); err != nil {
+				logger.Error("Erro ao sincronizar os papéis do usuário %s pelos grupos LDAP: %v", user.Username, err)
+			} else {
+				user.Roles = roles
+			}
+		}
+	}
+
+	if err := models.TouchUserIdentity(identity.ID, entry.Email); err != nil {
+		logger.Error("Erro ao registrar o login pela identidade LDAP ID=%d: %v", identity.ID, err)
+	}
+	return user, nil
+}
+
+// ldapVerifyPassword localiza o usuário no diretório e valida a senha com um bind no seu DN.
+func ldapVerifyPassword(config *LDAPConfig, username, password string) (*ldapEntry, error) {
+	conn, err := ldap.DialURL(config.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: config.Timeout}),
+		ldap.DialWithTLSConfig(config.TLSConfig))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAuthenticatorUnavailable, err)
+	}
+	defer conn.Close()
+	conn.SetTimeout(config.Timeout)
+
+	if config.StartTLS {
+		if err := conn.StartTLS(config.TLSConfig); err != nil {
+			return nil, fmt.Errorf("%w: starttls: %v", ErrAuthenticatorUnavailable, err)
+		}
+	}
+	if config.BindDN != "" {
+		if err := conn.Bind(config.BindDN, config.BindPassword); err != nil {
+			return nil, fmt.Errorf("%w: bind da conta de serviço: %v", ErrAuthenticatorUnavailable, err)
+		}
+	}
+
+	request := ldap.NewSearchRequest(
+		config.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(config.Timeout.Seconds()), false,
+		strings.ReplaceAll(config.UserFilter, "{username}", ldap.EscapeFilter(username)),
+		[]string{config.UsernameAttribute, config.EmailAttribute, config.NameAttribute, config.GroupAttribute},
+		nil,
+	)
+	result, err := conn.Search(request)
+	switch {
+	case ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) || (err == nil && len(result.Entries) > 1):
+		logger.Warn("Filtro LDAP ambíguo: mais de uma entrada para o usuário %s", username)
+		return nil, ErrInvalidCredentials
+	case err != nil:
+		return nil, fmt.Errorf("%w: busca: %v", ErrAuthenticatorUnavailable, err)
+	case len(result.Entries) == 0:
+		return nil, ErrUnknownUser
+	}
+
+	found := result.Entries[0]
+	if err := conn.Bind(found.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			logger.Warn("Senha recusada pelo diretório LDAP para o usuário %s", username)
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("%w: bind do usuário: %v", ErrAuthenticatorUnavailable, err)
+	}
+
+	entry := &ldapEntry{
+		DN:       found.DN,
+		Username: strings.ToLower(found.GetAttributeValue(config.UsernameAttribute)),
+		Email:    found.GetAttributeValue(config.EmailAttribute),
+		Name:     found.GetAttributeValue(config.NameAttribute),
+		Groups:   found.GetAttributeValues(config.GroupAttribute),
+	}
+	if entry.Username == "" {
+		entry.Username = strings.ToLower(username)
+	}
+	return entry, nil
+}
+
+// ldapSubject retorna o username do diretório vinculado ao usuário, ou "" se o usuário for apenas local.
+func ldapSubject(userID int) (string, error) {
+	identities, err := models.ListUserIdentities(userID)
+	if err != nil {
+		return "", err
+	}
+	for _, identity := range identities {
+		if identity.Provider == LDAPProvider {
+			return identity.Subject, nil
+		}
+	}
+	return "", nil
+}
+
+// resolveLDAPUser localiza o usuário vinculado à entrada do diretório, vinculando-a ou criando o usuário se configurado.
+func resolveLDAPUser(config *LDAPConfig, entry *ldapEntry, ip string) (*models.User, *models.UserIdentity, error) {
+	identity, err := models.GetUserIdentity(LDAPProvider, entry.Username)
+	if err != nil {
+		return nil, nil, err
+	}
+	if identity != nil {
+		user, err := models.GetUserByID(identity.UserID)
+		if err != nil {
+			return nil, nil, err
+		}
+		return user, identity, nil
+	}
+
+	user, err := matchLDAPAccount(config, entry, models.GetUserByUsername)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil {
+		if user, err = createLDAPUser(config, entry, ip); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	identity = &models.UserIdentity{UserID: user.ID, Provider: LDAPProvider, Subject: entry.Username, Email: entry.Email}
+	if identity.ID, err = models.CreateUserIdentity(*identity); err != nil {
+		return nil, nil, err
+	}
+
+	_ = models.RecordAuditEvent(models.AuditEvent{
+		EventType: models.AuditIdentityLinked,
+		UserID:    user.ID,
+		Username:  user.Username,
+		IP:        ip,
+		Details:   fmt.Sprintf("provedor=%s; dn=%s", LDAPProvider, entry.DN),
+	})
+	return user, identity, nil
+}
+
+// matchLDAPAccount escolhe a conta local de uma entrada do diretório ainda sem vínculo.
+//
+// Parâmetros:
+// - config: A configuração do diretório.
+// - entry: A entrada autenticada pelo diretório.
+// - findByUsername: Busca o usuário local pelo username (models.GetUserByUsername).
+//
+// Retorno:
+// - *models.User: O usuário com o mesmo username, a vincular, ou nil se um novo usuário deve ser criado (LDAP_AUTO_CREATE).
+// - error: ErrUnknownUser se o usuário local não puder ser vinculado, ErrInvalidCredentials se a entrada não puder
+// ser vinculada nem criada, ou erro da busca.
+//
+// Detalhes:
+//   - Usuários locais com papéis além do padrão (ex: o administrador) só são vinculados com LDAP_LINK_PRIVILEGED:
+//     sem isso, quem tivesse no diretório o mesmo username passaria a entrar como esse usuário. Recusado o vínculo,
+//     o login passa ao próximo backend e o usuário continua entrando com a senha local.
+func matchLDAPAccount(config *LDAPConfig, entry *ldapEntry, findByUsername func(username string) (*models.User, error)) (*models.User, error) {
+	if config.LinkByUsername {
+		user, err := findByUsername(entry.Username)
+		if err == nil {
+			if !config.LinkPrivileged && !onlyDefaultRole(user.Roles) {
+				logger.Warn("Vínculo LDAP recusado: o usuário local %s possui papéis além do padrão (%s)",
+					user.Username, strings.Join(user.Roles, ","))
+				return nil, ErrUnknownUser
+			}
+			return user, nil
+		}
+		if !errors.Is(err, models.ErrUserNotFound) {
+			return nil, err
+		}
+	}
+
+	if !config.AutoCreate {
+		logger.Warn("Usuário %s autenticado pelo diretório LDAP, mas sem conta local", entry.Username)
+		return nil, ErrInvalidCredentials
+	}
+	if entry.Email == "" {
+		logger.Warn("Usuário LDAP %s não criado: a entrada não possui o atributo %s", entry.Username, config.EmailAttribute)
+		return nil, ErrInvalidCredentials
+	}
+	return nil, nil
+}
+
+// onlyDefaultRole informa se a lista de papéis está vazia ou contém apenas o papel padrão.
+func onlyDefaultRole(roles []string) bool {
+	for _, role := range roles {
+		if role != models.DefaultRole {
+			return false
+		}
+	}
+	return true
+}
+
+// createLDAPUser cria o usuário local de uma entrada do diretório, com o e-mail do diretório já verificado.
+// A senha local é aleatória: o usuário entra sempre pelo diretório.
+func createLDAPUser(config *LDAPConfig, entry *ldapEntry, ip string) (*models.User, error) {
+	username, err := availableUsername(&oidcClaims{PreferredUsername: entry.Username, Email: entry.Email})
+	if err != nil {
+		return nil, err
+	}
+	password, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+
+	user := models.User{
+		Name:     strings.TrimSpace(entry.Name),
+		Username: username,
+		Email:    entry.Email,
+		Password: password,
+		Roles:    mapLDAPRoles(config.GroupRoles, entry.Groups),
+	}
+	if user.Name == "" {
+		user.Name = username
+	}
+	if user.ID, err = models.CreateNewUser(user); err != nil {
+		return nil, err
+	}
+	if _, err := models.MarkEmailVerified(user.ID, user.Email); err != nil {
+		logger.Error("Erro ao marcar o e-mail do usuário ID=%d como verificado: %v", user.ID, err)
+	}
+
+	_ = models.RecordAuditEvent(models.AuditEvent{
+		EventType: models.AuditUserCreated,
+		UserID:    user.ID,
+		Username:  user.Username,
+		IP:        ip,
+		Details:   fmt.Sprintf("ldap=%s; papeis=%s", entry.DN, strings.Join(user.Roles, ",")),
+	})
+	logger.Info("Usuário %s criado pelo login LDAP", user.Username)
+
+	return models.GetUserByID(user.ID)
+}
+
+// mapLDAPRoles retorna os papéis dos grupos do usuário, sem repetições.
+func mapLDAPRoles(rules []LDAPGroupRole, groups []string) []string {
+	var roles []string
+	seen := map[string]bool{}
+	for _, rule := range rules {
+		if seen[rule.Role] {
+			continue
+		}
+		for _, group := range groups {
+			if ldapGroupMatches(rule.Group, group) {
+				seen[rule.Role] = true
+				roles = append(roles, rule.Role)
+				break
+			}
+		}
+	}
+	return roles
+}
+
+// ldapGroupMatches compara o grupo da regra com o DN do grupo do usuário: pelo DN completo ou, se a regra
+// não for um DN, pelo CN (sem diferenciar maiúsculas).
+func ldapGroupMatches(rule, groupDN string) bool {
+	dn, err := ldap.ParseDN(groupDN)
+	if err != nil || len(dn.RDNs) == 0 || len(dn.RDNs[0].Attributes) == 0 {
+		return strings.EqualFold(rule, groupDN)
+	}
+
+	if strings.Contains(rule, "=") {
+		ruleDN, err := ldap.ParseDN(rule)
+		return err == nil && ruleDN.EqualFold(dn)
+	}
+	return strings.EqualFold(rule, dn.RDNs[0].Attributes[0].Value)
+}
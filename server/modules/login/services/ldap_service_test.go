@@ -0,0 +1,546 @@
+// pwd: /app/server/modules/login/services/ldap_service_test.go
+
+package services
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"api/server/modules/login/models"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
+
+const (
+	testLDAPBaseDN    = "dc=example,dc=com"
+	testLDAPBindDN    = "cn=svc,dc=example,dc=com"
+	testLDAPBindPass  = "svc-secret"
+	testLDAPFinanceDN = "cn=Financeiro,ou=groups,dc=example,dc=com"
+	testLDAPSupportDN = "cn=TI,ou=groups,dc=example,dc=com"
+	testLDAPInjection = "*)(uid=*"
+	testLDAPMariaPass = "senha-maria"
+	testLDAPJoaoPass  = "senha-joao"
+)
+
+// testLDAPEntry é uma entrada do diretório de teste
+type testLDAPEntry struct {
+	dn         string
+	password   string
+	attributes map[string][]string
+}
+
+// testLDAPServer é um servidor LDAP em processo, com bind simples e busca por filtros de igualdade e presença.
+type testLDAPServer struct {
+	t        *testing.T
+	listener net.Listener
+	entries  []testLDAPEntry
+
+	mu      sync.Mutex
+	binds   []string // DNs dos binds recebidos
+	filters []string // Filtros das buscas recebidas
+	values  []string // Valores das asserções de igualdade recebidas, sem escape
+}
+
+func newTestLDAPServer(t *testing.T) *testLDAPServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("erro ao iniciar o servidor LDAP: %v", err)
+	}
+	s := &testLDAPServer{t: t, listener: listener, entries: []testLDAPEntry{
+		{dn: testLDAPBindDN, password: testLDAPBindPass, attributes: map[string][]string{
+			"objectClass": {"applicationProcess"},
+			"cn":          {"svc"},
+		}},
+		{dn: "uid=maria,ou=people," + testLDAPBaseDN, password: testLDAPMariaPass, attributes: map[string][]string{
+			"objectClass": {"person", "inetOrgPerson"},
+			"uid":         {"maria"},
+			"cn":          {"Maria Souza"},
+			"mail":        {"maria@example.com"},
+			"memberOf":    {testLDAPFinanceDN, testLDAPSupportDN},
+		}},
+		{dn: "uid=joao,ou=people," + testLDAPBaseDN, password: testLDAPJoaoPass, attributes: map[string][]string{
+			"objectClass": {"person"},
+			"uid":         {"joao"},
+			"cn":          {"João"},
+		}},
+	}}
+
+	go s.serve()
+	t.Cleanup(func() { listener.Close() })
+	return s
+}
+
+// url retorna o endereço ldap:// do servidor.
+func (s *testLDAPServer) url() string {
+	return "ldap://" + s.listener.Addr().String()
+}
+
+// config retorna a configuração do backend apontando para o servidor, com os padrões aplicados.
+func (s *testLDAPServer) config() *LDAPConfig {
+	config := &LDAPConfig{
+		URL:          s.url(),
+		BindDN:       testLDAPBindDN,
+		BindPassword: testLDAPBindPass,
+		BaseDN:       testLDAPBaseDN,
+		GroupRoles: []LDAPGroupRole{
+			{Group: "CN=FINANCEIRO,OU=Groups,DC=example,DC=com", Role: "finance"},
+			{Group: "ti", Role: "support"},
+			{Group: "rh", Role: "hr"},
+			{Group: "Financeiro", Role: "finance"},
+		},
+	}
+	applyLDAPDefaults(config)
+	return config
+}
+
+func (s *testLDAPServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *testLDAPServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		messageID, _ := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			s.bind(conn, messageID, op)
+		case ldap.ApplicationSearchRequest:
+			s.search(conn, messageID, op)
+		case ldap.ApplicationUnbindRequest:
+			return
+		default:
+			s.write(conn, messageID, ldapResult(ldap.ApplicationExtendedResponse, ldap.LDAPResultUnwillingToPerform))
+		}
+	}
+}
+
+func (s *testLDAPServer) bind(conn net.Conn, messageID int64, op *ber.Packet) {
+	dn := op.Children[1].Data.String()
+	password := op.Children[2].Data.String()
+
+	s.mu.Lock()
+	s.binds = append(s.binds, dn)
+	s.mu.Unlock()
+
+	code := ldap.LDAPResultInvalidCredentials
+	if dn == "" && password == "" {
+		code = ldap.LDAPResultSuccess // Bind anônimo
+	}
+	for _, entry := range s.entries {
+		if strings.EqualFold(entry.dn, dn) && password != "" && entry.password == password {
+			code = ldap.LDAPResultSuccess
+		}
+	}
+	s.write(conn, messageID, ldapResult(ldap.ApplicationBindResponse, code))
+}
+
+func (s *testLDAPServer) search(conn net.Conn, messageID int64, op *ber.Packet) {
+	base := op.Children[0].Data.String()
+	sizeLimit, _ := op.Children[3].Value.(int64)
+	filter := op.Children[6]
+
+	decompiled, _ := ldap.DecompileFilter(filter)
+	s.mu.Lock()
+	s.filters = append(s.filters, decompiled)
+	s.mu.Unlock()
+
+	found := 0
+	for _, entry := range s.entries {
+		if !strings.HasSuffix(strings.ToLower(entry.dn), strings.ToLower(base)) || !s.matches(entry, filter) {
+			continue
+		}
+		if sizeLimit > 0 && int64(found) == sizeLimit {
+			s.write(conn, messageID, ldapResult(ldap.ApplicationSearchResultDone, ldap.LDAPResultSizeLimitExceeded))
+			return
+		}
+		found++
+
+		result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+		result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.dn, "DN"))
+		attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+		for name, values := range entry.attributes {
+			attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+			attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+			set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+			for _, value := range values {
+				set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+			}
+			attribute.AppendChild(set)
+			attributes.AppendChild(attribute)
+		}
+		result.AppendChild(attributes)
+		s.write(conn, messageID, result)
+	}
+	s.write(conn, messageID, ldapResult(ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess))
+}
+
+// matches avalia os filtros and, or, not, igualdade (sem diferenciar maiúsculas) e presença.
+func (s *testLDAPServer) matches(entry testLDAPEntry, filter *ber.Packet) bool {
+	switch filter.Tag {
+	case ldap.FilterAnd:
+		for _, child := range filter.Children {
+			if !s.matches(entry, child) {
+				return false
+			}
+		}
+		return true
+	case ldap.FilterOr:
+		for _, child := range filter.Children {
+			if s.matches(entry, child) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterNot:
+		return len(filter.Children) == 1 && !s.matches(entry, filter.Children[0])
+	case ldap.FilterEqualityMatch:
+		value := filter.Children[1].Data.String()
+		s.mu.Lock()
+		s.values = append(s.values, value)
+		s.mu.Unlock()
+
+		for _, v := range entry.values(filter.Children[0].Data.String()) {
+			if strings.EqualFold(v, value) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterPresent:
+		return len(entry.values(filter.Data.String())) > 0
+	}
+	return false
+}
+
+// values retorna os valores do atributo, sem diferenciar maiúsculas no nome.
+func (e testLDAPEntry) values(name string) []string {
+	for attribute, values := range e.attributes {
+		if strings.EqualFold(attribute, name) {
+			return values
+		}
+	}
+	return nil
+}
+
+func (s *testLDAPServer) write(conn net.Conn, messageID int64, op *ber.Packet) {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "Message ID"))
+	packet.AppendChild(op)
+	_, _ = conn.Write(packet.Bytes())
+}
+
+// ldapResult monta um LDAPResult (resultCode, matchedDN, diagnosticMessage) da operação informada.
+func ldapResult(tag ber.Tag, code int) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "Result Code"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	return op
+}
+
+// setupTestLDAP aponta o backend LDAP para o servidor de teste.
+func setupTestLDAP(t *testing.T, config *LDAPConfig) {
+	t.Helper()
+
+	SetLDAPConfig(config)
+	t.Cleanup(func() {
+		ldapMu.Lock()
+		ldapConfig, ldapLoaded = nil, false
+		ldapMu.Unlock()
+	})
+}
+
+func TestLDAPVerifyPassword(t *testing.T) {
+	server := newTestLDAPServer(t)
+
+	entry, err := ldapVerifyPassword(server.config(), "MARIA", testLDAPMariaPass)
+	if err != nil {
+		t.Fatalf("ldapVerifyPassword() = %v", err)
+	}
+	want := &ldapEntry{
+		DN:       "uid=maria,ou=people," + testLDAPBaseDN,
+		Username: "maria",
+		Email:    "maria@example.com",
+		Name:     "Maria Souza",
+		Groups:   []string{testLDAPFinanceDN, testLDAPSupportDN},
+	}
+	if !reflect.DeepEqual(entry, want) {
+		t.Errorf("entrada = %+v, esperado %+v", entry, want)
+	}
+
+	// A busca usa a conta de serviço e a senha é validada com um bind no DN encontrado
+	if want := []string{testLDAPBindDN, want.DN}; !reflect.DeepEqual(server.binds, want) {
+		t.Errorf("binds = %q, esperado %q", server.binds, want)
+	}
+}
+
+func TestLDAPVerifyPasswordRejected(t *testing.T) {
+	server := newTestLDAPServer(t)
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("erro ao reservar uma porta: %v", err)
+	}
+	downURL := "ldap://" + closed.Addr().String()
+	closed.Close()
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		change   func(*LDAPConfig)
+		wantErr  error
+	}{
+		{"senha errada", "maria", "errada", nil, ErrInvalidCredentials},
+		{"senha de outro usuário", "maria", testLDAPJoaoPass, nil, ErrInvalidCredentials},
+		{"usuário fora do diretório", "backup", "qualquer", nil, ErrUnknownUser},
+		{"injeção no filtro", testLDAPInjection, "qualquer", nil, ErrUnknownUser},
+		{"filtro ambíguo", "maria", testLDAPMariaPass, func(c *LDAPConfig) { c.UserFilter = "(objectClass=person)" }, ErrInvalidCredentials},
+		{"bind da conta de serviço recusado", "maria", testLDAPMariaPass, func(c *LDAPConfig) { c.BindPassword = "errada" }, ErrAuthenticatorUnavailable},
+		{"diretório fora do ar", "maria", testLDAPMariaPass, func(c *LDAPConfig) { c.URL = downURL }, ErrAuthenticatorUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := server.config()
+			if tt.change != nil {
+				tt.change(config)
+			}
+			if _, err := ldapVerifyPassword(config, tt.username, tt.password); !errors.Is(err, tt.wantErr) {
+				t.Errorf("ldapVerifyPassword() = %v, esperado %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLDAPFilterEscaping(t *testing.T) {
+	server := newTestLDAPServer(t)
+
+	if _, err := ldapVerifyPassword(server.config(), testLDAPInjection, "qualquer"); !errors.Is(err, ErrUnknownUser) {
+		t.Fatalf("ldapVerifyPassword() = %v, esperado %v", err, ErrUnknownUser)
+	}
+
+	// O username chega ao servidor como um único valor de igualdade, e não como novos filtros
+	if want := `(&(objectClass=person)(uid=\2a\29\28uid=\2a))`; len(server.filters) != 1 || server.filters[0] != want {
+		t.Errorf("filtros = %q, esperado %q", server.filters, want)
+	}
+	found := false
+	for _, value := range server.values {
+		found = found || value == testLDAPInjection
+	}
+	if !found {
+		t.Errorf("valores de igualdade = %q, esperado %q", server.values, testLDAPInjection)
+	}
+}
+
+func TestLDAPGroupRoles(t *testing.T) {
+	server := newTestLDAPServer(t)
+	config := server.config()
+
+	maria, err := ldapVerifyPassword(config, "maria", testLDAPMariaPass)
+	if err != nil {
+		t.Fatalf("ldapVerifyPassword() = %v", err)
+	}
+	// Pelo DN completo (sem diferenciar maiúsculas) ou pelo CN, sem repetir o papel
+	if roles := mapLDAPRoles(config.GroupRoles, maria.Groups); !reflect.DeepEqual(roles, []string{"finance", "support"}) {
+		t.Errorf("papéis = %v, esperado [finance support]", roles)
+	}
+
+	joao, err := ldapVerifyPassword(config, "joao", testLDAPJoaoPass)
+	if err != nil {
+		t.Fatalf("ldapVerifyPassword() = %v", err)
+	}
+	if roles := mapLDAPRoles(config.GroupRoles, joao.Groups); len(roles) != 0 {
+		t.Errorf("papéis = %v, esperado nenhum", roles)
+	}
+
+	// Um DN de outra árvore com o mesmo CN não atende a uma regra por DN
+	if ldapGroupMatches("cn=Financeiro,ou=groups,dc=example,dc=com", "cn=Financeiro,ou=groups,dc=outra,dc=com") {
+		t.Errorf("ldapGroupMatches() aceitou um DN de outra árvore")
+	}
+}
+
+func TestLDAPConfigFromEnv(t *testing.T) {
+	setupTestLDAP(t, nil)
+	ldapMu.Lock()
+	ldapLoaded = false
+	ldapMu.Unlock()
+
+	t.Setenv("LDAP_URL", "ldap://ldap.example.com")
+	t.Setenv("LDAP_BASE_DN", testLDAPBaseDN)
+	t.Setenv("LDAP_GROUP_ROLES", testLDAPFinanceDN+":finance; TI : support ;inválida;:semgrupo")
+
+	config := getLDAPConfig()
+	if config == nil {
+		t.Fatalf("getLDAPConfig() = nil")
+	}
+	want := []LDAPGroupRole{{Group: testLDAPFinanceDN, Role: "finance"}, {Group: "TI", Role: "support"}}
+	if !reflect.DeepEqual(config.GroupRoles, want) {
+		t.Errorf("regras = %+v, esperado %+v", config.GroupRoles, want)
+	}
+	if config.UserFilter != "(&(objectClass=person)(uid={username}))" || config.AutoCreate || config.LinkByUsername ||
+		config.LinkPrivileged || !config.SyncRoles {
+		t.Errorf("padrões = %+v", config)
+	}
+}
+
+func TestLDAPAccountMatching(t *testing.T) {
+	server := newTestLDAPServer(t)
+	maria, err := ldapVerifyPassword(server.config(), "maria", testLDAPMariaPass)
+	if err != nil {
+		t.Fatalf("ldapVerifyPassword() = %v", err)
+	}
+	joao, err := ldapVerifyPassword(server.config(), "joao", testLDAPJoaoPass)
+	if err != nil {
+		t.Fatalf("ldapVerifyPassword() = %v", err)
+	}
+
+	local := &models.User{ID: 3, Username: "maria", Roles: []string{models.DefaultRole}}
+	admin := &models.User{ID: 1, Username: "admin", Roles: []string{models.AdminRole, models.DefaultRole}}
+	adminEntry := &ldapEntry{Username: "admin", Email: "admin@example.com"}
+	findByUsername := func(username string) (*models.User, error) {
+		for _, user := range []*models.User{local, admin} {
+			if username == user.Username {
+				return user, nil
+			}
+		}
+		return nil, models.ErrUserNotFound
+	}
+
+	tests := []struct {
+		name           string
+		entry          *ldapEntry
+		linkByUsername bool
+		linkPrivileged bool
+		autoCreate     bool
+		wantUser       *models.User
+		wantErr        error
+	}{
+		{"vínculo pelo username", maria, true, false, false, local, nil},
+		{"sem LINK_BY_USERNAME cria a conta", maria, false, false, true, nil, nil},
+		{"sem vínculo nem AUTO_CREATE", maria, false, false, false, nil, ErrInvalidCredentials},
+		{"usuário novo com AUTO_CREATE", &ldapEntry{Username: "ana", Email: "ana@example.com"}, true, false, true, nil, nil},
+		{"AUTO_CREATE sem e-mail no diretório", joao, true, false, true, nil, ErrInvalidCredentials},
+		{"usuário novo sem AUTO_CREATE", joao, true, false, false, nil, ErrInvalidCredentials},
+		{"administrador local sem LINK_PRIVILEGED", adminEntry, true, false, true, nil, ErrUnknownUser},
+		{"administrador local com LINK_PRIVILEGED", adminEntry, true, true, false, admin, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := server.config()
+			config.LinkByUsername = tt.linkByUsername
+			config.LinkPrivileged = tt.linkPrivileged
+			config.AutoCreate = tt.autoCreate
+
+			user, err := matchLDAPAccount(config, tt.entry, findByUsername)
+			if !errors.Is(err, tt.wantErr) || user != tt.wantUser {
+				t.Errorf("matchLDAPAccount() = %v, %v; esperado %v, %v", user, err, tt.wantUser, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("erro na busca", func(t *testing.T) {
+		failure := errors.New("banco indisponível")
+		config := server.config()
+		config.LinkByUsername, config.AutoCreate = true, true
+
+		_, err := matchLDAPAccount(config, maria, func(string) (*models.User, error) { return nil, failure })
+		if !errors.Is(err, failure) {
+			t.Errorf("matchLDAPAccount() = %v, esperado %v", err, failure)
+		}
+	})
+}
+
+// stubAuthenticator simula o banco local, com as contas de serviço que não existem no diretório.
+type stubAuthenticator struct {
+	users map[string]string // username → senha
+	calls []string
+}
+
+func (a *stubAuthenticator) Name() string {
+	return "local"
+}
+
+func (a *stubAuthenticator) Authenticate(username, password, ip string) (*models.User, error) {
+	a.calls = append(a.calls, username)
+	expected, ok := a.users[username]
+	if !ok {
+		return nil, ErrUnknownUser
+	}
+	if password != expected {
+		return nil, ErrInvalidCredentials
+	}
+	return &models.User{Username: username}, nil
+}
+
+func TestAuthenticatePasswordFallback(t *testing.T) {
+	server := newTestLDAPServer(t)
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("erro ao reservar uma porta: %v", err)
+	}
+	downURL := "ldap://" + closed.Addr().String()
+	closed.Close()
+
+	tests := []struct {
+		name      string
+		username  string
+		password  string
+		down      bool
+		wantErr   error
+		wantLocal bool // O banco local foi consultado
+	}{
+		{"conta de serviço local", "backup", "senha-local", false, nil, true},
+		{"conta de serviço com senha errada", "backup", "errada", false, ErrInvalidCredentials, true},
+		{"senha recusada pelo diretório", "maria", "errada", false, ErrInvalidCredentials, false},
+		{"injeção no filtro", testLDAPInjection, "qualquer", false, ErrInvalidCredentials, true},
+		{"senha vazia", "maria", "", false, ErrInvalidCredentials, false},
+		{"diretório fora do ar", "backup", "senha-local", true, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := server.config()
+			if tt.down {
+				config.URL = downURL
+			}
+			setupTestLDAP(t, config)
+
+			local := &stubAuthenticator{users: map[string]string{"backup": "senha-local"}}
+			SetAuthenticators([]Authenticator{LDAPAuthenticator{}, local})
+			t.Cleanup(func() { SetAuthenticators(nil) })
+
+			user, err := AuthenticatePassword(tt.username, tt.password, "127.0.0.1")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AuthenticatePassword() = %v, esperado %v", err, tt.wantErr)
+			}
+			if err == nil && user.Username != tt.username {
+				t.Errorf("usuário = %q, esperado %q", user.Username, tt.username)
+			}
+			if called := len(local.calls) > 0; called != tt.wantLocal {
+				t.Errorf("banco local consultado = %t, esperado %t", called, tt.wantLocal)
+			}
+		})
+	}
+}
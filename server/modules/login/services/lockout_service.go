@@ -0,0 +1,198 @@
+// pwd: /app/server/modules/login/services/lockout_service.go
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"api/logger"
+	"api/server/modules/login/models"
+	"api/utils"
+)
+
+// Configuração da proteção contra força bruta, carregada das variáveis de ambiente
+var (
+	maxAttemptsPerUser = utils.GetEnvInt("LOGIN_MAX_ATTEMPTS", 5)
+	maxAttemptsPerIP   = utils.GetEnvInt("LOGIN_MAX_ATTEMPTS_IP", 20)
+	failureWindow      = utils.GetEnvDuration("LOGIN_FAILURE_WINDOW", 15*time.Minute)
+	lockoutDuration    = utils.GetEnvDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute)
+	failureDelayBase   = utils.GetEnvDuration("LOGIN_DELAY_BASE", 250*time.Millisecond)
+	failureDelayMax    = utils.GetEnvDuration("LOGIN_DELAY_MAX", 5*time.Second)
+)
+
+// CheckLoginLockout verifica se o login está bloqueado para o usuário ou para o IP.
+//
+// Parâmetros:
+// - username: O nome de usuário informado na tentativa de login.
+// - ip: O IP de origem da requisição.
+//
+// Retorno:
+// - time.Duration: Tempo restante de bloqueio (zero se o login estiver liberado).
+//
+// Detalhes:
+// - O bloqueio é avaliado independentemente de o usuário existir, para não revelar contas válidas.
+// - Em caso de erro no banco de dados, o login é liberado (a verificação de senha continua valendo).
+func CheckLoginLockout(username, ip string) time.Duration {
+	var remaining time.Duration
+
+	for _, key := range lockoutKeys(username, ip) {
+		failure, err := models.GetLoginFailure(key.scope, key.identifier)
+		if err != nil || failure == nil || failure.LockedUntil == nil {
+			continue
+		}
+
+		if left := time.Until(*failure.LockedUntil); left > remaining {
+			remaining = left
+		}
+	}
+
+	return remaining
+}
+
+// RegisterLoginFailure contabiliza uma tentativa de login com falha e aplica o bloqueio quando necessário.
+//
+// Parâmetros:
+// - username: O nome de usuário informado na tentativa de login.
+// - ip: O IP de origem da requisição.
+//
+// Retorno:
+// - time.Duration: Atraso progressivo a ser aplicado antes de responder ao cliente.
+//
+// Detalhes:
+// - Ao atingir LOGIN_MAX_ATTEMPTS (usuário) ou LOGIN_MAX_ATTEMPTS_IP (IP), o login é bloqueado por LOGIN_LOCKOUT_DURATION.
+// - Cada bloqueio é registrado na auditoria.
+func RegisterLoginFailure(username, ip string) time.Duration {
+	highest := 0
+
+	for _, key := range lockoutKeys(username, ip) {
+		failures, err := models.IncrementLoginFailure(key.scope, key.identifier, failureWindow)
+		if err != nil {
+			continue
+		}
+
+		if failures > highest {
+			highest = failures
+		}
+
+		if key.limit > 0 && failures >= key.limit {
+			lockLogin(key, ip)
+		}
+	}
+
+	return progressiveDelay(highest)
+}
+
+// RegisterLoginSuccess zera o contador de falhas do usuário após um login bem-sucedido.
+//
+// Parâmetros:
+// - username: O nome de usuário autenticado.
+//
+// Detalhes:
+// - O contador do IP não é zerado, para que um atacante não o reinicie usando uma conta própria.
+func RegisterLoginSuccess(username string) {
+	if _, err := models.ClearLoginFailures(models.FailureScopeUsername, normalizeUsername(username)); err != nil {
+		logger.Warn("Não foi possível zerar as falhas de login do usuário %s: %v", username, err)
+	}
+}
+
+// UnlockLogin remove o bloqueio de login de um usuário e/ou IP.
+//
+// Parâmetros:
+// - username: O nome de usuário a desbloquear (opcional).
+// - ip: O IP a desbloquear (opcional).
+// - actorID: ID do administrador que executou a ação.
+// - actorIP: IP de origem da requisição do administrador.
+//
+// Retorno:
+// - bool: true se algum bloqueio ou contador foi removido.
+// - error: Retorna erro se a remoção falhar.
+func UnlockLogin(username, ip string, actorID int, actorIP string) (bool, error) {
+	removed := false
+
+	for _, key := range lockoutKeys(username, ip) {
+		cleared, err := models.ClearLoginFailures(key.scope, key.identifier)
+		if err != nil {
+			return removed, err
+		}
+		if !cleared {
+			continue
+		}
+
+		removed = true
+		_ = models.RecordAuditEvent(models.AuditEvent{
+			EventType: models.AuditAccountUnlocked,
+			Username:  key.username(),
+			ActorID:   actorID,
+			IP:        actorIP,
+			Details:   fmt.Sprintf("%s=%s", key.scope, key.identifier),
+		})
+	}
+
+	return removed, nil
+}
+
+// lockoutKey identifica um contador de falhas e o limite que dispara o bloqueio.
+type lockoutKey struct {
+	scope      string
+	identifier string
+	limit      int
+}
+
+// username retorna o nome de usuário do contador, ou vazio para contadores por IP.
+func (k lockoutKey) username() string {
+	if k.scope == models.FailureScopeUsername {
+		return k.identifier
+	}
+	return ""
+}
+
+// lockoutKeys monta os contadores aplicáveis a uma tentativa de login.
+func lockoutKeys(username, ip string) []lockoutKey {
+	var keys []lockoutKey
+	if username = normalizeUsername(username); username != "" {
+		keys = append(keys, lockoutKey{models.FailureScopeUsername, username, maxAttemptsPerUser})
+	}
+	if ip != "" {
+		keys = append(keys, lockoutKey{models.FailureScopeIP, ip, maxAttemptsPerIP})
+	}
+	return keys
+}
+
+// lockLogin aplica o bloqueio temporário e registra o evento de auditoria.
+func lockLogin(key lockoutKey, ip string) {
+	until := time.Now().Add(lockoutDuration)
+	if err := models.LockLogin(key.scope, key.identifier, until); err != nil {
+		return
+	}
+
+	logger.Warn("Login bloqueado até %s (%s=%s)", until.Format(time.RFC3339), key.scope, key.identifier)
+	_ = models.RecordAuditEvent(models.AuditEvent{
+		EventType: models.AuditAccountLocked,
+		Username:  key.username(),
+		IP:        ip,
+		Details:   fmt.Sprintf("%s=%s; bloqueado_ate=%s", key.scope, key.identifier, until.Format(time.RFC3339)),
+	})
+}
+
+// progressiveDelay calcula o atraso exponencial para a quantidade de falhas informada.
+func progressiveDelay(failures int) time.Duration {
+	if failures <= 1 || failureDelayBase <= 0 {
+		return failureDelayBase
+	}
+
+	delay := failureDelayBase
+	for i := 1; i < failures && delay < failureDelayMax; i++ {
+		delay *= 2
+	}
+
+	if delay > failureDelayMax {
+		delay = failureDelayMax
+	}
+	return delay
+}
+
+// normalizeUsername padroniza o nome de usuário usado como chave dos contadores.
+func normalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
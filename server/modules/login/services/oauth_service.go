@@ -0,0 +1,696 @@
+// pwd: /app/server/modules/login/services/oauth_service.go
+package services
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"api/logger"
+	"api/server/modules/login/auth_utils"
+	"api/server/modules/login/models"
+	"api/utils"
+	"api/utils/cache"
+)
+
+// Tipos de concessão (grant types) suportados pelo servidor OAuth2
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantClientCredentials = "client_credentials"
+)
+
+// Erros da administração dos clientes OAuth2 e dos consentimentos
+var (
+	ErrOAuthClientNotFound    = errors.New("cliente oauth2 não encontrado")
+	ErrOAuthConsentNotFound   = errors.New("consentimento oauth2 não encontrado")
+	ErrInvalidOAuthClientName = errors.New("nome de cliente oauth2 inválido")
+	ErrInvalidRedirectURI     = errors.New("redirect_uri inválida")
+	ErrInvalidGrantTypes      = errors.New("grant_types inválidos")
+)
+
+var (
+	oauthAccessTokenTTL = utils.GetEnvDuration("OAUTH_ACCESS_TOKEN_TTL", 15*time.Minute)
+	oauthCodeTTL        = utils.GetEnvDuration("OAUTH_CODE_TTL", time.Minute)
+
+	// Códigos de autorização emitidos, indexados pelo hash do código. Cada código só pode ser trocado uma vez.
+	oauthCodes = cache.New[string, oauthAuthorizationCode](oauthCodeTTL)
+)
+
+// OAuthError representa um erro do protocolo OAuth2 (RFC 6749, seção 5.2), com o código padronizado e a descrição.
+type OAuthError struct {
+	Code        string // invalid_request, invalid_client, invalid_grant, invalid_scope, access_denied...
+	Description string
+	RedirectTo  string // Endereço do cliente que recebe o erro, quando a redirect_uri já foi validada
+}
+
+// Error implementa a interface error.
+func (e *OAuthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+// AuthorizationRequest representa os parâmetros de uma requisição de autorização (RFC 6749, seção 4.1.1, e RFC 7636)
+type AuthorizationRequest struct {
+	ResponseType        string `form:"response_type" json:"response_type"`
+	ClientID            string `form:"client_id" json:"client_id"`
+	RedirectURI         string `form:"redirect_uri" json:"redirect_uri"`
+	Scope               string `form:"scope" json:"scope"`
+	State               string `form:"state" json:"state"`
+	CodeChallenge       string `form:"code_challenge" json:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method" json:"code_challenge_method"`
+}
+
+// ConsentInfo representa os dados exibidos pelo front-end na tela de consentimento
+type ConsentInfo struct {
+	ClientID        string   `json:"client_id"`
+	ClientName      string   `json:"client_name"`
+	Scopes          []string `json:"scopes"`           // Escopos que serão concedidos (os solicitados que o usuário possui)
+	ConsentRequired bool     `json:"consent_required"` // false se o cliente for confiável ou se o usuário já autorizou os escopos
+}
+
+// TokenRequest representa os parâmetros do endpoint de token (RFC 6749, seções 4.1.3 e 4.4.2)
+type TokenRequest struct {
+	GrantType    string `form:"grant_type"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	Scope        string `form:"scope"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+}
+
+// TokenResponse representa a resposta do endpoint de token (RFC 6749, seção 5.1)
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	Scope       string `json:"scope"`
+}
+
+// IntrospectionResponse representa a resposta do endpoint de introspecção (RFC 7662, seção 2.2)
+type IntrospectionResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	Aud       string `json:"aud,omitempty"`
+	Iss       string `json:"iss,omitempty"`
+	Jti       string `json:"jti,omitempty"`
+}
+
+// OAuthClientInput representa os dados de cadastro de um cliente OAuth2
+type OAuthClientInput struct {
+	Name         string   `json:"name"`
+	RedirectURIs []string `json:"redirect_uris"`
+	Scopes       []string `json:"scopes"`
+	GrantTypes   []string `json:"grant_types"`
+	Confidential bool     `json:"confidential"` // Aplicação com back-end, que guarda o client_secret
+	Trusted      bool     `json:"trusted"`      // Dispensa a tela de consentimento
+}
+
+// oauthAuthorizationCode guarda os dados de um código de autorização até a troca pelo token
+type oauthAuthorizationCode struct {
+	clientID      string
+	userID        int
+	redirectURI   string
+	scopes        []string
+	codeChallenge string
+}
+
+// oauthError cria um erro do protocolo OAuth2.
+func oauthError(code, description string) *OAuthError {
+	return &OAuthError{Code: code, Description: description}
+}
+
+// AuthorizeInfo valida uma requisição de autorização e retorna os dados da tela de consentimento.
+//
+// Parâmetros:
+// - userID: O ID do usuário autenticado no front-end.
+// - req: Os parâmetros recebidos do cliente.
+//
+// Retorno:
+// - *ConsentInfo: O cliente, os escopos que serão concedidos e se o consentimento precisa ser solicitado.
+// - error: *OAuthError (com RedirectTo quando o erro deve ser devolvido ao cliente) ou erro interno.
+//
+// Detalhes:
+// - O PKCE (code_challenge com S256) é obrigatório para todos os clientes.
+// - Sem scope, são solicitados todos os escopos do cliente. Os escopos que o usuário não possui são descartados.
+func AuthorizeInfo(userID int, req AuthorizationRequest) (*ConsentInfo, error) {
+	info, _, err := validateAuthorizationRequest(userID, req)
+	return info, err
+}
+
+// Authorize registra a decisão do usuário na tela de consentimento e retorna o endereço de retorno ao cliente.
+//
+// Parâmetros:
+// - userID: O ID do usuário autenticado no front-end.
+// - req: Os parâmetros recebidos do cliente.
+// - approve: true se o usuário autorizou o acesso.
+// - ip: O IP de origem da requisição.
+//
+// Retorno:
+// - string: A redirect_uri com o code e o state, ou com error=access_denied se o usuário recusou.
+// - error: *OAuthError ou erro interno.
+func Authorize(userID int, req AuthorizationRequest, approve bool, ip string) (string, error) {
+	info, consented, err := validateAuthorizationRequest(userID, req)
+	if err != nil {
+		return "", err
+	}
+	if !approve {
+		return oauthRedirect(req.RedirectURI, map[string]string{"error": "access_denied", "state": req.State}), nil
+	}
+
+	if info.ConsentRequired {
+		if err := models.SaveOAuthConsent(userID, info.ClientID, unionScopes(consented, info.Scopes)); err != nil {
+			return "", err
+		}
+		_ = models.RecordAuditEvent(models.AuditEvent{
+			EventType: models.AuditOAuthConsentGranted,
+			UserID:    userID,
+			ActorID:   userID,
+			IP:        ip,
+			Details:   fmt.Sprintf("cliente=%s; escopos=%s", info.ClientID, strings.Join(info.Scopes, " ")),
+		})
+	}
+
+	code, err := auth_utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	oauthCodes.Set(auth_utils.HashToken(code), oauthAuthorizationCode{
+		clientID:      info.ClientID,
+		userID:        userID,
+		redirectURI:   req.RedirectURI,
+		scopes:        info.Scopes,
+		codeChallenge: req.CodeChallenge,
+	})
+
+	return oauthRedirect(req.RedirectURI, map[string]string{"code": code, "state": req.State}), nil
+}
+
+// validateAuthorizationRequest valida o cliente, a redirect_uri, o PKCE e os escopos de uma requisição de autorização.
+// Retorna também os escopos que o usuário já havia autorizado para o cliente.
+func validateAuthorizationRequest(userID int, req AuthorizationRequest) (*ConsentInfo, []string, error) {
+	client, err := activeOAuthClient(req.ClientID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if client == nil {
+		return nil, nil, oauthError("invalid_client", "Cliente não encontrado")
+	}
+	if req.RedirectURI == "" || !slices.Contains(client.RedirectURIs, req.RedirectURI) {
+		return nil, nil, oauthError("invalid_request", "redirect_uri não registrada para o cliente")
+	}
+
+	// A partir daqui, os erros são devolvidos ao cliente pela redirect_uri
+	fail := func(code, description string) error {
+		e := oauthError(code, description)
+		e.RedirectTo = oauthRedirect(req.RedirectURI, map[string]string{"error": code, "error_description": description, "state": req.State})
+		return e
+	}
+
+	if req.ResponseType != "code" {
+		return nil, nil, fail("unsupported_response_type", "Somente response_type=code é suportado")
+	}
+	if !slices.Contains(client.GrantTypes, GrantAuthorizationCode) {
+		return nil, nil, fail("unauthorized_client", "O cliente não pode usar o fluxo authorization_code")
+	}
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != "S256" {
+		return nil, nil, fail("invalid_request", "PKCE obrigatório: informe code_challenge com code_challenge_method=S256")
+	}
+
+	requested := strings.Fields(req.Scope)
+	if len(requested) == 0 {
+		requested = client.Scopes
+	}
+	for _, scope := range requested {
+		if !slices.Contains(client.Scopes, scope) {
+			return nil, nil, fail("invalid_scope", "Escopo não permitido para o cliente: "+scope)
+		}
+	}
+
+	permissions, err := GetUserPermissions(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	granted := filterScopes(requested, permissions)
+	if len(granted) == 0 {
+		return nil, nil, fail("access_denied", "O usuário não possui nenhuma das permissões solicitadas")
+	}
+
+	consented, err := models.GetOAuthConsent(userID, client.ClientID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info := &ConsentInfo{
+		ClientID:        client.ClientID,
+		ClientName:      client.Name,
+		Scopes:          granted,
+		ConsentRequired: !client.Trusted && len(unionScopes(consented, granted)) > len(consented),
+	}
+	return info, consented, nil
+}
+
+// ExchangeToken implementa o endpoint de token para os fluxos authorization_code (com PKCE) e client_credentials.
+//
+// Parâmetros:
+// - req: Os parâmetros recebidos, inclusive as credenciais do cliente (do corpo ou do cabeçalho Basic).
+// - ip: O IP de origem da requisição.
+//
+// Retorno:
+// - *TokenResponse: O token de acesso emitido.
+// - error: *OAuthError (invalid_client, invalid_grant, invalid_scope, unauthorized_client, unsupported_grant_type) ou erro interno.
+//
+// Detalhes:
+// - Os tokens são assinados por auth_utils, com a audiência "<JWT_AUDIENCE>:oauth", e valem por OAUTH_ACCESS_TOKEN_TTL.
+// - Não são emitidos refresh tokens: o cliente repete o fluxo, e a autorização já consentida dispensa a tela de consentimento.
+func ExchangeToken(req TokenRequest, ip string) (*TokenResponse, error) {
+	client, err := authenticateOAuthClient(req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(client.GrantTypes, req.GrantType) {
+		if req.GrantType != GrantAuthorizationCode && req.GrantType != GrantClientCredentials {
+			return nil, oauthError("unsupported_grant_type", "grant_type não suportado")
+		}
+		return nil, oauthError("unauthorized_client", "O cliente não pode usar o grant_type "+req.GrantType)
+	}
+
+	var subject auth_utils.TokenSubject
+	var scopes []string
+	switch req.GrantType {
+	case GrantAuthorizationCode:
+		code, ok := oauthCodes.Take(auth_utils.HashToken(req.Code))
+		if !ok || code.clientID != client.ClientID {
+			return nil, oauthError("invalid_grant", "Código de autorização inválido ou expirado")
+		}
+		if req.RedirectURI != code.redirectURI {
+			return nil, oauthError("invalid_grant", "redirect_uri diferente da usada na autorização")
+		}
+		if !verifyCodeChallenge(req.CodeVerifier, code.codeChallenge) {
+			logger.Warn("code_verifier inválido na troca do código do cliente %s (IP %s)", client.ClientID, ip)
+			return nil, oauthError("invalid_grant", "code_verifier inválido")
+		}
+
+		user, err := models.GetUserByID(code.userID)
+		if err != nil || !user.Active {
+			return nil, oauthError("invalid_grant", "Usuário inválido ou desativado")
+		}
+		subject = user.TokenSubject()
+		scopes = code.scopes
+
+	case GrantClientCredentials:
+		scopes = strings.Fields(req.Scope)
+		if len(scopes) == 0 {
+			scopes = client.Scopes
+		}
+		for _, scope := range scopes {
+			if !slices.Contains(client.Scopes, scope) {
+				return nil, oauthError("invalid_scope", "Escopo não permitido para o cliente: "+scope)
+			}
+		}
+	}
+
+	token, claims, err := auth_utils.GenerateOAuthAccessToken(client.ClientID, subject, scopes, oauthAccessTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Token OAuth2 emitido ao cliente %s (grant=%s, sub=%s, IP %s)", client.ClientID, req.GrantType, claims.Subject, ip)
+	return &TokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(oauthAccessTokenTTL.Seconds()),
+		Scope:       claims.Scope,
+	}, nil
+}
+
+// IntrospectToken implementa o endpoint de introspecção de tokens (RFC 7662).
+//
+// Parâmetros:
+// - clientID, clientSecret: As credenciais do cliente confidencial que consulta o token (ex: o back-end de uma aplicação).
+// - token: O token de acesso OAuth2 a consultar.
+//
+// Retorno:
+// - *IntrospectionResponse: {"active": false} para tokens inválidos, expirados, revogados ou de clientes revogados.
+// - error: *OAuthError (invalid_client) ou erro interno.
+//
+// Detalhes:
+//   - Nos tokens de usuário, o token deixa de ser ativo se o usuário for desativado ou encerrar todas as sessões,
+//     e o scope retornado exclui as permissões que o usuário perdeu desde a emissão.
+func IntrospectToken(clientID, clientSecret, token string) (*IntrospectionResponse, error) {
+	caller, err := authenticateOAuthClient(clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+	if !caller.Confidential() {
+		return nil, oauthError("invalid_client", "A introspecção exige um cliente confidencial")
+	}
+
+	inactive := &IntrospectionResponse{Active: false}
+	claims, err := auth_utils.ParseOAuthAccessToken(token)
+	if err != nil {
+		return inactive, nil
+	}
+
+	issuer, err := activeOAuthClient(claims.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	if issuer == nil {
+		return inactive, nil
+	}
+
+	scopes := claims.Scopes()
+	if userID := claims.UserID(); userID != 0 {
+		access := &auth_utils.Claims{ID: userID, TokenVersion: claims.TokenVersion}
+		access.Id = claims.Id
+		if CheckTokenRevocation(access) != nil {
+			return inactive, nil
+		}
+		user, err := models.GetUserByID(userID)
+		if err != nil || !user.Active {
+			return inactive, nil
+		}
+		permissions, err := GetUserPermissions(userID)
+		if err != nil {
+			return nil, err
+		}
+		scopes = filterScopes(scopes, permissions)
+	} else {
+		if models.IsTokenBlacklisted(claims.Id) {
+			return inactive, nil
+		}
+		scopes = filterScopes(scopes, scopeSet(issuer.Scopes))
+	}
+
+	return &IntrospectionResponse{
+		Active:    true,
+		Scope:     strings.Join(scopes, " "),
+		ClientID:  claims.ClientID,
+		Username:  claims.Username,
+		TokenType: "Bearer",
+		Exp:       claims.ExpiresAt,
+		Iat:       claims.IssuedAt,
+		Sub:       claims.Subject,
+		Aud:       claims.Audience,
+		Iss:       claims.Issuer,
+		Jti:       claims.Id,
+	}, nil
+}
+
+// CreateOAuthClient registra uma aplicação no servidor OAuth2.
+//
+// Parâmetros:
+// - input: Os dados do cliente.
+// - actorID: O ID do administrador que executou a ação.
+// - ip: O IP de origem da requisição.
+//
+// Retorno:
+// - string: O client_secret, exibido uma única vez (vazio nos clientes públicos).
+// - *models.OAuthClient: O cliente criado.
+// - error: ErrInvalidOAuthClientName, ErrInvalidRedirectURI, ErrInvalidGrantTypes, ErrInvalidScopes ou erro interno.
+//
+// Detalhes:
+// - Os escopos são permissões, e só é possível conceder ao cliente permissões que o administrador possui.
+// - O fluxo client_credentials exige um cliente confidencial.
+func CreateOAuthClient(input OAuthClientInput, actorID int, ip string) (string, *models.OAuthClient, error) {
+	name := strings.TrimSpace(input.Name)
+	if name == "" || utf8.RuneCountInString(name) > maxAPIKeyName {
+		return "", nil, ErrInvalidOAuthClientName
+	}
+
+	grantTypes := []string{}
+	for _, grant := range input.GrantTypes {
+		if grant != GrantAuthorizationCode && grant != GrantClientCredentials {
+			return "", nil, ErrInvalidGrantTypes
+		}
+		if !slices.Contains(grantTypes, grant) {
+			grantTypes = append(grantTypes, grant)
+		}
+	}
+	if len(grantTypes) == 0 || (slices.Contains(grantTypes, GrantClientCredentials) && !input.Confidential) {
+		return "", nil, ErrInvalidGrantTypes
+	}
+
+	for _, redirectURI := range input.RedirectURIs {
+		if !validRedirectURI(redirectURI) {
+			return "", nil, ErrInvalidRedirectURI
+		}
+	}
+	if slices.Contains(grantTypes, GrantAuthorizationCode) && len(input.RedirectURIs) == 0 {
+		return "", nil, ErrInvalidRedirectURI
+	}
+
+	scopes, err := validateScopes(actorID, input.Scopes)
+	if err != nil {
+		return "", nil, err
+	}
+
+	clientID, err := randomHex(16)
+	if err != nil {
+		return "", nil, err
+	}
+	client := models.OAuthClient{
+		ClientID:     clientID,
+		Name:         name,
+		RedirectURIs: input.RedirectURIs,
+		Scopes:       scopes,
+		GrantTypes:   grantTypes,
+		Trusted:      input.Trusted,
+		CreatedBy:    actorID,
+		CreatedAt:    time.Now(),
+	}
+
+	var secret string
+	if input.Confidential {
+		if secret, err = auth_utils.GenerateOpaqueToken(); err != nil {
+			return "", nil, err
+		}
+		client.SecretHash = auth_utils.HashToken(secret)
+	}
+
+	if client.ID, err = models.CreateOAuthClient(client); err != nil {
+		return "", nil, err
+	}
+
+	_ = models.RecordAuditEvent(models.AuditEvent{
+		EventType: models.AuditOAuthClientCreated,
+		ActorID:   actorID,
+		IP:        ip,
+		Details:   fmt.Sprintf("cliente=%s; nome=%s; grants=%s; escopos=%s", clientID, name, strings.Join(grantTypes, " "), strings.Join(scopes, " ")),
+	})
+	return secret, &client, nil
+}
+
+// ListOAuthClients lista os clientes OAuth2 ativos.
+//
+// Retorno:
+// - []models.OAuthClient: Os clientes (sem os segredos).
+// - error: Retorna erro se a consulta falhar.
+func ListOAuthClients() ([]models.OAuthClient, error) {
+	return models.ListOAuthClients()
+}
+
+// RevokeOAuthClient revoga um cliente OAuth2: os tokens já emitidos deixam de ser ativos na introspecção.
+//
+// Parâmetros:
+// - clientID: O client_id.
+// - actorID: O ID do administrador que executou a ação.
+// - ip: O IP de origem da requisição.
+//
+// Retorno:
+// - error: ErrOAuthClientNotFound se o cliente não existir ou já estiver revogado, ou erro interno.
+func RevokeOAuthClient(clientID string, actorID int, ip string) error {
+	revoked, err := models.RevokeOAuthClient(clientID)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrOAuthClientNotFound
+	}
+
+	_ = models.RecordAuditEvent(models.AuditEvent{
+		EventType: models.AuditOAuthClientRevoked,
+		ActorID:   actorID,
+		IP:        ip,
+		Details:   "cliente=" + clientID,
+	})
+	return nil
+}
+
+// ListOAuthConsents lista as aplicações autorizadas pelo usuário.
+//
+// Parâmetros:
+// - userID: O ID do usuário.
+//
+// Retorno:
+// - []models.OAuthConsent: Os consentimentos do usuário.
+// - error: Retorna erro se a consulta falhar.
+func ListOAuthConsents(userID int) ([]models.OAuthConsent, error) {
+	return models.ListOAuthConsents(userID)
+}
+
+// RevokeOAuthConsent remove a autorização dada pelo usuário a uma aplicação.
+// A tela de consentimento volta a ser exibida no próximo login pela aplicação.
+//
+// Parâmetros:
+// - userID: O ID do usuário.
+// - clientID: O client_id.
+// - ip: O IP de origem da requisição.
+//
+// Retorno:
+// - error: ErrOAuthConsentNotFound se não houver consentimento, ou erro interno.
+func RevokeOAuthConsent(userID int, clientID, ip string) error {
+	deleted, err := models.DeleteOAuthConsent(userID, clientID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrOAuthConsentNotFound
+	}
+
+	_ = models.RecordAuditEvent(models.AuditEvent{
+		EventType: models.AuditOAuthConsentRevoked,
+		UserID:    userID,
+		ActorID:   userID,
+		IP:        ip,
+		Details:   "cliente=" + clientID,
+	})
+	return nil
+}
+
+// activeOAuthClient busca um cliente não revogado, ou retorna nil.
+func activeOAuthClient(clientID string) (*models.OAuthClient, error) {
+	if clientID == "" {
+		return nil, nil
+	}
+	client, err := models.GetOAuthClient(clientID)
+	if err != nil || client == nil || client.RevokedAt != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// authenticateOAuthClient valida as credenciais do cliente. Clientes públicos são identificados apenas pelo client_id.
+func authenticateOAuthClient(clientID, clientSecret string) (*models.OAuthClient, error) {
+	client, err := activeOAuthClient(clientID)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, oauthError("invalid_client", "Cliente inválido")
+	}
+
+	if client.Confidential() {
+		if subtle.ConstantTimeCompare([]byte(auth_utils.HashToken(clientSecret)), []byte(client.SecretHash)) != 1 {
+			logger.Warn("Credenciais inválidas para o cliente OAuth2 %s", clientID)
+			return nil, oauthError("invalid_client", "Cliente inválido")
+		}
+	} else if clientSecret != "" {
+		return nil, oauthError("invalid_client", "Cliente público não possui client_secret")
+	}
+	return client, nil
+}
+
+// verifyCodeChallenge verifica o code_verifier contra o code_challenge S256 (RFC 7636, seção 4.6).
+func verifyCodeChallenge(verifier, challenge string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+// validRedirectURI aceita URLs absolutas sem fragmento, com HTTPS ou, em desenvolvimento, HTTP em localhost.
+func validRedirectURI(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || u.Fragment != "" || strings.ContainsAny(raw, " \t\n") {
+		return false
+	}
+	switch u.Scheme {
+	case "https":
+		return true
+	case "http":
+		host := u.Hostname()
+		return host == "localhost" || host == "127.0.0.1" || host == "::1"
+	}
+	return false
+}
+
+// oauthRedirect acrescenta os parâmetros (ignorando os vazios) à query da redirect_uri.
+func oauthRedirect(redirectURI string, params map[string]string) string {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return redirectURI
+	}
+	query := u.Query()
+	for key, value := range params {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// filterScopes mantém, na ordem original, os escopos presentes no conjunto de permissões.
+func filterScopes(scopes []string, granted map[string]bool) []string {
+	filtered := []string{}
+	for _, scope := range scopes {
+		if granted[scope] && !slices.Contains(filtered, scope) {
+			filtered = append(filtered, scope)
+		}
+	}
+	return filtered
+}
+
+// scopeSet converte uma lista de escopos em conjunto.
+func scopeSet(scopes []string) map[string]bool {
+	set := make(map[string]bool, len(scopes))
+	for _, scope := range scopes {
+		set[scope] = true
+	}
+	return set
+}
+
+// unionScopes retorna os escopos das duas listas, sem repetições.
+func unionScopes(a, b []string) []string {
+	union := slices.Clone(a)
+	for _, scope := range b {
+		if !slices.Contains(union, scope) {
+			union = append(union, scope)
+		}
+	}
+	return union
+}
+
+// OAuthClientCredentials extrai as credenciais do cliente do cabeçalho "Authorization: Basic" (RFC 6749, seção 2.3.1),
+// em que o client_id e o client_secret são codificados como application/x-www-form-urlencoded.
+//
+// Parâmetros:
+// - username, password: Os valores decodificados do cabeçalho Basic.
+//
+// Retorno:
+// - string, string: O client_id e o client_secret.
+func OAuthClientCredentials(username, password string) (string, string) {
+	if id, err := url.QueryUnescape(username); err == nil {
+		username = id
+	}
+	if secret, err := url.QueryUnescape(password); err == nil {
+		password = secret
+	}
+	return username, password
+}
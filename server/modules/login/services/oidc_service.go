@@ -0,0 +1,691 @@
+// pwd: /app/server/modules/login/services/oidc_service.go
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"api/logger"
+	"api/server/modules/login/auth_utils"
+	"api/server/modules/login/models"
+	"api/utils"
+	"api/utils/cache"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// Erros do login por OpenID Connect
+var (
+	ErrOIDCProviderNotFound = errors.New("provedor oidc não encontrado")
+	ErrOIDCUnavailable      = errors.New("provedor oidc indisponível")
+	ErrInvalidOIDCState     = errors.New("state oidc inválido ou expirado")
+	ErrOIDCRejected         = errors.New("autenticação oidc recusada")
+	ErrOIDCNoAccount        = errors.New("nenhuma conta vinculada à identidade externa")
+	ErrIdentityNotFound     = errors.New("identidade externa não encontrada")
+)
+
+// maxUsernameAttempts é o número de sufixos testados ao gerar o username de um usuário criado pelo OIDC
+const maxUsernameAttempts = 20
+
+var (
+	oidcStateTTL = utils.GetEnvDuration("OIDC_STATE_TTL", 10*time.Minute)
+
+	// Logins em andamento, indexados pelo state enviado ao provedor. Cada state só pode ser usado uma vez.
+	oidcStates = cache.New[string, oidcLoginState](oidcStateTTL)
+
+	oidcMu        sync.Mutex
+	oidcProviders map[string]*oidcProvider // nil até a primeira chamada a getOIDCProviders ou SetOIDCProviders
+
+	usernameInvalidChars = regexp.MustCompile(`[^a-z0-9._-]+`)
+)
+
+// OIDCRoleRule atribui um papel local aos usuários cujo claim contém o valor informado
+// (ex: claim "groups" com o valor "financeiro" → papel "finance").
+type OIDCRoleRule struct {
+	Claim string // Nome do claim; claims aninhados usam ponto (ex: "realm_access.roles" no Keycloak)
+	Value string
+	Role  string
+}
+
+// OIDCProviderConfig representa a configuração de um provedor OpenID Connect
+type OIDCProviderConfig struct {
+	Name         string // Identificador usado nas rotas (ex: "google")
+	DisplayName  string // Nome exibido na tela de login (padrão: Name)
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	AutoCreate   bool // Cria o usuário local no primeiro login (just-in-time)
+	LinkByEmail  bool // Vincula a identidade ao usuário local com o mesmo e-mail, se o provedor o tiver verificado (inclusive administradores)
+	SyncRoles    bool // Substitui os papéis do usuário a cada login pelos papéis das regras
+	RoleRules    []OIDCRoleRule
+	HTTPClient   *http.Client // Cliente HTTP usado com o provedor (padrão: timeout de 10s)
+}
+
+// OIDCProviderInfo representa um provedor na listagem pública
+type OIDCProviderInfo struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+}
+
+// oidcProvider guarda a configuração de um provedor e, após a descoberta, os seus endpoints e chaves
+type oidcProvider struct {
+	config OIDCProviderConfig
+
+	mu       sync.Mutex
+	oauth2   *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+// oidcLoginState guarda os dados de um login entre o redirecionamento ao provedor e o retorno
+type oidcLoginState struct {
+	provider string
+	nonce    string
+	verifier string // code_verifier do PKCE
+	device   string
+}
+
+// oidcClaims reúne os claims do ID token usados no vínculo com o usuário local
+type oidcClaims struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+	Raw               map[string]any
+}
+
+// SetOIDCProviders substitui os provedores lidos do ambiente.
+//
+// Permite usar outros provedores (ex: um provedor local em testes, com o próprio HTTPClient).
+//
+// Parâmetros:
+// - configs: As configurações dos provedores.
+func SetOIDCProviders(configs []OIDCProviderConfig) {
+	providers := make(map[string]*oidcProvider, len(configs))
+	for _, config := range configs {
+		providers[config.Name] = &oidcProvider{config: config}
+	}
+
+	oidcMu.Lock()
+	oidcProviders = providers
+	oidcMu.Unlock()
+}
+
+// getOIDCProviders retorna os provedores configurados, lidos do ambiente na primeira chamada.
+//
+// Variáveis:
+// - OIDC_PROVIDERS: Nomes dos provedores, separados por vírgula (ex: "google,keycloak").
+// - OIDC_<NOME>_ISSUER, OIDC_<NOME>_CLIENT_ID, OIDC_<NOME>_CLIENT_SECRET: Obrigatórios.
+// - OIDC_<NOME>_REDIRECT_URL: Página do front-end que recebe o retorno (padrão: APP_BASE_URL + "/oidc/<nome>/callback").
+// - OIDC_<NOME>_SCOPES, OIDC_<NOME>_DISPLAY_NAME, OIDC_<NOME>_AUTO_CREATE, OIDC_<NOME>_LINK_BY_EMAIL,
+// OIDC_<NOME>_SYNC_ROLES e OIDC_<NOME>_ROLE_RULES: Opcionais.
+func getOIDCProviders() map[string]*oidcProvider {
+	oidcMu.Lock()
+	defer oidcMu.Unlock()
+
+	if oidcProviders != nil {
+		return oidcProviders
+	}
+
+	oidcProviders = map[string]*oidcProvider{}
+	for _, name := range utils.GetEnvList("OIDC_PROVIDERS") {
+		config, ok := loadOIDCProviderConfig(strings.ToLower(name))
+		if ok {
+			oidcProviders[config.Name] = &oidcProvider{config: config}
+		}
+	}
+	return oidcProviders
+}
+
+// loadOIDCProviderConfig lê a configuração de um provedor das variáveis OIDC_<NOME>_*.
+func loadOIDCProviderConfig(name string) (OIDCProviderConfig, bool) {
+	prefix := "OIDC_" + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name)) + "_"
+
+	config := OIDCProviderConfig{
+		Name:         name,
+		DisplayName:  utils.GetEnv(prefix + "DISPLAY_NAME"),
+		Issuer:       utils.GetEnv(prefix + "ISSUER"),
+		ClientID:     utils.GetEnv(prefix + "CLIENT_ID"),
+		ClientSecret: utils.GetEnv(prefix + "CLIENT_SECRET"),
+		RedirectURL:  utils.GetEnv(prefix + "REDIRECT_URL"),
+		Scopes:       utils.GetEnvList(prefix + "SCOPES"),
+		AutoCreate:   utils.GetEnvBool(prefix+"AUTO_CREATE", false),
+		LinkByEmail:  utils.GetEnvBool(prefix+"LINK_BY_EMAIL", false),
+		SyncRoles:    utils.GetEnvBool(prefix+"SYNC_ROLES", false),
+	}
+	if config.RedirectURL == "" {
+		if baseURL := strings.TrimRight(utils.GetEnv("APP_BASE_URL"), "/"); baseURL != "" {
+			config.RedirectURL = baseURL + "/oidc/" + name + "/callback"
+		}
+	}
+	if config.Issuer == "" || config.ClientID == "" || config.ClientSecret == "" || config.RedirectURL == "" {
+		logger.Error("Provedor OIDC %q ignorado: configure %sISSUER, %sCLIENT_ID, %sCLIENT_SECRET e %sREDIRECT_URL (ou APP_BASE_URL)",
+			name, prefix, prefix, prefix, prefix)
+		return config, false
+	}
+
+	for _, item := range utils.GetEnvList(prefix + "ROLE_RULES") {
+		rule, ok := parseOIDCRoleRule(item)
+		if !ok {
+			logger.Warn("Regra inválida em %sROLE_RULES: %q (formato esperado: claim=valor:papel)", prefix, item)
+			continue
+		}
+		config.RoleRules = append(config.RoleRules, rule)
+	}
+	return config, true
+}
+
+// parseOIDCRoleRule lê uma regra no formato "claim=valor:papel". O papel fica após o último ":".
+func parseOIDCRoleRule(item string) (OIDCRoleRule, bool) {
+	claim, rest, ok := strings.Cut(item, "=")
+	i := strings.LastIndex(rest, ":")
+	if !ok || i < 0 {
+		return OIDCRoleRule{}, false
+	}
+
+	rule := OIDCRoleRule{
+		Claim: strings.TrimSpace(claim),
+		Value: strings.TrimSpace(rest[:i]),
+		Role:  strings.TrimSpace(rest[i+1:]),
+	}
+	return rule, rule.Claim != "" && rule.Value != "" && rule.Role != ""
+}
+
+// client retorna o cliente HTTP usado com o provedor.
+func (p *oidcProvider) client() *http.Client {
+	if p.config.HTTPClient != nil {
+		return p.config.HTTPClient
+	}
+	return &http.Client{Timeout: 10 * time.Second}
+}
+
+// context retorna um contexto que faz as bibliotecas OIDC e OAuth2 usarem o cliente HTTP do provedor.
+func (p *oidcProvider) context() context.Context {
+	client := p.client()
+	return context.WithValue(oidc.ClientContext(context.Background(), client), oauth2.HTTPClient, client)
+}
+
+// discover consulta o documento de descoberta do provedor na primeira chamada.
+// Em caso de falha, uma nova tentativa é feita no próximo login.
+func (p *oidcProvider) discover() (*oauth2.Config, *oidc.IDTokenVerifier, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.oauth2 != nil {
+		return p.oauth2, p.verifier, nil
+	}
+
+	provider, err := oidc.NewProvider(p.context(), p.config.Issuer)
+	if err != nil {
+		logger.Error("Erro na descoberta do provedor OIDC %q: %v", p.config.Name, err)
+		return nil, nil, ErrOIDCUnavailable
+	}
+
+	scopes := p.config.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID, "email", "profile"}
+	}
+	p.oauth2 = &oauth2.Config{
+		ClientID:     p.config.ClientID,
+		ClientSecret: p.config.ClientSecret,
+		RedirectURL:  p.config.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       scopes,
+	}
+	p.verifier = provider.Verifier(&oidc.Config{ClientID: p.config.ClientID})
+	return p.oauth2, p.verifier, nil
+}
+
+// ListOIDCProviders lista os provedores configurados, para a tela de login.
+//
+// Retorno:
+// - []OIDCProviderInfo: Os provedores, em ordem alfabética.
+func ListOIDCProviders() []OIDCProviderInfo {
+	providers := []OIDCProviderInfo{}
+	for _, p := range getOIDCProviders() {
+		info := OIDCProviderInfo{Name: p.config.Name, DisplayName: p.config.DisplayName}
+		if info.DisplayName == "" {
+			info.DisplayName = info.Name
+		}
+		providers = append(providers, info)
+	}
+	sort.Slice(providers, func(i, j int) bool { return providers[i].Name < providers[j].Name })
+	return providers
+}
+
+// BeginOIDCLogin inicia um login pelo fluxo authorization code com PKCE.
+//
+// Parâmetros:
+// - providerName: O nome do provedor.
+// - device: O nome do dispositivo, repassado à sessão criada no retorno.
+//
+// Retorno:
+// - string: A URL de autorização do provedor, para onde o navegador deve ser redirecionado.
+// - string: O state, que o provedor devolve à REDIRECT_URL junto com o code.
+// - error: ErrOIDCProviderNotFound, ErrOIDCUnavailable ou erro interno.
+func BeginOIDCLogin(providerName, device string) (string, string, error) {
+	provider, ok := getOIDCProviders()[providerName]
+	if !ok {
+		return "", "", ErrOIDCProviderNotFound
+	}
+	config, _, err := provider.discover()
+	if err != nil {
+		return "", "", err
+	}
+
+	state := auth_utils.NewTokenID()
+	login := oidcLoginState{
+		provider: providerName,
+		nonce:    auth_utils.NewTokenID(),
+		verifier: oauth2.GenerateVerifier(),
+		device:   device,
+	}
+	oidcStates.Set(state, login)
+
+	authURL := config.AuthCodeURL(state, oidc.Nonce(login.nonce), oauth2.S256ChallengeOption(login.verifier))
+	return authURL, state, nil
+}
+
+// FinishOIDCLogin troca o code pelos tokens do provedor, valida o ID token e retorna o usuário local vinculado.
+//
+// Parâmetros:
+// - providerName: O nome do provedor.
+// - state: O state devolvido pelo provedor.
+// - code: O code devolvido pelo provedor.
+// - ip: O IP de origem da requisição.
+//
+// Retorno:
+// - *models.User: O usuário local autenticado.
+// - string: O nome do dispositivo informado em BeginOIDCLogin.
+// - error: ErrInvalidOIDCState, ErrOIDCRejected, ErrOIDCNoAccount, ErrEmailNotVerified ou erro interno.
+//
+// Detalhes:
+//   - A identidade é localizada pelo par provedor + "sub". Sem vínculo, ela é vinculada ao usuário com o mesmo e-mail
+//     (se LINK_BY_EMAIL e o provedor tiver verificado o e-mail) ou, com AUTO_CREATE, a um novo usuário.
+//   - Os papéis das regras (ROLE_RULES) são atribuídos ao novo usuário e, com SYNC_ROLES, a cada login
+//     (sem regra atendida, o usuário fica só com o papel padrão).
+func FinishOIDCLogin(providerName, state, code, ip string) (*models.User, string, error) {
+	config, claims, device, err := completeOIDCLogin(providerName, state, code, ip)
+	if err != nil {
+		return nil, "", err
+	}
+
+	user, identity, err := resolveOIDCUser(config, claims, ip)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if !user.Active {
+		logger.Warn("Login OIDC recusado para o usuário desativado %s", user.Username)
+		return nil, "", ErrOIDCRejected
+	}
+
+	if roles := syncedOIDCRoles(config, claims.Raw); roles != nil && !sameRoles(roles, user.Roles) {
+		if err := AssignRoles(user.ID, roles, 0, ip); err != nil {
+			logger.Error("Erro ao sincronizar os papéis do usuário %s pelo provedor %q: %v", user.Username, providerName, err)
+		} else {
+			user.Roles = roles
+		}
+	}
+
+	// O provedor confirmou o mesmo e-mail do cadastro local
+	if claims.EmailVerified && !user.EmailVerified() && strings.EqualFold(claims.Email, user.Email) {
+		if _, err := models.MarkEmailVerified(user.ID, user.Email); err == nil {
+			now := time.Now()
+			user.EmailVerifiedAt = &now
+		}
+	}
+	if err := CheckEmailVerifiedForLogin(*user); err != nil {
+		return nil, "", err
+	}
+
+	if err := models.TouchUserIdentity(identity.ID, claims.Email); err != nil {
+		logger.Error("Erro ao registrar o login pela identidade externa ID=%d: %v", identity.ID, err)
+	}
+	RegisterLoginSuccess(user.Username)
+	return user, device, nil
+}
+
+// completeOIDCLogin consome o state do login e troca o code pelos claims validados do ID token.
+//
+// Retorno:
+// - OIDCProviderConfig: A configuração do provedor.
+// - *oidcClaims: Os claims do ID token.
+// - string: O nome do dispositivo informado em BeginOIDCLogin.
+// - error: ErrOIDCProviderNotFound, ErrInvalidOIDCState ou ErrOIDCRejected.
+func completeOIDCLogin(providerName, state, code, ip string) (OIDCProviderConfig, *oidcClaims, string, error) {
+	provider, ok := getOIDCProviders()[providerName]
+	if !ok {
+		return OIDCProviderConfig{}, nil, "", ErrOIDCProviderNotFound
+	}
+
+	login, ok := oidcStates.Take(state)
+	if !ok || login.provider != providerName {
+		return OIDCProviderConfig{}, nil, "", ErrInvalidOIDCState
+	}
+
+	claims, err := provider.exchange(code, login)
+	if err != nil {
+		logger.Warn("Login OIDC recusado pelo provedor %q (IP %s): %v", providerName, ip, err)
+		return OIDCProviderConfig{}, nil, "", ErrOIDCRejected
+	}
+	return provider.config, claims, login.device, nil
+}
+
+// exchange troca o code pelos tokens (com o code_verifier do PKCE) e valida o ID token e o nonce.
+func (p *oidcProvider) exchange(code string, login oidcLoginState) (*oidcClaims, error) {
+	config, verifier, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(p.context(), 15*time.Second)
+	defer cancel()
+
+	token, err := config.Exchange(ctx, code, oauth2.VerifierOption(login.verifier))
+	if err != nil {
+		return nil, fmt.Errorf("troca do code: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, errors.New("resposta sem id_token")
+	}
+
+	idToken, err := verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("id_token inválido: %w", err)
+	}
+	if idToken.Nonce != login.nonce {
+		return nil, errors.New("nonce inválido")
+	}
+
+	claims := &oidcClaims{Subject: idToken.Subject}
+	if err := idToken.Claims(&claims.Raw); err != nil {
+		return nil, fmt.Errorf("claims inválidos: %w", err)
+	}
+	claims.Email, _ = claims.Raw["email"].(string)
+	claims.EmailVerified = claimIsTrue(claims.Raw["email_verified"])
+	claims.Name, _ = claims.Raw["name"].(string)
+	claims.PreferredUsername, _ = claims.Raw["preferred_username"].(string)
+	return claims, nil
+}
+
+// resolveOIDCUser localiza o usuário vinculado à identidade externa, vinculando-a ou criando o usuário se configurado.
+func resolveOIDCUser(config OIDCProviderConfig, claims *oidcClaims, ip string) (*models.User, *models.UserIdentity, error) {
+	identity, err := models.GetUserIdentity(config.Name, claims.Subject)
+	if err != nil {
+		return nil, nil, err
+	}
+	if identity != nil {
+		user, err := models.GetUserByID(identity.UserID)
+		if err != nil {
+			return nil, nil, err
+		}
+		return user, identity, nil
+	}
+
+	user, err := matchOIDCAccount(config, claims, models.GetUserByEmail)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil {
+		if user, err = createOIDCUser(config, claims, ip); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	identity = &models.UserIdentity{UserID: user.ID, Provider: config.Name, Subject: claims.Subject, Email: claims.Email}
+	if identity.ID, err = models.CreateUserIdentity(*identity); err != nil {
+		return nil, nil, err
+	}
+
+	_ = models.RecordAuditEvent(models.AuditEvent{
+		EventType: models.AuditIdentityLinked,
+		UserID:    user.ID,
+		Username:  user.Username,
+		IP:        ip,
+		Details:   fmt.Sprintf("provedor=%s; sub=%s", config.Name, claims.Subject),
+	})
+	return user, identity, nil
+}
+
+// matchOIDCAccount escolhe a conta local de uma identidade externa ainda sem vínculo.
+//
+// Parâmetros:
+// - config: A configuração do provedor.
+// - claims: Os claims do ID token.
+// - findByEmail: Busca o usuário local pelo e-mail (models.GetUserByEmail).
+//
+// Retorno:
+// - *models.User: O usuário com o mesmo e-mail, a vincular, ou nil se um novo usuário deve ser criado (AUTO_CREATE).
+// - error: ErrOIDCNoAccount se a identidade não puder ser vinculada nem criada, ou erro da busca.
+func matchOIDCAccount(config OIDCProviderConfig, claims *oidcClaims, findByEmail func(email string) (*models.User, error)) (*models.User, error) {
+	// Sem e-mail verificado pelo provedor não há como vincular nem criar a conta com segurança
+	if claims.Email == "" || !claims.EmailVerified {
+		logger.Warn("Identidade externa %q sem vínculo e sem e-mail verificado (provedor %q)", claims.Subject, config.Name)
+		return nil, ErrOIDCNoAccount
+	}
+
+	user, err := findByEmail(claims.Email)
+	switch {
+	case err == nil:
+		if !config.LinkByEmail {
+			logger.Warn("Identidade externa do provedor %q não vinculada: o e-mail %s já pertence a um usuário local", config.Name, claims.Email)
+			return nil, ErrOIDCNoAccount
+		}
+		return user, nil
+	case errors.Is(err, models.ErrUserNotFound):
+		if !config.AutoCreate {
+			logger.Warn("Identidade externa do provedor %q sem conta local (%s)", config.Name, claims.Email)
+			return nil, ErrOIDCNoAccount
+		}
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
+// createOIDCUser cria o usuário local de uma identidade externa (just-in-time), com o e-mail já verificado.
+// A senha é aleatória: o usuário entra pelo provedor ou define uma senha pela recuperação de senha.
+func createOIDCUser(config OIDCProviderConfig, claims *oidcClaims, ip string) (*models.User, error) {
+	username, err := availableUsername(claims)
+	if err != nil {
+		return nil, err
+	}
+	password, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+
+	user := models.User{
+		Name:     strings.TrimSpace(claims.Name),
+		Username: username,
+		Email:    claims.Email,
+		Password: password,
+		Roles:    mapOIDCRoles(config.RoleRules, claims.Raw),
+	}
+	if user.Name == "" {
+		user.Name = username
+	}
+	if user.ID, err = models.CreateNewUser(user); err != nil {
+		return nil, err
+	}
+	if _, err := models.MarkEmailVerified(user.ID, user.Email); err != nil {
+		logger.Error("Erro ao marcar o e-mail do usuário ID=%d como verificado: %v", user.ID, err)
+	}
+
+	_ = models.RecordAuditEvent(models.AuditEvent{
+		EventType: models.AuditUserCreated,
+		UserID:    user.ID,
+		Username:  user.Username,
+		IP:        ip,
+		Details:   fmt.Sprintf("oidc=%s; papeis=%s", config.Name, strings.Join(user.Roles, ",")),
+	})
+	logger.Info("Usuário %s criado pelo login OIDC do provedor %q", user.Username, config.Name)
+
+	return models.GetUserByID(user.ID)
+}
+
+// availableUsername gera um username livre a partir do preferred_username ou do e-mail (ex: "maria", "maria2").
+func availableUsername(claims *oidcClaims) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base, _, _ = strings.Cut(claims.Email, "@")
+	}
+	base = strings.Trim(usernameInvalidChars.ReplaceAllString(strings.ToLower(base), ""), "._-")
+	if base == "" {
+		base = "user"
+	}
+	if len(base) > 50 {
+		base = base[:50]
+	}
+
+	for i := 1; i <= maxUsernameAttempts; i++ {
+		candidate := base
+		if i > 1 {
+			candidate = fmt.Sprintf("%s%d", base, i)
+		}
+		if models.CheckUserExists(candidate) == nil {
+			return candidate, nil
+		}
+	}
+	return "", errors.New("não foi possível gerar um username")
+}
+
+// mapOIDCRoles retorna os papéis das regras atendidas pelos claims, sem repetições.
+func mapOIDCRoles(rules []OIDCRoleRule, claims map[string]any) []string {
+	var roles []string
+	seen := map[string]bool{}
+	for _, rule := range rules {
+		if !seen[rule.Role] && claimContains(lookupClaim(claims, rule.Claim), rule.Value) {
+			seen[rule.Role] = true
+			roles = append(roles, rule.Role)
+		}
+	}
+	return roles
+}
+
+// syncedOIDCRoles retorna os papéis que substituem os do usuário no login, ou nil se o provedor não sincroniza papéis.
+// Sem regra atendida (ex: o usuário saiu dos grupos mapeados), retorna só o papel padrão.
+func syncedOIDCRoles(config OIDCProviderConfig, claims map[string]any) []string {
+	if !config.SyncRoles || len(config.RoleRules) == 0 {
+		return nil
+	}
+	roles := mapOIDCRoles(config.RoleRules, claims)
+	if len(roles) == 0 {
+		roles = []string{models.DefaultRole}
+	}
+	return roles
+}
+
+// lookupClaim busca um claim pelo nome; nomes com ponto percorrem objetos aninhados (ex: "realm_access.roles").
+func lookupClaim(claims map[string]any, name string) any {
+	if value, ok := claims[name]; ok {
+		return value
+	}
+
+	var current any = claims
+	for _, part := range strings.Split(name, ".") {
+		object, ok := current.(map[string]any)
+		if !ok {
+			return nil
+		}
+		current = object[part]
+	}
+	return current
+}
+
+// claimContains verifica se o claim é igual ao valor ou, em listas, se contém o valor.
+func claimContains(claim any, value string) bool {
+	switch v := claim.(type) {
+	case []any:
+		for _, item := range v {
+			if claimContains(item, value) {
+				return true
+			}
+		}
+		return false
+	case nil:
+		return false
+	default:
+		return fmt.Sprint(v) == value
+	}
+}
+
+// claimIsTrue interpreta claims booleanos, que alguns provedores enviam como texto ("true").
+func claimIsTrue(claim any) bool {
+	switch v := claim.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return false
+}
+
+// sameRoles verifica se as duas listas têm os mesmos papéis, em qualquer ordem.
+func sameRoles(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	set := make(map[string]bool, len(a))
+	for _, role := range a {
+		set[role] = true
+	}
+	for _, role := range b {
+		if !set[role] {
+			return false
+		}
+	}
+	return true
+}
+
+// ListIdentities lista as identidades externas vinculadas ao usuário.
+//
+// Parâmetros:
+// - userID: O ID do usuário.
+//
+// Retorno:
+// - []models.UserIdentity: As identidades do usuário.
+// - error: Retorna erro se a consulta falhar.
+func ListIdentities(userID int) ([]models.UserIdentity, error) {
+	return models.ListUserIdentities(userID)
+}
+
+// UnlinkIdentity desvincula uma identidade externa do usuário e registra a remoção na auditoria.
+//
+// Parâmetros:
+// - userID: O ID do usuário.
+// - identityID: O ID da identidade.
+// - ip: O IP de origem da requisição.
+//
+// Retorno:
+// - error: ErrIdentityNotFound se a identidade não existir ou pertencer a outro usuário, ou erro interno.
+func UnlinkIdentity(userID int, identityID int64, ip string) error {
+	identity, err := models.DeleteUserIdentity(userID, identityID)
+	if err != nil {
+		return err
+	}
+	if identity == nil {
+		return ErrIdentityNotFound
+	}
+
+	_ = models.RecordAuditEvent(models.AuditEvent{
+		EventType: models.AuditIdentityUnlinked,
+		UserID:    userID,
+		ActorID:   userID,
+		IP:        ip,
+		Details:   fmt.Sprintf("provedor=%s; sub=%s", identity.Provider, identity.Subject),
+	})
+	return nil
+}
@@ -0,0 +1,491 @@
+// pwd: /app/server/modules/login/services/oidc_service_test.go
+
+package services
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"api/server/modules/login/models"
+)
+
+const (
+	testOIDCClientID    = "api"
+	testOIDCSecret      = "segredo"
+	testOIDCRedirectURL = "https://app.example.com/oidc/mock/callback"
+)
+
+// mockOIDCProvider é um provedor OpenID Connect local, com descoberta, JWKS e endpoint de token.
+type mockOIDCProvider struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu        sync.Mutex
+	codes     map[string]mockOIDCCode
+	verifiers []string // code_verifier recebidos no endpoint de token
+}
+
+// mockOIDCCode é um code emitido pela autorização, com o code_challenge do PKCE e os claims do ID token.
+type mockOIDCCode struct {
+	challenge string
+	claims    map[string]any
+}
+
+func newMockOIDCProvider(t *testing.T) *mockOIDCProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("erro ao gerar a chave: %v", err)
+	}
+	m := &mockOIDCProvider{t: t, key: key, codes: map[string]mockOIDCCode{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", m.discovery)
+	mux.HandleFunc("/jwks", m.jwks)
+	mux.HandleFunc("/token", m.token)
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+func (m *mockOIDCProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                m.server.URL,
+		"authorization_endpoint":                m.server.URL + "/authorize",
+		"token_endpoint":                        m.server.URL + "/token",
+		"jwks_uri":                              m.server.URL + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (m *mockOIDCProvider) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]any{{
+			"kty": "RSA",
+			"kid": "mock",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(m.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+		}},
+	})
+}
+
+// token troca o code pelo ID token, exigindo o cliente e o code_verifier que corresponde ao code_challenge.
+func (m *mockOIDCProvider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid_request"})
+		return
+	}
+	clientID, secret, ok := r.BasicAuth()
+	if !ok {
+		clientID, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != testOIDCClientID || secret != testOIDCSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]any{"error": "invalid_client"})
+		return
+	}
+	if r.PostForm.Get("redirect_uri") != testOIDCRedirectURL {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid_grant"})
+		return
+	}
+
+	verifier := r.PostForm.Get("code_verifier")
+	m.mu.Lock()
+	m.verifiers = append(m.verifiers, verifier)
+	code, ok := m.codes[r.PostForm.Get("code")]
+	delete(m.codes, r.PostForm.Get("code"))
+	m.mu.Unlock()
+
+	if !ok || pkceChallenge(verifier) != code.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid_grant"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     m.sign(code.claims),
+	})
+}
+
+// authorize simula a autorização no provedor e retorna o code emitido.
+// O nonce da autorização é incluído no ID token, a menos que os claims já tragam um.
+func (m *mockOIDCProvider) authorize(authURL string, claims map[string]any) string {
+	m.t.Helper()
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		m.t.Fatalf("URL de autorização inválida: %v", err)
+	}
+	query := u.Query()
+	if u.Path != "/authorize" || query.Get("response_type") != "code" || query.Get("client_id") != testOIDCClientID ||
+		query.Get("redirect_uri") != testOIDCRedirectURL {
+		m.t.Fatalf("URL de autorização inesperada: %s", authURL)
+	}
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		m.t.Fatalf("a autorização deve usar PKCE com S256: %s", authURL)
+	}
+	if query.Get("nonce") == "" || query.Get("state") == "" {
+		m.t.Fatalf("a autorização deve enviar o nonce e o state: %s", authURL)
+	}
+
+	idClaims := map[string]any{"nonce": query.Get("nonce")}
+	for k, v := range claims {
+		idClaims[k] = v
+	}
+
+	code := newTestCode(m.t)
+	m.mu.Lock()
+	m.codes[code] = mockOIDCCode{challenge: query.Get("code_challenge"), claims: idClaims}
+	m.mu.Unlock()
+	return code
+}
+
+// sign emite um ID token RS256 do provedor para o cliente de teste.
+func (m *mockOIDCProvider) sign(claims map[string]any) string {
+	now := time.Now()
+	payload := map[string]any{
+		"iss": m.server.URL,
+		"aud": testOIDCClientID,
+		"iat": now.Unix(),
+		"exp": now.Add(5 * time.Minute).Unix(),
+	}
+	for k, v := range claims {
+		payload[k] = v
+	}
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "mock", "typ": "JWT"})
+	body, _ := json.Marshal(payload)
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(body)
+
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, m.key, crypto.SHA256, digest[:])
+	if err != nil {
+		m.t.Fatalf("erro ao assinar o ID token: %v", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func newTestCode(t *testing.T) string {
+	t.Helper()
+
+	token, err := randomHex(16)
+	if err != nil {
+		t.Fatalf("erro ao gerar o code: %v", err)
+	}
+	return token
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// setupMockOIDC configura o provedor "mock" apontando para o provedor local.
+func setupMockOIDC(t *testing.T) *mockOIDCProvider {
+	t.Helper()
+
+	mock := newMockOIDCProvider(t)
+	SetOIDCProviders([]OIDCProviderConfig{{
+		Name:         "mock",
+		Issuer:       mock.server.URL,
+		ClientID:     testOIDCClientID,
+		ClientSecret: testOIDCSecret,
+		RedirectURL:  testOIDCRedirectURL,
+		AutoCreate:   true,
+		SyncRoles:    true,
+		RoleRules: []OIDCRoleRule{
+			{Claim: "groups", Value: "financeiro", Role: "finance"},
+			{Claim: "realm_access.roles", Value: "admin", Role: "admin"},
+			{Claim: "groups", Value: "rh", Role: "hr"},
+			{Claim: "groups", Value: "ti", Role: "finance"},
+		},
+		HTTPClient: mock.server.Client(),
+	}})
+	t.Cleanup(func() {
+		oidcMu.Lock()
+		oidcProviders = nil
+		oidcMu.Unlock()
+	})
+	return mock
+}
+
+// loginOIDC executa o login no provedor local com os claims informados e retorna o resultado do retorno.
+func loginOIDC(t *testing.T, mock *mockOIDCProvider, claims map[string]any) (OIDCProviderConfig, *oidcClaims, error) {
+	t.Helper()
+
+	authURL, state, err := BeginOIDCLogin("mock", "Notebook")
+	if err != nil {
+		t.Fatalf("BeginOIDCLogin() = %v", err)
+	}
+	code := mock.authorize(authURL, claims)
+
+	config, idClaims, device, err := completeOIDCLogin("mock", state, code, "127.0.0.1")
+	if err == nil && device != "Notebook" {
+		t.Errorf("dispositivo = %q, esperado %q", device, "Notebook")
+	}
+	return config, idClaims, err
+}
+
+func verifiedClaims(subject, email string) map[string]any {
+	return map[string]any{"sub": subject, "email": email, "email_verified": true}
+}
+
+func TestOIDCLogin(t *testing.T) {
+	mock := setupMockOIDC(t)
+
+	claims := verifiedClaims("u-1", "maria@example.com")
+	claims["name"] = "Maria"
+	claims["preferred_username"] = "maria"
+	claims["groups"] = []string{"financeiro", "ti"}
+	claims["realm_access"] = map[string]any{"roles": []string{"admin", "offline_access"}}
+
+	config, idClaims, err := loginOIDC(t, mock, claims)
+	if err != nil {
+		t.Fatalf("login = %v", err)
+	}
+	if idClaims.Subject != "u-1" || idClaims.Email != "maria@example.com" || !idClaims.EmailVerified ||
+		idClaims.Name != "Maria" || idClaims.PreferredUsername != "maria" {
+		t.Errorf("claims = %+v", idClaims)
+	}
+
+	// Regras de papéis: claims simples e aninhados, sem repetir o papel
+	if roles := mapOIDCRoles(config.RoleRules, idClaims.Raw); !reflect.DeepEqual(roles, []string{"finance", "admin"}) {
+		t.Errorf("papéis = %v, esperado [finance admin]", roles)
+	}
+
+	// O code_verifier do PKCE foi enviado na troca do code
+	if len(mock.verifiers) != 1 || mock.verifiers[0] == "" {
+		t.Errorf("code_verifier recebidos = %q", mock.verifiers)
+	}
+
+	// Com SYNC_ROLES, os papéis das regras substituem os do usuário a cada login
+	if roles := syncedOIDCRoles(config, idClaims.Raw); !reflect.DeepEqual(roles, []string{"finance", "admin"}) {
+		t.Errorf("papéis sincronizados = %v, esperado [finance admin]", roles)
+	}
+
+	// O usuário saiu de todos os grupos mapeados: volta ao papel padrão
+	config, idClaims, err = loginOIDC(t, mock, verifiedClaims("u-1", "maria@example.com"))
+	if err != nil {
+		t.Fatalf("segundo login = %v", err)
+	}
+	if roles := syncedOIDCRoles(config, idClaims.Raw); !reflect.DeepEqual(roles, []string{models.DefaultRole}) {
+		t.Errorf("papéis sem claims mapeados = %v, esperado [%s]", roles, models.DefaultRole)
+	}
+
+	// Sem SYNC_ROLES, os papéis do usuário não são alterados
+	config.SyncRoles = false
+	if roles := syncedOIDCRoles(config, idClaims.Raw); roles != nil {
+		t.Errorf("papéis sem SYNC_ROLES = %v, esperado nil", roles)
+	}
+}
+
+func TestOIDCLoginStateReuse(t *testing.T) {
+	mock := setupMockOIDC(t)
+
+	authURL, state, err := BeginOIDCLogin("mock", "")
+	if err != nil {
+		t.Fatalf("BeginOIDCLogin() = %v", err)
+	}
+	code := mock.authorize(authURL, verifiedClaims("u-1", "maria@example.com"))
+
+	if _, _, _, err := completeOIDCLogin("mock", state, code, ""); err != nil {
+		t.Fatalf("login = %v", err)
+	}
+	if _, _, _, err := completeOIDCLogin("mock", state, code, ""); !errors.Is(err, ErrInvalidOIDCState) {
+		t.Fatalf("repetição do state = %v, esperado %v", err, ErrInvalidOIDCState)
+	}
+	if _, _, _, err := completeOIDCLogin("mock", "desconhecido", code, ""); !errors.Is(err, ErrInvalidOIDCState) {
+		t.Fatalf("state desconhecido = %v, esperado %v", err, ErrInvalidOIDCState)
+	}
+	if _, _, _, err := completeOIDCLogin("outro", state, code, ""); !errors.Is(err, ErrOIDCProviderNotFound) {
+		t.Fatalf("provedor desconhecido = %v, esperado %v", err, ErrOIDCProviderNotFound)
+	}
+}
+
+func TestOIDCLoginRejected(t *testing.T) {
+	mock := setupMockOIDC(t)
+
+	t.Run("nonce diferente", func(t *testing.T) {
+		claims := verifiedClaims("u-1", "maria@example.com")
+		claims["nonce"] = "outro-login"
+
+		if _, _, err := loginOIDC(t, mock, claims); !errors.Is(err, ErrOIDCRejected) {
+			t.Fatalf("login = %v, esperado %v", err, ErrOIDCRejected)
+		}
+	})
+
+	t.Run("ID token de outro cliente", func(t *testing.T) {
+		claims := verifiedClaims("u-1", "maria@example.com")
+		claims["aud"] = "outro-cliente"
+
+		if _, _, err := loginOIDC(t, mock, claims); !errors.Is(err, ErrOIDCRejected) {
+			t.Fatalf("login = %v, esperado %v", err, ErrOIDCRejected)
+		}
+	})
+
+	t.Run("code emitido para outro login (PKCE)", func(t *testing.T) {
+		victimURL, _, err := BeginOIDCLogin("mock", "")
+		if err != nil {
+			t.Fatalf("BeginOIDCLogin() = %v", err)
+		}
+		code := mock.authorize(victimURL, verifiedClaims("u-1", "maria@example.com"))
+
+		// O code é injetado no retorno de outro login, cujo code_verifier não corresponde ao code_challenge
+		_, state, err := BeginOIDCLogin("mock", "")
+		if err != nil {
+			t.Fatalf("BeginOIDCLogin() = %v", err)
+		}
+		if _, _, _, err := completeOIDCLogin("mock", state, code, ""); !errors.Is(err, ErrOIDCRejected) {
+			t.Fatalf("login = %v, esperado %v", err, ErrOIDCRejected)
+		}
+	})
+
+	t.Run("code desconhecido", func(t *testing.T) {
+		_, state, err := BeginOIDCLogin("mock", "")
+		if err != nil {
+			t.Fatalf("BeginOIDCLogin() = %v", err)
+		}
+		if _, _, _, err := completeOIDCLogin("mock", state, "inexistente", ""); !errors.Is(err, ErrOIDCRejected) {
+			t.Fatalf("login = %v, esperado %v", err, ErrOIDCRejected)
+		}
+	})
+}
+
+func TestOIDCAccountMatching(t *testing.T) {
+	mock := setupMockOIDC(t)
+	admin := &models.User{ID: 1, Username: "admin", Email: "admin@example.com", Roles: []string{"admin"}}
+
+	findByEmail := func(lookups *int) func(string) (*models.User, error) {
+		return func(email string) (*models.User, error) {
+			*lookups++
+			if email == admin.Email {
+				return admin, nil
+			}
+			return nil, models.ErrUserNotFound
+		}
+	}
+
+	tests := []struct {
+		name        string
+		claims      map[string]any
+		linkByEmail bool
+		autoCreate  bool
+		wantUser    *models.User
+		wantErr     error
+		wantLookups int
+	}{
+		{"e-mail não verificado", map[string]any{"sub": "u-1", "email": "admin@example.com", "email_verified": false}, true, true, nil, ErrOIDCNoAccount, 0},
+		{"email_verified ausente", map[string]any{"sub": "u-1", "email": "admin@example.com"}, true, true, nil, ErrOIDCNoAccount, 0},
+		{"sem e-mail", map[string]any{"sub": "u-1", "email_verified": true}, true, true, nil, ErrOIDCNoAccount, 0},
+		{"e-mail existente sem LINK_BY_EMAIL", verifiedClaims("u-1", "admin@example.com"), false, true, nil, ErrOIDCNoAccount, 1},
+		{"e-mail existente com LINK_BY_EMAIL", verifiedClaims("u-1", "admin@example.com"), true, false, admin, nil, 1},
+		{"email_verified em texto", map[string]any{"sub": "u-1", "email": "admin@example.com", "email_verified": "true"}, true, false, admin, nil, 1},
+		{"e-mail novo com AUTO_CREATE", verifiedClaims("u-2", "novo@example.com"), false, true, nil, nil, 1},
+		{"e-mail novo sem AUTO_CREATE", verifiedClaims("u-2", "novo@example.com"), true, false, nil, ErrOIDCNoAccount, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, claims, err := loginOIDC(t, mock, tt.claims)
+			if err != nil {
+				t.Fatalf("login = %v", err)
+			}
+			config.LinkByEmail = tt.linkByEmail
+			config.AutoCreate = tt.autoCreate
+
+			lookups := 0
+			user, err := matchOIDCAccount(config, claims, findByEmail(&lookups))
+			if !errors.Is(err, tt.wantErr) || user != tt.wantUser {
+				t.Errorf("matchOIDCAccount() = %v, %v; esperado %v, %v", user, err, tt.wantUser, tt.wantErr)
+			}
+			if lookups != tt.wantLookups {
+				t.Errorf("buscas por e-mail = %d, esperado %d", lookups, tt.wantLookups)
+			}
+		})
+	}
+
+	t.Run("erro na busca", func(t *testing.T) {
+		failure := errors.New("banco indisponível")
+		config := OIDCProviderConfig{Name: "mock", LinkByEmail: true, AutoCreate: true}
+		claims := &oidcClaims{Subject: "u-3", Email: "x@example.com", EmailVerified: true}
+
+		_, err := matchOIDCAccount(config, claims, func(string) (*models.User, error) { return nil, failure })
+		if !errors.Is(err, failure) {
+			t.Errorf("matchOIDCAccount() = %v, esperado %v", err, failure)
+		}
+	})
+}
+
+func TestOIDCLinkByEmailDefault(t *testing.T) {
+	t.Setenv("OIDC_MOCK_ISSUER", "https://idp.example.com")
+	t.Setenv("OIDC_MOCK_CLIENT_ID", testOIDCClientID)
+	t.Setenv("OIDC_MOCK_CLIENT_SECRET", testOIDCSecret)
+	t.Setenv("OIDC_MOCK_REDIRECT_URL", testOIDCRedirectURL)
+	t.Setenv("OIDC_MOCK_ROLE_RULES", "groups=financeiro:finance,realm_access.roles=admin:admin,inválida")
+
+	config, ok := loadOIDCProviderConfig("mock")
+	if !ok {
+		t.Fatalf("loadOIDCProviderConfig() recusou a configuração")
+	}
+	if config.LinkByEmail || config.AutoCreate || config.SyncRoles {
+		t.Errorf("LINK_BY_EMAIL, AUTO_CREATE e SYNC_ROLES devem vir desativados: %+v", config)
+	}
+	want := []OIDCRoleRule{
+		{Claim: "groups", Value: "financeiro", Role: "finance"},
+		{Claim: "realm_access.roles", Value: "admin", Role: "admin"},
+	}
+	if !reflect.DeepEqual(config.RoleRules, want) {
+		t.Errorf("regras = %+v, esperado %+v", config.RoleRules, want)
+	}
+}
+
+func TestParseOIDCRoleRule(t *testing.T) {
+	tests := []struct {
+		item string
+		want OIDCRoleRule
+		ok   bool
+	}{
+		{"groups=financeiro:finance", OIDCRoleRule{Claim: "groups", Value: "financeiro", Role: "finance"}, true},
+		{" groups = cn=fin:x,o=corp : finance ", OIDCRoleRule{Claim: "groups", Value: "cn=fin:x,o=corp", Role: "finance"}, true},
+		{"groups=financeiro", OIDCRoleRule{}, false},
+		{"groups:finance", OIDCRoleRule{}, false},
+		{"=financeiro:finance", OIDCRoleRule{}, false},
+		{"groups=financeiro:", OIDCRoleRule{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.item, func(t *testing.T) {
+			got, ok := parseOIDCRoleRule(tt.item)
+			if ok != tt.ok || (ok && got != tt.want) {
+				t.Errorf("parseOIDCRoleRule(%q) = %+v, %t; esperado %+v, %t", tt.item, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,279 @@
+// pwd: /app/server/modules/login/services/password_policy.go
+package services
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+
+	"api/logger"
+	"api/server/modules/login/auth_utils"
+	"api/server/modules/login/models"
+	"api/utils"
+)
+
+// Tamanho mínimo da senha (PASSWORD_MIN_LENGTH). O máximo é o limite do bcrypt (72 bytes), mantido também com
+// argon2id para que as senhas continuem válidas se o algoritmo voltar a ser o bcrypt.
+var passwordMinLength = utils.GetEnvInt("PASSWORD_MIN_LENGTH", 8)
+
+const passwordMaxBytes = 72
+
+var (
+	// Classes de caracteres obrigatórias (PASSWORD_REQUIRE_CLASSES: lower, upper, digit, symbol)
+	passwordRequiredClasses = loadPasswordClasses()
+	// Quantidade de senhas recentes que não podem ser reutilizadas, incluindo a atual (PASSWORD_HISTORY; 0 = desabilitado)
+	passwordHistorySize = utils.GetEnvInt("PASSWORD_HISTORY", 0)
+	// Recusa senhas vazadas da lista embutida e de PASSWORD_BREACHED_FILE
+	passwordBreachedCheck = utils.GetEnvBool("PASSWORD_BREACHED_CHECK", true)
+
+	breachedOnce     sync.Once
+	breachedPassword map[[sha1.Size]byte]struct{}
+)
+
+// passwordClassNames descreve cada classe de caracteres nas mensagens de erro
+var passwordClassNames = map[string]string{
+	"lower":  "uma letra minúscula",
+	"upper":  "uma letra maiúscula",
+	"digit":  "um número",
+	"symbol": "um símbolo",
+}
+
+// commonPasswords é a lista embutida de senhas vazadas mais comuns, verificada mesmo sem PASSWORD_BREACHED_FILE
+var commonPasswords = []string{
+	"password", "password1", "password123", "passw0rd", "p@ssw0rd", "12345678", "123456789", "1234567890",
+	"12341234", "87654321", "123123123", "11111111", "00000000", "abc12345", "a1b2c3d4", "qwerty123",
+	"qwertyuiop", "1q2w3e4r", "1q2w3e4r5t", "q1w2e3r4", "zaq12wsx", "asdfghjkl", "iloveyou", "sunshine",
+	"princess", "football", "baseball", "welcome1", "trustno1", "changeme", "admin123", "administrador",
+	"senha123", "senha1234", "mudar123", "123mudar", "brasil123",
+}
+
+// PasswordPolicyError indica que a senha não atende à política de senhas.
+type PasswordPolicyError struct {
+	Reason string
+}
+
+// Error retorna o motivo da recusa da senha.
+func (e *PasswordPolicyError) Error() string {
+	return e.Reason
+}
+
+// loadPasswordClasses lê PASSWORD_REQUIRE_CLASSES, ignorando as classes desconhecidas.
+func loadPasswordClasses() []string {
+	var classes []string
+	for _, class := range utils.GetEnvList("PASSWORD_REQUIRE_CLASSES") {
+		class = strings.ToLower(class)
+		if _, ok := passwordClassNames[class]; !ok {
+			logger.Warn("Classe desconhecida em PASSWORD_REQUIRE_CLASSES: %q (use lower, upper, digit ou symbol)", class)
+			continue
+		}
+		classes = append(classes, class)
+	}
+	return classes
+}
+
+// ValidatePassword verifica se a senha atende à política de senhas.
+//
+// Parâmetros:
+// - password: A senha proposta.
+// - user: O usuário dono da senha (com ID, para a verificação do histórico; 0 em cadastros).
+//
+// Retorno:
+// - error: *PasswordPolicyError com o motivo da recusa, erro interno, ou nil se a senha for aceita.
+//
+// Detalhes:
+// - Tamanho (PASSWORD_MIN_LENGTH até 72 bytes) e classes de caracteres (PASSWORD_REQUIRE_CLASSES).
+// - A senha não pode conter o username nem o e-mail (ou o nome antes do @) do usuário.
+// - A senha não pode estar na lista de senhas vazadas (PASSWORD_BREACHED_CHECK e PASSWORD_BREACHED_FILE).
+// - A senha não pode ser uma das últimas PASSWORD_HISTORY senhas do usuário.
+func ValidatePassword(password string, user models.User) error {
+	if len([]rune(password)) < passwordMinLength {
+		return &PasswordPolicyError{Reason: fmt.Sprintf("A senha deve ter pelo menos %d caracteres", passwordMinLength)}
+	}
+	if len(password) > passwordMaxBytes {
+		return &PasswordPolicyError{Reason: fmt.Sprintf("A senha deve ter no máximo %d bytes", passwordMaxBytes)}
+	}
+	if strings.TrimSpace(password) == "" {
+		return &PasswordPolicyError{Reason: "A senha não pode ser composta apenas por espaços"}
+	}
+
+	for _, class := range passwordRequiredClasses {
+		if !hasPasswordClass(password, class) {
+			return &PasswordPolicyError{Reason: "A senha deve conter pelo menos " + passwordClassNames[class]}
+		}
+	}
+
+	if containsPersonalInfo(strings.ToLower(password), user) {
+		return &PasswordPolicyError{Reason: "A senha não pode conter o usuário ou o e-mail"}
+	}
+
+	if passwordBreachedCheck && isBreachedPassword(password) {
+		return &PasswordPolicyError{Reason: "Esta senha aparece em vazamentos de dados conhecidos. Escolha outra senha"}
+	}
+
+	if passwordHistorySize > 0 && user.ID != 0 {
+		reused, err := isRecentPassword(password, user.ID)
+		if err != nil {
+			return err
+		}
+		if reused {
+			return &PasswordPolicyError{Reason: fmt.Sprintf("A senha não pode ser igual a nenhuma das últimas %d senhas", passwordHistorySize)}
+		}
+	}
+
+	return nil
+}
+
+// hasPasswordClass verifica se a senha contém um caractere da classe.
+func hasPasswordClass(password, class string) bool {
+	for _, r := range password {
+		switch {
+		case class == "lower" && unicode.IsLower(r),
+			class == "upper" && unicode.IsUpper(r),
+			class == "digit" && unicode.IsDigit(r),
+			class == "symbol" && !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsSpace(r):
+			return true
+		}
+	}
+	return false
+}
+
+// containsPersonalInfo verifica se a senha (em minúsculas) contém o username, o e-mail ou o nome antes do @.
+// Trechos com menos de 4 caracteres são ignorados, para não recusar senhas por coincidências curtas.
+func containsPersonalInfo(lower string, user models.User) bool {
+	email := strings.ToLower(user.Email)
+	local, _, _ := strings.Cut(email, "@")
+	for _, item := range []string{strings.ToLower(user.Username), email, local} {
+		if len(item) >= 4 && strings.Contains(lower, item) {
+			return true
+		}
+	}
+	return false
+}
+
+// isBreachedPassword verifica a senha (e a versão em minúsculas) na lista de senhas vazadas.
+func isBreachedPassword(password string) bool {
+	breachedOnce.Do(loadBreachedPasswords)
+
+	if _, ok := breachedPassword[sha1.Sum([]byte(password))]; ok {
+		return true
+	}
+	_, ok := breachedPassword[sha1.Sum([]byte(strings.ToLower(password)))]
+	return ok
+}
+
+// loadBreachedPasswords carrega a lista embutida e o arquivo PASSWORD_BREACHED_FILE.
+//
+// O arquivo tem uma entrada por linha: a senha em texto puro ou o seu SHA-1 em hexadecimal, opcionalmente seguido
+// de ":contagem" (formato das listas do Have I Been Pwned).
+func loadBreachedPasswords() {
+	breachedPassword = make(map[[sha1.Size]byte]struct{}, len(commonPasswords))
+	for _, password := range commonPasswords {
+		breachedPassword[sha1.Sum([]byte(password))] = struct{}{}
+	}
+
+	path := utils.GetEnv("PASSWORD_BREACHED_FILE")
+	if path == "" {
+		return
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		logger.Error("Erro ao abrir PASSWORD_BREACHED_FILE %s: %v", path, err)
+		return
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+
+		hash, _, _ := strings.Cut(line, ":")
+		var key [sha1.Size]byte
+		if decoded, err := hex.DecodeString(hash); err == nil && len(decoded) == sha1.Size {
+			copy(key[:], decoded)
+		} else {
+			key = sha1.Sum([]byte(line))
+		}
+		breachedPassword[key] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		logger.Error("Erro ao ler PASSWORD_BREACHED_FILE %s: %v", path, err)
+	}
+	logger.Info("Lista de senhas vazadas carregada: %d entradas", len(breachedPassword))
+}
+
+// isRecentPassword verifica a senha contra a senha atual e o histórico do usuário.
+func isRecentPassword(password string, userID int) (bool, error) {
+	current, err := models.GetPasswordHash(userID)
+	if err != nil {
+		return false, err
+	}
+	if auth_utils.VerifyPassword(password, current) {
+		return true, nil
+	}
+
+	if passwordHistorySize <= 1 {
+		return false, nil
+	}
+	history, err := models.GetPasswordHistory(userID, passwordHistorySize-1)
+	if err != nil {
+		return false, err
+	}
+	for _, hash := range history {
+		if auth_utils.VerifyPassword(password, hash) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// setPassword grava a nova senha do usuário e, com PASSWORD_HISTORY, guarda a senha substituída no histórico.
+func setPassword(userID int, newPassword string) error {
+	newHash, err := auth_utils.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	var oldHash string
+	if passwordHistorySize > 1 {
+		if oldHash, err = models.GetPasswordHash(userID); err != nil {
+			return err
+		}
+	}
+
+	if err := models.UpdatePassword(userID, newHash); err != nil {
+		return err
+	}
+
+	if oldHash != "" {
+		if err := models.AddPasswordHistory(userID, oldHash, passwordHistorySize-1); err != nil {
+			logger.Error("Erro ao gravar o histórico de senhas do usuário ID=%d: %v", userID, err)
+		}
+	}
+	return nil
+}
+
+// rehashPassword refaz o hash de uma senha validada no login quando o hash armazenado usa outro algoritmo ou
+// parâmetros diferentes dos configurados (PASSWORD_HASH_ALGORITHM, BCRYPT_COST, ARGON2_*).
+func rehashPassword(user models.User, password string) {
+	if user.Password == "" || !auth_utils.PasswordNeedsRehash(user.Password) {
+		return
+	}
+
+	hash, err := auth_utils.HashPassword(password)
+	if err != nil {
+		return
+	}
+	if err := models.UpdatePassword(user.ID, hash); err != nil {
+		logger.Error("Erro ao atualizar o hash da senha do usuário ID=%d: %v", user.ID, err)
+		return
+	}
+	logger.Info("Hash da senha do usuário ID=%d atualizado para a configuração atual", user.ID)
+}
@@ -0,0 +1,162 @@
+// pwd: /app/server/modules/login/services/password_reset_service.go
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"api/logger"
+	"api/server/modules/login/auth_utils"
+	"api/server/modules/login/models"
+	"api/utils"
+)
+
+// ErrInvalidResetToken indica que o token de redefinição de senha é inválido, já foi utilizado ou expirou.
+var ErrInvalidResetToken = errors.New("token de redefinição inválido ou expirado")
+
+var (
+	passwordResetTokenTTL       = utils.GetEnvDuration("PASSWORD_RESET_TOKEN_TTL", time.Hour)
+	passwordResetResendInterval = utils.GetEnvDuration("PASSWORD_RESET_RESEND_INTERVAL", 5*time.Minute)
+	passwordResetIPInterval     = utils.GetEnvDuration("PASSWORD_RESET_IP_INTERVAL", 10*time.Second)
+	passwordResetPath           = "/reset-password"
+)
+
+// RequestPasswordReset inicia a redefinição de senha da conta com o e-mail informado.
+//
+// Parâmetros:
+// - email: O e-mail informado pelo usuário.
+// - ip: O IP de origem da requisição.
+//
+// Detalhes:
+// - O processamento ocorre em segundo plano e nenhum resultado é retornado, para que nem a resposta
+// nem o tempo de resposta revelem se a conta existe.
+// - Contas inexistentes, desativadas ou vinculadas ao diretório LDAP não recebem e-mail.
+// - Cada IP pode fazer um pedido a cada PASSWORD_RESET_IP_INTERVAL e cada conta recebe no máximo um link a cada
+// PASSWORD_RESET_RESEND_INTERVAL; os pedidos acima do limite são ignorados.
+// - Um novo pedido atendido invalida os links enviados anteriormente.
+func RequestPasswordReset(email, ip string) {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return
+	}
+
+	go func() {
+		if !reservePasswordReset(models.ResetScopeIP, ip, passwordResetIPInterval) {
+			logger.Warn("Pedido de redefinição de senha ignorado: limite do IP %s", ip)
+			return
+		}
+
+		user, err := models.GetUserByEmail(email)
+		if err != nil {
+			if err != models.ErrUserNotFound {
+				logger.Error("Erro ao buscar usuário para redefinição de senha: %v", err)
+			}
+			return
+		}
+		if !user.Active {
+			logger.Warn("Redefinição de senha solicitada para a conta desativada ID=%d (ip=%s)", user.ID, ip)
+			return
+		}
+		if subject, err := ldapSubject(user.ID); err != nil || subject != "" {
+			logger.Warn("Redefinição de senha ignorada para o usuário ID=%d, vinculado ao diretório LDAP (ip=%s)", user.ID, ip)
+			return
+		}
+
+		if !reservePasswordReset(models.ResetScopeUser, strconv.Itoa(user.ID), passwordResetResendInterval) {
+			logger.Warn("Pedido de redefinição de senha ignorado: último link enviado ao usuário ID=%d há menos de %s (ip=%s)", user.ID, passwordResetResendInterval, ip)
+			return
+		}
+
+		token, err := issueUserToken(user.ID, models.TokenPurposePasswordReset, "", passwordResetTokenTTL)
+		if err != nil {
+			logger.Error("Erro ao gerar token de redefinição de senha: %v", err)
+			return
+		}
+
+		logger.Info("Redefinição de senha solicitada para o usuário ID=%d (ip=%s)", user.ID, ip)
+		sendEmail(user.Email, "Redefinição de senha", fmt.Sprintf(
+			"Olá, %s.\n\nRecebemos um pedido para redefinir a senha da sua conta. Para criar uma nova senha, acesse o link abaixo (válido por %s):\n\n%s\n\nSe você não fez o pedido, ignore esta mensagem: a sua senha continua a mesma.",
+			user.Name, passwordResetTokenTTL, appLink(passwordResetPath, token)))
+	}()
+}
+
+// reservePasswordReset reserva um pedido de redefinição de senha para o escopo. Em caso de erro, o pedido é recusado.
+func reservePasswordReset(scope, identifier string, interval time.Duration) bool {
+	reserved, err := models.ReservePasswordResetRequest(scope, identifier, interval)
+	if err != nil {
+		logger.Error("Erro ao reservar o pedido de redefinição de senha (%s=%s): %v", scope, identifier, err)
+		return false
+	}
+	return reserved
+}
+
+// ResetPassword define uma nova senha a partir do token enviado por e-mail.
+//
+// Parâmetros:
+// - token: O token recebido por e-mail.
+// - newPassword: A nova senha.
+// - ip: O IP de origem da requisição.
+//
+// Retorno:
+// - error: ErrInvalidResetToken, *PasswordPolicyError ou erro interno.
+//
+// Detalhes:
+// - O token só é consumido se a nova senha atender à política, permitindo nova tentativa com o mesmo link.
+// - Todas as sessões e tokens do usuário são revogados e o bloqueio de login por usuário é removido.
+func ResetPassword(token, newPassword, ip string) error {
+	tokenHash := auth_utils.HashToken(token)
+
+	stored, err := models.GetUserToken(models.TokenPurposePasswordReset, tokenHash)
+	if err != nil {
+		return err
+	}
+	if stored == nil {
+		return ErrInvalidResetToken
+	}
+
+	user, err := models.GetUserByID(stored.UserID)
+	if err != nil {
+		if err == models.ErrUserNotFound {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+	if !user.Active {
+		return ErrInvalidResetToken
+	}
+	if err := ValidatePassword(newPassword, *user); err != nil {
+		return err
+	}
+
+	// Consome o token apenas agora; uma requisição concorrente com o mesmo token é recusada aqui
+	if stored, err = models.ConsumeUserToken(models.TokenPurposePasswordReset, tokenHash); err != nil {
+		return err
+	}
+	if stored == nil {
+		return ErrInvalidResetToken
+	}
+
+	if err := setPassword(user.ID, newPassword); err != nil {
+		return err
+	}
+
+	if err := RevokeAllUserTokens(user.ID, user.ID, ip, "password_reset"); err != nil {
+		logger.Error("Erro ao revogar as sessões após a redefinição de senha: %v", err)
+	}
+	RegisterLoginSuccess(user.Username)
+
+	_ = models.RecordAuditEvent(models.AuditEvent{
+		EventType: models.AuditPasswordReset,
+		UserID:    user.ID,
+		Username:  user.Username,
+		ActorID:   user.ID,
+		IP:        ip,
+	})
+	sendEmail(user.Email, "Sua senha foi redefinida",
+		"A senha da sua conta foi redefinida e todas as sessões foram encerradas. Se não foi você, entre em contato com o suporte imediatamente.")
+
+	return nil
+}
@@ -0,0 +1,265 @@
+// pwd: /app/server/modules/login/services/profile_service.go
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"api/logger"
+	"api/server/modules/login/auth_utils"
+	"api/server/modules/login/models"
+	"api/utils"
+)
+
+// Erros do autoatendimento do perfil
+var (
+	ErrInvalidEmailToken   = errors.New("token de confirmação inválido ou expirado")
+	ErrWrongPassword       = errors.New("senha atual incorreta")
+	ErrPasswordUnchanged   = errors.New("a nova senha deve ser diferente da atual")
+	ErrTooManyAttempts     = errors.New("muitas tentativas com senha incorreta")
+	emailChangeTokenTTL    = utils.GetEnvDuration("EMAIL_CHANGE_TOKEN_TTL", 24*time.Hour)
+	emailChangeConfirmPath = "/confirm-email-change"
+)
+
+// ProfileChanges contém as alterações solicitadas pelo próprio usuário (campos nil não são alterados)
+type ProfileChanges struct {
+	Name  *string
+	Email *string
+}
+
+// UpdateProfile altera o nome do usuário e inicia a troca de e-mail, quando solicitada.
+//
+// Parâmetros:
+// - userID: O ID do usuário autenticado.
+// - changes: As alterações solicitadas.
+// - ip: O IP de origem da requisição.
+//
+// Retorno:
+// - *models.User: O perfil atualizado.
+// - bool: true se a troca de e-mail aguarda confirmação.
+// - error: ErrInvalidName, ErrInvalidEmail, ErrEmailInUse ou erro interno.
+//
+// Detalhes:
+// - O novo e-mail só é gravado depois que o usuário confirmar o link enviado para ele (ConfirmEmailChange).
+// - O endereço atual recebe um aviso da solicitação.
+func UpdateProfile(userID int, changes ProfileChanges, ip string) (*models.User, bool, error) {
+	user, err := models.GetUserByID(userID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var emailChange string
+	if changes.Email != nil {
+		email, err := normalizeEmail(*changes.Email)
+		if err != nil {
+			return nil, false, err
+		}
+		if !strings.EqualFold(email, user.Email) {
+			inUse, err := models.IsEmailInUse(email, userID)
+			if err != nil {
+				return nil, false, err
+			}
+			if inUse {
+				return nil, false, ErrEmailInUse
+			}
+			emailChange = email
+		}
+	}
+
+	if changes.Name != nil {
+		if _, err := UpdateUser(userID, UserChanges{Name: changes.Name}, userID, ip); err != nil {
+			return nil, false, err
+		}
+	}
+
+	if emailChange != "" {
+		if err := requestEmailChange(*user, emailChange); err != nil {
+			return nil, false, err
+		}
+	}
+
+	updated, err := models.GetUserByID(userID)
+	return updated, emailChange != "", err
+}
+
+// ConfirmEmailChange conclui a troca de e-mail a partir do token enviado ao novo endereço.
+//
+// Parâmetros:
+// - token: O token recebido por e-mail.
+// - ip: O IP de origem da requisição.
+//
+// Retorno:
+// - error: ErrInvalidEmailToken, ErrEmailInUse (o endereço foi ocupado depois da solicitação) ou erro interno.
+func ConfirmEmailChange(token, ip string) error {
+	stored, err := models.ConsumeUserToken(models.TokenPurposeEmailChange, auth_utils.HashToken(token))
+	if err != nil {
+		return err
+	}
+	if stored == nil {
+		return ErrInvalidEmailToken
+	}
+
+	inUse, err := models.IsEmailInUse(stored.Data, stored.UserID)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		return ErrEmailInUse
+	}
+
+	user, err := models.GetUserByID(stored.UserID)
+	if err != nil {
+		return err
+	}
+
+	email := stored.Data
+	if err := models.UpdateUser(stored.UserID, models.UserUpdate{Email: &email}); err != nil {
+		return err
+	}
+	// O link foi aberto a partir do novo endereço, que portanto já está verificado
+	if _, err := models.MarkEmailVerified(stored.UserID, email); err != nil {
+		logger.Error("Erro ao registrar a verificação do novo e-mail do usuário ID=%d: %v", stored.UserID, err)
+	}
+	userPermissionsCache.Delete(stored.UserID)
+
+	logger.Info("E-mail do usuário ID=%d alterado", stored.UserID)
+	_ = models.RecordAuditEvent(models.AuditEvent{
+		EventType: models.AuditEmailChanged,
+		UserID:    stored.UserID,
+		Username:  user.Username,
+		ActorID:   stored.UserID,
+		IP:        ip,
+		Details:   fmt.Sprintf("de=%s; para=%s", user.Email, email),
+	})
+	return nil
+}
+
+// ChangePassword troca a senha do usuário autenticado, invalida todos os seus tokens e encerra as suas outras sessões.
+//
+// Parâmetros:
+// - userID: O ID do usuário autenticado.
+// - sessionID: A sessão atual, que permanece ativa.
+// - currentPassword: A senha atual, para confirmação.
+// - newPassword: A nova senha.
+// - ip: O IP de origem da requisição.
+//
+// Retorno:
+// - *models.AuthResponse: O novo access token da sessão atual.
+// - int: A quantidade de outras sessões encerradas.
+// - error: ErrWrongPassword, ErrTooManyAttempts, ErrPasswordUnchanged, ErrPasswordManagedByDirectory,
+// *PasswordPolicyError ou erro interno.
+//
+// Detalhes:
+//   - Senhas atuais incorretas contam como falhas de login, sujeitas ao mesmo bloqueio.
+//   - A versão dos tokens do usuário é incrementada: todos os tokens de acesso já emitidos deixam de valer,
+//     inclusive os sem sessão (ex: tokens OAuth) e o próprio token da requisição, que é substituído pelo retornado.
+//     O refresh token da sessão atual continua válido.
+func ChangePassword(userID int, sessionID, currentPassword, newPassword, ip string) (*models.AuthResponse, int, error) {
+	user, err := models.GetUserByID(userID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// A senha dos usuários do diretório é trocada no próprio LDAP / Active Directory
+	if subject, err := ldapSubject(userID); err != nil {
+		return nil, 0, err
+	} else if subject != "" {
+		return nil, 0, ErrPasswordManagedByDirectory
+	}
+
+	if err := verifyCurrentPassword(*user, currentPassword, ip); err != nil {
+		return nil, 0, err
+	}
+	if currentPassword == newPassword {
+		return nil, 0, ErrPasswordUnchanged
+	}
+	if err := ValidatePassword(newPassword, *user); err != nil {
+		return nil, 0, err
+	}
+
+	if err := setPassword(userID, newPassword); err != nil {
+		return nil, 0, err
+	}
+
+	revoked, err := RevokeOtherSessions(userID, sessionID, userID, ip)
+	if err != nil {
+		logger.Error("Erro ao encerrar as outras sessões após a troca de senha: %v", err)
+	}
+	if version, err := incrementTokenVersion(userID); err != nil {
+		logger.Error("Erro ao revogar os tokens após a troca de senha: %v", err)
+	} else {
+		user.TokenVersion = version
+	}
+
+	_ = models.RecordAuditEvent(models.AuditEvent{
+		EventType: models.AuditPasswordChanged,
+		UserID:    userID,
+		Username:  user.Username,
+		ActorID:   userID,
+		IP:        ip,
+		Details:   fmt.Sprintf("sessoes_encerradas=%d", revoked),
+	})
+	sendEmail(user.Email, "Sua senha foi alterada",
+		"A senha da sua conta foi alterada. Se não foi você, entre em contato com o suporte imediatamente.")
+
+	authResponse, err := IssueAccessToken(*user, sessionID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return authResponse, revoked, nil
+}
+
+// requestEmailChange envia o link de confirmação ao novo e-mail e um aviso ao e-mail atual.
+func requestEmailChange(user models.User, newEmail string) error {
+	token, err := issueUserToken(user.ID, models.TokenPurposeEmailChange, newEmail, emailChangeTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	sendEmail(newEmail, "Confirme o seu novo e-mail", fmt.Sprintf(
+		"Olá, %s.\n\nPara confirmar a troca do e-mail da sua conta, acesse o link abaixo (válido por %s):\n\n%s\n\nSe você não solicitou a troca, ignore esta mensagem.",
+		user.Name, emailChangeTokenTTL, appLink(emailChangeConfirmPath, token)))
+	sendEmail(user.Email, "Solicitação de troca de e-mail", fmt.Sprintf(
+		"Foi solicitada a troca do e-mail da sua conta para %s. A troca só será concluída após a confirmação no novo endereço.\n\nSe não foi você, altere a sua senha.",
+		newEmail))
+
+	logger.Info("Troca de e-mail solicitada pelo usuário ID=%d", user.ID)
+	return nil
+}
+
+// verifyCurrentPassword confirma a senha atual do usuário antes de uma operação sensível.
+// Para usuários vinculados ao diretório, a senha é validada no LDAP. Senhas incorretas contam como falhas de login, sujeitas ao mesmo bloqueio.
+func verifyCurrentPassword(user models.User, password, ip string) error {
+	if CheckLoginLockout(user.Username, ip) > 0 {
+		return ErrTooManyAttempts
+	}
+
+	// Usuários do diretório confirmam a senha no LDAP
+	subject, err := ldapSubject(user.ID)
+	if err != nil {
+		return err
+	}
+	if config := getLDAPConfig(); subject != "" && config != nil && password != "" {
+		if _, err := ldapVerifyPassword(config, subject, password); err != nil {
+			if errors.Is(err, ErrAuthenticatorUnavailable) {
+				logger.Error("Diretório LDAP indisponível na confirmação da senha do usuário %s: %v", user.Username, err)
+				return err
+			}
+			RegisterLoginFailure(user.Username, ip)
+			return ErrWrongPassword
+		}
+		return nil
+	}
+
+	hash, err := models.GetPasswordHash(user.ID)
+	if err != nil {
+		return err
+	}
+	if !auth_utils.VerifyPassword(password, hash) {
+		RegisterLoginFailure(user.Username, ip)
+		return ErrWrongPassword
+	}
+	return nil
+}
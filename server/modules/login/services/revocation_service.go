@@ -0,0 +1,185 @@
+// pwd: /app/server/modules/login/services/revocation_service.go
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"api/logger"
+	"api/server/modules/login/auth_utils"
+	"api/server/modules/login/models"
+	"api/utils"
+	"api/utils/cache"
+)
+
+// ErrTokenRevoked indica que o token foi revogado (logout, logout-all, troca de senha ou conta desativada).
+var ErrTokenRevoked = errors.New("token revogado")
+
+// Tempo máximo que uma consulta de revogação fica em cache.
+//
+// Revogações feitas por esta instância atualizam o cache na hora; o TTL limita o atraso
+// com que revogações feitas em outras instâncias passam a valer.
+var revocationCacheTTL = utils.GetEnvDuration("REVOCATION_CACHE_TTL", 30*time.Second)
+
+var (
+	revokedTokensCache   = cache.New[string, bool](revocationCacheTTL)
+	tokenVersionCache    = cache.New[int, int](revocationCacheTTL)
+	revokedSessionsCache = cache.New[string, bool](revocationCacheTTL)
+)
+
+// CheckTokenRevocation verifica se um token de acesso válido ainda pode ser usado.
+//
+// Parâmetros:
+// - claims: Os claims do token já validado por auth_utils.ParseToken.
+//
+// Retorno:
+// - error: ErrTokenRevoked se o jti ou a sessão (sid) estiverem revogados, se a versão do token for anterior à versão atual do usuário ou se o usuário tiver sido excluído.
+//
+// Detalhes:
+// - Nos tokens de personificação, a versão dos tokens e a sessão do administrador (claim act) também são verificadas.
+// - As consultas são mantidas em cache por REVOCATION_CACHE_TTL.
+// - A cada consulta da sessão ao banco, o horário de último uso da sessão é atualizado.
+// - Em caso de erro no banco de dados, o token é aceito (a assinatura e a expiração continuam valendo).
+func CheckTokenRevocation(claims *auth_utils.Claims) error {
+	revoked, ok := revokedTokensCache.Get(claims.Id)
+	if !ok {
+		revoked = models.IsTokenBlacklisted(claims.Id)
+		revokedTokensCache.Set(claims.Id, revoked)
+	}
+	if revoked {
+		return ErrTokenRevoked
+	}
+
+	if err := checkTokenVersion(claims.ID, claims.TokenVersion); err != nil {
+		return err
+	}
+
+	if claims.SessionID != "" && isSessionRevoked(claims.SessionID) {
+		return ErrTokenRevoked
+	}
+
+	// Tokens de personificação também caem quando o administrador perde os seus (logout-all, conta desativada)
+	if actor := claims.Actor; actor != nil {
+		if err := checkTokenVersion(actor.ID, actor.TokenVersion); err != nil {
+			return err
+		}
+		if actor.SessionID != "" && isSessionRevoked(actor.SessionID) {
+			return ErrTokenRevoked
+		}
+	}
+
+	return nil
+}
+
+// checkTokenVersion verifica (com cache) se a versão do token ainda é a versão atual dos tokens do usuário.
+func checkTokenVersion(userID, tokenVersion int) error {
+	version, ok := tokenVersionCache.Get(userID)
+	if !ok {
+		current, err := models.GetUserTokenVersion(userID)
+		if err == models.ErrUserNotFound {
+			return ErrTokenRevoked
+		}
+		if err != nil {
+			logger.Warn("Não foi possível verificar a versão dos tokens do usuário ID=%d: %v", userID, err)
+			return nil
+		}
+		version = current
+		tokenVersionCache.Set(userID, version)
+	}
+	if tokenVersion < version {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// incrementTokenVersion incrementa a versão dos tokens do usuário e atualiza o cache, invalidando os tokens de acesso já emitidos.
+func incrementTokenVersion(userID int) (int, error) {
+	version, err := models.IncrementTokenVersion(userID)
+	if err != nil {
+		return 0, err
+	}
+	tokenVersionCache.Set(userID, version)
+	return version, nil
+}
+
+// isSessionRevoked verifica (com cache) se a sessão foi revogada e registra o uso da sessão ativa.
+func isSessionRevoked(sessionID string) bool {
+	if revoked, ok := revokedSessionsCache.Get(sessionID); ok {
+		return revoked
+	}
+
+	session, err := models.GetSession(sessionID)
+	if err != nil {
+		return false
+	}
+
+	// Sessões inexistentes correspondem a logins anteriores ao registro de sessões
+	revoked := session != nil && session.RevokedAt != nil
+	revokedSessionsCache.Set(sessionID, revoked)
+
+	if session != nil && !revoked {
+		_ = models.TouchSession(sessionID, "")
+	}
+	return revoked
+}
+
+// RevokeAccessToken revoga um único token de acesso (logout da sessão atual).
+//
+// Parâmetros:
+// - claims: Os claims do token a revogar.
+//
+// Retorno:
+// - error: Retorna erro se a revogação não puder ser gravada.
+func RevokeAccessToken(claims *auth_utils.Claims) error {
+	expiresAt := time.Unix(claims.ExpiresAt, 0)
+	if err := models.AddTokenToBlacklist(claims.Id, claims.ID, expiresAt); err != nil {
+		return err
+	}
+
+	// Não é preciso manter no cache por mais tempo do que o token seria aceito
+	if ttl := time.Until(expiresAt); ttl < revocationCacheTTL {
+		revokedTokensCache.SetWithTTL(claims.Id, true, ttl)
+	} else {
+		revokedTokensCache.Set(claims.Id, true)
+	}
+	return nil
+}
+
+// RevokeAllUserTokens invalida todos os tokens de acesso e refresh tokens emitidos para um usuário.
+//
+// Parâmetros:
+// - userID: O ID do usuário cujos tokens serão revogados.
+// - actorID: O ID de quem executou a ação (o próprio usuário ou um administrador).
+// - ip: O IP de origem da requisição.
+// - reason: O motivo da revogação, registrado na auditoria (ex: "logout-all", "password_change").
+//
+// Retorno:
+// - error: Retorna erro se a revogação falhar.
+//
+// Detalhes:
+// - A versão dos tokens do usuário é incrementada; tokens com versão anterior passam a ser recusados pelo AuthMiddleware.
+// - Todas as sessões ativas do usuário são marcadas como revogadas.
+func RevokeAllUserTokens(userID, actorID int, ip, reason string) error {
+	version, err := incrementTokenVersion(userID)
+	if err != nil {
+		return err
+	}
+
+	if err := models.RevokeUserRefreshTokens(userID); err != nil {
+		return err
+	}
+	if err := models.RevokeUserSessions(userID); err != nil {
+		return err
+	}
+
+	logger.Info("Todos os tokens do usuário ID=%d foram revogados (%s)", userID, reason)
+	_ = models.RecordAuditEvent(models.AuditEvent{
+		EventType: models.AuditTokensRevoked,
+		UserID:    userID,
+		ActorID:   actorID,
+		IP:        ip,
+		Details:   fmt.Sprintf("motivo=%s; versao=%d", reason, version),
+	})
+	return nil
+}
@@ -0,0 +1,246 @@
+// pwd: /app/server/modules/login/services/session_service.go
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"api/logger"
+	"api/server/modules/login/auth_utils"
+	"api/server/modules/login/models"
+)
+
+// ErrSessionNotFound indica que a sessão não existe, já foi revogada ou pertence a outro usuário.
+var ErrSessionNotFound = errors.New("sessão não encontrada")
+
+// Tamanho máximo dos campos da sessão (colunas user_agent e device)
+const (
+	maxUserAgentLength = 512
+	maxDeviceLength    = 128
+)
+
+// StartSession cria a sessão de um login e emite o access token e o refresh token vinculados a ela.
+//
+// Parâmetros:
+// - user: O usuário autenticado.
+// - userAgent: O cabeçalho User-Agent da requisição de login.
+// - device: O nome do dispositivo informado pelo cliente (opcional; se vazio, é derivado do User-Agent).
+// - ip: O IP de origem da requisição.
+//
+// Retorno:
+// - *models.AuthResponse: O access token e os dados do usuário.
+// - string: O refresh token.
+// - error: Retorna erro se a sessão ou os tokens não puderem ser gerados.
+//
+// Detalhes:
+// - O ID da sessão é também o family_id dos refresh tokens e o claim sid dos access tokens.
+func StartSession(user models.User, userAgent, device, ip string) (*models.AuthResponse, string, error) {
+	device = strings.TrimSpace(device)
+	if device == "" {
+		device = describeDevice(userAgent)
+	}
+
+	session := models.Session{
+		ID:        auth_utils.NewTokenID(),
+		UserID:    user.ID,
+		UserAgent: truncate(userAgent, maxUserAgentLength),
+		Device:    truncate(device, maxDeviceLength),
+		IP:        ip,
+	}
+	if err := models.CreateSession(session); err != nil {
+		return nil, "", err
+	}
+
+	authResponse, err := IssueAccessToken(user, session.ID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	refreshToken, err := IssueRefreshToken(user.ID, session.ID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	logger.Info("Sessão %s iniciada para o usuário ID=%d (%s, IP %s)", session.ID, user.ID, session.Device, ip)
+	return authResponse, refreshToken, nil
+}
+
+// ListSessions lista as sessões ativas de um usuário.
+//
+// Parâmetros:
+// - userID: O ID do usuário.
+//
+// Retorno:
+// - []models.Session: As sessões ativas, da mais recente para a mais antiga.
+// - error: Retorna erro se a consulta falhar.
+func ListSessions(userID int) ([]models.Session, error) {
+	return models.ListActiveSessions(userID)
+}
+
+// RevokeSession encerra uma sessão de um usuário, invalidando seus tokens de acesso e refresh tokens.
+//
+// Parâmetros:
+// - userID: O ID do dono da sessão.
+// - sessionID: O ID da sessão.
+// - actorID: O ID de quem executou a ação (o próprio usuário ou um administrador).
+// - ip: O IP de origem da requisição.
+//
+// Retorno:
+// - error: ErrSessionNotFound se a sessão não estiver ativa ou não pertencer ao usuário, ou erro interno.
+func RevokeSession(userID int, sessionID string, actorID int, ip string) error {
+	session, err := models.GetSession(sessionID)
+	if err != nil {
+		return err
+	}
+	if session == nil || session.UserID != userID || session.RevokedAt != nil {
+		return ErrSessionNotFound
+	}
+
+	if err := revokeSession(sessionID); err != nil {
+		return err
+	}
+
+	_ = models.RecordAuditEvent(models.AuditEvent{
+		EventType: models.AuditSessionRevoked,
+		UserID:    userID,
+		ActorID:   actorID,
+		IP:        ip,
+		Details:   fmt.Sprintf("session_id=%s; device=%s", sessionID, session.Device),
+	})
+	return nil
+}
+
+// RevokeOtherSessions encerra todas as sessões ativas de um usuário, exceto a informada.
+//
+// Parâmetros:
+// - userID: O ID do usuário.
+// - keepSessionID: A sessão que permanece ativa (normalmente a sessão atual).
+// - actorID: O ID de quem executou a ação.
+// - ip: O IP de origem da requisição.
+//
+// Retorno:
+// - int: A quantidade de sessões encerradas.
+// - error: Retorna erro se a revogação falhar.
+func RevokeOtherSessions(userID int, keepSessionID string, actorID int, ip string) (int, error) {
+	sessions, err := models.ListActiveSessions(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	revoked := 0
+	for _, session := range sessions {
+		if session.ID == keepSessionID {
+			continue
+		}
+		if err := RevokeSession(userID, session.ID, actorID, ip); err != nil && err != ErrSessionNotFound {
+			return revoked, err
+		}
+		revoked++
+	}
+
+	return revoked, nil
+}
+
+// Logout encerra a sessão do token de acesso apresentado no logout.
+//
+// Parâmetros:
+// - claims: Os claims do token de acesso, já validado.
+// - refreshToken: O refresh token informado pelo cliente (opcional).
+//
+// Retorno:
+// - error: Retorna erro se o token de acesso não puder ser revogado.
+//
+// Detalhes:
+//   - O token, a sua sessão (sid) e a família de refresh tokens da sessão são revogados, mesmo sem o refresh token.
+//   - O refresh token informado é revogado junto, para os tokens sem sessão (emitidos antes do registro de sessões).
+//   - Nos tokens de personificação, apenas o token é revogado; a sessão do administrador continua ativa.
+func Logout(claims *auth_utils.Claims, refreshToken string) error {
+	if err := RevokeAccessToken(claims); err != nil {
+		return err
+	}
+
+	if claims.SessionID != "" {
+		if err := revokeSession(claims.SessionID); err != nil {
+			logger.Error("Erro ao encerrar a sessão %s no logout: %v", claims.SessionID, err)
+		}
+	}
+	if refreshToken != "" {
+		if err := RevokeRefreshToken(refreshToken); err != nil {
+			logger.Error("Erro ao revogar refresh token no logout: %v", err)
+		}
+	}
+	return nil
+}
+
+// revokeSession marca a sessão como revogada, revoga a sua família de refresh tokens e atualiza o cache de revogação.
+func revokeSession(sessionID string) error {
+	if _, err := models.RevokeSessionByID(sessionID); err != nil {
+		return err
+	}
+	if err := models.RevokeRefreshTokenFamily(sessionID); err != nil {
+		return err
+	}
+
+	revokedSessionsCache.Set(sessionID, true)
+	return nil
+}
+
+// describeDevice monta uma descrição curta do dispositivo a partir do User-Agent (ex: "Chrome em Windows").
+func describeDevice(userAgent string) string {
+	if userAgent == "" {
+		return "Desconhecido"
+	}
+
+	browser := firstMatch(userAgent, [][2]string{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"curl/", "curl"},
+		{"PostmanRuntime/", "Postman"},
+		{"okhttp/", "OkHttp"},
+	})
+	system := firstMatch(userAgent, [][2]string{
+		{"Android", "Android"},
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"Linux", "Linux"},
+	})
+
+	switch {
+	case browser != "" && system != "":
+		return browser + " em " + system
+	case browser != "":
+		return browser
+	case system != "":
+		return system
+	default:
+		return truncate(userAgent, 64)
+	}
+}
+
+// firstMatch retorna o nome associado ao primeiro marcador encontrado no texto.
+func firstMatch(text string, markers [][2]string) string {
+	for _, marker := range markers {
+		if strings.Contains(text, marker[0]) {
+			return marker[1]
+		}
+	}
+	return ""
+}
+
+// truncate limita o texto ao tamanho máximo informado (em bytes, sem quebrar caracteres UTF-8).
+func truncate(text string, max int) string {
+	if len(text) <= max {
+		return text
+	}
+	for max > 0 && !utf8.RuneStart(text[max]) {
+		max--
+	}
+	return text[:max]
+}
@@ -0,0 +1,172 @@
+// pwd: /app/server/modules/login/services/token_service.go
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"api/logger"
+	"api/server/modules/login/auth_utils"
+	"api/server/modules/login/models"
+)
+
+// Erros retornados na renovação de tokens
+var (
+	ErrInvalidRefreshToken = errors.New("refresh token inválido ou expirado")
+	ErrRefreshTokenReused  = errors.New("refresh token reutilizado")
+)
+
+// IssueAccessToken gera um access token (JWT) para o usuário e monta a resposta de autenticação.
+//
+// Parâmetros:
+// - user: O usuário autenticado.
+// - sessionID: A sessão de login à qual o token pertence (claim sid).
+//
+// Retorno:
+// - *models.AuthResponse: Token (com prefixo "Bearer "), usuário e tempo restante até a expiração.
+// - error: Retorna erro se a geração do token falhar.
+func IssueAccessToken(user models.User, sessionID string) (*models.AuthResponse, error) {
+	subject := user.TokenSubject()
+	subject.SessionID = sessionID
+
+	token, err := auth_utils.GenerateJWT(subject)
+	if err != nil {
+		logger.Error("Erro ao gerar token JWT: %v", err)
+		return nil, errors.New("erro interno ao gerar token")
+	}
+
+	token = "Bearer " + token
+	timeRemaining, err := auth_utils.CalculateTokenExpirationTime(token)
+	if err != nil {
+		logger.Error("Erro ao calcular o tempo de expiração do token: %v", err)
+		return nil, errors.New("erro interno ao calcular o tempo de expiração do token")
+	}
+
+	return &models.AuthResponse{
+		Token:         token,
+		User:          user,
+		TimeRemaining: timeRemaining.String(),
+	}, nil
+}
+
+// IssueRefreshToken gera um novo refresh token opaco e grava seu hash no banco de dados.
+//
+// Parâmetros:
+// - userID: O ID do usuário dono do token.
+// - familyID: A família do token (o ID da sessão de login). Se vazio, uma nova família é criada.
+//
+// Retorno:
+// - string: O refresh token em texto claro (só é exposto ao cliente neste momento).
+// - error: Retorna erro se a geração ou a gravação falhar.
+func IssueRefreshToken(userID int, familyID string) (string, error) {
+	if familyID == "" {
+		family, err := auth_utils.GenerateOpaqueToken()
+		if err != nil {
+			return "", err
+		}
+		familyID = family
+	}
+
+	token, err := auth_utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	expiresAt := time.Now().Add(auth_utils.GetRefreshExpirationTime())
+	if err := models.CreateRefreshToken(userID, familyID, auth_utils.HashToken(token), expiresAt); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// RefreshTokens troca um refresh token válido por um novo access token e um novo refresh token.
+//
+// Parâmetros:
+// - refreshToken: O refresh token apresentado pelo cliente.
+// - ip: O IP de origem da requisição (para auditoria e para a lista de sessões).
+//
+// Retorno:
+// - *models.AuthResponse: O novo access token e os dados do usuário.
+// - string: O novo refresh token.
+// - error: ErrInvalidRefreshToken, ErrRefreshTokenReused ou erro interno.
+//
+// Detalhes:
+// - O token apresentado é invalidado (rotação) e o novo pertence à mesma família.
+// - Se um token já rotacionado for apresentado novamente, toda a família é revogada (indício de token copiado).
+func RefreshTokens(refreshToken, ip string) (*models.AuthResponse, string, error) {
+	stored, err := models.GetRefreshTokenByHash(auth_utils.HashToken(refreshToken))
+	if err != nil {
+		return nil, "", err
+	}
+	if stored == nil || stored.RevokedAt != nil || time.Now().After(stored.ExpiresAt) {
+		return nil, "", ErrInvalidRefreshToken
+	}
+
+	if stored.RotatedAt != nil {
+		revokeReusedFamily(stored, ip)
+		return nil, "", ErrRefreshTokenReused
+	}
+
+	rotated, err := models.MarkRefreshTokenRotated(stored.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	if !rotated {
+		// Outra requisição rotacionou o mesmo token ao mesmo tempo
+		revokeReusedFamily(stored, ip)
+		return nil, "", ErrRefreshTokenReused
+	}
+
+	user, err := models.GetUserByID(stored.UserID)
+	if err != nil || !user.Active || CheckEmailVerifiedForLogin(*user) != nil {
+		return nil, "", ErrInvalidRefreshToken
+	}
+
+	authResponse, err := IssueAccessToken(*user, stored.FamilyID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := models.TouchSession(stored.FamilyID, ip); err != nil {
+		logger.Warn("Não foi possível atualizar a sessão %s: %v", stored.FamilyID, err)
+	}
+
+	newRefreshToken, err := IssueRefreshToken(user.ID, stored.FamilyID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return authResponse, newRefreshToken, nil
+}
+
+// RevokeRefreshToken revoga a família do refresh token informado e a sua sessão (usado no logout).
+//
+// Parâmetros:
+// - refreshToken: O refresh token apresentado pelo cliente.
+//
+// Retorno:
+// - error: Retorna erro se a revogação falhar. Tokens desconhecidos são ignorados.
+func RevokeRefreshToken(refreshToken string) error {
+	stored, err := models.GetRefreshTokenByHash(auth_utils.HashToken(refreshToken))
+	if err != nil || stored == nil {
+		return err
+	}
+	return revokeSession(stored.FamilyID)
+}
+
+// revokeReusedFamily revoga a família de um token reutilizado e registra o evento.
+func revokeReusedFamily(stored *models.RefreshToken, ip string) {
+	logger.Warn("Reutilização de refresh token detectada para o usuário ID=%d. Revogando a família.", stored.UserID)
+	if err := revokeSession(stored.FamilyID); err != nil {
+		logger.Error("Erro ao revogar família de refresh tokens: %v", err)
+	}
+
+	_ = models.RecordAuditEvent(models.AuditEvent{
+		EventType: models.AuditRefreshReuse,
+		UserID:    stored.UserID,
+		IP:        ip,
+		Details:   fmt.Sprintf("refresh_token_id=%d", stored.ID),
+	})
+}
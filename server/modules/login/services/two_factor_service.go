@@ -0,0 +1,472 @@
+// pwd: /app/server/modules/login/services/two_factor_service.go
+package services
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"api/logger"
+	"api/server/modules/login/auth_utils"
+	"api/server/modules/login/models"
+	"api/utils"
+)
+
+// Erros da autenticação em dois fatores
+var (
+	ErrTwoFactorAlreadyEnabled = errors.New("2FA já está ativado")
+	ErrTwoFactorNotPending     = errors.New("nenhum cadastro de 2FA pendente")
+	ErrTwoFactorNotEnabled     = errors.New("2FA não está ativado")
+	ErrTwoFactorRequired       = errors.New("2FA é obrigatório para um dos papéis do usuário")
+	ErrInvalidTwoFactorCode    = errors.New("código de verificação inválido")
+	ErrInvalidChallenge        = errors.New("desafio de login inválido ou expirado")
+)
+
+var (
+	twoFactorIssuer       = utils.GetEnv("TWO_FACTOR_ISSUER")
+	twoFactorChallengeTTL = utils.GetEnvDuration("TWO_FACTOR_CHALLENGE_TTL", 5*time.Minute)
+)
+
+// Quantidade e formato dos códigos de recuperação (ex: "K7Q2M-XW9PA")
+const (
+	recoveryCodeCount    = 10
+	recoveryCodeLength   = 10
+	recoveryCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+)
+
+// TwoFactorStatus resume a configuração de 2FA de um usuário
+type TwoFactorStatus struct {
+	Enabled                bool `json:"enabled"`
+	Pending                bool `json:"pending"`
+	Required               bool `json:"required"`
+	RecoveryCodesRemaining int  `json:"recovery_codes_remaining"`
+}
+
+// GetTwoFactorStatus retorna a configuração de 2FA do usuário.
+//
+// Parâmetros:
+// - userID: O ID do usuário.
+//
+// Retorno:
+// - *TwoFactorStatus: Se o 2FA está ativado, pendente de confirmação ou é exigido, e os códigos de recuperação restantes.
+// - error: Retorna erro se a consulta falhar.
+func GetTwoFactorStatus(userID int) (*TwoFactorStatus, error) {
+	state, err := models.GetTwoFactorState(userID)
+	if err != nil {
+		return nil, err
+	}
+	required, err := models.UserRequiresTwoFactor(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &TwoFactorStatus{Enabled: state.Enabled, Pending: !state.Enabled && state.Secret != "", Required: required}
+	if state.Enabled {
+		if status.RecoveryCodesRemaining, err = models.CountRecoveryCodes(userID); err != nil {
+			return nil, err
+		}
+	}
+	return status, nil
+}
+
+// BeginTOTPEnrollment gera um novo segredo TOTP, pendente de confirmação.
+//
+// Parâmetros:
+// - user: O usuário autenticado.
+//
+// Retorno:
+// - string: O segredo em base32, para cadastro manual no aplicativo autenticador.
+// - string: A URI otpauth:// a ser exibida como QR code.
+// - error: ErrTwoFactorAlreadyEnabled, auth_utils.ErrEncryptionKeyMissing ou erro interno.
+//
+// Detalhes:
+// - Um novo início substitui o segredo pendente anterior. O 2FA só passa a valer após ConfirmTOTPEnrollment.
+func BeginTOTPEnrollment(user models.User) (string, string, error) {
+	secret, err := auth_utils.GenerateTOTPSecret()
+	if err != nil {
+		logger.Error("Erro ao gerar segredo TOTP: %v", err)
+		return "", "", errors.New("erro ao gerar segredo")
+	}
+
+	encrypted, err := auth_utils.EncryptSecret(secret)
+	if err != nil {
+		return "", "", err
+	}
+
+	pending, err := models.SetPendingTOTPSecret(user.ID, encrypted)
+	if err != nil {
+		return "", "", err
+	}
+	if !pending {
+		return "", "", ErrTwoFactorAlreadyEnabled
+	}
+
+	return secret, auth_utils.TOTPProvisioningURI(issuerName(), user.Username, secret), nil
+}
+
+// ConfirmTOTPEnrollment ativa o 2FA após o usuário informar um código gerado com o novo segredo.
+//
+// Parâmetros:
+// - userID: O ID do usuário autenticado.
+// - code: O código de 6 dígitos exibido no aplicativo autenticador.
+// - ip: O IP de origem da requisição.
+//
+// Retorno:
+// - []string: Os códigos de recuperação, exibidos uma única vez (somente o hash é gravado).
+// - error: ErrTwoFactorAlreadyEnabled, ErrTwoFactorNotPending, ErrInvalidTwoFactorCode ou erro interno.
+func ConfirmTOTPEnrollment(userID int, code, ip string) ([]string, error) {
+	state, err := models.GetTwoFactorState(userID)
+	if err != nil {
+		return nil, err
+	}
+	if state.Enabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if state.Secret == "" {
+		return nil, ErrTwoFactorNotPending
+	}
+
+	secret, err := auth_utils.DecryptSecret(state.Secret)
+	if err != nil {
+		return nil, err
+	}
+	step, ok := auth_utils.ValidateTOTP(secret, code, time.Now(), state.LastStep)
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	codes, err := replaceRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+	enabled, err := models.EnableTOTP(userID, step)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return nil, ErrTwoFactorNotPending
+	}
+	userPermissionsCache.Delete(userID)
+
+	recordTwoFactorEvent(models.AuditTwoFactorEnabled, userID, userID, ip, "")
+	return codes, nil
+}
+
+// DisableTwoFactor desativa o 2FA do usuário autenticado.
+//
+// Parâmetros:
+// - userID: O ID do usuário autenticado.
+// - password: A senha atual.
+// - code: Um código TOTP ou de recuperação.
+// - ip: O IP de origem da requisição.
+//
+// Retorno:
+// - error: ErrTwoFactorNotEnabled, ErrTwoFactorRequired, ErrTooManyAttempts, ErrWrongPassword, ErrInvalidTwoFactorCode ou erro interno.
+//
+// Detalhes:
+// - Senhas e códigos incorretos contam como falhas de login, sujeitas ao mesmo bloqueio.
+func DisableTwoFactor(userID int, password, code, ip string) error {
+	user, err := models.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if !user.TwoFactor {
+		return ErrTwoFactorNotEnabled
+	}
+	required, err := models.UserRequiresTwoFactor(userID)
+	if err != nil {
+		return err
+	}
+	if required {
+		return ErrTwoFactorRequired
+	}
+
+	if err := verifyCurrentPassword(*user, password, ip); err != nil {
+		return err
+	}
+	if err := verifySecondFactor(*user, code, ip); err != nil {
+		return err
+	}
+
+	return disableTwoFactor(userID, userID, ip)
+}
+
+// ResetTwoFactor remove o 2FA de um usuário que perdeu o aplicativo autenticador e os códigos de recuperação.
+//
+// Parâmetros:
+// - userID: O ID do usuário.
+// - actorID: O ID do administrador que executou a ação.
+// - ip: O IP de origem da requisição.
+//
+// Retorno:
+// - error: models.ErrUserNotFound, ErrTwoFactorNotEnabled ou erro interno.
+//
+// Detalhes:
+// - Se algum papel do usuário exigir 2FA, ele precisará cadastrá-lo novamente para recuperar as permissões.
+func ResetTwoFactor(userID, actorID int, ip string) error {
+	if _, err := models.GetUserByID(userID); err != nil {
+		return err
+	}
+	return disableTwoFactor(userID, actorID, ip)
+}
+
+// RegenerateRecoveryCodes substitui os códigos de recuperação do usuário autenticado.
+//
+// Parâmetros:
+// - userID: O ID do usuário autenticado.
+// - code: Um código TOTP atual (códigos de recuperação não são aceitos).
+// - ip: O IP de origem da requisição.
+//
+// Retorno:
+// - []string: Os novos códigos de recuperação; os anteriores deixam de valer.
+// - error: ErrTwoFactorNotEnabled, ErrTooManyAttempts, ErrInvalidTwoFactorCode ou erro interno.
+func RegenerateRecoveryCodes(userID int, code, ip string) ([]string, error) {
+	user, err := models.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TwoFactor {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	if CheckLoginLockout(user.Username, ip) > 0 {
+		return nil, ErrTooManyAttempts
+	}
+
+	ok, err := verifyTOTP(user.ID, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		RegisterLoginFailure(user.Username, ip)
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	codes, err := replaceRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+	recordTwoFactorEvent(models.AuditRecoveryCodesRegenerated, userID, userID, ip, "")
+	return codes, nil
+}
+
+// NewTwoFactorChallenge emite o token de desafio da segunda etapa do login.
+//
+// Parâmetros:
+// - user: O usuário que informou a senha correta.
+// - device: O nome do dispositivo informado no login.
+//
+// Retorno:
+// - string: O token de desafio.
+// - time.Duration: A validade do desafio (TWO_FACTOR_CHALLENGE_TTL).
+// - error: Retorna erro se o token não puder ser gerado.
+func NewTwoFactorChallenge(user models.User, device string) (string, time.Duration, error) {
+	token, err := auth_utils.GenerateTwoFactorChallenge(user.ID, device, twoFactorChallengeTTL)
+	return token, twoFactorChallengeTTL, err
+}
+
+// CompleteTwoFactorLogin conclui o login com o token de desafio e um código TOTP ou de recuperação.
+//
+// Parâmetros:
+// - challenge: O token de desafio devolvido pela etapa de senha.
+// - code: O código TOTP ou de recuperação.
+// - ip: O IP de origem da requisição.
+//
+// Retorno:
+// - *models.User: O usuário autenticado.
+// - string: O nome do dispositivo informado na etapa de senha.
+// - error: ErrInvalidChallenge, ErrTooManyAttempts, ErrInvalidTwoFactorCode ou erro interno.
+func CompleteTwoFactorLogin(challenge, code, ip string) (*models.User, string, error) {
+	claims, err := auth_utils.ParseTwoFactorChallenge(challenge)
+	if err != nil {
+		return nil, "", ErrInvalidChallenge
+	}
+	userID, err := claims.UserID()
+	if err != nil {
+		return nil, "", ErrInvalidChallenge
+	}
+
+	user, err := models.GetUserByID(userID)
+	if err != nil || !user.Active || !user.TwoFactor || CheckEmailVerifiedForLogin(*user) != nil {
+		return nil, "", ErrInvalidChallenge
+	}
+	if CheckLoginLockout(user.Username, ip) > 0 {
+		return nil, "", ErrTooManyAttempts
+	}
+
+	if err := verifySecondFactor(*user, code, ip); err != nil {
+		return nil, "", err
+	}
+
+	RegisterLoginSuccess(user.Username)
+	return user, claims.Device, nil
+}
+
+// TwoFactorSetupRequired informa se o usuário precisa cadastrar o 2FA exigido por um dos seus papéis.
+//
+// Enquanto o cadastro não for feito, o usuário não tem nenhuma permissão (ver GetUserPermissions).
+func TwoFactorSetupRequired(user models.User) bool {
+	if user.TwoFactor {
+		return false
+	}
+	required, err := models.UserRequiresTwoFactor(user.ID)
+	if err != nil {
+		logger.Error("Erro ao verificar a exigência de 2FA do usuário ID=%d: %v", user.ID, err)
+		return false
+	}
+	return required
+}
+
+// SetRoleRequire2FA define se os usuários de um papel são obrigados a usar 2FA.
+//
+// Parâmetros:
+// - role: O nome do papel.
+// - require: true para exigir 2FA.
+// - actorID: O ID do administrador que executou a ação.
+// - ip: O IP de origem da requisição.
+//
+// Retorno:
+// - error: models.ErrUnknownRole ou erro interno.
+//
+// Detalhes:
+// - Usuários do papel sem 2FA perdem todas as permissões até cadastrá-lo (o cache de permissões é descartado).
+func SetRoleRequire2FA(role string, require bool, actorID int, ip string) error {
+	if err := models.SetRoleRequire2FA(role, require); err != nil {
+		return err
+	}
+	userPermissionsCache.Clear()
+
+	_ = models.RecordAuditEvent(models.AuditEvent{
+		EventType: models.AuditRoleUpdated,
+		ActorID:   actorID,
+		IP:        ip,
+		Details:   fmt.Sprintf("papel=%s; require_2fa=%t", role, require),
+	})
+	return nil
+}
+
+// verifySecondFactor valida um código TOTP ou, se não for um, um código de recuperação.
+// Códigos inválidos contam como falhas de login.
+func verifySecondFactor(user models.User, code, ip string) error {
+	ok, err := verifyTOTP(user.ID, code)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return nil
+	}
+
+	if normalized := normalizeRecoveryCode(code); len(normalized) == recoveryCodeLength {
+		used, err := models.UseRecoveryCode(user.ID, auth_utils.HashToken(normalized))
+		if err != nil {
+			return err
+		}
+		if used {
+			remaining, _ := models.CountRecoveryCodes(user.ID)
+			recordTwoFactorEvent(models.AuditRecoveryCodeUsed, user.ID, user.ID, ip, fmt.Sprintf("restantes=%d", remaining))
+			return nil
+		}
+	}
+
+	logger.Warn("Código de 2FA inválido para o usuário %s", user.Username)
+	RegisterLoginFailure(user.Username, ip)
+	return ErrInvalidTwoFactorCode
+}
+
+// verifyTOTP valida um código TOTP e registra o passo utilizado, impedindo que o mesmo código seja reutilizado.
+func verifyTOTP(userID int, code string) (bool, error) {
+	state, err := models.GetTwoFactorState(userID)
+	if err != nil {
+		return false, err
+	}
+	if !state.Enabled {
+		return false, nil
+	}
+
+	secret, err := auth_utils.DecryptSecret(state.Secret)
+	if err != nil {
+		logger.Error("Erro ao descriptografar o segredo TOTP do usuário ID=%d: %v", userID, err)
+		return false, err
+	}
+
+	step, ok := auth_utils.ValidateTOTP(secret, code, time.Now(), state.LastStep)
+	if !ok {
+		return false, nil
+	}
+	return models.UseTOTPStep(userID, step)
+}
+
+// disableTwoFactor remove o 2FA e os códigos de recuperação e registra a ação.
+func disableTwoFactor(userID, actorID int, ip string) error {
+	disabled, err := models.DisableTOTP(userID)
+	if err != nil {
+		return err
+	}
+	if !disabled {
+		return ErrTwoFactorNotEnabled
+	}
+	userPermissionsCache.Delete(userID)
+
+	recordTwoFactorEvent(models.AuditTwoFactorDisabled, userID, actorID, ip, "")
+	return nil
+}
+
+// replaceRecoveryCodes gera novos códigos de recuperação, grava os seus hashes e os retorna formatados.
+func replaceRecoveryCodes(userID int) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		code, err := randomRecoveryCode()
+		if err != nil {
+			logger.Error("Erro ao gerar código de recuperação: %v", err)
+			return nil, errors.New("erro ao gerar códigos de recuperação")
+		}
+		hashes[i] = auth_utils.HashToken(code)
+		codes[i] = code[:recoveryCodeLength/2] + "-" + code[recoveryCodeLength/2:]
+	}
+
+	if err := models.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// randomRecoveryCode gera um código aleatório com o alfabeto sem caracteres ambíguos (0/O, 1/I).
+func randomRecoveryCode() (string, error) {
+	b := make([]byte, recoveryCodeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = recoveryCodeAlphabet[int(b[i])%len(recoveryCodeAlphabet)]
+	}
+	return string(b), nil
+}
+
+// normalizeRecoveryCode remove hífens e espaços e converte para maiúsculas.
+func normalizeRecoveryCode(code string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(code)))
+}
+
+// issuerName retorna o nome do serviço exibido no aplicativo autenticador (TWO_FACTOR_ISSUER, padrão JWT_ISSUER).
+func issuerName() string {
+	if twoFactorIssuer != "" {
+		return twoFactorIssuer
+	}
+	if issuer := utils.GetEnv("JWT_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return "api_backend"
+}
+
+// recordTwoFactorEvent registra um evento de 2FA na auditoria.
+func recordTwoFactorEvent(eventType string, userID, actorID int, ip, details string) {
+	_ = models.RecordAuditEvent(models.AuditEvent{
+		EventType: eventType,
+		UserID:    userID,
+		ActorID:   actorID,
+		IP:        ip,
+		Details:   details,
+	})
+}
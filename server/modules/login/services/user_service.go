@@ -0,0 +1,276 @@
+// pwd: /app/server/modules/login/services/user_service.go
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"api/logger"
+	"api/server/modules/login/models"
+)
+
+// Erros da administração de usuários
+var (
+	ErrEmailInUse     = errors.New("e-mail já está em uso")
+	ErrInvalidUser    = errors.New("username e senha são obrigatórios")
+	ErrInvalidName    = errors.New("nome inválido")
+	ErrInvalidEmail   = errors.New("e-mail inválido")
+	ErrSelfManagement = errors.New("operação não permitida sobre o próprio usuário")
+)
+
+// Limites da paginação da listagem de usuários
+const (
+	defaultUsersPerPage = 20
+	maxUsersPerPage     = 100
+)
+
+// UserListResult representa uma página da listagem de usuários
+type UserListResult struct {
+	Users   []models.User `json:"users"`
+	Page    int           `json:"page"`
+	PerPage int           `json:"per_page"`
+	Total   int           `json:"total"`
+}
+
+// UserChanges contém as alterações solicitadas no cadastro de um usuário (campos nil não são alterados)
+type UserChanges struct {
+	Name  *string
+	Email *string
+	Roles *[]string
+}
+
+// ListUsers lista os usuários com paginação e busca.
+//
+// Parâmetros:
+// - search: Busca parcial por nome, username ou e-mail.
+// - active: Filtra por contas ativas (true) ou desativadas (false); nil lista todas.
+// - page: A página solicitada (a partir de 1).
+// - perPage: A quantidade de usuários por página (padrão 20, máximo 100).
+//
+// Retorno:
+// - *UserListResult: A página de usuários e o total de registros.
+// - error: Retorna erro se a consulta falhar.
+func ListUsers(search string, active *bool, page, perPage int) (*UserListResult, error) {
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = defaultUsersPerPage
+	}
+	if perPage > maxUsersPerPage {
+		perPage = maxUsersPerPage
+	}
+
+	users, total, err := models.ListUsers(models.UserFilter{
+		Search: strings.TrimSpace(search),
+		Active: active,
+		Limit:  perPage,
+		Offset: (page - 1) * perPage,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserListResult{Users: users, Page: page, PerPage: perPage, Total: total}, nil
+}
+
+// UpdateUser valida e aplica as alterações no cadastro de um usuário.
+//
+// Parâmetros:
+// - id: O ID do usuário.
+// - changes: As alterações solicitadas.
+// - actorID: O ID do administrador que executou a ação.
+// - ip: O IP de origem da requisição.
+//
+// Retorno:
+// - *models.User: O usuário atualizado.
+// - error: models.ErrUserNotFound, ErrInvalidName, ErrInvalidEmail, ErrEmailInUse, ErrRoleGrantForbidden,
+// models.ErrUnknownRole ou erro interno.
+//
+// Detalhes:
+// - Os campos e os papéis são gravados juntos: se algum deles falhar, nenhuma alteração é feita.
+// - Ao alterar o e-mail, a verificação é desfeita e o link de verificação é enviado ao novo endereço.
+func UpdateUser(id int, changes UserChanges, actorID int, ip string) (*models.User, error) {
+	user, err := models.GetUserByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var update models.UserUpdate
+	var changed []string
+
+	if changes.Name != nil {
+		name := strings.TrimSpace(*changes.Name)
+		if name == "" || len(name) > 255 {
+			return nil, ErrInvalidName
+		}
+		if name != user.Name {
+			update.Name = &name
+			changed = append(changed, "name")
+		}
+	}
+
+	if changes.Email != nil {
+		email, err := normalizeEmail(*changes.Email)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.EqualFold(email, user.Email) {
+			inUse, err := models.IsEmailInUse(email, id)
+			if err != nil {
+				return nil, err
+			}
+			if inUse {
+				return nil, ErrEmailInUse
+			}
+			update.Email = &email
+			changed = append(changed, "email")
+		}
+	}
+
+	// Os papéis são validados antes de qualquer alteração e gravados na mesma transação dos demais campos
+	if changes.Roles != nil {
+		current, err := models.GetUserRoles(id)
+		if err != nil {
+			return nil, err
+		}
+		if err := CheckRoleGrant(actorID, newRoles(*changes.Roles, current)); err != nil {
+			return nil, err
+		}
+		update.Roles = changes.Roles
+	}
+
+	if err := models.UpdateUser(id, update); err != nil {
+		return nil, err
+	}
+	if update.Roles != nil {
+		rolesChanged(id, *update.Roles, actorID, ip)
+	}
+
+	// O novo e-mail fica pendente de verificação
+	if update.Email != nil {
+		updated, err := models.GetUserByID(id)
+		if err == nil {
+			_, err = SendVerificationEmail(*updated)
+		}
+		if err != nil {
+			logger.Error("Erro ao enviar e-mail de verificação ao usuário ID=%d: %v", id, err)
+		}
+	}
+
+	if len(changed) > 0 {
+		_ = models.RecordAuditEvent(models.AuditEvent{
+			EventType: models.AuditUserUpdated,
+			UserID:    id,
+			Username:  user.Username,
+			ActorID:   actorID,
+			IP:        ip,
+			Details:   "campos=" + strings.Join(changed, ","),
+		})
+	}
+
+	return models.GetUserByID(id)
+}
+
+// SetUserActive desativa ou reativa a conta de um usuário.
+//
+// Parâmetros:
+// - id: O ID do usuário.
+// - active: O novo estado da conta.
+// - actorID: O ID do administrador que executou a ação.
+// - ip: O IP de origem da requisição.
+//
+// Retorno:
+// - error: models.ErrUserNotFound, ErrSelfManagement (o administrador não pode desativar a si mesmo) ou erro interno.
+//
+// Detalhes:
+// - Ao desativar, todos os tokens e sessões do usuário são revogados imediatamente.
+func SetUserActive(id int, active bool, actorID int, ip string) error {
+	if !active && id == actorID {
+		return ErrSelfManagement
+	}
+
+	user, err := models.GetUserByID(id)
+	if err != nil {
+		return err
+	}
+
+	changed, err := models.SetUserActive(id, active)
+	if err != nil || !changed {
+		return err
+	}
+
+	eventType := models.AuditUserReactivated
+	if !active {
+		eventType = models.AuditUserDeactivated
+		if err := RevokeAllUserTokens(id, actorID, ip, "account_deactivated"); err != nil {
+			return err
+		}
+	}
+
+	logger.Info("Conta do usuário ID=%d alterada: %s", id, eventType)
+	_ = models.RecordAuditEvent(models.AuditEvent{
+		EventType: eventType,
+		UserID:    id,
+		Username:  user.Username,
+		ActorID:   actorID,
+		IP:        ip,
+	})
+	return nil
+}
+
+// DeleteUser exclui um usuário e os seus dados de autenticação.
+//
+// Parâmetros:
+// - id: O ID do usuário.
+// - actorID: O ID do administrador que executou a ação.
+// - ip: O IP de origem da requisição.
+//
+// Retorno:
+// - error: models.ErrUserNotFound, ErrSelfManagement (o administrador não pode excluir a si mesmo) ou erro interno.
+//
+// Detalhes:
+// - Tokens de acesso ainda não expirados deixam de ser aceitos, pois o usuário não existe mais.
+func DeleteUser(id, actorID int, ip string) error {
+	if id == actorID {
+		return ErrSelfManagement
+	}
+
+	user, err := models.GetUserByID(id)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := models.DeleteUser(id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return models.ErrUserNotFound
+	}
+
+	tokenVersionCache.Delete(id)
+	userPermissionsCache.Delete(id)
+
+	logger.Info("Usuário ID=%d (%s) excluído", id, user.Username)
+	_ = models.RecordAuditEvent(models.AuditEvent{
+		EventType: models.AuditUserDeleted,
+		Username:  user.Username,
+		ActorID:   actorID,
+		IP:        ip,
+		Details:   fmt.Sprintf("user_id=%d; email=%s", id, user.Email),
+	})
+	return nil
+}
+
+// normalizeEmail valida o formato do e-mail e remove espaços nas extremidades.
+func normalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email || len(email) > 255 {
+		return "", ErrInvalidEmail
+	}
+	return email, nil
+}
@@ -0,0 +1,533 @@
+// pwd: /app/server/modules/login/services/webauthn_service.go
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"api/logger"
+	"api/server/modules/login/auth_utils"
+	"api/server/modules/login/models"
+	"api/utils"
+	"api/utils/cache"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// Erros das passkeys (WebAuthn)
+var (
+	ErrWebAuthnUnavailable = errors.New("webauthn não configurado")
+	ErrInvalidCeremony     = errors.New("cerimônia webauthn inválida ou expirada")
+	ErrPasskeyRejected     = errors.New("passkey recusada")
+	ErrPasskeyNotFound     = errors.New("passkey não encontrada")
+	ErrInvalidPasskeyName  = errors.New("nome de passkey inválido")
+
+	// errPasskeyCloned indica que o contador de assinaturas não avançou (possível clonagem do autenticador)
+	errPasskeyCloned = errors.New("contador de assinaturas da passkey inválido")
+)
+
+// Tipos de cerimônia WebAuthn
+const (
+	ceremonyRegistration = "registration"
+	ceremonyLogin        = "login"
+)
+
+// maxPasskeyNameLength é o tamanho máximo do nome dado a uma passkey (coluna name)
+const maxPasskeyNameLength = 100
+
+var (
+	webAuthnCeremonyTTL = utils.GetEnvDuration("WEBAUTHN_CEREMONY_TTL", 5*time.Minute)
+
+	// Cerimônias em andamento, indexadas pelo ID devolvido ao cliente. Cada cerimônia só pode ser concluída uma vez.
+	webAuthnCeremonies = cache.New[string, webAuthnCeremony](webAuthnCeremonyTTL)
+
+	webAuthnMu       sync.Mutex
+	webAuthnInstance *webauthn.WebAuthn
+)
+
+// webAuthnCeremony guarda os dados de uma cerimônia entre as etapas begin e finish
+type webAuthnCeremony struct {
+	kind    string
+	userID  int // Usuário do registro (vazio no login, em que o usuário é identificado pela passkey)
+	device  string
+	session webauthn.SessionData
+}
+
+// webAuthnUser adapta um usuário e as suas credenciais à interface webauthn.User
+type webAuthnUser struct {
+	user        models.User
+	credentials []webauthn.Credential
+}
+
+// WebAuthnID retorna o identificador (user handle) gravado na passkey: o ID do usuário em texto.
+func (u *webAuthnUser) WebAuthnID() []byte {
+	return []byte(strconv.Itoa(u.user.ID))
+}
+
+// WebAuthnName retorna o nome de usuário exibido pelo autenticador.
+func (u *webAuthnUser) WebAuthnName() string {
+	return u.user.Username
+}
+
+// WebAuthnDisplayName retorna o nome completo exibido pelo autenticador.
+func (u *webAuthnUser) WebAuthnDisplayName() string {
+	if u.user.Name != "" {
+		return u.user.Name
+	}
+	return u.user.Username
+}
+
+// WebAuthnCredentials retorna as credenciais já registradas pelo usuário.
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
+
+// SetWebAuthn substitui a configuração WebAuthn lida do ambiente.
+//
+// Permite usar outro Relying Party (ex: um autenticador de software em testes, com RPID e origens próprios).
+//
+// Parâmetros:
+// - w: A instância configurada com webauthn.New.
+func SetWebAuthn(w *webauthn.WebAuthn) {
+	webAuthnMu.Lock()
+	webAuthnInstance = w
+	webAuthnMu.Unlock()
+}
+
+// getWebAuthn retorna a configuração WebAuthn, criada na primeira chamada a partir do ambiente.
+//
+// Variáveis:
+// - WEBAUTHN_RP_ID: Domínio do Relying Party (padrão: DOMAIN_NAME). Obrigatório.
+// - WEBAUTHN_RP_ORIGINS: Origens aceitas, separadas por vírgula (padrão: APP_BASE_URL ou https://<WEBAUTHN_RP_ID>).
+// - WEBAUTHN_RP_NAME: Nome exibido pelo autenticador (padrão: o mesmo emissor do 2FA).
+func getWebAuthn() (*webauthn.WebAuthn, error) {
+	webAuthnMu.Lock()
+	defer webAuthnMu.Unlock()
+
+	if webAuthnInstance != nil {
+		return webAuthnInstance, nil
+	}
+
+	rpID := utils.GetEnv("WEBAUTHN_RP_ID")
+	if rpID == "" {
+		rpID = utils.GetEnv("DOMAIN_NAME")
+	}
+	if rpID == "" {
+		logger.Error("WebAuthn indisponível: WEBAUTHN_RP_ID não configurado")
+		return nil, ErrWebAuthnUnavailable
+	}
+
+	origins := utils.GetEnvList("WEBAUTHN_RP_ORIGINS")
+	if len(origins) == 0 {
+		if baseURL := strings.TrimRight(utils.GetEnv("APP_BASE_URL"), "/"); baseURL != "" {
+			origins = []string{baseURL}
+		} else {
+			origins = []string{"https://" + rpID}
+		}
+	}
+
+	rpName := utils.GetEnv("WEBAUTHN_RP_NAME")
+	if rpName == "" {
+		rpName = issuerName()
+	}
+
+	w, err := newWebAuthn(rpID, rpName, origins)
+	if err != nil {
+		logger.Error("WebAuthn indisponível: configuração inválida: %v", err)
+		return nil, ErrWebAuthnUnavailable
+	}
+
+	webAuthnInstance = w
+	return w, nil
+}
+
+// newWebAuthn cria a configuração WebAuthn de um Relying Party.
+//
+// Parâmetros:
+// - rpID: Domínio do Relying Party.
+// - rpName: Nome exibido pelo autenticador.
+// - origins: Origens aceitas nas respostas do autenticador.
+//
+// Retorno:
+// - *webauthn.WebAuthn: A configuração, que exige passkeys detectáveis e a verificação do usuário.
+// - error: Retorna erro se a configuração for inválida.
+func newWebAuthn(rpID, rpName string, origins []string) (*webauthn.WebAuthn, error) {
+	return webauthn.New(&webauthn.Config{
+		RPID:          rpID,
+		RPDisplayName: rpName,
+		RPOrigins:     origins,
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			RequireResidentKey: protocol.ResidentKeyRequired(),
+			ResidentKey:        protocol.ResidentKeyRequirementRequired,
+			UserVerification:   protocol.VerificationRequired,
+		},
+		Timeouts: webauthn.TimeoutsConfig{
+			Login:        webauthn.TimeoutConfig{Enforce: true, Timeout: webAuthnCeremonyTTL},
+			Registration: webauthn.TimeoutConfig{Enforce: true, Timeout: webAuthnCeremonyTTL},
+		},
+	})
+}
+
+// BeginPasskeyRegistration inicia o registro de uma nova passkey para o usuário autenticado.
+//
+// Parâmetros:
+// - userID: O ID do usuário autenticado.
+// - password: A senha atual, exigida para vincular um novo autenticador à conta.
+// - ip: O IP de origem da requisição.
+//
+// Retorno:
+// - string: O ID da cerimônia, a ser devolvido em FinishPasskeyRegistration.
+// - *protocol.CredentialCreation: As opções a repassar a navigator.credentials.create().
+// - error: ErrWebAuthnUnavailable, ErrTooManyAttempts, ErrWrongPassword ou erro interno.
+//
+// Detalhes:
+// - As passkeys já registradas são excluídas, para que o mesmo autenticador não seja registrado duas vezes.
+func BeginPasskeyRegistration(userID int, password, ip string) (string, *protocol.CredentialCreation, error) {
+	w, err := getWebAuthn()
+	if err != nil {
+		return "", nil, err
+	}
+
+	user, err := loadWebAuthnUser(userID)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := verifyCurrentPassword(user.user, password, ip); err != nil {
+		return "", nil, err
+	}
+
+	return startPasskeyRegistration(w, user)
+}
+
+// startPasskeyRegistration gera as opções de registro e guarda a cerimônia do usuário.
+func startPasskeyRegistration(w *webauthn.WebAuthn, user *webAuthnUser) (string, *protocol.CredentialCreation, error) {
+	exclusions := webauthn.Credentials(user.credentials).CredentialDescriptors()
+	creation, session, err := w.BeginRegistration(user, webauthn.WithExclusions(exclusions))
+	if err != nil {
+		logger.Error("Erro ao iniciar o registro de passkey: %v", err)
+		return "", nil, errors.New("erro ao iniciar o registro de passkey")
+	}
+
+	ceremonyID := auth_utils.NewTokenID()
+	webAuthnCeremonies.Set(ceremonyID, webAuthnCeremony{kind: ceremonyRegistration, userID: user.user.ID, session: *session})
+	return ceremonyID, creation, nil
+}
+
+// FinishPasskeyRegistration valida a resposta do autenticador e grava a nova passkey.
+//
+// Parâmetros:
+// - userID: O ID do usuário autenticado (o mesmo que iniciou a cerimônia).
+// - ceremonyID: O ID devolvido por BeginPasskeyRegistration.
+// - name: Nome dado à passkey (opcional).
+// - response: O JSON devolvido por navigator.credentials.create().
+// - ip: O IP de origem da requisição.
+//
+// Retorno:
+// - *models.WebAuthnCredential: A passkey registrada.
+// - error: ErrInvalidPasskeyName, ErrInvalidCeremony, ErrPasskeyRejected ou erro interno.
+func FinishPasskeyRegistration(userID int, ceremonyID, name string, response []byte, ip string) (*models.WebAuthnCredential, error) {
+	name = strings.TrimSpace(name)
+	if utf8.RuneCountInString(name) > maxPasskeyNameLength {
+		return nil, ErrInvalidPasskeyName
+	}
+
+	w, err := getWebAuthn()
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := loadWebAuthnUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	credential, err := completePasskeyRegistration(w, user, ceremonyID, response)
+	if err != nil {
+		return nil, err
+	}
+
+	if name == "" {
+		name = fmt.Sprintf("Passkey %d", len(user.credentials)+1)
+	}
+	stored := fromWebAuthnCredential(userID, *credential)
+	stored.Name = name
+	if stored.ID, err = models.CreateWebAuthnCredential(stored, auth_utils.HashToken(string(credential.ID))); err != nil {
+		return nil, err
+	}
+	stored.CreatedAt = time.Now()
+
+	recordPasskeyEvent(models.AuditPasskeyAdded, user.user, ip, fmt.Sprintf("passkey_id=%d; nome=%s", stored.ID, name))
+	return &stored, nil
+}
+
+// completePasskeyRegistration consome a cerimônia de registro do usuário e valida a resposta do autenticador.
+//
+// Retorno:
+// - *webauthn.Credential: A credencial validada, ainda não gravada.
+// - error: ErrInvalidCeremony ou ErrPasskeyRejected.
+func completePasskeyRegistration(w *webauthn.WebAuthn, user *webAuthnUser, ceremonyID string, response []byte) (*webauthn.Credential, error) {
+	ceremony, ok := webAuthnCeremonies.Take(ceremonyID)
+	if !ok || ceremony.kind != ceremonyRegistration || ceremony.userID != user.user.ID {
+		return nil, ErrInvalidCeremony
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(response)
+	if err != nil {
+		logger.Warn("Resposta de registro de passkey inválida (usuário ID=%d): %v", user.user.ID, err)
+		return nil, ErrPasskeyRejected
+	}
+	credential, err := w.CreateCredential(user, ceremony.session, parsed)
+	if err != nil {
+		logger.Warn("Registro de passkey recusado (usuário ID=%d): %v", user.user.ID, err)
+		return nil, ErrPasskeyRejected
+	}
+	return credential, nil
+}
+
+// ListPasskeys lista as passkeys do usuário.
+//
+// Parâmetros:
+// - userID: O ID do usuário.
+//
+// Retorno:
+// - []models.WebAuthnCredential: As passkeys registradas.
+// - error: Retorna erro se a consulta falhar.
+func ListPasskeys(userID int) ([]models.WebAuthnCredential, error) {
+	return models.ListWebAuthnCredentials(userID)
+}
+
+// DeletePasskey remove uma passkey do usuário.
+//
+// Parâmetros:
+// - userID: O ID do usuário autenticado.
+// - passkeyID: O ID da passkey.
+// - ip: O IP de origem da requisição.
+//
+// Retorno:
+// - error: ErrPasskeyNotFound se a passkey não existir ou pertencer a outro usuário, ou erro interno.
+func DeletePasskey(userID int, passkeyID int64, ip string) error {
+	user, err := models.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := models.DeleteWebAuthnCredential(userID, passkeyID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrPasskeyNotFound
+	}
+
+	recordPasskeyEvent(models.AuditPasskeyRemoved, *user, ip, fmt.Sprintf("passkey_id=%d", passkeyID))
+	return nil
+}
+
+// BeginPasskeyLogin inicia um login por passkey.
+//
+// O usuário não é informado: ele é identificado pela passkey escolhida no autenticador (credencial detectável).
+//
+// Parâmetros:
+// - device: O nome do dispositivo, repassado à sessão criada em FinishPasskeyLogin.
+//
+// Retorno:
+// - string: O ID da cerimônia, a ser devolvido em FinishPasskeyLogin.
+// - *protocol.CredentialAssertion: As opções a repassar a navigator.credentials.get().
+// - error: ErrWebAuthnUnavailable ou erro interno.
+func BeginPasskeyLogin(device string) (string, *protocol.CredentialAssertion, error) {
+	w, err := getWebAuthn()
+	if err != nil {
+		return "", nil, err
+	}
+
+	assertion, session, err := w.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+	if err != nil {
+		logger.Error("Erro ao iniciar o login por passkey: %v", err)
+		return "", nil, errors.New("erro ao iniciar o login por passkey")
+	}
+
+	ceremonyID := auth_utils.NewTokenID()
+	webAuthnCeremonies.Set(ceremonyID, webAuthnCeremony{kind: ceremonyLogin, device: device, session: *session})
+	return ceremonyID, assertion, nil
+}
+
+// FinishPasskeyLogin valida a assinatura da passkey e retorna o usuário autenticado.
+//
+// Parâmetros:
+// - ceremonyID: O ID devolvido por BeginPasskeyLogin.
+// - response: O JSON devolvido por navigator.credentials.get().
+// - ip: O IP de origem da requisição.
+//
+// Retorno:
+// - *models.User: O usuário autenticado.
+// - string: O nome do dispositivo informado em BeginPasskeyLogin.
+// - error: ErrInvalidCeremony, ErrPasskeyRejected, ErrEmailNotVerified ou erro interno.
+//
+// Detalhes:
+// - A verificação do usuário (biometria ou PIN) é exigida, por isso a passkey dispensa o desafio TOTP.
+// - O contador de assinaturas é gravado a cada login. Se ele não avançar (possível clonagem do autenticador),
+// o login é recusado e a passkey fica marcada com clone_warning, deixando de ser aceita até ser removida.
+func FinishPasskeyLogin(ceremonyID string, response []byte, ip string) (*models.User, string, error) {
+	w, err := getWebAuthn()
+	if err != nil {
+		return nil, "", err
+	}
+
+	owner, credential, device, err := completePasskeyLogin(w, ceremonyID, response, ip, loadWebAuthnUser)
+	if credential != nil {
+		// O contador é gravado também quando o login é recusado por clonagem, para manter a passkey marcada
+		credentialHash := auth_utils.HashToken(string(credential.ID))
+		if err := models.UpdateWebAuthnCredentialUsage(credentialHash, credential.Authenticator.SignCount,
+			uint8(credential.Flags.ProtocolValue()), credential.Authenticator.CloneWarning); err != nil {
+			return nil, "", err
+		}
+	}
+	if errors.Is(err, errPasskeyCloned) {
+		logger.Warn("Passkey com contador de assinaturas inválido (possível clonagem) para o usuário %s", owner.user.Username)
+		recordPasskeyEvent(models.AuditPasskeyCloneWarning, owner.user, ip, fmt.Sprintf("sign_count=%d", credential.Authenticator.SignCount))
+		return nil, "", ErrPasskeyRejected
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	user := owner.user
+
+	if !user.Active {
+		logger.Warn("Login por passkey recusado para o usuário desativado %s", user.Username)
+		return nil, "", ErrPasskeyRejected
+	}
+	if err := CheckEmailVerifiedForLogin(user); err != nil {
+		return nil, "", err
+	}
+
+	RegisterLoginSuccess(user.Username)
+	return &user, device, nil
+}
+
+// completePasskeyLogin consome a cerimônia de login e valida a assinatura da passkey.
+//
+// Parâmetros:
+// - w: A configuração WebAuthn.
+// - ceremonyID: O ID devolvido por BeginPasskeyLogin.
+// - response: O JSON devolvido por navigator.credentials.get().
+// - ip: O IP de origem da requisição.
+// - loadUser: Carrega o dono da passkey a partir do user handle (o ID do usuário).
+//
+// Retorno:
+// - *webAuthnUser: O dono da passkey.
+// - *webauthn.Credential: A credencial com o contador de assinaturas atualizado.
+// - string: O nome do dispositivo informado em BeginPasskeyLogin.
+// - error: ErrInvalidCeremony, ErrPasskeyRejected ou errPasskeyCloned.
+//
+// Detalhes:
+// - Com errPasskeyCloned, o dono e a credencial também são retornados, para que a marcação seja gravada.
+func completePasskeyLogin(w *webauthn.WebAuthn, ceremonyID string, response []byte, ip string,
+	loadUser func(userID int) (*webAuthnUser, error)) (*webAuthnUser, *webauthn.Credential, string, error) {
+	ceremony, ok := webAuthnCeremonies.Take(ceremonyID)
+	if !ok || ceremony.kind != ceremonyLogin {
+		return nil, nil, "", ErrInvalidCeremony
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		logger.Warn("Resposta de login por passkey inválida: %v", err)
+		return nil, nil, "", ErrPasskeyRejected
+	}
+
+	handler := func(rawID, userHandle []byte) (webauthn.User, error) {
+		userID, err := strconv.Atoi(string(userHandle))
+		if err != nil {
+			return nil, ErrPasskeyRejected
+		}
+		return loadUser(userID)
+	}
+	owner, credential, err := w.ValidatePasskeyLogin(handler, ceremony.session, parsed)
+	if err != nil {
+		logger.Warn("Login por passkey recusado (IP %s): %v", ip, err)
+		return nil, nil, "", ErrPasskeyRejected
+	}
+
+	user := owner.(*webAuthnUser)
+	if credential.Authenticator.CloneWarning {
+		return user, credential, ceremony.device, errPasskeyCloned
+	}
+	return user, credential, ceremony.device, nil
+}
+
+// loadWebAuthnUser carrega o usuário e as suas passkeys no formato da biblioteca WebAuthn.
+func loadWebAuthnUser(userID int) (*webAuthnUser, error) {
+	user, err := models.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := models.ListWebAuthnCredentials(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials := make([]webauthn.Credential, 0, len(stored))
+	for _, credential := range stored {
+		credentials = append(credentials, toWebAuthnCredential(credential))
+	}
+	return &webAuthnUser{user: *user, credentials: credentials}, nil
+}
+
+// toWebAuthnCredential converte uma passkey gravada para o formato da biblioteca WebAuthn.
+func toWebAuthnCredential(stored models.WebAuthnCredential) webauthn.Credential {
+	transports := make([]protocol.AuthenticatorTransport, 0, len(stored.Transports))
+	for _, transport := range stored.Transports {
+		transports = append(transports, protocol.AuthenticatorTransport(transport))
+	}
+
+	return webauthn.Credential{
+		ID:                stored.CredentialID,
+		PublicKey:         stored.PublicKey,
+		AttestationType:   stored.AttestationType,
+		AttestationFormat: stored.AttestationFormat,
+		Transport:         transports,
+		Flags:             webauthn.NewCredentialFlags(protocol.AuthenticatorFlags(stored.Flags)),
+		Authenticator: webauthn.Authenticator{
+			AAGUID:       stored.AAGUID,
+			SignCount:    stored.SignCount,
+			CloneWarning: stored.CloneWarning,
+		},
+	}
+}
+
+// fromWebAuthnCredential converte uma credencial recém-registrada para o formato gravado no banco.
+func fromWebAuthnCredential(userID int, credential webauthn.Credential) models.WebAuthnCredential {
+	transports := make([]string, 0, len(credential.Transport))
+	for _, transport := range credential.Transport {
+		transports = append(transports, string(transport))
+	}
+
+	return models.WebAuthnCredential{
+		UserID:            userID,
+		CredentialID:      credential.ID,
+		PublicKey:         credential.PublicKey,
+		AttestationType:   credential.AttestationType,
+		AttestationFormat: credential.AttestationFormat,
+		Transports:        transports,
+		AAGUID:            credential.Authenticator.AAGUID,
+		Flags:             uint8(credential.Flags.ProtocolValue()),
+		SignCount:         credential.Authenticator.SignCount,
+	}
+}
+
+// recordPasskeyEvent registra um evento de passkey na auditoria.
+func recordPasskeyEvent(eventType string, user models.User, ip, details string) {
+	_ = models.RecordAuditEvent(models.AuditEvent{
+		EventType: eventType,
+		UserID:    user.ID,
+		Username:  user.Username,
+		ActorID:   user.ID,
+		IP:        ip,
+		Details:   details,
+	})
+}
@@ -0,0 +1,368 @@
+// pwd: /app/server/modules/login/services/webauthn_service_test.go
+
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"api/server/modules/login/models"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+const (
+	testRPID   = "example.org"
+	testOrigin = "https://example.org"
+)
+
+// softAuthenticator é um autenticador de software: uma chave ES256 com atestação "none" e verificação do usuário.
+type softAuthenticator struct {
+	t            *testing.T
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("erro ao gerar a chave: %v", err)
+	}
+	credentialID := make([]byte, 32)
+	if _, err := rand.Read(credentialID); err != nil {
+		t.Fatalf("erro ao gerar o ID da credencial: %v", err)
+	}
+	return &softAuthenticator{t: t, key: key, credentialID: credentialID}
+}
+
+// register responde às opções de navigator.credentials.create().
+func (a *softAuthenticator) register(creation *protocol.CredentialCreation, userHandle []byte) []byte {
+	a.t.Helper()
+
+	if creation.Response.RelyingParty.ID != testRPID {
+		a.t.Fatalf("RPID = %q, esperado %q", creation.Response.RelyingParty.ID, testRPID)
+	}
+	if creation.Response.AuthenticatorSelection.UserVerification != protocol.VerificationRequired {
+		a.t.Fatalf("o registro deve exigir a verificação do usuário")
+	}
+	a.userHandle = userHandle
+
+	publicKey, err := webauthncbor.Marshal(map[int64]any{
+		1:  int64(webauthncose.EllipticKey),
+		3:  int64(webauthncose.AlgES256),
+		-1: int64(webauthncose.P256),
+		-2: a.key.PublicKey.X.FillBytes(make([]byte, 32)),
+		-3: a.key.PublicKey.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		a.t.Fatalf("erro ao codificar a chave pública: %v", err)
+	}
+
+	attested := make([]byte, 16) // AAGUID zerado
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(a.credentialID)))
+	attested = append(attested, a.credentialID...)
+	attested = append(attested, publicKey...)
+
+	authData := a.authenticatorData(protocol.FlagUserPresent|protocol.FlagUserVerified|protocol.FlagAttestedCredentialData, 0, attested)
+	clientData := a.clientData("webauthn.create", creation.Response.Challenge.String())
+
+	attestation, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": authData,
+	})
+	if err != nil {
+		a.t.Fatalf("erro ao codificar a atestação: %v", err)
+	}
+
+	return a.body(map[string]any{
+		"attestationObject": encode(attestation),
+		"clientDataJSON":    encode(clientData),
+	})
+}
+
+// login responde às opções de navigator.credentials.get(), informando o contador de assinaturas.
+func (a *softAuthenticator) login(assertion *protocol.CredentialAssertion, signCount uint32) []byte {
+	a.t.Helper()
+
+	authData := a.authenticatorData(protocol.FlagUserPresent|protocol.FlagUserVerified, signCount, nil)
+	clientData := a.clientData("webauthn.get", assertion.Response.Challenge.String())
+
+	return a.body(map[string]any{
+		"authenticatorData": encode(authData),
+		"clientDataJSON":    encode(clientData),
+		"signature":         encode(a.sign(authData, clientData)),
+		"userHandle":        encode(a.userHandle),
+	})
+}
+
+func (a *softAuthenticator) authenticatorData(flags protocol.AuthenticatorFlags, signCount uint32, attested []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(testRPID))
+
+	data := append([]byte{}, rpIDHash[:]...)
+	data = append(data, byte(flags))
+	data = binary.BigEndian.AppendUint32(data, signCount)
+	return append(data, attested...)
+}
+
+func (a *softAuthenticator) clientData(ceremony, challenge string) []byte {
+	data, err := json.Marshal(map[string]any{
+		"type":        ceremony,
+		"challenge":   challenge,
+		"origin":      testOrigin,
+		"crossOrigin": false,
+	})
+	if err != nil {
+		a.t.Fatalf("erro ao codificar o clientDataJSON: %v", err)
+	}
+	return data
+}
+
+func (a *softAuthenticator) sign(authData, clientData []byte) []byte {
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		a.t.Fatalf("erro ao assinar: %v", err)
+	}
+	return signature
+}
+
+func (a *softAuthenticator) body(response map[string]any) []byte {
+	id := encode(a.credentialID)
+	data, err := json.Marshal(map[string]any{
+		"id":       id,
+		"rawId":    id,
+		"type":     "public-key",
+		"response": response,
+	})
+	if err != nil {
+		a.t.Fatalf("erro ao codificar a resposta: %v", err)
+	}
+	return data
+}
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// setupTestWebAuthn troca a configuração WebAuthn pelo Relying Party de teste.
+func setupTestWebAuthn(t *testing.T) *webauthn.WebAuthn {
+	t.Helper()
+
+	w, err := newWebAuthn(testRPID, "Teste", []string{testOrigin})
+	if err != nil {
+		t.Fatalf("erro ao configurar o WebAuthn: %v", err)
+	}
+	SetWebAuthn(w)
+	t.Cleanup(func() { SetWebAuthn(nil) })
+
+	w, err = getWebAuthn()
+	if err != nil {
+		t.Fatalf("getWebAuthn() = %v", err)
+	}
+	return w
+}
+
+// passkeyStore simula as passkeys gravadas no banco, convertendo-as como loadWebAuthnUser
+type passkeyStore struct {
+	user    models.User
+	stored  []models.WebAuthnCredential
+	lookups int
+}
+
+func (s *passkeyStore) load(userID int) (*webAuthnUser, error) {
+	s.lookups++
+	if userID != s.user.ID {
+		return nil, errors.New("usuário não encontrado")
+	}
+	credentials := make([]webauthn.Credential, 0, len(s.stored))
+	for _, credential := range s.stored {
+		credentials = append(credentials, toWebAuthnCredential(credential))
+	}
+	return &webAuthnUser{user: s.user, credentials: credentials}, nil
+}
+
+// update grava o uso da passkey, como models.UpdateWebAuthnCredentialUsage
+func (s *passkeyStore) update(credential *webauthn.Credential) {
+	for i := range s.stored {
+		if string(s.stored[i].CredentialID) == string(credential.ID) {
+			s.stored[i].SignCount = credential.Authenticator.SignCount
+			s.stored[i].Flags = uint8(credential.Flags.ProtocolValue())
+			s.stored[i].CloneWarning = credential.Authenticator.CloneWarning
+		}
+	}
+}
+
+// registerPasskey executa o registro de uma passkey pelo autenticador e a grava no store.
+func registerPasskey(t *testing.T, w *webauthn.WebAuthn, store *passkeyStore, authenticator *softAuthenticator) {
+	t.Helper()
+
+	user, _ := store.load(store.user.ID)
+	ceremonyID, creation, err := startPasskeyRegistration(w, user)
+	if err != nil {
+		t.Fatalf("startPasskeyRegistration() = %v", err)
+	}
+
+	response := authenticator.register(creation, user.WebAuthnID())
+	credential, err := completePasskeyRegistration(w, user, ceremonyID, response)
+	if err != nil {
+		t.Fatalf("completePasskeyRegistration() = %v", err)
+	}
+	if string(credential.ID) != string(authenticator.credentialID) {
+		t.Fatalf("ID da credencial diferente do gerado pelo autenticador")
+	}
+	store.stored = append(store.stored, fromWebAuthnCredential(store.user.ID, *credential))
+
+	// A cerimônia só pode ser concluída uma vez
+	if _, err := completePasskeyRegistration(w, user, ceremonyID, response); !errors.Is(err, ErrInvalidCeremony) {
+		t.Fatalf("repetição do registro = %v, esperado %v", err, ErrInvalidCeremony)
+	}
+}
+
+// loginPasskey executa um login por passkey com o contador informado e grava o uso no store.
+func loginPasskey(t *testing.T, store *passkeyStore, authenticator *softAuthenticator, signCount uint32) (*webAuthnUser, error) {
+	t.Helper()
+
+	ceremonyID, assertion, err := BeginPasskeyLogin("Notebook")
+	if err != nil {
+		t.Fatalf("BeginPasskeyLogin() = %v", err)
+	}
+	if len(assertion.Response.AllowedCredentials) != 0 {
+		t.Fatalf("o login por passkey não deve listar credenciais (credencial detectável)")
+	}
+
+	w, _ := getWebAuthn()
+	owner, credential, device, err := completePasskeyLogin(w, ceremonyID, authenticator.login(assertion, signCount), "127.0.0.1", store.load)
+	if credential != nil {
+		store.update(credential)
+	}
+	if err == nil && device != "Notebook" {
+		t.Errorf("dispositivo = %q, esperado %q", device, "Notebook")
+	}
+	return owner, err
+}
+
+func TestPasskeyRegistrationAndLogin(t *testing.T) {
+	w := setupTestWebAuthn(t)
+	store := &passkeyStore{user: models.User{ID: 42, Username: "alice", Name: "Alice"}}
+	authenticator := newSoftAuthenticator(t)
+
+	registerPasskey(t, w, store, authenticator)
+	if store.stored[0].SignCount != 0 || store.stored[0].CloneWarning {
+		t.Fatalf("passkey registrada = %+v", store.stored[0])
+	}
+
+	owner, err := loginPasskey(t, store, authenticator, 1)
+	if err != nil {
+		t.Fatalf("login = %v", err)
+	}
+	if owner.user.ID != 42 {
+		t.Errorf("usuário = %d, esperado 42", owner.user.ID)
+	}
+
+	// O contador de assinaturas avança a cada login
+	for _, signCount := range []uint32{2, 10} {
+		if _, err := loginPasskey(t, store, authenticator, signCount); err != nil {
+			t.Fatalf("login com sign_count=%d = %v", signCount, err)
+		}
+		if store.stored[0].SignCount != signCount {
+			t.Errorf("sign_count gravado = %d, esperado %d", store.stored[0].SignCount, signCount)
+		}
+	}
+}
+
+func TestPasskeyLoginCloneWarning(t *testing.T) {
+	w := setupTestWebAuthn(t)
+	store := &passkeyStore{user: models.User{ID: 7, Username: "bob"}}
+	authenticator := newSoftAuthenticator(t)
+	registerPasskey(t, w, store, authenticator)
+
+	if _, err := loginPasskey(t, store, authenticator, 5); err != nil {
+		t.Fatalf("login = %v", err)
+	}
+
+	// Contador repetido ou menor que o gravado: possível clonagem
+	for _, signCount := range []uint32{5, 3} {
+		owner, err := loginPasskey(t, store, authenticator, signCount)
+		if !errors.Is(err, errPasskeyCloned) {
+			t.Fatalf("login com sign_count=%d = %v, esperado %v", signCount, err, errPasskeyCloned)
+		}
+		if owner == nil || owner.user.ID != 7 {
+			t.Fatalf("o dono da passkey deve ser retornado para a auditoria")
+		}
+		if !store.stored[0].CloneWarning || store.stored[0].SignCount != 5 {
+			t.Fatalf("passkey gravada = %+v, esperado clone_warning com sign_count=5", store.stored[0])
+		}
+	}
+
+	// Uma passkey marcada continua recusada, mesmo com o contador voltando a avançar
+	if _, err := loginPasskey(t, store, authenticator, 100); !errors.Is(err, errPasskeyCloned) {
+		t.Fatalf("login com passkey marcada = %v, esperado %v", err, errPasskeyCloned)
+	}
+}
+
+func TestPasskeyLoginRejected(t *testing.T) {
+	w := setupTestWebAuthn(t)
+	store := &passkeyStore{user: models.User{ID: 9, Username: "carol"}}
+	authenticator := newSoftAuthenticator(t)
+	registerPasskey(t, w, store, authenticator)
+
+	t.Run("assinatura de outra chave", func(t *testing.T) {
+		forged := newSoftAuthenticator(t)
+		forged.credentialID = authenticator.credentialID
+		forged.userHandle = authenticator.userHandle
+
+		if _, err := loginPasskey(t, store, forged, 1); !errors.Is(err, ErrPasskeyRejected) {
+			t.Fatalf("login = %v, esperado %v", err, ErrPasskeyRejected)
+		}
+	})
+
+	t.Run("user handle de outro usuário", func(t *testing.T) {
+		impostor := *authenticator
+		impostor.userHandle = []byte("1")
+
+		if _, err := loginPasskey(t, store, &impostor, 1); !errors.Is(err, ErrPasskeyRejected) {
+			t.Fatalf("login = %v, esperado %v", err, ErrPasskeyRejected)
+		}
+	})
+
+	t.Run("cerimônia repetida", func(t *testing.T) {
+		ceremonyID, assertion, err := BeginPasskeyLogin("")
+		if err != nil {
+			t.Fatalf("BeginPasskeyLogin() = %v", err)
+		}
+		response := authenticator.login(assertion, 1)
+		if _, _, _, err := completePasskeyLogin(w, ceremonyID, response, "", store.load); err != nil {
+			t.Fatalf("login = %v", err)
+		}
+		if _, _, _, err := completePasskeyLogin(w, ceremonyID, response, "", store.load); !errors.Is(err, ErrInvalidCeremony) {
+			t.Fatalf("repetição do login = %v, esperado %v", err, ErrInvalidCeremony)
+		}
+	})
+
+	t.Run("cerimônia de registro usada no login", func(t *testing.T) {
+		user, _ := store.load(9)
+		ceremonyID, _, err := startPasskeyRegistration(w, user)
+		if err != nil {
+			t.Fatalf("startPasskeyRegistration() = %v", err)
+		}
+		if _, _, _, err := completePasskeyLogin(w, ceremonyID, nil, "", store.load); !errors.Is(err, ErrInvalidCeremony) {
+			t.Fatalf("login = %v, esperado %v", err, ErrInvalidCeremony)
+		}
+	})
+}
@@ -3,6 +3,7 @@ package ppr
 
 import (
 	"api/logger"
+	"api/server/modules/login/middleware"
 
 	"github.com/gin-gonic/gin"
 )
@@ -10,7 +11,8 @@ import (
 // RegisterRoutes registra as rotas do módulo de PPR (Plano de Participação nos Resultados).
 //
 // Esta função adiciona as rotas necessárias ao grupo "/ppr", incluindo o endpoint "/ping"
-// para verificação de funcionamento e o endpoint "/calculate" para o cálculo do PPR.
+// para verificação de funcionamento e o endpoint "/calculate" para o cálculo do PPR, que
+// requer um token JWT ou uma chave de API com a permissão "ppr:calculate".
 //
 // Parâmetros:
 //   - router (*gin.Engine): A instância do roteador Gin onde as rotas serão registradas.
@@ -23,8 +25,8 @@ func RegisterRoutes(router *gin.Engine) {
 			c.JSON(200, gin.H{"message": "pong PPR"})
 		})
 
-		// Rota para calcular o PPR
-		group.GET("/calculate", calculatePPRHandler)
+		// Rota para calcular o PPR (JWT ou chave de API com a permissão ppr:calculate)
+		group.GET("/calculate", middleware.AuthOrAPIKeyMiddleware(), middleware.RequirePermission("ppr:calculate"), calculatePPRHandler)
 	}
 
 	// Chama a função de verificação de saúde do módulo
@@ -0,0 +1,73 @@
+// pwd: /app/server/route_policies.go
+
+package server
+
+import (
+	"sort"
+	"strings"
+
+	"api/logger"
+	"api/utils"
+)
+
+// routeGroup associa um nome de configuração a um prefixo de rota (ex: "finance" → "/finance").
+//
+// Os grupos permitem sobrescrever políticas globais (CORS, cabeçalhos de segurança...) para
+// partes específicas da API, usando variáveis de ambiente com o nome do grupo no prefixo.
+type routeGroup struct {
+	name   string
+	prefix string
+}
+
+// loadRouteGroups lê a lista de grupos de rotas de uma variável de ambiente.
+//
+// O formato esperado é "nome=/prefixo" separado por vírgulas (ex: "finance=/finance,docs=/docs").
+// Os grupos são retornados do prefixo mais longo para o mais curto, para que o mais específico prevaleça.
+//
+// Parâmetros:
+//   - key (string): Nome da variável de ambiente.
+//
+// Retorna:
+//   - []routeGroup: Grupos configurados.
+func loadRouteGroups(key string) []routeGroup {
+	var groups []routeGroup
+
+	for _, item := range utils.GetEnvList(key) {
+		name, prefix, ok := strings.Cut(item, "=")
+		name = strings.TrimSpace(name)
+		prefix = strings.TrimSpace(prefix)
+		if !ok || name == "" || !strings.HasPrefix(prefix, "/") {
+			logger.Warn("Grupo de rotas inválido em %s: %q (formato esperado: nome=/prefixo)", key, item)
+			continue
+		}
+
+		groups = append(groups, routeGroup{name: name, prefix: strings.TrimSuffix(prefix, "/")})
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool { return len(groups[i].prefix) > len(groups[j].prefix) })
+	return groups
+}
+
+// envPrefix monta o prefixo das variáveis de ambiente do grupo (ex: "CORS" e grupo "finance" → "CORS_FINANCE").
+func (g routeGroup) envPrefix(prefix string) string {
+	name := strings.ToUpper(strings.NewReplacer("-", "_", ".", "_", " ", "_").Replace(g.name))
+	return prefix + "_" + name
+}
+
+// matches verifica se o caminho da requisição pertence ao grupo, respeitando os limites de segmento.
+func (g routeGroup) matches(path string) bool {
+	if g.prefix == "" {
+		return true
+	}
+	return path == g.prefix || strings.HasPrefix(path, g.prefix+"/")
+}
+
+// matchRouteGroup retorna o índice do grupo mais específico que atende ao caminho, ou -1.
+func matchRouteGroup(groups []routeGroup, path string) int {
+	for i, group := range groups {
+		if group.matches(path) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,173 @@
+// pwd: /app/server/security_headers.go
+
+package server
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"api/logger"
+	"api/utils"
+	"api/utils/netutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SecurityHeadersPolicy define os cabeçalhos de segurança enviados nas respostas.
+//
+// Um valor vazio desativa o cabeçalho correspondente.
+type SecurityHeadersPolicy struct {
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+	ContentTypeNosniff    bool
+	FrameOptions          string
+	FrameAncestors        string
+	ReferrerPolicy        string
+	PermissionsPolicy     string
+	ContentSecurityPolicy string
+}
+
+// defaultSecurityHeadersPolicy retorna a política padrão, restritiva, adequada para uma API JSON.
+func defaultSecurityHeadersPolicy() SecurityHeadersPolicy {
+	return SecurityHeadersPolicy{
+		HSTSMaxAge:            365 * 24 * time.Hour,
+		HSTSIncludeSubdomains: true,
+		ContentTypeNosniff:    true,
+		FrameOptions:          "DENY",
+		FrameAncestors:        "'none'",
+		ReferrerPolicy:        "no-referrer",
+		PermissionsPolicy:     "camera=(), microphone=(), geolocation=(), payment=(), usb=()",
+		ContentSecurityPolicy: "default-src 'none'",
+	}
+}
+
+// loadSecurityHeadersPolicy carrega uma política de cabeçalhos das variáveis de ambiente.
+//
+// Cada variável é lida como <prefix>_<NOME>; quando não definida, o valor da política base é mantido.
+// O valor "off" desativa o cabeçalho.
+//
+// Parâmetros:
+//   - prefix (string): Prefixo das variáveis (ex: "SECURITY_HEADERS" ou "SECURITY_HEADERS_DOCS").
+//   - base (SecurityHeadersPolicy): Política usada como valor padrão.
+//
+// Retorna:
+//   - SecurityHeadersPolicy: Política carregada.
+func loadSecurityHeadersPolicy(prefix string, base SecurityHeadersPolicy) SecurityHeadersPolicy {
+	policy := base
+
+	if value := utils.GetEnv(prefix + "_HSTS_MAX_AGE"); strings.EqualFold(value, "off") {
+		policy.HSTSMaxAge = 0
+	} else {
+		policy.HSTSMaxAge = utils.GetEnvDuration(prefix+"_HSTS_MAX_AGE", policy.HSTSMaxAge)
+	}
+	policy.HSTSIncludeSubdomains = utils.GetEnvBool(prefix+"_HSTS_INCLUDE_SUBDOMAINS", policy.HSTSIncludeSubdomains)
+	policy.HSTSPreload = utils.GetEnvBool(prefix+"_HSTS_PRELOAD", policy.HSTSPreload)
+	policy.ContentTypeNosniff = utils.GetEnvBool(prefix+"_NOSNIFF", policy.ContentTypeNosniff)
+	policy.FrameOptions = headerValue(prefix+"_FRAME_OPTIONS", policy.FrameOptions)
+	policy.FrameAncestors = headerValue(prefix+"_FRAME_ANCESTORS", policy.FrameAncestors)
+	policy.ReferrerPolicy = headerValue(prefix+"_REFERRER_POLICY", policy.ReferrerPolicy)
+	policy.PermissionsPolicy = headerValue(prefix+"_PERMISSIONS_POLICY", policy.PermissionsPolicy)
+	policy.ContentSecurityPolicy = headerValue(prefix+"_CSP", policy.ContentSecurityPolicy)
+
+	return policy
+}
+
+// headers calcula os cabeçalhos da política, uma única vez, para reaproveitamento em cada requisição.
+//
+// Retorna:
+//   - map[string]string: Cabeçalhos aplicados em qualquer requisição.
+//   - string: Valor do Strict-Transport-Security (vazio se desativado), aplicado apenas sob TLS.
+func (p SecurityHeadersPolicy) headers() (map[string]string, string) {
+	headers := map[string]string{}
+
+	if p.ContentTypeNosniff {
+		headers["X-Content-Type-Options"] = "nosniff"
+	}
+	if p.FrameOptions != "" {
+		headers["X-Frame-Options"] = p.FrameOptions
+	}
+	if p.ReferrerPolicy != "" {
+		headers["Referrer-Policy"] = p.ReferrerPolicy
+	}
+	if p.PermissionsPolicy != "" {
+		headers["Permissions-Policy"] = p.PermissionsPolicy
+	}
+
+	csp := p.ContentSecurityPolicy
+	if p.FrameAncestors != "" && !strings.Contains(csp, "frame-ancestors") {
+		if csp != "" {
+			csp += "; "
+		}
+		csp += "frame-ancestors " + p.FrameAncestors
+	}
+	if csp != "" {
+		headers["Content-Security-Policy"] = csp
+	}
+
+	hsts := ""
+	if p.HSTSMaxAge > 0 {
+		hsts = fmt.Sprintf("max-age=%d", int64(p.HSTSMaxAge.Seconds()))
+		if p.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if p.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+
+	return headers, hsts
+}
+
+// SecurityHeadersMiddleware cria o middleware que adiciona cabeçalhos de segurança às respostas.
+//
+// A política global é lida das variáveis SECURITY_HEADERS_*. Grupos de rotas declarados em
+// SECURITY_HEADERS_GROUPS (ex: "docs=/docs") podem sobrescrever os valores com
+// SECURITY_HEADERS_<GRUPO>_* — por exemplo, uma CSP mais permissiva para uma interface de documentação.
+// O Strict-Transport-Security só é enviado quando o cliente acessou via HTTPS.
+//
+// Retorna:
+//   - gin.HandlerFunc: Middleware de cabeçalhos de segurança.
+func SecurityHeadersMiddleware() gin.HandlerFunc {
+	global := loadSecurityHeadersPolicy("SECURITY_HEADERS", defaultSecurityHeadersPolicy())
+	globalHeaders, globalHSTS := global.headers()
+
+	groups := loadRouteGroups("SECURITY_HEADERS_GROUPS")
+	groupHeaders := make([]map[string]string, len(groups))
+	groupHSTS := make([]string, len(groups))
+	for i, group := range groups {
+		groupHeaders[i], groupHSTS[i] = loadSecurityHeadersPolicy(group.envPrefix("SECURITY_HEADERS"), global).headers()
+		logger.Debug("Cabeçalhos de segurança do grupo %s (%s): %v", group.name, group.prefix, groupHeaders[i])
+	}
+
+	return func(c *gin.Context) {
+		headers, hsts := globalHeaders, globalHSTS
+		if i := matchRouteGroup(groups, c.Request.URL.Path); i >= 0 {
+			headers, hsts = groupHeaders[i], groupHSTS[i]
+		}
+
+		h := c.Writer.Header()
+		for name, value := range headers {
+			h.Set(name, value)
+		}
+		if hsts != "" && netutil.IsTLS(c.Request) {
+			h.Set("Strict-Transport-Security", hsts)
+		}
+
+		c.Next()
+	}
+}
+
+// headerValue lê o valor de um cabeçalho de uma variável de ambiente, onde "off" desativa o cabeçalho.
+func headerValue(key, defaultValue string) string {
+	value := strings.TrimSpace(utils.GetEnv(key))
+	switch {
+	case value == "":
+		return defaultValue
+	case strings.EqualFold(value, "off"):
+		return ""
+	default:
+		return value
+	}
+}
@@ -3,20 +3,20 @@
 package server
 
 import (
+	"api/db"
 	"api/logger"
 	"api/utils"
+	"api/utils/netutil"
 	"context"
 	"fmt"
 	"net/http"
-	"strings"
 
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
 // StartServer inicia o servidor HTTP e retorna a instância do servidor.
 //
-// Configura e inicia o servidor HTTP, incluindo configuração de CORS e log.
+// Configura e inicia o servidor HTTP, incluindo configuração de CORS, cabeçalhos de segurança, compressão e log.
 // Caso a variável de ambiente PORT_HTTP não esteja definida, usa a porta padrão "80".
 // O modo do Gin é definido a partir da variável de ambiente GIN_MODE, ou "release" por padrão.
 //
@@ -48,26 +48,36 @@ func StartServer() (*http.Server, error) {
 	gin.SetMode(ginMode)
 	logger.Debug("Modo do Gin definido para %s", ginMode)
 
-	// Função para permitir apenas o domínio e subdomínios especificados
-	domainName := utils.GetEnv("DOMAIN_NAME")
-	allowOrigins := func(origin string) bool {
-		// Permite apenas origens que correspondem ao domínio configurado
-		return strings.HasSuffix(origin, "."+domainName) || origin == "https://"+domainName
+	// Aplica as migrações pendentes do banco de dados
+	if utils.GetEnvBool("DB_AUTO_MIGRATE", true) {
+		if err := db.Migrate(); err != nil {
+			logger.Error("Erro ao aplicar migrações do banco de dados: %v", err)
+		}
+	}
+
+	// Define os proxies confiáveis para resolução do IP do cliente
+	trustedProxies := utils.GetEnvList("TRUSTED_PROXIES")
+	if err := netutil.SetTrustedProxies(trustedProxies, utils.GetEnvBool("TRUST_CF_CONNECTING_IP", false)); err != nil {
+		logger.Error("Configuração TRUSTED_PROXIES inválida, nenhum proxy será considerado confiável: %v", err)
+		_ = netutil.SetTrustedProxies(nil, false)
 	}
+	logger.Debug("Proxies confiáveis: %v", netutil.TrustedProxies())
 
 	// Cria uma nova instância do Gin
 	r := gin.New()
 
-	// Aplica middlewares: log e CORS
+	// Informa ao Gin os mesmos proxies confiáveis, para que c.ClientIP() seja coerente com netutil.ClientIP
+	if err := r.SetTrustedProxies(netutil.TrustedProxies()); err != nil {
+		logger.Error("Erro ao configurar proxies confiáveis no Gin: %v", err)
+	}
+
+	// Aplica middlewares: log, CORS, cabeçalhos de segurança e compressão
 	r.Use(
 		logger.LoggerMiddleware(),
-		cors.New(cors.Config{
-			AllowMethods:     []string{"GET", "POST", "PUT", "DELETE"},
-			AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "*"},
-			ExposeHeaders:    []string{"Content-Length"},
-			AllowCredentials: true,
-			AllowOriginFunc:  allowOrigins,
-		}))
+		CORSMiddleware(),
+		SecurityHeadersMiddleware(),
+		CompressionMiddleware(),
+	)
 
 	// Define rotas padrão do servidor (health check, ping e favicon)
 	r.StaticFile("/favicon.ico", "./server/static/favicon.ico")
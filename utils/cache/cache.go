@@ -0,0 +1,134 @@
+// pwd: /app/utils/cache/cache.go
+
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// entry representa um valor armazenado e o seu horário de expiração.
+type entry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+// TTLCache é um cache em memória, seguro para uso concorrente, com expiração por item.
+//
+// É usado para evitar consultas repetidas ao banco de dados em verificações feitas a cada
+// requisição (revogação de tokens, permissões...). Itens expirados são descartados na leitura
+// e periodicamente durante a escrita.
+type TTLCache[K comparable, V any] struct {
+	mu     sync.RWMutex
+	items  map[K]entry[V]
+	ttl    time.Duration
+	writes int
+}
+
+// New cria um cache com o tempo de vida padrão informado.
+//
+// Parâmetros:
+//   - ttl (time.Duration): Tempo de vida padrão dos itens. Zero ou negativo desativa o cache.
+//
+// Retorna:
+//   - *TTLCache[K, V]: Cache criado.
+func New[K comparable, V any](ttl time.Duration) *TTLCache[K, V] {
+	return &TTLCache[K, V]{items: map[K]entry[V]{}, ttl: ttl}
+}
+
+// Get retorna o valor armazenado para a chave, se existir e não estiver expirado.
+//
+// Parâmetros:
+//   - key (K): Chave do item.
+//
+// Retorna:
+//   - V: Valor armazenado.
+//   - bool: true se o valor foi encontrado.
+func (c *TTLCache[K, V]) Get(key K) (V, bool) {
+	c.mu.RLock()
+	item, ok := c.items[key]
+	c.mu.RUnlock()
+
+	if !ok || time.Now().After(item.expiresAt) {
+		var zero V
+		return zero, false
+	}
+	return item.value, true
+}
+
+// Set armazena o valor com o tempo de vida padrão do cache.
+//
+// Parâmetros:
+//   - key (K): Chave do item.
+//   - value (V): Valor a armazenar.
+func (c *TTLCache[K, V]) Set(key K, value V) {
+	c.SetWithTTL(key, value, c.ttl)
+}
+
+// SetWithTTL armazena o valor com um tempo de vida específico.
+//
+// Parâmetros:
+//   - key (K): Chave do item.
+//   - value (V): Valor a armazenar.
+//   - ttl (time.Duration): Tempo de vida do item. Zero ou negativo não armazena o valor.
+func (c *TTLCache[K, V]) SetWithTTL(key K, value V, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.items[key] = entry[V]{value: value, expiresAt: time.Now().Add(ttl)}
+
+	// Remove itens expirados a cada 1000 escritas, para limitar o crescimento do mapa
+	c.writes++
+	if c.writes%1000 == 0 {
+		now := time.Now()
+		for k, item := range c.items {
+			if now.After(item.expiresAt) {
+				delete(c.items, k)
+			}
+		}
+	}
+}
+
+// Take retorna e remove o valor armazenado para a chave, de forma atômica.
+//
+// É usado para valores de uso único: em leituras simultâneas da mesma chave, apenas uma o recebe.
+//
+// Parâmetros:
+//   - key (K): Chave do item.
+//
+// Retorna:
+//   - V: Valor armazenado.
+//   - bool: true se o valor foi encontrado e não estava expirado.
+func (c *TTLCache[K, V]) Take(key K) (V, bool) {
+	c.mu.Lock()
+	item, ok := c.items[key]
+	delete(c.items, key)
+	c.mu.Unlock()
+
+	if !ok || time.Now().After(item.expiresAt) {
+		var zero V
+		return zero, false
+	}
+	return item.value, true
+}
+
+// Delete remove a chave do cache.
+//
+// Parâmetros:
+//   - key (K): Chave do item.
+func (c *TTLCache[K, V]) Delete(key K) {
+	c.mu.Lock()
+	delete(c.items, key)
+	c.mu.Unlock()
+}
+
+// Clear remove todos os itens do cache.
+func (c *TTLCache[K, V]) Clear() {
+	c.mu.Lock()
+	c.items = make(map[K]entry[V])
+	c.mu.Unlock()
+}
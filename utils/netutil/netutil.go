@@ -0,0 +1,220 @@
+// pwd: /app/utils/netutil/netutil.go
+
+package netutil
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	mu              sync.RWMutex
+	trustedNetworks []*net.IPNet
+	trustedCIDRs    []string
+	trustCloudflare bool
+)
+
+// SetTrustedProxies define a lista de proxies confiáveis (CIDRs ou IPs individuais).
+//
+// Somente requisições vindas desses endereços têm os cabeçalhos de encaminhamento
+// (X-Forwarded-For, X-Forwarded-Proto, CF-Connecting-IP...) considerados.
+//
+// Parâmetros:
+//   - cidrs ([]string): Lista de redes confiáveis (ex: "10.0.0.0/8", "172.18.0.2").
+//   - cloudflare (bool): Se true, o cabeçalho CF-Connecting-IP é aceito quando vier de um proxy confiável.
+//
+// Retorna:
+//   - error: Erro caso algum item da lista não seja um IP ou CIDR válido.
+func SetTrustedProxies(cidrs []string, cloudflare bool) error {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	normalized := make([]string, 0, len(cidrs))
+
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return fmt.Errorf("proxy confiável inválido: %q", cidr)
+			}
+			if ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("proxy confiável inválido: %q", cidr)
+		}
+		networks = append(networks, network)
+		normalized = append(normalized, network.String())
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	trustedNetworks = networks
+	trustedCIDRs = normalized
+	trustCloudflare = cloudflare
+	return nil
+}
+
+// TrustedProxies retorna a lista normalizada de proxies confiáveis, no formato aceito pelo Gin.
+//
+// Retorna:
+//   - []string: CIDRs confiáveis (nil se nenhum proxy for confiável).
+func TrustedProxies() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	if len(trustedCIDRs) == 0 {
+		return nil
+	}
+	return append([]string(nil), trustedCIDRs...)
+}
+
+// IsTrustedProxy verifica se o IP pertence a um dos proxies confiáveis.
+//
+// Parâmetros:
+//   - ip (string): Endereço IP a ser verificado.
+//
+// Retorna:
+//   - bool: true se o IP for de um proxy confiável.
+func IsTrustedProxy(ip string) bool {
+	parsed := net.ParseIP(strings.TrimSpace(ip))
+	if parsed == nil {
+		return false
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, network := range trustedNetworks {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFromTrustedProxy verifica se a conexão da requisição veio diretamente de um proxy confiável.
+//
+// Parâmetros:
+//   - r (*http.Request): Requisição HTTP.
+//
+// Retorna:
+//   - bool: true se o par remoto da conexão for um proxy confiável.
+func IsFromTrustedProxy(r *http.Request) bool {
+	return IsTrustedProxy(remoteIP(r))
+}
+
+// ClientIP retorna o IP real do cliente da requisição.
+//
+// Regras:
+//   - Se a conexão não vier de um proxy confiável, usa o IP da conexão e ignora os cabeçalhos.
+//   - Se vier de um proxy confiável, usa CF-Connecting-IP (quando habilitado) ou percorre o
+//     X-Forwarded-For da direita para a esquerda, retornando o primeiro IP não confiável.
+//
+// Parâmetros:
+//   - c (*gin.Context): Contexto da requisição.
+//
+// Retorna:
+//   - string: IP do cliente.
+func ClientIP(c *gin.Context) string {
+	return RequestClientIP(c.Request)
+}
+
+// RequestClientIP aplica as mesmas regras de ClientIP a uma *http.Request.
+//
+// Parâmetros:
+//   - r (*http.Request): Requisição HTTP.
+//
+// Retorna:
+//   - string: IP do cliente.
+func RequestClientIP(r *http.Request) string {
+	remote := remoteIP(r)
+	if !IsTrustedProxy(remote) {
+		return remote
+	}
+
+	mu.RLock()
+	cloudflare := trustCloudflare
+	mu.RUnlock()
+
+	if cloudflare {
+		if cfIP := strings.TrimSpace(r.Header.Get("CF-Connecting-IP")); net.ParseIP(cfIP) != nil {
+			return cfIP
+		}
+	}
+
+	hops := forwardedHops(r.Header.Values("X-Forwarded-For"))
+	for i := len(hops) - 1; i >= 0; i-- {
+		if !IsTrustedProxy(hops[i]) {
+			return hops[i]
+		}
+	}
+
+	// Todos os saltos são confiáveis: o cliente é o mais à esquerda
+	if len(hops) > 0 {
+		return hops[0]
+	}
+	return remote
+}
+
+// forwardedHops extrai os IPs válidos de um ou mais cabeçalhos X-Forwarded-For, na ordem em que aparecem.
+func forwardedHops(values []string) []string {
+	var hops []string
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			part = strings.TrimSpace(part)
+			if net.ParseIP(part) == nil {
+				// Um salto inválido interrompe a cadeia: nada à esquerda dele é confiável
+				hops = hops[:0]
+				continue
+			}
+			hops = append(hops, part)
+		}
+	}
+	return hops
+}
+
+// remoteIP retorna o IP do par remoto da conexão.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(strings.TrimSpace(r.RemoteAddr))
+	if err != nil {
+		return strings.TrimSpace(r.RemoteAddr)
+	}
+	return host
+}
+
+// IsTLS verifica se a requisição do cliente foi feita via HTTPS.
+//
+// Considera a conexão TLS direta ou, quando a requisição vem de um proxy confiável
+// que termina o TLS (ex: Traefik), o cabeçalho X-Forwarded-Proto.
+//
+// Parâmetros:
+//   - r (*http.Request): Requisição HTTP.
+//
+// Retorna:
+//   - bool: true se o cliente usou HTTPS.
+func IsTLS(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+	if !IsFromTrustedProxy(r) {
+		return false
+	}
+
+	proto := r.Header.Get("X-Forwarded-Proto")
+	if i := strings.LastIndex(proto, ","); i >= 0 {
+		proto = proto[i+1:]
+	}
+	return strings.EqualFold(strings.TrimSpace(proto), "https")
+}
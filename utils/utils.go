@@ -8,6 +8,8 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -81,3 +83,92 @@ func GracefulShutdown(server *http.Server) {
 	// Emite um log informando que o servidor foi encerrado com sucesso
 	logger.Info("Servidor encerrado com sucesso")
 }
+
+// GetEnvInt retorna o valor inteiro de uma variável de ambiente.
+//
+// Caso a variável não exista ou não seja um inteiro válido, retorna o valor padrão.
+//
+// Parâmetros:
+//   - key (string): Nome da variável de ambiente.
+//   - defaultValue (int): Valor padrão.
+//
+// Retorna:
+//   - int: Valor da variável de ambiente ou o valor padrão.
+func GetEnvInt(key string, defaultValue int) int {
+	value := GetEnv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	parsed, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		logger.Warn("Valor inválido para %s: %q. Usando valor padrão: %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+// GetEnvDuration retorna o valor de uma variável de ambiente como time.Duration (ex: "15m", "1h").
+//
+// Caso a variável não exista ou não seja uma duração válida, retorna o valor padrão.
+//
+// Parâmetros:
+//   - key (string): Nome da variável de ambiente.
+//   - defaultValue (time.Duration): Valor padrão.
+//
+// Retorna:
+//   - time.Duration: Valor da variável de ambiente ou o valor padrão.
+func GetEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := GetEnv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	parsed, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil {
+		logger.Warn("Valor inválido para %s: %q. Usando valor padrão: %s", key, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+// GetEnvBool retorna o valor booleano de uma variável de ambiente ("true", "1", "false", "0"...).
+//
+// Parâmetros:
+//   - key (string): Nome da variável de ambiente.
+//   - defaultValue (bool): Valor padrão.
+//
+// Retorna:
+//   - bool: Valor da variável de ambiente ou o valor padrão.
+func GetEnvBool(key string, defaultValue bool) bool {
+	value := GetEnv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	parsed, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		logger.Warn("Valor inválido para %s: %q. Usando valor padrão: %t", key, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+// GetEnvList retorna o valor de uma variável de ambiente separado por vírgulas.
+//
+// Itens vazios são descartados e os espaços nas extremidades de cada item são removidos.
+//
+// Parâmetros:
+//   - key (string): Nome da variável de ambiente.
+//
+// Retorna:
+//   - []string: Lista de valores (vazia se a variável não estiver definida).
+func GetEnvList(key string) []string {
+	var items []string
+	for _, item := range strings.Split(GetEnv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}